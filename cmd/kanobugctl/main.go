@@ -0,0 +1,813 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/dlq"
+	"github.com/anzellai/kanobug/internal/export"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/search"
+	"github.com/anzellai/kanobug/internal/secrets"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+const usage = `kanobugctl administers a kanobug deployment using the same environment as
+the Lambda functions (REGION, TABLE_NAME, tracker credentials), working on the
+team named by DEFAULT_TEAM_ID.
+
+Usage:
+  kanobugctl list [-deleted] [filters]           list bugs, or only the deleted ones
+  kanobugctl restore <bug-id>                    undo /kanobug delete
+  kanobugctl export [-format csv|json] [-o file | -s3 [-ttl 1h]] [filters]
+  kanobugctl import [-format csv|json] [-keep 0] [-jira [-batch 20] [-pause 10s]] [-dry-run] <file|->
+                                                 store historical bugs, optionally filing them to Jira
+  kanobugctl fixed -product p -since 2006-01-02 [-until 2006-01-02] [-format markdown|slack]
+                                                 list the bugs fixed in the window as release notes
+  kanobugctl reindex [-dry-run]                  index the bugs of every team into SEARCH_ENDPOINT
+  kanobugctl retention [-dry-run]                set the expiry of every stored bug as BUG_RETENTION says
+  kanobugctl replay [-older 10m] [-dry-run] [filters]
+                                                 re-file bugs whose tracker sync failed
+  kanobugctl dlq list [-limit n]                 list failed notifier invocations on DLQ_URL
+  kanobugctl dlq replay [-limit n] [-dry-run] [message-id ...]
+                                                 re-invoke NOTIFIER_FUNCTION with them
+  kanobugctl products list
+  kanobugctl products add [-trackers jira,webhook] <value> <label>
+  kanobugctl products remove <value>
+  kanobugctl rotate [-value secret] <secret-id>  replace a Secrets Manager token
+  kanobugctl audit (-subject id | -actor user) [-since t] [-until t] [-limit n] [-json]
+                                                 list the audit trail of a bug, config entry or user
+  kanobugctl stats [-since 2006-01-02] [-until 2006-01-02] [-rebuild]
+                                                 print the daily counters, or recount them from the bugs
+  kanobugctl migrate [-bugs t] [-config t] [-audit t] [-dry-run]
+                                                 copy the legacy per-kind tables into TABLE_NAME
+  kanobugctl migrate-team [-dry-run] <team-id>   move single team data into the partitions of a team
+  kanobugctl test-dialog -url <interactive-url> [-product p] [-severity s] [-summary text]
+                                                 submit a test dialog as Slack would
+  kanobugctl smoke -url <api-url> [-key k] [-product p] [-keep]
+                                                 file a synthetic bug to JIRA_SMOKE_PROJECT through the
+                                                 API, check it was stored and filed, then delete it
+
+Filters: -user, -product, -status, -severity, -tag, -since, -until (RFC3339), -limit
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+	var err error
+	switch command {
+	case "list":
+		err = list(args)
+	case "restore":
+		err = restore(args)
+	case "export":
+		err = exportBugs(args)
+	case "import":
+		err = importBugs(args)
+	case "fixed":
+		err = fixed(args)
+	case "reindex":
+		err = reindex(args)
+	case "retention":
+		err = retention(args)
+	case "replay":
+		err = replay(args)
+	case "dlq":
+		err = deadLetters(args)
+	case "products":
+		err = products(args)
+	case "rotate":
+		err = rotate(args)
+	case "audit":
+		err = auditTrail(args)
+	case "stats":
+		err = stats(args)
+	case "migrate":
+		err = migrate(args)
+	case "migrate-team":
+		err = migrateTeam(args)
+	case "test-dialog":
+		err = testDialog(args)
+	case "smoke":
+		err = smoke(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("kanobugctl %s - error: %v", command, err)
+	}
+}
+
+// filterFlags register the shared bug filters on fs, the returned func
+// builds the store.Filter once fs is parsed
+func filterFlags(fs *flag.FlagSet) func() (store.Filter, error) {
+	user := fs.String("user", "", "reporter user id")
+	product := fs.String("product", "", "product value")
+	status := fs.String("status", "", "bug status")
+	severity := fs.String("severity", "", "bug severity")
+	tag := fs.String("tag", "", "bug tag")
+	since := fs.String("since", "", "created at or after (RFC3339)")
+	until := fs.String("until", "", "created at or before (RFC3339)")
+	limit := fs.Int64("limit", 0, "maximum bugs, 0 for all")
+	return func() (filter store.Filter, err error) {
+		filter = store.Filter{
+			UserID:   *user,
+			Product:  *product,
+			Status:   *status,
+			Severity: *severity,
+			Tag:      *tag,
+			Limit:    *limit,
+		}
+		if len(*since) > 0 {
+			if filter.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+				return
+			}
+		}
+		if len(*until) > 0 {
+			filter.Until, err = time.Parse(time.RFC3339, *until)
+		}
+		return
+	}
+}
+
+// bugs return every bug matching filter, or the first page when a limit is set
+func bugs(filter store.Filter) ([]store.Bug, error) {
+	if filter.Limit > 0 {
+		page, _, err := store.ListBugs(filter)
+		return page, err
+	}
+	return store.AllBugs(filter)
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	deleted := fs.Bool("deleted", false, "list only deleted bugs")
+	filter := filterFlags(fs)
+	fs.Parse(args)
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	f.Deleted = *deleted
+	found, err := bugs(f)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tPRODUCT\tSEVERITY\tSTATUS\tISSUE\tSUMMARY")
+	for _, bug := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			bug.ID, bug.CreatedAt.Format(time.RFC3339), bug.Product, bug.Severity, bug.Status, bug.IssueKey, bug.Summary)
+	}
+	return w.Flush()
+}
+
+// restore bring back a deleted bug
+func restore(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: restore <bug-id>")
+	}
+	bug, err := store.RestoreBug(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s\t%s\n", bug.ID, bug.Summary)
+	return store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   actor(),
+		Action:  store.AuditRestore,
+		After:   store.Audited(bug),
+	})
+}
+
+func exportBugs(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", export.FormatCSV, "csv or json")
+	output := fs.String("o", "", "output file, stdout when empty")
+	upload := fs.Bool("s3", false, "upload to EXPORT_BUCKET and print a presigned link")
+	ttl := fs.Duration("ttl", time.Hour, "presigned link lifetime")
+	filter := filterFlags(fs)
+	fs.Parse(args)
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	found, err := bugs(f)
+	if err != nil {
+		return err
+	}
+	if *upload {
+		link, err := export.Upload(*format, found, *ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Println(link)
+		return nil
+	}
+	var w io.Writer = os.Stdout
+	if len(*output) > 0 {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return export.Write(w, *format, found)
+}
+
+// importBugs store the bugs of a csv or json export from another system,
+// filing them to Jira in paced batches with -jira
+func importBugs(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", export.FormatCSV, "csv or json")
+	keep := fs.Duration("keep", 0, "how long the imported bugs are kept, 0 as BUG_RETENTION says")
+	jira := fs.Bool("jira", false, "file the bugs without a Jira issue to Jira")
+	batch := fs.Int("batch", 20, "Jira issues created before each pause")
+	pause := fs.Duration("pause", 10*time.Second, "pause between batches of Jira issues")
+	dryRun := fs.Bool("dry-run", false, "validate the bugs without storing them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-format csv|json] [-keep 0] [-jira [-batch 20] [-pause 10s]] [-dry-run] <file|->")
+	}
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	found, err := export.Read(r, *format)
+	if err != nil {
+		return err
+	}
+	result := export.Import(found, export.Options{Keep: *keep, Jira: *jira, Batch: *batch, Pause: *pause, DryRun: *dryRun})
+	for _, e := range result.Errors {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	fmt.Printf("imported %d, skipped %d already stored, filed %d to Jira, %d error(s)\n", result.Imported, result.Skipped, result.Filed, len(result.Errors))
+	if *dryRun || result.Imported == 0 {
+		return nil
+	}
+	return store.Audit(store.AuditEntry{
+		Subject: "import",
+		Actor:   actor(),
+		Action:  store.AuditAdmin,
+		Detail:  fmt.Sprintf("import %d bugs from %s", result.Imported, fs.Arg(0)),
+	})
+}
+
+// fixed print the bugs of a product fixed in a window as release notes
+func fixed(args []string) error {
+	fs := flag.NewFlagSet("fixed", flag.ExitOnError)
+	product := fs.String("product", "", "product value")
+	since := fs.String("since", "", "first day of the window, 2006-01-02")
+	until := fs.String("until", "", "day after the window, today by default")
+	format := fs.String("format", export.FormatMarkdown, "markdown or slack")
+	fs.Parse(args)
+	if len(*product) == 0 || len(*since) == 0 {
+		return fmt.Errorf("usage: fixed -product p -since 2006-01-02 [-until 2006-01-02] [-format markdown|slack]")
+	}
+	from, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	if len(*until) > 0 {
+		if to, err = time.Parse("2006-01-02", *until); err != nil {
+			return err
+		}
+	}
+	bugs, err := export.Fixed(*product, from, to)
+	if err != nil {
+		return err
+	}
+	if len(bugs) > 0 {
+		fmt.Println(export.ReleaseNotes(bugs, *format))
+	}
+	return nil
+}
+
+// reindex create the search index and index the bugs of every team, e.g.
+// after the first deploy, since the stream only indexes bugs written after
+func reindex(args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only count the bugs")
+	fs.Parse(args)
+	if !search.Enabled() {
+		return fmt.Errorf("SEARCH_ENDPOINT is not set")
+	}
+	bugs, err := store.ScanBugs()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf("%d bugs to index\n", len(bugs))
+		return nil
+	}
+	if err = search.Setup(); err != nil {
+		return err
+	}
+	failed := 0
+	for _, bug := range bugs {
+		if search.Index(bug) != nil {
+			failed++
+		}
+	}
+	fmt.Printf("indexed %d bugs, %d failed\n", len(bugs)-failed, failed)
+	return nil
+}
+
+// retention apply BUG_RETENTION to the bugs stored before it, or before bugs
+// stopped expiring after a week
+func retention(args []string) error {
+	fs := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only count the bugs to change")
+	fs.Parse(args)
+	changed, err := store.ApplyRetention(*dryRun)
+	if err != nil {
+		return err
+	}
+	kept := "kept until purged"
+	if r := store.Retention(); r > 0 {
+		kept = "kept " + r.String() + " after their last update"
+	}
+	fmt.Printf("%d bugs changed, bugs are %s\n", changed, kept)
+	return nil
+}
+
+// replay re-file bugs still new after older, which means every tracker
+// failed when they were submitted
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	older := fs.Duration("older", 10*time.Minute, "only bugs submitted at least this long ago")
+	dryRun := fs.Bool("dry-run", false, "print the bugs without filing them")
+	filter := filterFlags(fs)
+	fs.Parse(args)
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	f.Status = store.StatusNew
+	if cutoff := time.Now().Add(-*older); f.Until.IsZero() || f.Until.After(cutoff) {
+		f.Until = cutoff
+	}
+	found, err := bugs(f)
+	if err != nil {
+		return err
+	}
+	for _, bug := range found {
+		if *dryRun {
+			fmt.Printf("%s\t%s\t%s\n", bug.ID, bug.Product, bug.Summary)
+			continue
+		}
+		issues := pipeline.File(bug, "")
+		fmt.Printf("%s\tfiled %d issue(s)\n", bug.ID, len(issues))
+		for _, issue := range issues {
+			fmt.Printf("\t%s\n", issue.Text())
+		}
+	}
+	return nil
+}
+
+// deadLetters list the failed notifier invocations parked on the dead-letter
+// queue, or replay them, every one or those given by message ID
+func deadLetters(args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "replay") {
+		return fmt.Errorf("usage: dlq list|replay [-limit n] [-dry-run] [message-id ...]")
+	}
+	fs := flag.NewFlagSet("dlq "+args[0], flag.ExitOnError)
+	limit := fs.Int("limit", 100, "maximum number of messages")
+	dryRun := fs.Bool("dry-run", false, "print the messages without replaying them")
+	fs.Parse(args[1:])
+	messages, err := dlq.Receive(*limit)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSENT\tEVENT\tRECEIVES\tERROR")
+		for _, m := range messages {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", m.ID, m.SentAt.Format(time.RFC3339), m.DetailType(), m.Receives, m.Error)
+		}
+		return w.Flush()
+	case "replay":
+		wanted := map[string]bool{}
+		for _, id := range fs.Args() {
+			wanted[id] = true
+		}
+		replayed := 0
+		for _, m := range messages {
+			if len(wanted) > 0 && !wanted[m.ID] {
+				continue
+			}
+			if *dryRun {
+				fmt.Printf("%s\t%s\t%s\n", m.ID, m.DetailType(), m.Payload)
+				continue
+			}
+			if err = dlq.Replay(m); err != nil {
+				return err
+			}
+			replayed++
+		}
+		fmt.Printf("replayed %d message(s)\n", replayed)
+	}
+	return nil
+}
+
+func products(args []string) error {
+	if !store.ConfigEnabled() {
+		return fmt.Errorf("TABLE_NAME is not set")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VALUE\tLABEL\tTRACKERS")
+		for _, p := range catalog.ProductOptions() {
+			trackers := "-"
+			if configured, err := store.GetProduct(p.Value); err == nil && len(configured.Trackers) > 0 {
+				trackers = strings.Join(configured.Trackers, ",")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Value, p.Label, trackers)
+		}
+		return w.Flush()
+	case "add":
+		fs := flag.NewFlagSet("products add", flag.ExitOnError)
+		trackers := fs.String("trackers", "", "comma separated trackers overriding TRACKER_ROUTES")
+		fs.Parse(args[1:])
+		if fs.NArg() < 2 {
+			return fmt.Errorf("usage: products add [-trackers t1,t2] <value> <label>")
+		}
+		product := store.Product{
+			Value: fs.Arg(0),
+			Label: strings.Join(fs.Args()[1:], " "),
+		}
+		for _, t := range strings.Split(*trackers, ",") {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				product.Trackers = append(product.Trackers, t)
+			}
+		}
+		if err := catalog.SeedProducts(); err != nil {
+			return err
+		}
+		if err := store.PutProduct(product); err != nil {
+			return err
+		}
+		return store.Audit(store.AuditEntry{
+			Subject: store.KindProduct + "/" + product.Value,
+			Actor:   actor(),
+			Action:  store.AuditAdmin,
+			Detail:  "product add",
+			After:   product,
+		})
+	case "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: products remove <value>")
+		}
+		if err := catalog.SeedProducts(); err != nil {
+			return err
+		}
+		before, getErr := store.GetProduct(args[1])
+		if err := store.DeleteProduct(args[1]); err != nil {
+			return err
+		}
+		entry := store.AuditEntry{
+			Subject: store.KindProduct + "/" + args[1],
+			Actor:   actor(),
+			Action:  store.AuditAdmin,
+			Detail:  "product remove",
+		}
+		if getErr == nil {
+			entry.Before = before
+		}
+		return store.Audit(entry)
+	}
+	return fmt.Errorf("unknown products subcommand: %s", args[0])
+}
+
+// rotate replace secret id with value, generating a random one for shared
+// signing secrets when none is given
+func rotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	value := fs.String("value", "", "new secret value, random when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: rotate [-value secret] <secret-id>")
+	}
+	secret := *value
+	if len(secret) == 0 {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		secret = hex.EncodeToString(b)
+	}
+	if err := secrets.Put(fs.Arg(0), secret); err != nil {
+		return err
+	}
+	if err := store.Audit(store.AuditEntry{
+		Subject: "secret/" + fs.Arg(0),
+		Actor:   actor(),
+		Action:  store.AuditAdmin,
+		Detail:  "rotate",
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("rotated %s\n", fs.Arg(0))
+	if len(*value) == 0 {
+		fmt.Println(secret)
+	}
+	return nil
+}
+
+// auditTrail print the audit entries of a subject or actor, newest first
+func auditTrail(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	subject := fs.String("subject", "", "bug id or config entry such as role/U123")
+	by := fs.String("actor", "", "user id of the actor")
+	since := fs.String("since", "", "at or after (RFC3339)")
+	until := fs.String("until", "", "at or before (RFC3339)")
+	limit := fs.Int64("limit", 0, "maximum entries, 0 for all")
+	asJSON := fs.Bool("json", false, "print entries with before and after as JSON lines")
+	fs.Parse(args)
+	filter := store.AuditFilter{Subject: *subject, Actor: *by, Limit: *limit}
+	var err error
+	if len(*since) > 0 {
+		if filter.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+			return err
+		}
+	}
+	if len(*until) > 0 {
+		if filter.Until, err = time.Parse(time.RFC3339, *until); err != nil {
+			return err
+		}
+	}
+	var entries []store.AuditEntry
+	for {
+		page, cursor, err := store.ListAudit(filter)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, page...)
+		if len(cursor) == 0 || *limit > 0 {
+			break
+		}
+		filter.Cursor = cursor
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err = encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tACTOR\tACTION\tSUBJECT\tDETAIL")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			entry.At.Format(time.RFC3339), entry.Actor, entry.Action, entry.Subject, entry.Detail)
+	}
+	return w.Flush()
+}
+
+// stats print the daily counters per product, recounting them from the
+// stored bugs first with -rebuild
+func stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.String("since", time.Now().AddDate(0, 0, -6).Format("2006-01-02"), "first day (UTC)")
+	until := fs.String("until", time.Now().Format("2006-01-02"), "last day (UTC)")
+	rebuild := fs.Bool("rebuild", false, "recount every counter from the stored bugs")
+	fs.Parse(args)
+	if *rebuild {
+		counters, err := store.RebuildCounters()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rebuilt %d counter(s)\n", len(counters))
+	}
+	first, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		return err
+	}
+	last, err := time.Parse("2006-01-02", *until)
+	if err != nil {
+		return err
+	}
+	counters, err := store.ListCounters(first, last)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DAY\tPRODUCT\tSUBMITTED\tSYNCED\tTRIAGED\tRESOLVED")
+	for _, c := range counters {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", c.Day, c.Product, c.Submitted, c.Synced, c.Triaged, c.Resolved)
+	}
+	return w.Flush()
+}
+
+// migrate copy the bug, config and audit tables of the multi table design
+// into the single table
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var legacy store.Legacy
+	fs.StringVar(&legacy.BugTable, "bugs", "", "legacy bug table, skipped when empty")
+	fs.StringVar(&legacy.ConfigTable, "config", "", "legacy config table, skipped when empty")
+	fs.StringVar(&legacy.AuditTable, "audit", "", "legacy audit table, skipped when empty")
+	dryRun := fs.Bool("dry-run", false, "count the items without copying them")
+	fs.Parse(args)
+	copied, err := store.MigrateLegacy(legacy, *dryRun)
+	for _, kind := range []string{"bugs", "config", "audit"} {
+		fmt.Printf("%s\t%d\n", kind, copied[kind])
+	}
+	return err
+}
+
+// migrateTeam move the data of the single team layout into a team
+func migrateTeam(args []string) error {
+	fs := flag.NewFlagSet("migrate-team", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "count the items without moving them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("a team id is required")
+	}
+	moved, err := store.MigrateTeam(fs.Arg(0), *dryRun)
+	for _, kind := range []string{"bugs", "comments", "config", "audit"} {
+		fmt.Printf("%s\t%d\n", kind, moved[kind])
+	}
+	return err
+}
+
+// actor return the audit actor of kanobugctl changes, the local user
+func actor() string {
+	return "kanobugctl:" + os.Getenv("USER")
+}
+
+// testDialog post a report-bug submission to the interactive component
+// endpoint, exercising verification, storage and filing end to end
+func testDialog(args []string) error {
+	fs := flag.NewFlagSet("test-dialog", flag.ExitOnError)
+	endpoint := fs.String("url", "", "KanobugInteractiveComponent URL")
+	product := fs.String("product", catalog.Products[0].Value, "product value")
+	severity := fs.String("severity", store.SeverityTrivial, "severity")
+	summary := fs.String("summary", "kanobugctl test dialog", "summary")
+	responseURL := fs.String("response-url", "", "response_url to receive the issue links")
+	fs.Parse(args)
+	if len(*endpoint) == 0 {
+		return fmt.Errorf("-url is required")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"type":         "dialog_submission",
+		"callback_id":  "report-bug",
+		"token":        config.Get().SlackVerificationToken,
+		"action_ts":    fmt.Sprintf("%d", time.Now().Unix()),
+		"response_url": *responseURL,
+		"user":         map[string]string{"id": "kanobugctl", "name": "kanobugctl"},
+		"submission": map[string]string{
+			"summary":            *summary,
+			"product":            *product,
+			"severity":           *severity,
+			"security":           "no",
+			"customer_impacting": "no",
+			"details":            "Sent by kanobugctl test-dialog",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.PostForm(*endpoint, url.Values{"payload": {string(payload)}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	fmt.Printf("%s %s\n", resp.Status, body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// smoke submit a synthetic bug to POST /bugs of the deployed API, check it
+// was stored and filed to JIRA_SMOKE_PROJECT, then delete the issue and purge
+// the bug, failing when any step does so it can gate a deploy
+func smoke(args []string) (err error) {
+	fs := flag.NewFlagSet("smoke", flag.ExitOnError)
+	endpoint := fs.String("url", os.Getenv("KANOBUG_API_URL"), "KanobugAPI base URL, e.g. https://<id>.execute-api.<region>.amazonaws.com/<stage>")
+	key := fs.String("key", os.Getenv("KANOBUG_API_KEY"), "API Gateway API key")
+	product := fs.String("product", catalog.Products[0].Value, "product value")
+	keep := fs.Bool("keep", false, "leave the bug and its issue for inspection")
+	fs.Parse(args)
+	jira := tracker.NewJira()
+	switch {
+	case len(*endpoint) == 0:
+		return fmt.Errorf("-url is required")
+	case len(jira.SmokeProject) == 0:
+		return fmt.Errorf("JIRA_SMOKE_PROJECT is not set")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"user_id":  "kanobugctl",
+		"reporter": "kanobugctl smoke",
+		"summary":  "kanobugctl smoke " + time.Now().UTC().Format(time.RFC3339),
+		"product":  *product,
+		"severity": store.SeverityTrivial,
+		"details":  "Synthetic bug of kanobugctl smoke, deleted once checked",
+		"smoke":    true,
+	})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(*endpoint, "/")+"/bugs", bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", *key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("submit: unexpected status: %s %s", resp.Status, body)
+	}
+	var submitted store.Bug
+	if err = json.Unmarshal(body, &submitted); err != nil {
+		return
+	}
+	fmt.Printf("submitted\t%s\n", submitted.ID)
+
+	var issue tracker.Issue
+	for _, filed := range submitted.Issues {
+		if filed.Tracker == jira.Name() {
+			issue = filed
+		}
+	}
+	defer func() {
+		if *keep {
+			return
+		}
+		if len(issue.Key) > 0 {
+			if deleteErr := jira.Delete(issue); deleteErr != nil && err == nil {
+				err = fmt.Errorf("delete %s: %v", issue.Key, deleteErr)
+			}
+		}
+		if purgeErr := store.PurgeBug(submitted.ID); purgeErr != nil && err == nil {
+			err = fmt.Errorf("purge %s: %v", submitted.ID, purgeErr)
+		}
+		fmt.Printf("cleaned up\t%s\t%s\n", submitted.ID, issue.Key)
+	}()
+
+	stored, err := store.GetBug(submitted.ID)
+	switch {
+	case err != nil:
+		return fmt.Errorf("stored: %v", err)
+	case !stored.Smoke:
+		return fmt.Errorf("stored: %s is not marked smoke", stored.ID)
+	case len(stored.Issues) == 0:
+		return fmt.Errorf("stored: %s has no issues recorded", stored.ID)
+	}
+	fmt.Printf("stored\t%s\t%s\n", stored.ID, stored.Status)
+
+	if len(issue.Key) == 0 {
+		return fmt.Errorf("filed: no Jira issue, see the KanobugAPI logs")
+	}
+	current, err := jira.Issue(issue.Key)
+	switch {
+	case err != nil:
+		return fmt.Errorf("filed: %s: %v", issue.Key, err)
+	case !strings.HasPrefix(current.Key, jira.SmokeProject+"-"):
+		return fmt.Errorf("filed: %s is not in %s", current.Key, jira.SmokeProject)
+	}
+	fmt.Printf("filed\t%s\t%s\n", current.Key, issue.URL)
+	return nil
+}
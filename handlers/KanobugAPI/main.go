@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/analytics"
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/export"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/search"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+const handler = "KanobugAPI"
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// Submission is the POST /bugs body
+type Submission struct {
+	Reporter          string   `json:"reporter"`
+	UserID            string   `json:"user_id"`
+	Summary           string   `json:"summary"`
+	Product           string   `json:"product"`
+	Severity          string   `json:"severity"`
+	Security          bool     `json:"security"`
+	CustomerImpacting bool     `json:"customer_impacting"`
+	Details           string   `json:"details"`
+	Tags              []string `json:"tags"`
+	// Smoke marks a synthetic bug of kanobugctl smoke
+	Smoke bool `json:"smoke"`
+}
+
+// StatusChange is the PATCH /bugs/{id}/status body, Actor names who made
+// the change in the audit trail
+type StatusChange struct {
+	Status     string `json:"status"`
+	Resolution string `json:"resolution"`
+	Actor      string `json:"actor"`
+}
+
+// ToBug transform the submission to Bug
+func (submission Submission) ToBug() store.Bug {
+	details := submission.Details
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	severity := submission.Severity
+	if len(severity) == 0 {
+		severity = catalog.DefaultSeverity
+	}
+	now := time.Now()
+	return store.Bug{
+		Source:            "api",
+		UserID:            submission.UserID,
+		UserName:          submission.Reporter,
+		Summary:           submission.Summary,
+		Product:           submission.Product,
+		Severity:          severity,
+		Security:          submission.Security,
+		CustomerImpacting: submission.CustomerImpacting,
+		Details:           details,
+		Tags:              store.NormalizeTags(submission.Tags),
+		Smoke:             submission.Smoke,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// API keys are enforced by API Gateway before it is reached
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %s %s", handler, r.HTTPMethod, r.Path)
+	switch r.HTTPMethod + " " + r.Resource {
+	case "GET /bugs":
+		return listBugs(r), nil
+	case "POST /bugs":
+		return createBug(r), nil
+	case "POST /bugs/import":
+		return importBugs(r), nil
+	case "GET /bugs/search":
+		return searchBugs(r), nil
+	case "GET /bugs/{id}":
+		bug, err := store.GetBug(r.PathParameters["id"])
+		if err != nil {
+			return storeError(err), nil
+		}
+		return respond(200, bug), nil
+	case "PATCH /bugs/{id}/status":
+		return updateStatus(r), nil
+	case "GET /bugs/{id}/history":
+		return bugHistory(r), nil
+	case "GET /bugs/{id}/audit":
+		return listAudit(r, r.PathParameters["id"]), nil
+	case "GET /audit":
+		return listAudit(r, r.QueryStringParameters["subject"]), nil
+	case "GET /stats":
+		return stats(r), nil
+	}
+	return failure(404, "not found"), nil
+}
+
+func listBugs(r ProxyRequest) Response {
+	q := r.QueryStringParameters
+	limit, _ := strconv.ParseInt(q["limit"], 10, 64)
+	if len(q["status"]) > 0 && !store.ValidStatus(q["status"]) {
+		return failure(400, "invalid status")
+	}
+	bugs, cursor, err := store.ListBugs(store.Filter{
+		UserID:  q["user_id"],
+		Product: q["product"],
+		Status:  q["status"],
+		Tag:     q["tag"],
+		Limit:   limit,
+		Cursor:  q["cursor"],
+	})
+	if err != nil {
+		return storeError(err)
+	}
+	if bugs == nil {
+		bugs = []store.Bug{}
+	}
+	return respond(200, map[string]interface{}{"bugs": bugs, "next_cursor": cursor})
+}
+
+func createBug(r ProxyRequest) Response {
+	submission := Submission{}
+	if err := json.Unmarshal([]byte(r.Body), &submission); err != nil {
+		return failure(400, "invalid json")
+	}
+	switch {
+	case len(strings.TrimSpace(submission.Summary)) == 0:
+		return failure(422, "summary is required")
+	case len(submission.UserID) == 0:
+		return failure(422, "user_id is required")
+	case !knownProduct(submission.Product):
+		return failure(422, "unknown product")
+	case submission.Smoke && len(tracker.NewJira().SmokeProject) == 0:
+		return failure(422, "smoke tests need JIRA_SMOKE_PROJECT")
+	}
+	bug := submission.ToBug()
+	if err := pipeline.Store(&bug); err != nil {
+		return storeError(err)
+	}
+	bug.Issues = pipeline.File(bug, "")
+	return respond(201, bug)
+}
+
+// maxSearch is the most bugs a search returns
+const maxSearch = 50
+
+// searchBugs return the bugs matching ?q= best, most relevant first, each
+// with its score, filtered by product and status
+func searchBugs(r ProxyRequest) Response {
+	q := r.QueryStringParameters
+	switch {
+	case !search.Enabled():
+		return failure(501, "search is not set up")
+	case len(strings.TrimSpace(q["q"])) == 0:
+		return failure(400, "q is required")
+	case len(q["status"]) > 0 && !store.ValidStatus(q["status"]):
+		return failure(400, "invalid status")
+	}
+	limit, _ := strconv.Atoi(q["limit"])
+	if limit <= 0 || limit > maxSearch {
+		limit = maxSearch
+	}
+	hits, err := search.Search(store.HomeTeam(), q["q"], search.Filter{Product: q["product"], Status: q["status"]}, limit)
+	if err != nil {
+		return failure(502, "search failed")
+	}
+	type result struct {
+		store.Bug
+		Score float64 `json:"score"`
+	}
+	results := []result{}
+	for _, hit := range hits {
+		// deleted since it was indexed
+		bug, err := store.GetBug(hit.ID)
+		if err != nil {
+			continue
+		}
+		results = append(results, result{bug, hit.Score})
+	}
+	return respond(200, map[string]interface{}{"bugs": results})
+}
+
+// maxFiledImport is the most bugs an import request files to Jira, the rest
+// of a larger import would outlast API Gateway's 29 second timeout
+const maxFiledImport = 25
+
+// importBugs store the bugs of a JSON array or, with ?format=csv, a CSV
+// export, filing them to Jira with ?jira=true, and return the counts
+func importBugs(r ProxyRequest) Response {
+	q := r.QueryStringParameters
+	format := q["format"]
+	if len(format) == 0 {
+		format = export.FormatJSON
+	}
+	bugs, err := export.Read(strings.NewReader(r.Body), format)
+	if err != nil {
+		return failure(400, "invalid "+format+": "+err.Error())
+	}
+	options := export.Options{Keep: 365 * 24 * time.Hour, Jira: q["jira"] == "true", DryRun: q["dry_run"] == "true"}
+	if len(q["keep"]) > 0 {
+		if options.Keep, err = time.ParseDuration(q["keep"]); err != nil || options.Keep <= 0 {
+			return failure(400, "invalid keep")
+		}
+	}
+	if options.Jira && len(bugs) > maxFiledImport {
+		return failure(422, fmt.Sprintf("at most %d bugs per request with jira=true", maxFiledImport))
+	}
+	for _, bug := range bugs {
+		if len(bug.Product) > 0 && !knownProduct(bug.Product) {
+			return failure(422, "unknown product "+bug.Product)
+		}
+	}
+	result := export.Import(bugs, options)
+	if !options.DryRun && result.Imported > 0 {
+		_ = store.Audit(store.AuditEntry{
+			Subject: "import",
+			Actor:   "api",
+			Action:  store.AuditAdmin,
+			Detail:  fmt.Sprintf("import %d bugs", result.Imported),
+		})
+	}
+	return respond(200, result)
+}
+
+func updateStatus(r ProxyRequest) Response {
+	change := StatusChange{}
+	if err := json.Unmarshal([]byte(r.Body), &change); err != nil {
+		return failure(400, "invalid json")
+	}
+	if !store.ValidStatus(change.Status) {
+		return failure(422, "invalid status, expected one of: "+strings.Join(store.Statuses, ", "))
+	}
+	bug, err := store.GetBug(r.PathParameters["id"])
+	if err != nil {
+		return storeError(err)
+	}
+	actor := "api"
+	if len(change.Actor) > 0 {
+		actor += ":" + change.Actor
+	}
+	updated, err := pipeline.SetStatus(bug, change.Status, change.Resolution, actor)
+	if err != nil {
+		return storeError(err)
+	}
+	return respond(200, updated)
+}
+
+// bugHistory return the status transitions of a bug with the time it spent
+// in each status and, once fixed, its cycle time, in seconds
+func bugHistory(r ProxyRequest) Response {
+	bug, err := store.GetBug(r.PathParameters["id"])
+	if err != nil {
+		return storeError(err)
+	}
+	seconds := map[string]int64{}
+	for status, d := range bug.TimeInStatus(time.Now()) {
+		seconds[status] = int64(d.Seconds())
+	}
+	body := map[string]interface{}{
+		"history":                bug.History,
+		"time_in_status_seconds": seconds,
+	}
+	if cycle, ok := bug.CycleTime(); ok {
+		body["cycle_time_seconds"] = int64(cycle.Seconds())
+	}
+	return respond(200, body)
+}
+
+// stats return the analytics per product of the UTC days from since to until
+// (YYYY-MM-DD), the last 30 days by default
+func stats(r ProxyRequest) Response {
+	q := r.QueryStringParameters
+	until := time.Now()
+	since := until.AddDate(0, 0, -29)
+	var err error
+	if len(q["since"]) > 0 {
+		if since, err = time.Parse("2006-01-02", q["since"]); err != nil {
+			return failure(400, "invalid since")
+		}
+	}
+	if len(q["until"]) > 0 {
+		if until, err = time.Parse("2006-01-02", q["until"]); err != nil {
+			return failure(400, "invalid until")
+		}
+	}
+	products, err := analytics.Report(since, until)
+	if err != nil {
+		return storeError(err)
+	}
+	if products == nil {
+		products = []analytics.Product{}
+	}
+	return respond(200, map[string]interface{}{
+		"since":    since.Format("2006-01-02"),
+		"until":    until.Format("2006-01-02"),
+		"products": products,
+	})
+}
+
+// listAudit return a page of the audit trail of subject, or of the actor
+// query parameter, filtered by since and until (RFC3339)
+func listAudit(r ProxyRequest, subject string) Response {
+	q := r.QueryStringParameters
+	limit, _ := strconv.ParseInt(q["limit"], 10, 64)
+	filter := store.AuditFilter{Subject: subject, Actor: q["actor"], Limit: limit, Cursor: q["cursor"]}
+	var err error
+	if len(q["since"]) > 0 {
+		if filter.Since, err = time.Parse(time.RFC3339, q["since"]); err != nil {
+			return failure(400, "invalid since")
+		}
+	}
+	if len(q["until"]) > 0 {
+		if filter.Until, err = time.Parse(time.RFC3339, q["until"]); err != nil {
+			return failure(400, "invalid until")
+		}
+	}
+	entries, cursor, err := store.ListAudit(filter)
+	if err == store.ErrAuditFilter {
+		return failure(400, err.Error())
+	}
+	if err != nil {
+		return storeError(err)
+	}
+	if entries == nil {
+		entries = []store.AuditEntry{}
+	}
+	return respond(200, map[string]interface{}{"entries": entries, "next_cursor": cursor})
+}
+
+func knownProduct(product string) bool {
+	for _, p := range catalog.ProductOptions() {
+		if p.Value == product {
+			return true
+		}
+	}
+	return false
+}
+
+func storeError(err error) Response {
+	if err == store.ErrNotFound {
+		return failure(404, err.Error())
+	}
+	if err == store.ErrConflict {
+		return failure(409, err.Error())
+	}
+	log.Printf("%s.Handler - store error: %v", handler, err)
+	return failure(500, "internal error")
+}
+
+func failure(status int, message string) Response {
+	return respond(status, map[string]string{"error": message})
+}
+
+func respond(status int, body interface{}) Response {
+	payload, _ := json.Marshal(body)
+	return Response{
+		StatusCode:      status,
+		IsBase64Encoded: false,
+		Body:            string(payload),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/analytics"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/slack"
+)
+
+const (
+	handler = "KanobugAnalytics"
+)
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+// on the first of every month, posting the bugs submitted, triaged and
+// resolved per product in the month before, with their median time to
+// triage and to resolution, to LEADERSHIP_CHANNEL
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	channel := os.Getenv("LEADERSHIP_CHANNEL")
+	if len(channel) == 0 {
+		log.Printf("%s.Handler - LEADERSHIP_CHANNEL is not set", handler)
+		return nil
+	}
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	since, until := month.AddDate(0, -1, 0), month.AddDate(0, 0, -1)
+	products, err := analytics.Report(since, until)
+	if err != nil {
+		return err
+	}
+	err = post(channel, analytics.Format(products, since, until))
+	log.Printf("%s.Handler - since: %s, products: %d, channel: %s, error: %v", handler, since.Format("2006-01-02"), len(products), channel, err)
+	return err
+}
+
+// post send text to a Slack channel
+func post(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Slack)
+	lambda.Start(Handler)
+}
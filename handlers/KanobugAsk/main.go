@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/ask"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const handler = "KanobugAsk"
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// asynchronously by KanobugCommand for `/kanobug ask`, posting the answer to
+// the question's response url
+func Handler(ctx context.Context, question ask.Question) error {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - team: %s, user: %s, question: %q", handler, question.TeamID, question.UserID, question.Text)
+	store.UseWorkspace(question.EnterpriseID, question.TeamID)
+	answer, err := ask.Answer(question)
+	if err != nil {
+		answer = "Answering failed, please try again."
+	}
+	respond(question.ResponseURL, "> "+question.Text+"\n"+answer)
+	return nil
+}
+
+// respond post text to a command's response url
+func respond(responseURL, text string) {
+	err := slack.NewResponder(responseURL, "slack").Reply(text)
+	log.Printf("%s.respond - error: %v", handler, err)
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Slack)
+	lambda.Start(Handler)
+}
@@ -1,25 +1,53 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/ask"
+	"github.com/anzellai/kanobug/internal/authz"
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/export"
+	"github.com/anzellai/kanobug/internal/failure"
+	"github.com/anzellai/kanobug/internal/flags"
+	"github.com/anzellai/kanobug/internal/form"
+	"github.com/anzellai/kanobug/internal/health"
+	"github.com/anzellai/kanobug/internal/i18n"
+	"github.com/anzellai/kanobug/internal/mattermost"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/recovery"
+	"github.com/anzellai/kanobug/internal/scopes"
+	"github.com/anzellai/kanobug/internal/search"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
 )
 
 const (
 	handler     = "KanobugCommand"
-	apiEndpoint = "https://slack.com/api/dialog.open"
+	apiEndpoint = "https://slack.com/api/views.open"
+
+	// exportTTL is how long an export download link stays valid
+	exportTTL = time.Hour
 )
 
+// userMention matches an escaped <@U123|name> or <@U123> command argument
+var userMention = regexp.MustCompile(`^<@([UW][A-Z0-9]+)(?:\|[^>]*)?>$`)
+
 // Response is of type APIGatewayProxyResponse since we're leveraging the
 // AWS Lambda Proxy Request functionality (default behavior)
 //
@@ -31,171 +59,1489 @@ type ProxyRequest events.APIGatewayProxyRequest
 
 // Request is the proxy request from lambda
 type Request struct {
-	Token       string `json:"token"`
-	TeamID      string `json:"team_id"`
-	TeamDomain  string `json:"team_domain"`
-	ChannelID   string `json:"channel_id"`
-	ChannelName string `json:"channel_name"`
-	UserID      string `json:"user_id"`
-	UserName    string `json:"user_name"`
-	Text        string `json:"text"`
-	TriggerID   string `json:"trigger_id"`
-	ResponseURL string `json:"response_url"`
+	Token        string `json:"token"`
+	EnterpriseID string `json:"enterprise_id"`
+	TeamID       string `json:"team_id"`
+	TeamDomain   string `json:"team_domain"`
+	ChannelID    string `json:"channel_id"`
+	ChannelName  string `json:"channel_name"`
+	UserID       string `json:"user_id"`
+	UserName     string `json:"user_name"`
+	Text         string `json:"text"`
+	TriggerID    string `json:"trigger_id"`
+	ResponseURL  string `json:"response_url"`
 }
 
-// Payload struct type ...
-type Payload struct {
-	TriggerID string `json:"trigger_id"`
-	Dialog    Dialog `json:"dialog"`
+// commands are the /kanobug subcommands, matched on the first word of the
+// text, anything else opens the report dialog
+var commands = map[string]func(request Request, args []string) string{
+	"export": exportCommand,
+	"config": configCommand,
+	"admin":  adminCommand,
+
+	"edit":   editCommand,
+	"close":  closeCommand,
+	"merge":  mergeCommand,
+	"assign": assignCommand,
+	"delete": deleteCommand,
+	"list":   listCommand,
+	"search": searchCommand,
+	"ask":    askCommand,
+	"stats":  statsCommand,
+	"fixed":  fixedCommand,
+	"status": statusCommand,
+
+	"subscribe":     subscribeCommand,
+	"unsubscribe":   unsubscribeCommand,
+	"subscriptions": subscriptionsCommand,
+	"notify":        notifyCommand,
+
+	"bash": bashCommand,
 }
 
-// Dialog struct type ...
-type Dialog struct {
-	Title       string    `json:"title"`
-	CallbackID  string    `json:"callback_id"`
-	SubmitLabel string    `json:"submit_label"`
-	Elements    []Element `json:"elements"`
+// epicKey matches a Jira issue key, e.g. HP-123
+var epicKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// bashDuration return how long a bug bash runs unless started for longer or
+// shorter, BASH_DURATION or 8h
+func bashDuration() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("BASH_DURATION")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Hour
 }
 
-// Element struct type ...
-type Element struct {
-	Label    string   `json:"label"`
-	Type     string   `json:"type"`
-	Name     string   `json:"name"`
-	Value    string   `json:"value"`
-	Hint     string   `json:"hint"`
-	Options  []Option `json:"options"`
-	Optional bool     `json:"optional"`
+// bashCommand handle `/kanobug bash <EPIC-KEY> [duration]`, filing the bugs
+// reported from the channel under the epic while the bash runs,
+// `/kanobug bash end` and `/kanobug bash` showing the running one
+func bashCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug bash <EPIC-KEY> [duration, e.g. 4h]`, `/kanobug bash end` or `/kanobug bash`"
+	if !store.ConfigEnabled() {
+		return "Bug bashes are not enabled."
+	}
+	if len(args) == 0 {
+		bash, err := store.GetBash(request.ChannelID)
+		if err == store.ErrNotFound {
+			return "No bug bash is running in this channel. " + usage
+		}
+		if err != nil {
+			return "Looking up the bug bash failed, please try again."
+		}
+		return fmt.Sprintf("Bugs reported here are filed under %s until %s.", bash.Epic, bash.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	if denied := authz.Require(request.UserID, authz.Triager, "run a bug bash"); len(denied) > 0 {
+		return denied
+	}
+	if len(args) == 1 && strings.ToLower(args[0]) == "end" {
+		before := existing(store.GetBash(request.ChannelID))
+		if err := store.DeleteBash(request.ChannelID); err != nil {
+			return "Ending the bug bash failed, please try again."
+		}
+		audit(request, store.KindBash+"/"+request.ChannelID, "bash end", before, nil)
+		return "The bug bash is over, bugs reported here are filed as usual."
+	}
+	if len(args) > 2 {
+		return usage
+	}
+	epic := strings.ToUpper(args[0])
+	if !epicKey.MatchString(epic) {
+		return fmt.Sprintf("`%s` is not an issue key. %s", args[0], usage)
+	}
+	duration := bashDuration()
+	if len(args) == 2 {
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d <= 0 {
+			return fmt.Sprintf("`%s` is not a duration. %s", args[1], usage)
+		}
+		duration = d
+	}
+	bash := store.Bash{
+		ChannelID: request.ChannelID,
+		Epic:      epic,
+		StartedBy: request.UserID,
+		ExpiresAt: time.Now().Add(duration),
+	}
+	if err := store.PutBash(bash); err != nil {
+		return "Starting the bug bash failed, please try again."
+	}
+	audit(request, store.KindBash+"/"+request.ChannelID, "bash start", nil, bash)
+	return fmt.Sprintf("Bug bash started, bugs reported here are filed under %s until %s.", epic, bash.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
 }
 
-// Option struct type ...
-type Option struct {
-	Label string `json:"label"`
-	Value string `json:"value"`
+// lookupProduct match a product value or label, case insensitively
+func lookupProduct(name string) string {
+	for _, p := range catalog.ProductOptions() {
+		if strings.EqualFold(p.Value, name) || strings.EqualFold(p.Label, name) {
+			return p.Value
+		}
+	}
+	return ""
 }
 
-// Handler is our lambda handler invoked by the `lambda.Start` function call
-func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
-	log.Printf("%s.Handler - invoke: %+v", handler, r)
-	form, err := url.Parse("?" + r.Body)
+// platform return the chat platform the command came from
+func platform(request Request) string {
+	if mattermost.IsCommandToken(request.Token) {
+		return "mattermost"
+	}
+	return "slack"
+}
+
+// Bounds of the digest of a subscription, KanobugDigest runs every 15 minutes
+const (
+	minDigest = 15 * time.Minute
+	maxDigest = 24 * time.Hour
+)
+
+// subscribeCommand handle `/kanobug subscribe <product> [digest:<every>]`,
+// posting the product's new bugs and status changes to the channel, the
+// status changes of all but Blockers batched into a digest with digest:
+func subscribeCommand(request Request, args []string) string {
+	if !store.ConfigEnabled() {
+		return "Subscriptions are not enabled."
+	}
+	usage := "Usage: `/kanobug subscribe <product> [digest:<every, e.g. 1h>]`"
+	var digest time.Duration
+	if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "digest:") {
+		var err error
+		if digest, err = time.ParseDuration(strings.TrimPrefix(args[n-1], "digest:")); err != nil || digest < minDigest || digest > maxDigest {
+			return fmt.Sprintf("Digests are posted every %s to %s, e.g. `digest:1h`.", minDigest, maxDigest)
+		}
+		args = args[:n-1]
+	}
+	product := lookupProduct(strings.Join(args, " "))
+	if len(product) == 0 {
+		return usage
+	}
+	subscription := store.Subscription{
+		ChannelID: request.ChannelID,
+		Product:   product,
+		Platform:  platform(request),
+		UserID:    request.UserID,
+		Digest:    digest,
+	}
+	if err := store.PutSubscription(subscription); err != nil {
+		return "Subscribing failed, please try again."
+	}
+	audit(request, store.KindSubscription+product+"/"+request.ChannelID, "subscribe", nil, subscription)
+	if digest > 0 {
+		return fmt.Sprintf("This channel will now hear about new %s bugs, and get a digest of their status changes every %s. Blocker status changes are posted right away.", product, digest)
+	}
+	return fmt.Sprintf("This channel will now hear about new %s bugs and their status changes.", product)
+}
+
+// unsubscribeCommand handle `/kanobug unsubscribe <product>`
+func unsubscribeCommand(request Request, args []string) string {
+	if !store.ConfigEnabled() {
+		return "Subscriptions are not enabled."
+	}
+	product := lookupProduct(strings.Join(args, " "))
+	if len(product) == 0 {
+		return "Usage: `/kanobug unsubscribe <product>`"
+	}
+	if err := store.DeleteSubscription(product, request.ChannelID); err != nil {
+		return "Unsubscribing failed, please try again."
+	}
+	audit(request, store.KindSubscription+product+"/"+request.ChannelID, "unsubscribe", nil, nil)
+	return fmt.Sprintf("This channel is no longer subscribed to %s bugs.", product)
+}
+
+// subscriptionsCommand handle `/kanobug subscriptions`, listing the products
+// the channel is subscribed to
+func subscriptionsCommand(request Request, args []string) string {
+	if !store.ConfigEnabled() {
+		return "Subscriptions are not enabled."
+	}
+	var products []string
+	for _, p := range catalog.ProductOptions() {
+		if subscription, err := store.GetSubscription(p.Value, request.ChannelID); err == nil {
+			if subscription.Digest > 0 {
+				products = append(products, fmt.Sprintf("%s (digest every %s)", p.Label, subscription.Digest))
+			} else {
+				products = append(products, p.Label)
+			}
+		}
+	}
+	if len(products) == 0 {
+		return "This channel has no subscriptions, try `/kanobug subscribe <product>`."
+	}
+	return "This channel is subscribed to " + strings.Join(products, ", ") + "."
+}
+
+// changeableBug return the bug ref when the requesting Slack user may perform
+// action on it, or the reply explaining why not
+func changeableBug(request Request, ref, action string) (bug store.Bug, problem string) {
+	if mattermost.IsCommandToken(request.Token) {
+		return bug, "Changing bugs is only available in Slack."
+	}
+	bug, err := store.FindBug(ref)
+	if err == store.ErrNotFound {
+		return bug, fmt.Sprintf("No bug %s found.", ref)
+	}
 	if err != nil {
-		log.Printf("%s.Handler - unmarhsal error: %+v", handler, err)
+		log.Printf("%s.changeableBug - ref: %s, error: %v", handler, ref, err)
+		return bug, "Sorry, the bug could not be loaded."
 	}
-	query, _ := url.ParseQuery(form.RawQuery)
-	request := Request{
-		Token:       query["token"][0],
-		TeamID:      query["team_id"][0],
-		TeamDomain:  query["team_domain"][0],
-		ChannelID:   query["channel_id"][0],
-		ChannelName: query["channel_name"][0],
-		UserID:      query["user_id"][0],
-		UserName:    query["user_name"][0],
-		Text:        query["text"][0],
-		TriggerID:   query["trigger_id"][0],
-		ResponseURL: query["response_url"][0],
+	return bug, authz.RequireBug(request.UserID, bug, action)
+}
+
+// editCommand reopen the report form pre-filled with a bug for its reporter
+// or a triager
+func editCommand(request Request, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /kanobug edit <KEY or bug ID>"
 	}
-	log.Printf("%s.Handler - invoke: %+v, for: %s, trigger_id: %s", handler, request, request.Text, request.TriggerID)
-	if request.Token != os.Getenv("SLACK_VERIFICATION_TOKEN") {
-		err = errors.New("invalid verification token")
-		return Response{
-			StatusCode:      400,
-			IsBase64Encoded: false,
-			Body:            fmt.Sprintf("%s submitting - error: %v", handler, err),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
+	bug, problem := changeableBug(request, args[0], "edit this bug")
+	if len(problem) > 0 {
+		return problem
+	}
+	err := form.Open(request.TriggerID, form.Modal(editDialog(bug), form.Metadata{
+		ResponseURL: request.ResponseURL,
+		ChannelID:   request.ChannelID,
+		BugID:       bug.ID,
+		Version:     bug.Version,
+		Checksum:    bug.Checksum(),
+		Locale:      bug.Locale,
+	}))
+	if err != nil {
+		log.Printf("%s.editCommand - bug: %s, error: %v", handler, bug.ID, err)
+		return "Sorry, the edit form could not be opened."
+	}
+	return ""
+}
+
+// closeCommand open the resolution picker for a bug's reporter or a triager
+func closeCommand(request Request, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /kanobug close <KEY or bug ID>"
+	}
+	bug, problem := changeableBug(request, args[0], "close this bug")
+	if len(problem) > 0 {
+		return problem
+	}
+	if bug.Status == store.StatusClosed {
+		return fmt.Sprintf("Bug %s is already closed.", args[0])
+	}
+	dialog := form.Dialog{
+		Title:       "Close bug",
+		CallbackID:  "close-bug",
+		SubmitLabel: "Close",
+		Elements: []form.Element{
+			form.Element{
+				Label:   "Resolution",
+				Type:    "select",
+				Name:    "resolution",
+				Value:   store.ResolutionFixed,
+				Options: form.Options(catalog.Resolutions),
 			},
-		}, err
-	}
-	payload, err := json.Marshal(Payload{
-		TriggerID: request.TriggerID,
-		Dialog: Dialog{
-			Title:       "Report a Bug",
-			CallbackID:  "report-bug",
-			SubmitLabel: "Submit",
-			Elements: []Element{
-				Element{
-					Label: "Summarise the Problem",
-					Type:  "text",
-					Name:  "summary",
-					Value: request.Text,
-					Hint:  "A sentence to summarise the problem",
-				},
-				Element{
-					Label: "Product",
-					Type:  "select",
-					Name:  "product",
-					Options: []Option{
-						Option{
-							Label: "Harry Potter Coding Kit",
-							Value: "harry_potter_coding_kit",
-						},
-						Option{
-							Label: "Computer Kit Touch",
-							Value: "computer_kit_touch",
-						},
-						Option{
-							Label: "Computer Kit 2018",
-							Value: "computer_kit_2018",
-						},
-						Option{
-							Label: "Pixel Kit",
-							Value: "pixel_kit",
-						},
-						Option{
-							Label: "Motion Sensor Kit",
-							Value: "motion_sensor_kit",
-						},
-					},
-				},
-				Element{
-					Label:    "Any more details?",
-					Type:     "textarea",
-					Name:     "details",
-					Hint:     "If you can help us reproduce the bug, that'd be grand.",
-					Optional: true,
-				},
+			form.Element{
+				Label:    "Duplicate of",
+				Type:     "text",
+				Name:     "duplicate_of",
+				Hint:     "The Jira key of the original, e.g. IQ-123, for duplicates.",
+				Optional: true,
 			},
 		},
+	}
+	err := form.Open(request.TriggerID, form.Modal(dialog, form.Metadata{
+		ResponseURL: request.ResponseURL,
+		ChannelID:   request.ChannelID,
+		BugID:       bug.ID,
+	}))
+	if err != nil {
+		log.Printf("%s.closeCommand - bug: %s, error: %v", handler, bug.ID, err)
+		return "Sorry, the close form could not be opened."
+	}
+	return ""
+}
+
+// editDialog return the report fields that can be edited, filled from bug
+func editDialog(bug store.Bug) form.Dialog {
+	report := form.Report(bug.Summary, store.Channel{Product: bug.Product}, store.Preference{Severity: bug.Severity}, bug.Locale)
+	dialog := form.Dialog{Title: i18n.T(bug.Locale, "edit.title"), CallbackID: "edit-bug", SubmitLabel: i18n.T(bug.Locale, "edit.submit")}
+	for _, name := range []string{"summary", "product", "severity", "details"} {
+		if e := report.Element(name); e != nil {
+			dialog.Elements = append(dialog.Elements, *e)
+		}
+	}
+	if bug.Details != "N/A" {
+		dialog.Element("details").Value = bug.Details
+	}
+	for _, name := range []string{"tags", "other_tags"} {
+		if e := report.Element(name); e != nil {
+			dialog.Elements = append(dialog.Elements, *e)
+		}
+	}
+	var other []string
+	for _, tag := range bug.Tags {
+		if e := dialog.Element("tags"); e != nil && e.HasOption(tag) {
+			e.Values = append(e.Values, tag)
+			continue
+		}
+		other = append(other, tag)
+	}
+	dialog.Element("other_tags").Value = strings.Join(other, ", ")
+	return dialog
+}
+
+// statsDays is how many days `/kanobug stats` covers at most
+const statsDays = 90
+
+// statsCommand handle `/kanobug stats [days]`, the bugs submitted, synced to
+// trackers and resolved per product over the last days (7 by default), read
+// from the daily counters
+func statsCommand(request Request, args []string) string {
+	usage := fmt.Sprintf("Usage: `/kanobug stats [days]`, up to %d days", statsDays)
+	days := 7
+	if len(args) > 1 {
+		return usage
+	}
+	if len(args) == 1 {
+		var err error
+		if days, err = strconv.Atoi(args[0]); err != nil || days < 1 || days > statsDays {
+			return usage
+		}
+	}
+	until := time.Now()
+	counters, err := store.ListCounters(until.AddDate(0, 0, 1-days), until)
+	if err != nil {
+		log.Printf("%s.statsCommand - days: %d, error: %v", handler, days, err)
+		return "Loading stats failed, please try again."
+	}
+	totals := map[string]*store.Counter{}
+	var products []string
+	for _, c := range counters {
+		total, ok := totals[c.Product]
+		if !ok {
+			total = &store.Counter{Product: c.Product}
+			totals[c.Product] = total
+			products = append(products, c.Product)
+		}
+		total.Submitted += c.Submitted
+		total.Synced += c.Synced
+		total.Resolved += c.Resolved
+	}
+	if len(products) == 0 {
+		return fmt.Sprintf("No bugs in the last %d day(s).", days)
+	}
+	sort.Strings(products)
+	lines := []string{fmt.Sprintf("Bugs in the last %d day(s):", days)}
+	for _, product := range products {
+		t := totals[product]
+		lines = append(lines, fmt.Sprintf("• %s: %d submitted, %d synced, %d resolved",
+			store.Bug{Product: product}.ProductName(), t.Submitted, t.Synced, t.Resolved))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listLimit is the most bugs `/kanobug list` shows
+const listLimit = 10
+
+// listCommand handle `/kanobug list [tag:<tag>] [product:<product>]
+// [status:<status>]`, the most recent matching bugs. Security sensitive bugs
+// are only listed for their reporter and triagers
+func listCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug list [tag:<tag>] [product:<product>] [status:<status>]`"
+	var filter store.Filter
+	for _, arg := range args {
+		kv := strings.SplitN(arg, ":", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return usage
+		}
+		switch kv[0] {
+		case "tag":
+			tags := store.NormalizeTags(kv[1:])
+			if len(tags) == 0 {
+				return usage
+			}
+			filter.Tag = tags[0]
+		case "product":
+			if filter.Product = lookupProduct(kv[1]); len(filter.Product) == 0 {
+				return fmt.Sprintf("Unknown product `%s`.", kv[1])
+			}
+		case "status":
+			if !store.ValidStatus(kv[1]) {
+				return fmt.Sprintf("Unknown status `%s`, use one of %s.", kv[1], strings.Join(store.Statuses, ", "))
+			}
+			filter.Status = kv[1]
+		default:
+			return usage
+		}
+	}
+	bugs, err := store.AllBugs(filter)
+	if err != nil {
+		log.Printf("%s.listCommand - filter: %+v, error: %v", handler, filter, err)
+		return "Listing bugs failed, please try again."
+	}
+	sort.Slice(bugs, func(i, j int) bool { return bugs[i].CreatedAt.After(bugs[j].CreatedAt) })
+	triager := authz.Of(request.UserID) >= authz.Triager
+	var lines []string
+	for _, bug := range bugs {
+		if len(lines) == listLimit {
+			break
+		}
+		if bug.Security && !triager && !bug.ReportedBy(request.UserID) {
+			continue
+		}
+		ref := bug.IssueKey
+		if len(ref) == 0 {
+			ref = bug.ID
+		}
+		line := fmt.Sprintf("• %s %s (%s)", ref, bug.Summary, bug.Status)
+		if len(bug.Tags) > 0 {
+			line += " `" + strings.Join(bug.Tags, "` `") + "`"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "No bugs match."
+	}
+	return strings.Join(lines, "\n")
+}
+
+// searchCommand handle `/kanobug search [product:<product>] [status:<status>]
+// <words>`, the bugs matching the words best. Hits are read back from the
+// store, which leaves out bugs deleted since they were indexed, and security
+// sensitive ones are only shown to their reporter and triagers
+func searchCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug search [product:<product>] [status:<status>] <words>`"
+	if !search.Enabled() {
+		return "Search is not set up, try `/kanobug list`."
+	}
+	var filter search.Filter
+	var words []string
+	for _, arg := range args {
+		kv := strings.SplitN(arg, ":", 2)
+		switch {
+		case len(kv) == 2 && kv[0] == "product":
+			if filter.Product = lookupProduct(kv[1]); len(filter.Product) == 0 {
+				return fmt.Sprintf("Unknown product `%s`.", kv[1])
+			}
+		case len(kv) == 2 && kv[0] == "status":
+			if !store.ValidStatus(kv[1]) {
+				return fmt.Sprintf("Unknown status `%s`, use one of %s.", kv[1], strings.Join(store.Statuses, ", "))
+			}
+			filter.Status = kv[1]
+		default:
+			words = append(words, arg)
+		}
+	}
+	if len(words) == 0 {
+		return usage
+	}
+	query := strings.Join(words, " ")
+	hits, err := search.Search(request.TeamID, query, filter, 2*listLimit)
+	if err != nil {
+		log.Printf("%s.searchCommand - query: %q, error: %v", handler, query, err)
+		return "Searching failed, please try again."
+	}
+	triager := authz.Of(request.UserID) >= authz.Triager
+	var lines []string
+	for _, hit := range hits {
+		if len(lines) == listLimit {
+			break
+		}
+		bug, err := store.GetBug(hit.ID)
+		if err != nil || (bug.Security && !triager && !bug.ReportedBy(request.UserID)) {
+			continue
+		}
+		ref := bug.IssueKey
+		if len(ref) == 0 {
+			ref = bug.ID
+		}
+		lines = append(lines, fmt.Sprintf("• %s %s (%s)", ref, bug.Summary, bug.Status))
+	}
+	if len(lines) == 0 {
+		return "No bugs match."
+	}
+	return strings.Join(lines, "\n")
+}
+
+// askCommand handle `/kanobug ask <question>`, answered from the indexed
+// bugs by ASK_MODEL_ID through the response url once the model has written
+// it, see internal/ask
+func askCommand(request Request, args []string) string {
+	if !ask.Enabled() {
+		return "Asking is not set up, try `/kanobug search`."
+	}
+	if len(args) == 0 {
+		return "Usage: `/kanobug ask <question>`, e.g. `/kanobug ask how many touch screen bugs were filed for Pixel Kit last month?`"
+	}
+	question := ask.Question{
+		EnterpriseID: request.EnterpriseID,
+		TeamID:       request.TeamID,
+		UserID:       request.UserID,
+		Triager:      authz.Of(request.UserID) >= authz.Triager,
+		Text:         strings.Join(args, " "),
+		ResponseURL:  request.ResponseURL,
+	}
+	if err := ask.Queue(question); err != nil {
+		return "Asking failed, please try again."
+	}
+	return "Looking through the bugs, the answer follows shortly."
+}
+
+// fixedCommand handle `/kanobug fixed <product> <since> [until]`, the bugs of
+// the product fixed in the window as release notes lines
+func fixedCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug fixed <product> <since> [until]`, dates as YYYY-MM-DD"
+	if len(args) < 2 || len(args) > 3 {
+		return usage
+	}
+	product := lookupProduct(args[0])
+	if len(product) == 0 {
+		return fmt.Sprintf("Unknown product `%s`.", args[0])
+	}
+	since, err := parseDate(args[1])
+	if err != nil {
+		return usage
+	}
+	until := time.Now()
+	if len(args) == 3 {
+		if until, err = parseDate(args[2]); err != nil {
+			return usage
+		}
+	}
+	bugs, err := export.Fixed(product, since, until)
+	if err != nil {
+		log.Printf("%s.fixedCommand - product: %s, error: %v", handler, product, err)
+		return "Listing fixed bugs failed, please try again."
+	}
+	if len(bugs) == 0 {
+		return fmt.Sprintf("No %s bugs were fixed from %s until %s.", product, since.Format("2006-01-02"), until.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%d %s bug(s) fixed from %s until %s:\n%s", len(bugs), product, since.Format("2006-01-02"), until.Format("2006-01-02"), export.ReleaseNotes(bugs, export.FormatSlack))
+}
+
+// notifyModes are the notification modes offered in the preferences modal
+var notifyModes = []form.Option{
+	{Label: "DM on every change", Value: store.NotifyEvery},
+	{Label: "Daily digest", Value: store.NotifyDigest},
+	{Label: "Mute", Value: store.NotifyMute},
+}
+
+// notifyCommand handle `/kanobug notify`, opening the modal of the user's
+// notification preferences, saved by KanobugInteractiveComponent
+func notifyCommand(request Request, args []string) string {
+	if !store.ConfigEnabled() {
+		return "Notification preferences are not enabled."
+	}
+	if mattermost.IsCommandToken(request.Token) {
+		return "Notification preferences are only available in Slack."
+	}
+	preference, err := store.GetNotifyPreference(request.UserID)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("%s.notifyCommand - user: %s, error: %v", handler, request.UserID, err)
+		return "Sorry, your notification preferences could not be loaded."
+	}
+	dialog := form.Dialog{
+		Title:       "Notifications",
+		CallbackID:  "notify-preferences",
+		SubmitLabel: "Save",
+		Elements: []form.Element{{
+			Label:   "Bugs you reported, are CC'd on or are paged for",
+			Type:    "select",
+			Name:    "mode",
+			Value:   preference.ModeOf(""),
+			Options: notifyModes,
+			Hint:    "Blockers are sent as they change unless muted.",
+		}},
+	}
+	// every product follows the mode above unless overridden
+	for _, product := range catalog.ProductOptions() {
+		override := "default"
+		if mode, ok := preference.Products[product.Value]; ok {
+			override = mode
+		}
+		dialog.Elements = append(dialog.Elements, form.Element{
+			Label:   product.Label,
+			Type:    "select",
+			Name:    "product:" + product.Value,
+			Value:   override,
+			Options: append([]form.Option{{Label: "As above", Value: "default"}}, notifyModes...),
+		})
+	}
+	err = form.Open(request.TriggerID, form.Modal(dialog, form.Metadata{
+		ResponseURL: request.ResponseURL,
+		ChannelID:   request.ChannelID,
+	}))
+	if err != nil {
+		log.Printf("%s.notifyCommand - user: %s, error: %v", handler, request.UserID, err)
+		return "Sorry, the notification preferences could not be opened."
+	}
+	return ""
+}
+
+// statusCommand handle `/kanobug status <KEY>`, the status transitions of a
+// bug with who made them, the time spent in each status and its cycle time
+func statusCommand(request Request, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /kanobug status <KEY or bug ID>"
+	}
+	bug, err := store.FindBug(args[0])
+	if err == store.ErrNotFound {
+		return fmt.Sprintf("No bug %s found.", args[0])
+	}
+	if err != nil {
+		log.Printf("%s.statusCommand - ref: %s, error: %v", handler, args[0], err)
+		return "Sorry, the bug could not be loaded."
+	}
+	if bug.Security && authz.Of(request.UserID) < authz.Triager && !bug.ReportedBy(request.UserID) {
+		return fmt.Sprintf("No bug %s found.", args[0])
+	}
+	lines := []string{fmt.Sprintf("Bug %s is %s: %s", args[0], bug.Status, bug.Summary)}
+	for _, change := range bug.History {
+		line := fmt.Sprintf("• %s UTC %s", change.At.UTC().Format("Jan 2 15:04"), change.Status)
+		if len(change.Resolution) > 0 {
+			line += fmt.Sprintf(" (%s)", change.Resolution)
+		}
+		if len(change.From) > 0 {
+			line += " from " + change.From
+		}
+		switch {
+		case len(change.Actor) == 0:
+		case strings.Contains(change.Actor, ":"):
+			line += " by " + change.Actor
+		default:
+			line += fmt.Sprintf(" by <@%s>", change.Actor)
+		}
+		lines = append(lines, line)
+	}
+	spent := bug.TimeInStatus(time.Now())
+	var times []string
+	for _, status := range store.Statuses {
+		if d, ok := spent[status]; ok {
+			times = append(times, fmt.Sprintf("%s %s", status, d.Round(time.Minute)))
+		}
+	}
+	if len(times) > 0 {
+		lines = append(lines, "Time in status: "+strings.Join(times, ", "))
+	}
+	if cycle, ok := bug.CycleTime(); ok {
+		lines = append(lines, fmt.Sprintf("Cycle time: %s", cycle.Round(time.Minute)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// deleteCommand handle `/kanobug delete <KEY>`, hiding the bug from every
+// list for its reporter or an admin. Its tracker issues are left alone and
+// kanobugctl restore brings it back
+func deleteCommand(request Request, args []string) string {
+	if len(args) != 1 {
+		return "Usage: /kanobug delete <KEY or bug ID>"
+	}
+	if mattermost.IsCommandToken(request.Token) {
+		return "Deleting bugs is only available in Slack."
+	}
+	bug, err := store.FindBug(args[0])
+	if err == store.ErrNotFound {
+		return fmt.Sprintf("No bug %s found.", args[0])
+	}
+	if err != nil {
+		log.Printf("%s.deleteCommand - ref: %s, error: %v", handler, args[0], err)
+		return "Sorry, the bug could not be loaded."
+	}
+	if !bug.ReportedBy(request.UserID) {
+		if denied := authz.Require(request.UserID, authz.Admin, "delete other people's bugs"); len(denied) > 0 {
+			return denied
+		}
+	}
+	deleted, err := store.DeleteBug(bug)
+	if err == store.ErrConflict {
+		return "The bug changed meanwhile, please try again."
+	}
+	if err != nil {
+		return "Deleting the bug failed, please try again."
+	}
+	_ = store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   request.UserID,
+		Action:  store.AuditDelete,
+		Before:  store.Audited(bug),
+		After:   store.Audited(deleted),
+	})
+	return fmt.Sprintf("Deleted bug %s, its tracker issues are unchanged. An admin can restore it with `kanobugctl restore %s`.", bug.ID, bug.ID)
+}
+
+// mergeCommand handle `/kanobug merge <DUPLICATE> <SURVIVOR>` for triagers,
+// closing the duplicate's Jira issue as a duplicate linked to the survivor's,
+// and CC'ing the duplicate's reporter and CC'd users on the survivor, as
+// Jira watchers too, so they follow it from then on
+func mergeCommand(request Request, args []string) string {
+	if len(args) != 2 {
+		return "Usage: /kanobug merge <duplicate KEY or bug ID> <surviving KEY or bug ID>"
+	}
+	if denied := authz.Require(request.UserID, authz.Triager, "merge bugs"); len(denied) > 0 {
+		return denied
+	}
+	var bugs [2]store.Bug
+	for i, ref := range args {
+		bug, err := store.FindBug(ref)
+		if err == store.ErrNotFound {
+			return fmt.Sprintf("No bug %s found.", ref)
+		}
+		if err != nil {
+			log.Printf("%s.mergeCommand - ref: %s, error: %v", handler, ref, err)
+			return "Sorry, the bug could not be loaded."
+		}
+		bugs[i] = bug
+	}
+	duplicate, survivor := bugs[0], bugs[1]
+	switch {
+	case duplicate.ID == survivor.ID:
+		return "Please name two different bugs."
+	case len(duplicate.MergedInto) > 0:
+		return fmt.Sprintf("Bug %s is already merged into %s.", args[0], duplicate.MergedInto)
+	case len(survivor.MergedInto) > 0:
+		return fmt.Sprintf("Bug %s is merged into %s, merge into that one instead.", args[1], survivor.MergedInto)
+	}
+	jira := tracker.NewJira()
+	jiraIssue := func(bug store.Bug) (issue tracker.Issue) {
+		for _, i := range bug.Issues {
+			if i.Tracker == jira.Name() && len(i.Key) > 0 {
+				return i
+			}
+		}
+		return
+	}
+	var lines []string
+	target := jiraIssue(survivor)
+	if issue := jiraIssue(duplicate); len(issue.Key) > 0 && len(target.Key) > 0 && duplicate.Status != store.StatusClosed {
+		if err := jira.Close(issue, store.ResolutionDuplicate, target.Key); err != nil {
+			lines = append(lines, fmt.Sprintf("Could not close %s: %v", issue.Key, err))
+		} else {
+			lines = append(lines, fmt.Sprintf("Closed %s as a duplicate of %s", issue.Key, target.Key))
+		}
+	}
+	cc, seen := append([]string{}, survivor.CC...), map[string]bool{survivor.UserID: true}
+	for _, userID := range survivor.CC {
+		seen[userID] = true
+	}
+	followers := duplicate.CC
+	if !duplicate.Anonymous {
+		followers = append([]string{duplicate.UserID}, followers...)
+	}
+	var added []string
+	for _, userID := range followers {
+		if seen[userID] || len(userID) == 0 {
+			continue
+		}
+		seen[userID] = true
+		cc, added = append(cc, userID), append(added, userID)
+	}
+	err := store.Merge(duplicate, survivor, cc)
+	if err == store.ErrConflict {
+		return "One of the bugs changed meanwhile, please run the merge again."
+	}
+	if err != nil {
+		return "Merging the bugs failed, please try again."
+	}
+	merged := survivor
+	merged.CC = cc
+	_ = store.Audit(store.AuditEntry{
+		Subject: survivor.ID,
+		Actor:   request.UserID,
+		Action:  store.AuditMerge,
+		Detail:  "merged " + duplicate.ID,
+		Before:  survivor.CC,
+		After:   merged.CC,
 	})
+	if len(target.Key) > 0 && !mattermost.IsCommandToken(request.Token) {
+		for _, userID := range added {
+			if email := userEmail(userID); len(email) > 0 {
+				_ = jira.AddWatcher(target, email)
+			}
+		}
+	}
+	duplicate.MergedInto = survivor.ID
+	if duplicate.Status != store.StatusClosed {
+		if _, err := pipeline.SetStatus(duplicate, store.StatusClosed, store.ResolutionDuplicate, request.UserID); err != nil {
+			lines = append(lines, fmt.Sprintf("Could not close bug %s: %v", duplicate.ID, err))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Merged bug %s into %s, %d more people now follow it.", duplicate.ID, survivor.ID, len(added)))
+	return strings.Join(lines, "\n")
+}
+
+// assignCommand handle `/kanobug assign <KEY> @user`, assigning the bug's
+// Jira issues to the Jira account with the Slack user's email
+func assignCommand(request Request, args []string) string {
+	if len(args) != 2 {
+		return "Usage: /kanobug assign <KEY or bug ID> @user"
+	}
+	if mattermost.IsCommandToken(request.Token) {
+		return "Assigning bugs is only available in Slack."
+	}
+	if denied := authz.Require(request.UserID, authz.Triager, "assign bugs"); len(denied) > 0 {
+		return denied
+	}
+	match := userMention.FindStringSubmatch(args[1])
+	if match == nil {
+		return "Please mention the assignee, e.g. /kanobug assign IQ-123 @jo (the command needs to escape users)."
+	}
+	assignee := match[1]
+	bug, err := store.FindBug(args[0])
+	if err == store.ErrNotFound {
+		return fmt.Sprintf("No bug %s found.", args[0])
+	}
 	if err != nil {
-		log.Printf("%s.Handler - error marshalling dialog request: %v", handler, err)
+		log.Printf("%s.assignCommand - ref: %s, error: %v", handler, args[0], err)
+		return "Sorry, the bug could not be loaded."
+	}
+	email := userEmail(assignee)
+	if len(email) == 0 {
+		return fmt.Sprintf("Could not find the email of <@%s> to match a Jira account.", assignee)
+	}
+	jira := tracker.NewJira()
+	var lines []string
+	for _, issue := range bug.Issues {
+		if issue.Tracker != jira.Name() {
+			continue
+		}
+		if err = jira.Assign(issue, email); err != nil {
+			lines = append(lines, fmt.Sprintf("Could not assign %s: %v", issue.Key, err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Assigned %s to <@%s>", issue.Key, assignee))
+	}
+	if err = store.SetAssignee(bug, assignee); err != nil {
+		lines = append(lines, fmt.Sprintf("Could not record the assignment: %v", err))
 	} else {
-		req, reqErr := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(payload))
-		if reqErr != nil {
-			log.Printf("%s.Handler - error sending dialog request: %v", handler, reqErr)
-			err = reqErr
-		} else {
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+os.Getenv("SLACK_ACCESS_TOKEN"))
-			client := &http.Client{}
-			response, respErr := client.Do(req)
-			if respErr != nil {
-				log.Printf("%s.Handler - error receiving dialog response: %v", handler, reqErr)
-				err = respErr
-			} else {
-				defer response.Body.Close()
-				var status struct {
-					OK    bool   `json:"ok"`
-					Error string `json:"error"`
-				}
-				err = json.NewDecoder(response.Body).Decode(&status)
-				log.Printf("%s.Handler - ok: %t, error: %s, err: %v", handler, status.OK, status.Error, err)
+		assigned := bug
+		assigned.Assignee = assignee
+		_ = store.Audit(store.AuditEntry{
+			Subject: bug.ID,
+			Actor:   request.UserID,
+			Action:  store.AuditAssign,
+			Before:  store.Audited(bug),
+			After:   store.Audited(assigned),
+		})
+		if len(lines) == 0 {
+			lines = append(lines, fmt.Sprintf("Assigned bug %s to <@%s>", bug.ID, assignee))
+		}
+	}
+	text := fmt.Sprintf("<@%s> assigned this bug to <@%s>", request.UserID, assignee)
+	for _, thread := range bug.Threads() {
+		if err = post(thread.Channel, thread.TS, text); err != nil {
+			log.Printf("%s.assignCommand - thread: %+v, error: %v", handler, thread, err)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// userEmail look up the Slack user's email, empty when unavailable
+func userEmail(userID string) string {
+	email, _ := slackUser(userID)
+	return email
+}
+
+// userLocale look up the Slack user's locale, e.g. es-ES, empty when unavailable
+func userLocale(userID string) string {
+	_, locale := slackUser(userID)
+	return locale
+}
+
+// slackUser look up the Slack user's email and locale, empty when unavailable
+func slackUser(userID string) (email, locale string) {
+	user, err := slack.Default.UserInfo(userID)
+	if err != nil {
+		log.Printf("%s.slackUser - error: %v", handler, err)
+		return
+	}
+	return user.Profile.Email, user.Locale
+}
+
+// post send text to a Slack channel, as a reply when threadTS is set
+func post(channel, threadTS, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{
+		"channel":   channel,
+		"thread_ts": threadTS,
+		"text":      text,
+	})
+	return
+}
+
+// scopesCommand list the scopes the enabled features need that the bot token
+// of the workspace lacks
+func scopesCommand() string {
+	missing, err := scopes.Missing()
+	if err != nil {
+		return "Checking the scopes failed, please try again."
+	}
+	if len(missing) == 0 {
+		return fmt.Sprintf("The bot token has all %d scopes the enabled features need.", len(scopes.Required()))
+	}
+	lines := []string{"The bot token lacks these scopes, add them to the Slack app and reinstall it:"}
+	for _, scope := range scopes.Sorted(missing) {
+		lines = append(lines, fmt.Sprintf("• `%s` for %s", scope, strings.Join(missing[scope], ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// adminCommand handle `/kanobug admin product add <value> <label> [trackers=t1,t2]`,
+// `/kanobug admin product route <value> <t1,t2|default>`,
+// `/kanobug admin product template <value> <text|clear>`,
+// `/kanobug admin product remove <value>`, `/kanobug admin product list` and
+// `/kanobug admin scopes`
+func adminCommand(request Request, args []string) string {
+	if denied := authz.Require(request.UserID, authz.Admin, "manage kanobug"); len(denied) > 0 {
+		return denied
+	}
+	if len(args) > 0 && args[0] == "scopes" {
+		return scopesCommand()
+	}
+	if !store.ConfigEnabled() {
+		return "Product configuration is not enabled."
+	}
+	if len(args) > 0 && args[0] == "role" {
+		return roleCommand(request, args[1:])
+	}
+	if len(args) > 0 && args[0] == "tag" {
+		return tagCommand(request, args[1:])
+	}
+	usage := "Usage: `/kanobug admin product add <value> <label> [trackers=jira,webhook]`, " +
+		"`/kanobug admin product route <value> <trackers|default>`, `/kanobug admin product template <value> <text|clear>`, " +
+		"`/kanobug admin product remove <value>`, " +
+		"`/kanobug admin product list`, `/kanobug admin role grant|revoke|list`, `/kanobug admin tag add|remove|list` or `/kanobug admin scopes`"
+	if len(args) < 2 || args[0] != "product" {
+		return usage
+	}
+	switch action, args := args[1], args[2:]; action {
+	case "list":
+		configured, err := store.ListProducts()
+		if err != nil {
+			return "Listing products failed, please try again."
+		}
+		if len(configured) == 0 {
+			return "No products are configured, the built in list is offered."
+		}
+		var lines []string
+		for _, p := range configured {
+			routing := "default routing"
+			if len(p.Trackers) > 0 {
+				routing = strings.Join(p.Trackers, ", ")
+			}
+			line := fmt.Sprintf("• %s (`%s`) → %s", p.Label, p.Value, routing)
+			if len(p.Template) > 0 {
+				line += ", with a template"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	case "add":
+		if len(args) < 2 {
+			return usage
+		}
+		product := store.Product{Value: args[0]}
+		var label []string
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "trackers=") {
+				product.Trackers = trackerList(strings.TrimPrefix(arg, "trackers="))
+				continue
+			}
+			label = append(label, arg)
+		}
+		product.Label = strings.Join(label, " ")
+		if len(product.Label) == 0 {
+			return usage
+		}
+		if err := catalog.SeedProducts(); err != nil {
+			return "Adding the product failed, please try again."
+		}
+		if err := store.PutProduct(product); err != nil {
+			return "Adding the product failed, please try again."
+		}
+		audit(request, store.KindProduct+"/"+product.Value, "product add", nil, product)
+		return fmt.Sprintf("Added %s (`%s`).", product.Label, product.Value)
+	case "route":
+		if len(args) != 2 {
+			return usage
+		}
+		product, err := store.GetProduct(args[0])
+		if err == store.ErrNotFound {
+			return fmt.Sprintf("Unknown product `%s`.", args[0])
+		}
+		if err != nil {
+			return "Routing the product failed, please try again."
+		}
+		before := product
+		product.Trackers = nil
+		if args[1] != "default" {
+			product.Trackers = trackerList(args[1])
+		}
+		if err = store.PutProduct(product); err != nil {
+			return "Routing the product failed, please try again."
+		}
+		audit(request, store.KindProduct+"/"+product.Value, "product route", before, product)
+		return fmt.Sprintf("Routed `%s` to %s.", product.Value, args[1])
+	case "template":
+		if len(args) < 2 {
+			return usage
+		}
+		if err := catalog.SeedProducts(); err != nil {
+			return "Setting the template failed, please try again."
+		}
+		product, err := store.GetProduct(args[0])
+		if err == store.ErrNotFound {
+			return fmt.Sprintf("Unknown product `%s`.", args[0])
+		}
+		if err != nil {
+			return "Setting the template failed, please try again."
+		}
+		before := product
+		product.Template = ""
+		if len(args) > 2 || args[1] != "clear" {
+			// slash command text arrives on one line, \n breaks it
+			lines := strings.Split(strings.Join(args[1:], " "), `\n`)
+			for i := range lines {
+				lines[i] = strings.TrimSpace(lines[i])
 			}
+			product.Template = strings.Join(lines, "\n")
+		}
+		if err = store.PutProduct(product); err != nil {
+			return "Setting the template failed, please try again."
+		}
+		audit(request, store.KindProduct+"/"+product.Value, "product template", before, product)
+		if len(product.Template) == 0 {
+			return fmt.Sprintf("Cleared the template of `%s`.", product.Value)
+		}
+		return fmt.Sprintf("Reports of `%s` now start from:\n```%s```", product.Value, product.Template)
+	case "remove":
+		if len(args) != 1 {
+			return usage
+		}
+		if err := catalog.SeedProducts(); err != nil {
+			return "Removing the product failed, please try again."
+		}
+		before := existing(store.GetProduct(args[0]))
+		if err := store.DeleteProduct(args[0]); err != nil {
+			return "Removing the product failed, please try again."
+		}
+		audit(request, store.KindProduct+"/"+args[0], "product remove", before, nil)
+		return fmt.Sprintf("Removed `%s`.", args[0])
+	}
+	return usage
+}
+
+// roleCommand handle `/kanobug admin role grant @user <triager|admin>`,
+// `/kanobug admin role revoke @user` and `/kanobug admin role list`
+func roleCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug admin role grant @user <triager|admin>`, `/kanobug admin role revoke @user` or `/kanobug admin role list`"
+	if len(args) == 0 {
+		return usage
+	}
+	if args[0] == "list" {
+		grants, err := store.ListGrants()
+		if err != nil {
+			return "Listing roles failed, please try again."
+		}
+		if len(grants) == 0 {
+			return "No roles are granted in kanobug, user groups and KANOBUG_ADMINS still apply."
+		}
+		var lines []string
+		for _, g := range grants {
+			lines = append(lines, fmt.Sprintf("• <@%s> %s", g.UserID, g.Role))
+		}
+		return strings.Join(lines, "\n")
+	}
+	if len(args) < 2 {
+		return usage
+	}
+	match := userMention.FindStringSubmatch(args[1])
+	if match == nil {
+		return usage
+	}
+	switch args[0] {
+	case "grant":
+		if len(args) != 3 {
+			return usage
+		}
+		role, ok := authz.ParseRole(args[2])
+		if !ok || role == authz.Reporter {
+			return usage
 		}
+		before := existing(store.GetGrant(match[1]))
+		grant := store.Grant{UserID: match[1], Role: role.String(), GrantedBy: request.UserID}
+		if err := store.PutGrant(grant); err != nil {
+			return "Granting the role failed, please try again."
+		}
+		audit(request, store.KindRole+"/"+match[1], "role grant", before, grant)
+		return fmt.Sprintf("<@%s> is now a %s.", match[1], role)
+	case "revoke":
+		before := existing(store.GetGrant(match[1]))
+		if err := store.DeleteGrant(match[1]); err != nil {
+			return "Revoking the role failed, please try again."
+		}
+		audit(request, store.KindRole+"/"+match[1], "role revoke", before, nil)
+		return fmt.Sprintf("Revoked the kanobug role of <@%s>.", match[1])
+	}
+	return usage
+}
+
+// tagCommand handle `/kanobug admin tag add <tag>`, `/kanobug admin tag
+// remove <tag>` and `/kanobug admin tag list`, the tags offered in the report form
+func tagCommand(request Request, args []string) string {
+	usage := "Usage: `/kanobug admin tag add <tag>`, `/kanobug admin tag remove <tag>` or `/kanobug admin tag list`"
+	if len(args) == 1 && args[0] == "list" {
+		tags, err := store.ListTags()
+		if err != nil {
+			return "Listing tags failed, please try again."
+		}
+		if len(tags) == 0 {
+			return "No tags are configured, reporters can still add their own."
+		}
+		var names []string
+		for _, t := range tags {
+			names = append(names, "`"+t.Name+"`")
+		}
+		return "Tags offered: " + strings.Join(names, ", ")
+	}
+	if len(args) < 2 {
+		return usage
+	}
+	tags := store.NormalizeTags([]string{strings.Join(args[1:], " ")})
+	if len(tags) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "add":
+		tag := store.Tag{Name: tags[0], CreatedBy: request.UserID}
+		if err := store.PutTag(tag); err != nil {
+			return "Adding the tag failed, please try again."
+		}
+		audit(request, store.KindTag+"/"+tag.Name, "tag add", nil, tag)
+		return fmt.Sprintf("Added tag `%s`.", tag.Name)
+	case "remove":
+		if err := store.DeleteTag(tags[0]); err != nil {
+			return "Removing the tag failed, please try again."
+		}
+		audit(request, store.KindTag+"/"+tags[0], "tag remove", nil, nil)
+		return fmt.Sprintf("Removed tag `%s`, bugs keep it.", tags[0])
 	}
+	return usage
+}
+
+// audit record an admin action of the requesting user on the config entry
+// subject, before and after are nil for entries that did not or no longer exist
+func audit(request Request, subject, detail string, before, after interface{}) {
+	_ = store.Audit(store.AuditEntry{
+		Subject: subject,
+		Actor:   request.UserID,
+		Action:  store.AuditAdmin,
+		Detail:  detail,
+		Before:  before,
+		After:   after,
+	})
+}
+
+// existing return the config entry of a get for an audit before, nil when
+// there is none
+func existing(entry interface{}, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+func trackerList(value string) (trackers []string) {
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			trackers = append(trackers, t)
+		}
+	}
+	return
+}
 
-	resp := Response{
+// exportCommand handle `/kanobug export [csv|json] [product=p] [status=s]
+// [severity=s] [user=u] [since=2006-01-02] [until=2006-01-02]`
+func exportCommand(request Request, args []string) string {
+	if denied := authz.Require(request.UserID, authz.Admin, "export bugs"); len(denied) > 0 {
+		return denied
+	}
+	format := export.FormatCSV
+	var filter store.Filter
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) == 1 {
+			format = strings.ToLower(arg)
+			continue
+		}
+		var err error
+		switch kv[0] {
+		case "product":
+			filter.Product = kv[1]
+		case "status":
+			filter.Status = kv[1]
+		case "severity":
+			filter.Severity = kv[1]
+		case "user":
+			filter.UserID = kv[1]
+		case "since":
+			filter.Since, err = parseDate(kv[1])
+		case "until":
+			filter.Until, err = parseDate(kv[1])
+		default:
+			err = fmt.Errorf("unknown filter %s", kv[0])
+		}
+		if err != nil {
+			return fmt.Sprintf("Export failed: %v", err)
+		}
+	}
+	if format != export.FormatCSV && format != export.FormatJSON {
+		return "Export format must be csv or json."
+	}
+	bugs, err := store.AllBugs(filter)
+	if err != nil {
+		log.Printf("%s.Handler - export error: %v", handler, err)
+		return "Export failed, please try again."
+	}
+	link, err := export.Upload(format, bugs, exportTTL)
+	if err != nil {
+		log.Printf("%s.Handler - export upload error: %v", handler, err)
+		return "Export failed, please try again."
+	}
+	download := fmt.Sprintf("<%s|download the %s>", link, format)
+	if mattermost.IsCommandToken(request.Token) {
+		download = fmt.Sprintf("[download the %s](%s)", format, link)
+	}
+	return fmt.Sprintf("Exported %d bug(s), %s (link expires in %s).", len(bugs), download, exportTTL)
+}
+
+// configCommand handle `/kanobug config product <name> [hide]` and
+// `/kanobug config product clear`, mapping the channel to a product
+func configCommand(request Request, args []string) string {
+	if denied := authz.Require(request.UserID, authz.Admin, "configure channels"); len(denied) > 0 {
+		return denied
+	}
+	if !store.ConfigEnabled() {
+		return "Channel configuration is not enabled."
+	}
+	if len(args) == 0 || args[0] != "product" {
+		return "Usage: `/kanobug config product <name> [hide]` or `/kanobug config product clear`"
+	}
+	args = args[1:]
+	if len(args) == 0 {
+		channel, err := store.GetChannel(request.ChannelID)
+		if err != nil {
+			return "This channel has no default product."
+		}
+		return fmt.Sprintf("This channel reports %s bugs (hidden: %t).", channel.Product, channel.Hidden)
+	}
+	before := existing(store.GetChannel(request.ChannelID))
+	if len(args) == 1 && args[0] == "clear" {
+		if err := store.DeleteChannel(request.ChannelID); err != nil {
+			return "Clearing the channel product failed, please try again."
+		}
+		audit(request, store.KindChannel+"/"+request.ChannelID, "config product clear", before, nil)
+		return "This channel no longer has a default product."
+	}
+	hidden := args[len(args)-1] == "hide"
+	if hidden {
+		args = args[:len(args)-1]
+	}
+	name := strings.Join(args, " ")
+	product := lookupProduct(name)
+	if len(product) == 0 {
+		return fmt.Sprintf("Unknown product %q.", name)
+	}
+	channel := store.Channel{ID: request.ChannelID, Product: product, Hidden: hidden}
+	if err := store.PutChannel(channel); err != nil {
+		return "Saving the channel product failed, please try again."
+	}
+	audit(request, store.KindChannel+"/"+request.ChannelID, "config product", before, channel)
+	if hidden {
+		return fmt.Sprintf("Bugs reported here are now filed against %s without asking.", product)
+	}
+	return fmt.Sprintf("Bugs reported here now default to %s.", product)
+}
+
+// parseDate accept a date or an RFC3339 timestamp
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// reply return an ephemeral slash command response
+func reply(text string) Response {
+	body, _ := json.Marshal(map[string]string{
+		"response_type": "ephemeral",
+		"text":          text,
+	})
+	if len(text) == 0 {
+		// the command opened a modal, nothing to post
+		body = nil
+	}
+	return Response{
 		StatusCode:      200,
 		IsBase64Encoded: false,
-		Body:            "",
+		Body:            string(body),
 		Headers: map[string]string{
 			"Content-Type": "application/json",
 		},
 	}
+}
+
+// fail return the response telling the user about err in locale, ephemeral
+// and with the status code of its failure class
+func fail(err error, locale string) Response {
+	f := failure.From(err)
+	log.Printf("%s.Handler - %s, error: %v", handler, f.Class, f)
+	resp := reply(f.Text(locale))
+	resp.StatusCode = f.Status()
+	return resp
+}
+
+// mattermostDialog convert dialog to its Mattermost equivalent
+func mattermostDialog(dialog form.Dialog, state string) mattermost.Dialog {
+	converted := mattermost.Dialog{
+		CallbackID:  dialog.CallbackID,
+		Title:       dialog.Title,
+		SubmitLabel: dialog.SubmitLabel,
+		State:       state,
+	}
+	for _, e := range dialog.Elements {
+		if e.Type != "text" && e.Type != "textarea" && e.Type != "select" {
+			// Mattermost dialogs have no multi selects
+			continue
+		}
+		element := mattermost.Element{
+			DisplayName: e.Label,
+			Name:        e.Name,
+			Type:        e.Type,
+			SubType:     e.SubType,
+			Default:     e.Value,
+			HelpText:    e.Hint,
+			Optional:    e.Optional,
+		}
+		for _, o := range e.Options {
+			element.Options = append(element.Options, mattermost.Option{Text: o.Label, Value: o.Value})
+		}
+		converted.Elements = append(converted.Elements, element)
+	}
+	return converted
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	if health.IsPing(r.HTTPMethod, r.Path) {
+		report := health.Run(handler)
+		return Response{
+			StatusCode:      report.Status(),
+			IsBase64Encoded: false,
+			Body:            report.JSON(),
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+		}, nil
+	}
+	log.Printf("%s.Handler - invoke: %+v", handler, r)
+	query, err := url.ParseQuery(r.Body)
+	if err != nil {
+		return fail(failure.New(failure.BadRequest, "error.command.unreadable", err), ""), nil
+	}
+	request := Request{
+		Token:        query.Get("token"),
+		EnterpriseID: query.Get("enterprise_id"),
+		TeamID:       query.Get("team_id"),
+		TeamDomain:   query.Get("team_domain"),
+		ChannelID:    query.Get("channel_id"),
+		ChannelName:  query.Get("channel_name"),
+		UserID:       query.Get("user_id"),
+		UserName:     query.Get("user_name"),
+		Text:         query.Get("text"),
+		TriggerID:    query.Get("trigger_id"),
+		ResponseURL:  query.Get("response_url"),
+	}
+	log.Printf("%s.Handler - invoke: %+v, for: %s, trigger_id: %s", handler, request, request.Text, request.TriggerID)
+	if !slack.IsVerificationToken(request.Token) && !mattermost.IsCommandToken(request.Token) {
+		return fail(failure.New(failure.Unauthorized, "error.token", nil), ""), nil
+	}
+	if len(request.TeamID) == 0 || len(request.UserID) == 0 {
+		return fail(failure.New(failure.BadRequest, "error.command.no_user", nil), ""), nil
+	}
+	store.UseWorkspace(request.EnterpriseID, request.TeamID)
+	if blocked := authz.RequireChannel(request.TeamID, request.ChannelID); len(blocked) > 0 {
+		return reply(blocked), nil
+	}
+	if fields := strings.Fields(request.Text); len(fields) > 0 {
+		if command, ok := commands[strings.ToLower(fields[0])]; ok {
+			return reply(command(request, fields[1:])), nil
+		}
+	}
+	channel := form.ChannelProduct(request.ChannelID)
+	preference := form.UserPreference(request.UserID)
+	anonymous := flags.Enabled(flags.Anonymous, request.TeamID, channel.Product)
+	if mattermost.IsCommandToken(request.Token) {
+		// the Mattermost dialog state is taken by the signed response url
+		channel.Hidden = false
+		dialog := form.Report(request.Text, channel, preference, "")
+		form.SuggestSeverity(&dialog, request.Text, "")
+		form.InferProduct(&dialog, request.Text, channel, "")
+		form.ApplyTemplate(&dialog)
+		if !anonymous {
+			dialog.Remove("anonymous")
+		}
+		err = mattermost.OpenDialog(request.TriggerID, mattermostDialog(dialog, mattermost.SignState(request.ResponseURL, request.UserID, time.Now())))
+		log.Printf("%s.Handler - mattermost dialog error: %v", handler, err)
+		if err != nil {
+			return fail(failure.New(failure.Unavailable, "error.form.unavailable", err), ""), nil
+		}
+		return reply(""), nil
+	}
+	locale := userLocale(request.UserID)
+	dialog := form.Report(request.Text, channel, preference, locale)
+	form.SuggestSeverity(&dialog, request.Text, "")
+	form.InferProduct(&dialog, request.Text, channel, locale)
+	form.ApplyTemplate(&dialog)
+	if !anonymous {
+		dialog.Remove("anonymous")
+	}
+	form.RestoreDraft(&dialog, request.UserID)
+	err = form.Open(request.TriggerID, form.Modal(dialog, form.Metadata{
+		ResponseURL: request.ResponseURL,
+		ChannelID:   request.ChannelID,
+		Product:     dialog.State,
+		Locale:      locale,
+	}))
+	log.Printf("%s.Handler - modal error: %v", handler, err)
+	if err != nil {
+		return fail(failure.New(failure.Unavailable, "error.form.unavailable", err), locale), nil
+	}
+	return reply(""), nil
+}
 
-	return resp, nil
+// recovered serve r with Handler, answering a panic with an ephemeral reply
+// and keeping the summary given as command text as the user's draft
+func recovered(ctx context.Context, r ProxyRequest) (resp Response, err error) {
+	defer func() {
+		if !recovery.Recovered(handler, recover()) {
+			return
+		}
+		query, _ := url.ParseQuery(r.Body)
+		text, userID := strings.TrimSpace(query.Get("text")), query.Get("user_id")
+		command := ""
+		if fields := strings.Fields(text); len(fields) > 0 {
+			command = strings.ToLower(fields[0])
+		}
+		if _, ok := commands[command]; !ok && len(text) > 0 && len(userID) > 0 && store.ConfigEnabled() {
+			store.UseWorkspace(query.Get("enterprise_id"), query.Get("team_id"))
+			draftErr := store.PutDraft(store.Draft{UserID: userID, Values: map[string][]string{"summary": {text}}})
+			log.Printf("%s.Handler - draft: %s, error: %v", handler, userID, draftErr)
+		}
+		resp, err = reply(i18n.T("", "confirm.recovered")), nil
+	}()
+	return Handler(ctx, r)
 }
 
 func main() {
-	lambda.Start(Handler)
+	needs := []config.Need{config.Table, config.Slack, config.SlackRequests}
+	if flags.Offered(flags.Anonymous) {
+		needs = append(needs, config.Anonymous)
+	}
+	config.MustLoad(handler, needs...)
+	health.Warm()
+	scopes.Audit(handler)
+	lambda.Start(recovered)
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/dlq"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/slack"
+)
+
+const (
+	handler = "KanobugDLQ"
+	// sampled is how many failed messages an alert quotes
+	sampled = 5
+)
+
+// Alarm is the CloudWatch alarm state change published to the SNS topic
+type Alarm struct {
+	AlarmName      string `json:"AlarmName"`
+	NewStateValue  string `json:"NewStateValue"`
+	NewStateReason string `json:"NewStateReason"`
+}
+
+// alert return the ops channel text for the messages waiting on the queue
+func alert(depth int, messages []dlq.Message) string {
+	lines := []string{fmt.Sprintf(":rotating_light: %d failed notifier invocation(s) on the dead-letter queue", depth)}
+	for _, message := range messages {
+		lines = append(lines, fmt.Sprintf("• %s %s (%s): %s", message.SentAt.Format("2006-01-02 15:04"), message.DetailType(), message.ID, message.Error))
+	}
+	lines = append(lines, "Inspect with `kanobugctl dlq list`, replay with `kanobugctl dlq replay`")
+	return strings.Join(lines, "\n")
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// posting to OPS_CHANNEL when the alarm on the dead-letter queue goes off
+func Handler(ctx context.Context, event events.SNSEvent) error {
+	outbound.Use(ctx)
+	for _, record := range event.Records {
+		var alarm Alarm
+		if err := json.Unmarshal([]byte(record.SNS.Message), &alarm); err != nil {
+			log.Printf("%s.Handler - message: %s, error: %v", handler, record.SNS.MessageID, err)
+			continue
+		}
+		log.Printf("%s.Handler - alarm: %s, state: %s", handler, alarm.AlarmName, alarm.NewStateValue)
+		if alarm.NewStateValue != "ALARM" {
+			continue
+		}
+		depth, err := dlq.Depth()
+		if err != nil {
+			return err
+		}
+		messages, err := dlq.Peek(sampled)
+		if err != nil {
+			return err
+		}
+		channel := config.Get().OpsChannel
+		if len(channel) == 0 {
+			log.Printf("%s.Handler - %d message(s), no OPS_CHANNEL to alert", handler, depth)
+			continue
+		}
+		err = post(channel, alert(depth, messages))
+		log.Printf("%s.Handler - channel: %s, depth: %d, error: %v", handler, channel, depth, err)
+	}
+	return nil
+}
+
+// post send text to a Slack channel
+func post(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Slack)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/mattermost"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/quiet"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	handler = "KanobugDigest"
+)
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+// every 15 minutes, posting the status changes held for each digest
+// subscription once its interval has passed since the oldest of them. The
+// changes are kept until their digest is posted, a failed post is retried
+// on the next run. A user's digest due in their quiet hours is scheduled for
+// their end
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	entries, err := store.PendingDigests()
+	if err != nil {
+		return err
+	}
+	var channels []string
+	pending := map[string][]store.DigestEntry{}
+	for _, entry := range entries {
+		channel := entry.TeamID + "#" + entry.ChannelID
+		if _, ok := pending[channel]; !ok {
+			channels = append(channels, channel)
+		}
+		pending[channel] = append(pending[channel], entry)
+	}
+	posted := 0
+	for _, channel := range channels {
+		held := pending[channel]
+		// the latest subscription decides how often
+		if time.Since(held[0].At) < held[len(held)-1].Interval {
+			continue
+		}
+		text := digest(held)
+		// a user's digest waits out their quiet hours
+		if held[0].User {
+			if postAt, deferred := quiet.Defer(held[0].ChannelID, text, nil); deferred {
+				log.Printf("%s.Handler - channel: %s, changes: %d, scheduled: %s", handler, channel, len(held), postAt.UTC().Format(time.RFC3339))
+				posted++
+				_ = store.DeleteDigests(held)
+				continue
+			}
+		}
+		if held[0].Platform == "mattermost" {
+			err = mattermost.Post(held[0].ChannelID, text)
+		} else {
+			err = post(held[0].ChannelID, text)
+		}
+		log.Printf("%s.Handler - channel: %s, changes: %d, error: %v", handler, channel, len(held), err)
+		if err != nil {
+			continue
+		}
+		posted++
+		_ = store.DeleteDigests(held)
+	}
+	log.Printf("%s.Handler - pending: %d, channels: %d, posted: %d", handler, len(entries), len(channels), posted)
+	return nil
+}
+
+// digest return the compact digest of the status changes held for a
+// channel, one line per bug listing the statuses it went through
+func digest(held []store.DigestEntry) string {
+	var order []string
+	latest := map[string]store.DigestEntry{}
+	statuses := map[string][]string{}
+	for _, entry := range held {
+		if _, ok := latest[entry.BugID]; !ok {
+			order = append(order, entry.BugID)
+		}
+		latest[entry.BugID] = entry
+		statuses[entry.BugID] = append(statuses[entry.BugID], entry.Status)
+	}
+	lines := []string{fmt.Sprintf(":newspaper: %d status change(s) of %d bug(s) since %s UTC:", len(held), len(order), held[0].At.UTC().Format("Jan 2 15:04"))}
+	for _, id := range order {
+		entry := latest[id]
+		line := fmt.Sprintf("• %s bug %s %s", entry.Product, id, strings.Join(statuses[id], " → "))
+		if len(entry.Resolution) > 0 {
+			line += fmt.Sprintf(" (%s)", entry.Resolution)
+		}
+		lines = append(lines, line+": "+entry.Summary)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// post send text to a Slack channel
+func post(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Slack)
+	lambda.Start(Handler)
+}
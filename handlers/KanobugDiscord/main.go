@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	awslambda "github.com/aws/aws-sdk-go/service/lambda"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	handler    = "KanobugDiscord"
+	callbackID = "report-bug"
+
+	// followupURL is the message Discord shows for a deferred response,
+	// edited once the bug is filed
+	followupURL = "https://discord.com/api/v10/webhooks/%s/%s/messages/@original"
+)
+
+// Discord interaction and callback types
+const (
+	interactionPing        = 1
+	interactionCommand     = 2
+	interactionModalSubmit = 5
+
+	callbackPong            = 1
+	callbackMessage         = 4
+	callbackDeferredMessage = 5
+	callbackModal           = 9
+
+	flagEphemeral = 64
+)
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// Event is an interaction from API Gateway, or a Submission the Lambda
+// queued to itself
+type Event struct {
+	ProxyRequest
+	Submission *Submission `json:"submission,omitempty"`
+}
+
+// Submission is a submitted bug filed after Discord got its deferred
+// response, which has to come within 3 seconds, confirmed by editing that
+// response through the interaction's webhook
+type Submission struct {
+	ApplicationID string    `json:"application_id"`
+	Token         string    `json:"token"`
+	Bug           store.Bug `json:"bug"`
+}
+
+// Interaction is the Discord interaction payload
+type Interaction struct {
+	Type          int             `json:"type"`
+	ApplicationID string          `json:"application_id"`
+	Token         string          `json:"token"`
+	Data          InteractionData `json:"data"`
+	Member        *struct {
+		User User `json:"user"`
+	} `json:"member"`
+	User *User `json:"user"`
+}
+
+// InteractionData carries slash command options or modal components
+type InteractionData struct {
+	Name       string      `json:"name"`
+	CustomID   string      `json:"custom_id"`
+	Options    []Option    `json:"options"`
+	Components []Component `json:"components"`
+}
+
+// Option is a slash command option value
+type Option struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Component is a modal action row or text input
+type Component struct {
+	Type        int         `json:"type"`
+	CustomID    string      `json:"custom_id,omitempty"`
+	Style       int         `json:"style,omitempty"`
+	Label       string      `json:"label,omitempty"`
+	Value       string      `json:"value,omitempty"`
+	Placeholder string      `json:"placeholder,omitempty"`
+	Required    bool        `json:"required"`
+	MaxLength   int         `json:"max_length,omitempty"`
+	Components  []Component `json:"components,omitempty"`
+}
+
+// User is the Discord user invoking the interaction
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// sender return the invoking user, from the guild member or DM user
+func (interaction Interaction) sender() User {
+	if interaction.Member != nil {
+		return interaction.Member.User
+	}
+	if interaction.User != nil {
+		return *interaction.User
+	}
+	return User{}
+}
+
+// ToBug transform the modal submission to Bug
+func (interaction Interaction) ToBug() store.Bug {
+	// custom_id is report-bug:<product>:<severity>
+	parts := strings.SplitN(interaction.Data.CustomID, ":", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	values := map[string]string{}
+	for _, row := range interaction.Data.Components {
+		for _, c := range row.Components {
+			values[c.CustomID] = c.Value
+		}
+	}
+	details := values["details"]
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	user := interaction.sender()
+	now := time.Now()
+	return store.Bug{
+		Source:    "discord",
+		UserID:    user.ID,
+		UserName:  user.Username,
+		Summary:   values["summary"],
+		Product:   parts[1],
+		Severity:  parts[2],
+		Details:   details,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// by API Gateway for interactions and asynchronously by itself to file their
+// submissions
+func Handler(ctx context.Context, event Event) (Response, error) {
+	outbound.Use(ctx)
+	if event.Submission != nil {
+		file(*event.Submission)
+		return Response{}, nil
+	}
+	r := event.ProxyRequest
+	log.Printf("%s.Handler - invoke: %+v", handler, r)
+	if err := verify(r); err != nil {
+		log.Printf("%s.Handler - verify error: %v", handler, err)
+		return respond(401, map[string]string{"error": err.Error()}), nil
+	}
+	interaction := Interaction{}
+	if err := json.Unmarshal([]byte(r.Body), &interaction); err != nil {
+		log.Printf("%s.Handler - unmarshal interaction error: %+v", handler, err)
+		return respond(400, map[string]string{"error": "invalid interaction"}), nil
+	}
+
+	switch interaction.Type {
+	case interactionPing:
+		return respond(200, map[string]int{"type": callbackPong}), nil
+	case interactionCommand:
+		return respond(200, map[string]interface{}{"type": callbackModal, "data": reportModal(interaction.Data.Options)}), nil
+	case interactionModalSubmit:
+		if !strings.HasPrefix(interaction.Data.CustomID, callbackID+":") {
+			break
+		}
+		bug := interaction.ToBug()
+		if problem := invalidChoice(bug); len(problem) > 0 {
+			return message(problem), nil
+		}
+		err := queue(Submission{ApplicationID: interaction.ApplicationID, Token: interaction.Token, Bug: bug})
+		if err != nil {
+			log.Printf("%s.Handler - queue error: %v", handler, err)
+			return message("Sorry, your bug could not be submitted, please try again."), nil
+		}
+		return respond(200, map[string]interface{}{
+			"type": callbackDeferredMessage,
+			"data": map[string]interface{}{"flags": flagEphemeral},
+		}), nil
+	}
+	return respond(400, map[string]string{"error": "unsupported interaction"}), nil
+}
+
+// invalidChoice check the product and severity carried in the modal's
+// custom_id against the catalog, since Discord does not hold command options
+// to the registered choices, returning what to tell the reporter when either
+// is unknown
+func invalidChoice(bug store.Bug) string {
+	known := false
+	for _, p := range catalog.ProductOptions() {
+		known = known || p.Value == bug.Product
+	}
+	if !known {
+		return fmt.Sprintf("Unknown product %q, please pick one of the listed products.", bug.Product)
+	}
+	for _, s := range catalog.Severities {
+		if s.Value == bug.Severity {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Unknown severity %q, please pick one of the listed severities.", bug.Severity)
+}
+
+// queue invoke this Lambda with submission without waiting for it to be
+// filed, which takes longer than Discord waits for an interaction response
+func queue(submission Submission) error {
+	payload, err := json.Marshal(Event{Submission: &submission})
+	if err != nil {
+		return err
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return err
+	}
+	_, err = awslambda.New(sess).Invoke(&awslambda.InvokeInput{
+		FunctionName:   aws.String(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")),
+		InvocationType: aws.String(awslambda.InvocationTypeEvent),
+		Payload:        payload,
+	})
+	return err
+}
+
+// file store and file the bug of submission, then replace the deferred
+// response with the filed issues
+func file(submission Submission) {
+	bug := submission.Bug
+	err := pipeline.Store(&bug)
+	log.Printf("%s.file - bug: %+v, error: %v", handler, bug, err)
+	var lines []string
+	for _, issue := range pipeline.File(bug, "") {
+		lines = append(lines, issue.Text())
+	}
+	if len(lines) == 0 && bug.Queued {
+		lines = append(lines, pipeline.QueuedText(bug))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Thanks, your bug has been received.")
+	}
+	err = followup(submission, strings.Join(lines, "\n"))
+	log.Printf("%s.file - followup error: %v", handler, err)
+}
+
+// followup edit the deferred response of submission to content
+func followup(submission Submission, content string) error {
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf(followupURL, submission.ApplicationID, submission.Token)
+	req, err := http.NewRequest("PATCH", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := outbound.Do(outbound.Discord, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// message return an ephemeral reply of content
+func message(content string) Response {
+	return respond(200, map[string]interface{}{
+		"type": callbackMessage,
+		"data": map[string]interface{}{"content": content, "flags": flagEphemeral},
+	})
+}
+
+// reportModal return the modal opened by /bug, product and severity are
+// picked as command options since modals only hold text inputs
+func reportModal(options []Option) map[string]interface{} {
+	values := map[string]string{"severity": catalog.DefaultSeverity}
+	for _, o := range options {
+		values[o.Name] = o.Value
+	}
+	return map[string]interface{}{
+		"custom_id": strings.Join([]string{callbackID, values["product"], values["severity"]}, ":"),
+		"title":     "Report a Bug",
+		"components": []Component{
+			Component{Type: 1, Components: []Component{
+				Component{Type: 4, CustomID: "summary", Style: 1, Label: "Summarise the Problem", Value: values["summary"], Placeholder: "A sentence to summarise the problem", Required: true, MaxLength: 150},
+			}},
+			Component{Type: 1, Components: []Component{
+				Component{Type: 4, CustomID: "details", Style: 2, Label: "Any more details?", Placeholder: "If you can help us reproduce the bug, that'd be grand.", MaxLength: 4000},
+			}},
+		},
+	}
+}
+
+// verify check the Ed25519 signature Discord sends with every interaction
+func verify(r ProxyRequest) error {
+	key, err := hex.DecodeString(os.Getenv("DISCORD_PUBLIC_KEY"))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	signature, err := hex.DecodeString(header(r, "X-Signature-Ed25519"))
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+	message := header(r, "X-Signature-Timestamp") + r.Body
+	if !ed25519.Verify(ed25519.PublicKey(key), []byte(message), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func respond(status int, body interface{}) Response {
+	payload, _ := json.Marshal(body)
+	return Response{
+		StatusCode:      status,
+		IsBase64Encoded: false,
+		Body:            string(payload),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+func header(r ProxyRequest, name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
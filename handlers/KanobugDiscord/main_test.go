@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"type":1}`
+	sign := func(key ed25519.PrivateKey, timestamp, body string) string {
+		return hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+body)))
+	}
+	request := func(signature, timestamp, body string) ProxyRequest {
+		return ProxyRequest{
+			Headers: map[string]string{"x-signature-ed25519": signature, "x-signature-timestamp": timestamp},
+			Body:    body,
+		}
+	}
+	tests := []struct {
+		name      string
+		publicKey string
+		r         ProxyRequest
+		valid     bool
+	}{
+		{"valid", hex.EncodeToString(public), request(sign(private, "1700000000", body), "1700000000", body), true},
+		{"no public key", "", request(sign(private, "1700000000", body), "1700000000", body), false},
+		{"short public key", hex.EncodeToString(public[:16]), request(sign(private, "1700000000", body), "1700000000", body), false},
+		{"other signer", hex.EncodeToString(public), request(sign(other, "1700000000", body), "1700000000", body), false},
+		{"other timestamp", hex.EncodeToString(public), request(sign(private, "1700000000", body), "1700000001", body), false},
+		{"other body", hex.EncodeToString(public), request(sign(private, "1700000000", body), "1700000000", `{"type":2}`), false},
+		{"no signature", hex.EncodeToString(public), request("", "1700000000", body), false},
+		{"malformed signature", hex.EncodeToString(public), request("zz", "1700000000", body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DISCORD_PUBLIC_KEY", tt.publicKey)
+			if err := verify(tt.r); (err == nil) != tt.valid {
+				t.Errorf("verify() = %v, want valid: %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestInvalidChoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		product  string
+		severity string
+		valid    bool
+	}{
+		{"known", "pixel_kit", "major", true},
+		{"unknown product", "pixel_kit:major", "major", false},
+		{"no product", "", "major", false},
+		{"unknown severity", "pixel_kit", "apocalyptic", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := invalidChoice(store.Bug{Product: tt.product, Severity: tt.severity})
+			if (len(problem) == 0) != tt.valid {
+				t.Errorf("invalidChoice(%q, %q) = %q, want valid: %v", tt.product, tt.severity, problem, tt.valid)
+			}
+		})
+	}
+}
+
+func TestEvent(t *testing.T) {
+	var interaction Event
+	if err := json.Unmarshal([]byte(`{"httpMethod":"POST","path":"/discord/interactions","body":"{}"}`), &interaction); err != nil {
+		t.Fatal(err)
+	}
+	if interaction.Submission != nil || interaction.Path != "/discord/interactions" {
+		t.Errorf("API Gateway event = %+v, want an interaction", interaction)
+	}
+
+	payload, err := json.Marshal(Event{Submission: &Submission{ApplicationID: "app", Token: "token", Bug: store.Bug{Product: "pixel_kit"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queued Event
+	if err := json.Unmarshal(payload, &queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued.Submission == nil || queued.Submission.Token != "token" || queued.Submission.Bug.Product != "pixel_kit" {
+		t.Errorf("queued event = %+v, want the submission", queued)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ses"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+const (
+	handler = "KanobugEmailIntake"
+	// maxAttachment is Jira Cloud's default attachment size limit
+	maxAttachment = 10 << 20
+)
+
+// productTag matches a leading "[Pixel Kit]" style tag in the subject
+var productTag = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*`)
+
+// Email is an inbound bug report parsed from the raw MIME message
+type Email struct {
+	FromName    string
+	FromAddress string
+	Subject     string
+	Body        string
+	Recipients  []string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to the email
+type Attachment struct {
+	Name    string
+	Content []byte
+}
+
+// ToBug transform the email to Bug, taking the product from the subject tag
+// or the recipient's +product address
+func (email Email) ToBug() store.Bug {
+	summary := email.Subject
+	product := ""
+	if m := productTag.FindStringSubmatch(summary); m != nil {
+		product = lookupProduct(m[1])
+		summary = productTag.ReplaceAllString(summary, "")
+	}
+	for _, r := range email.Recipients {
+		if len(product) > 0 {
+			break
+		}
+		local := strings.SplitN(r, "@", 2)[0]
+		if i := strings.Index(local, "+"); i >= 0 {
+			product = lookupProduct(local[i+1:])
+		}
+	}
+	if len(product) == 0 {
+		product = os.Getenv("EMAIL_INTAKE_DEFAULT_PRODUCT")
+	}
+	details := strings.TrimSpace(email.Body)
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	name := email.FromName
+	if len(name) == 0 {
+		name = email.FromAddress
+	}
+	now := time.Now()
+	return store.Bug{
+		Source:    "email",
+		UserID:    strings.ToLower(email.FromAddress),
+		UserName:  name,
+		Summary:   strings.TrimSpace(summary),
+		Product:   product,
+		Severity:  catalog.DefaultSeverity,
+		Details:   details,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// lookupProduct match a product value or label, case insensitively
+func lookupProduct(name string) string {
+	name = strings.TrimSpace(name)
+	for _, p := range catalog.ProductOptions() {
+		if strings.EqualFold(p.Value, name) || strings.EqualFold(p.Label, name) {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Handler is our lambda handler invoked by the SES receipt rule, after the
+// rule's S3 action stored the raw message
+func Handler(ctx context.Context, e events.SimpleEmailEvent) error {
+	outbound.Use(ctx)
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return err
+	}
+	for _, record := range e.Records {
+		if err := process(sess, record.SES); err != nil {
+			log.Printf("%s.Handler - message: %s, error: %v", handler, record.SES.Mail.MessageID, err)
+		}
+	}
+	return nil
+}
+
+func process(sess *session.Session, message events.SimpleEmailService) error {
+	receipt := message.Receipt
+	if err := authenticated(receipt); err != nil {
+		return err
+	}
+
+	object, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(os.Getenv("EMAIL_INTAKE_BUCKET")),
+		Key:    aws.String(os.Getenv("EMAIL_INTAKE_PREFIX") + message.Mail.MessageID),
+	})
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+	email, err := parse(object.Body)
+	if err != nil {
+		return err
+	}
+	email.Recipients = receipt.Recipients
+	if !allowedSender(email.FromAddress) {
+		return fmt.Errorf("sender not allowed: %s", email.FromAddress)
+	}
+
+	bug := email.ToBug()
+	if len(bug.Summary) == 0 || len(bug.Product) == 0 {
+		return reply(sess, email, "We couldn't file your bug: please put a summary in the subject and a product tag, e.g. \"[Pixel Kit] Won't pair\".")
+	}
+	if err = pipeline.Store(&bug); err != nil {
+		return err
+	}
+	issues := pipeline.File(bug, email.FromAddress)
+	skipped := attach(issues, email.Attachments)
+
+	lines := []string{"Thanks, your bug has been received."}
+	for _, issue := range issues {
+		lines = append(lines, issue.Text())
+	}
+	for _, name := range skipped {
+		lines = append(lines, "Attachment not uploaded: "+name)
+	}
+	if bug.Queued {
+		lines = append(lines, pipeline.QueuedText(bug))
+		if len(email.Attachments) > 0 {
+			lines = append(lines, "Attachments are not kept for queued bugs, please add them to the issue once it is filed.")
+		}
+	}
+	return reply(sess, email, strings.Join(lines, "\n"))
+}
+
+// authenticated refuse messages failing the spam/virus scans or DMARC, a
+// DMARC pass means SPF or DKIM aligned with the From domain, so the header
+// sender can be trusted and replied to without backscatter
+func authenticated(receipt events.SimpleEmailReceipt) error {
+	if receipt.SpamVerdict.Status == "FAIL" || receipt.VirusVerdict.Status == "FAIL" {
+		return errors.New("rejected by spam/virus verdict")
+	}
+	if receipt.DMARCVerdict.Status != "PASS" {
+		return fmt.Errorf("sender not authenticated by DMARC: %s", receipt.DMARCVerdict.Status)
+	}
+	return nil
+}
+
+// attach upload the email attachments to every issue whose tracker accepts
+// files and return the names of those that were skipped
+func attach(issues []tracker.Issue, attachments []Attachment) (skipped []string) {
+	for _, a := range attachments {
+		if len(a.Content) > maxAttachment {
+			skipped = append(skipped, a.Name+" (too large)")
+			continue
+		}
+		for _, issue := range issues {
+			attacher, ok := tracker.ByName(issue.Tracker).(tracker.Attacher)
+			if !ok {
+				continue
+			}
+			if err := attacher.Attach(issue, a.Name, bytes.NewReader(a.Content)); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s (%s upload failed)", a.Name, issue.Tracker))
+			}
+		}
+	}
+	return
+}
+
+// allowedSender check the sender domain against EMAIL_INTAKE_DOMAINS, any
+// sender is allowed when it is empty
+func allowedSender(address string) bool {
+	domains := os.Getenv("EMAIL_INTAKE_DOMAINS")
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	for _, d := range strings.Split(domains, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), address[at+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse read the raw MIME message into an Email
+func parse(raw io.Reader) (email Email, err error) {
+	msg, err := mail.ReadMessage(raw)
+	if err != nil {
+		return
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return
+	}
+	email.FromName, email.FromAddress = from.Name, from.Address
+	decoder := new(mime.WordDecoder)
+	if email.Subject, err = decoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		email.Subject, err = msg.Header.Get("Subject"), nil
+	}
+	err = walk(&email, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), "", msg.Body)
+	return
+}
+
+// walk collect the first text/plain part as body and any named part as an
+// attachment, descending into nested multiparts
+func walk(email *Email, contentType, encoding, disposition string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = walk(email, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Disposition"), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := ioutil.ReadAll(decode(encoding, body))
+	if err != nil {
+		return err
+	}
+	_, dispositionParams, _ := mime.ParseMediaType(disposition)
+	name := dispositionParams["filename"]
+	if len(name) == 0 {
+		name = params["name"]
+	}
+	switch {
+	case len(name) > 0:
+		email.Attachments = append(email.Attachments, Attachment{Name: name, Content: content})
+	case mediaType == "text/plain" && len(email.Body) == 0:
+		email.Body = string(content)
+	}
+	return nil
+}
+
+func decode(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// reply email text back to the reporter
+func reply(sess *session.Session, email Email, text string) error {
+	_, err := ses.New(sess).SendEmail(&ses.SendEmailInput{
+		Source:      aws.String(os.Getenv("EMAIL_FROM")),
+		Destination: &ses.Destination{ToAddresses: []*string{aws.String(email.FromAddress)}},
+		Message: &ses.Message{
+			Subject: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String("Re: " + email.Subject)},
+			Body: &ses.Body{
+				Text: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(text)},
+			},
+		},
+	})
+	log.Printf("%s.reply - to: %s, error: %v", handler, email.FromAddress, err)
+	return err
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestAuthenticated(t *testing.T) {
+	verdict := func(status string) events.SimpleEmailVerdict {
+		return events.SimpleEmailVerdict{Status: status}
+	}
+	tests := []struct {
+		name    string
+		receipt events.SimpleEmailReceipt
+		ok      bool
+	}{
+		{"dmarc pass", events.SimpleEmailReceipt{DMARCVerdict: verdict("PASS"), SPFVerdict: verdict("PASS")}, true},
+		{"spf pass without dmarc", events.SimpleEmailReceipt{SPFVerdict: verdict("PASS"), DKIMVerdict: verdict("PASS"), DMARCVerdict: verdict("FAIL")}, false},
+		{"dmarc missing", events.SimpleEmailReceipt{SPFVerdict: verdict("PASS")}, false},
+		{"dmarc gray", events.SimpleEmailReceipt{DMARCVerdict: verdict("GRAY")}, false},
+		{"spam", events.SimpleEmailReceipt{DMARCVerdict: verdict("PASS"), SpamVerdict: verdict("FAIL")}, false},
+		{"virus", events.SimpleEmailReceipt{DMARCVerdict: verdict("PASS"), VirusVerdict: verdict("FAIL")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authenticated(tt.receipt)
+			if (err == nil) != tt.ok {
+				t.Errorf("authenticated() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAllowedSender(t *testing.T) {
+	t.Setenv("EMAIL_INTAKE_DOMAINS", "example.com, Kano.me")
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"jo@example.com", true},
+		{"jo@KANO.me", true},
+		{"jo@example.com.evil.io", false},
+		{"jo@evil.io", false},
+		{"nobody", false},
+	}
+	for _, tt := range tests {
+		if got := allowedSender(tt.address); got != tt.want {
+			t.Errorf("allowedSender(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: Jo Bloggs <jo@example.com>",
+		"Subject: [Pixel Kit] Won't pair",
+		"Content-Type: multipart/mixed; boundary=b",
+		"",
+		"--b",
+		"Content-Type: text/plain",
+		"",
+		"It blinks red.",
+		"--b",
+		"Content-Type: text/plain; name=log.txt",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"aGVsbG8=",
+		"--b--",
+		"",
+	}, "\r\n")
+	email, err := parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.FromAddress != "jo@example.com" || email.FromName != "Jo Bloggs" {
+		t.Errorf("from = %q <%s>", email.FromName, email.FromAddress)
+	}
+	if strings.TrimSpace(email.Body) != "It blinks red." {
+		t.Errorf("body = %q", email.Body)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Name != "log.txt" || string(email.Attachments[0].Content) != "hello" {
+		t.Errorf("attachments = %+v", email.Attachments)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/escalate"
+	"github.com/anzellai/kanobug/internal/eventbus"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const handler = "KanobugEscalate"
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+// every 15 minutes, escalating the open bugs of every team up the
+// ESCALATIONS ladder. Each escalation is published as a BugEscalated event
+// for KanobugNotifier to ping or page, and recorded on the bug so no rule
+// escalates it twice
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	rules, err := escalate.Rules()
+	if err != nil || len(rules) == 0 {
+		log.Printf("%s.Handler - rules: %d, error: %v", handler, len(rules), err)
+		return err
+	}
+	bugs, err := store.ScanBugs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	escalated := 0
+	for _, bug := range bugs {
+		due := escalate.Due(bug, rules, now)
+		if len(due) == 0 {
+			continue
+		}
+		names := append([]string{}, bug.Escalated...)
+		for _, rule := range due {
+			err = eventbus.Publish(eventbus.BugEscalated, eventbus.EscalatedDetail{
+				Bug:       bug,
+				Rule:      rule.Name,
+				Condition: rule.Condition,
+				After:     after(rule.After),
+				Action:    rule.Action,
+			})
+			log.Printf("%s.Handler - bug: %s/%s, rule: %s, error: %v", handler, bug.TeamID, bug.ID, rule.Name, err)
+			if err == nil {
+				names = append(names, rule.Name)
+				escalated++
+			}
+		}
+		if len(names) > len(bug.Escalated) {
+			store.UseWorkspace(bug.EnterpriseID, bug.TeamID)
+			_ = store.SetEscalated(bug, names)
+		}
+	}
+	log.Printf("%s.Handler - bugs: %d, rules: %d, escalated: %d", handler, len(bugs), len(rules), escalated)
+	return nil
+}
+
+// after return d without its zero minutes and seconds, e.g. 24h for 24h0m0s
+func after(d time.Duration) string {
+	return strings.Replace(strings.Replace(d.String(), "m0s", "m", 1), "h0m", "h", 1)
+}
+
+func main() {
+	config.MustLoad(handler, config.Table)
+	lambda.Start(Handler)
+}
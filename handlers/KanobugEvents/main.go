@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+	"github.com/anzellai/kanobug/internal/workflow"
+)
+
+const handler = "KanobugEvents"
+
+// jiraKey matches the issue key of both /browse/IQ-1 and /projects/IQ/issues/IQ-1 links
+var jiraKey = regexp.MustCompile(`/(?:browse|issues)/([A-Z][A-Z0-9]+-[0-9]+)`)
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// Request is a Slack Events API callback
+type Request struct {
+	Token        string `json:"token"`
+	Type         string `json:"type"`
+	Challenge    string `json:"challenge"`
+	EnterpriseID string `json:"enterprise_id"`
+	TeamID       string `json:"team_id"`
+	EventID      string `json:"event_id"`
+	Event        Event  `json:"event"`
+}
+
+// Event is the inner event of an event_callback
+type Event struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel"`
+	MessageTS string `json:"message_ts"`
+	Links     []struct {
+		Domain string `json:"domain"`
+		URL    string `json:"url"`
+	} `json:"links"`
+	WorkflowStep workflow.Step `json:"workflow_step"`
+}
+
+func respond(status int, body string) Response {
+	return Response{
+		StatusCode:      status,
+		IsBase64Encoded: false,
+		Body:            body,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %s", handler, r.Body)
+	var request Request
+	if err := json.Unmarshal([]byte(r.Body), &request); err != nil {
+		return respond(400, `{"error":"invalid payload"}`), nil
+	}
+	if !slack.IsVerificationToken(request.Token) {
+		return respond(400, `{"error":"invalid verification token"}`), nil
+	}
+	switch request.Type {
+	case "url_verification":
+		body, _ := json.Marshal(map[string]string{"challenge": request.Challenge})
+		return respond(200, string(body)), nil
+	case "event_callback":
+		key := eventKey(request, r.Body)
+		duplicate, claimed := claim(r, key)
+		if duplicate {
+			emf.Count(emf.DedupeHits, emf.Dimensions{"Handler": handler})
+			return respond(200, ""), nil
+		}
+		store.UseWorkspace(request.EnterpriseID, request.TeamID)
+		var err error
+		switch request.Event.Type {
+		case "link_shared":
+			err = unfurl(request.Event)
+		case "workflow_step_execute":
+			execute(request.TeamID, request.Event.WorkflowStep)
+		}
+		if claimed {
+			settle(key, err)
+		}
+	}
+	return respond(200, ""), nil
+}
+
+// eventKey return the key an event is claimed by, its event_id, unique
+// across the redeliveries of an event, or the hash of the payload without
+func eventKey(request Request, body string) string {
+	if len(request.EventID) > 0 {
+		return request.EventID
+	}
+	return store.PayloadKey(body)
+}
+
+// claim report whether r redelivers an event an earlier delivery handled, or
+// is handling, and whether this delivery claimed it to handle. Slack retries
+// events it got no 200 for within 3 seconds, with X-Slack-Retry-Num and
+// X-Slack-Retry-Reason, so the retry of a delivery that failed, or died
+// mid-way, is handled. Without the table, or when the claim fails, every
+// retry is taken for a duplicate
+func claim(r ProxyRequest, key string) (duplicate, claimed bool) {
+	retry := header(r, "X-Slack-Retry-Num")
+	if !store.ConfigEnabled() {
+		return len(retry) > 0, false
+	}
+	claimed, err := store.Claim(handler, key)
+	if err != nil {
+		return len(retry) > 0, false
+	}
+	if !claimed || len(retry) > 0 {
+		log.Printf("%s.claim - event: %s, retry: %s, reason: %s, duplicate: %t", handler, key, retry, header(r, "X-Slack-Retry-Reason"), !claimed)
+	}
+	return !claimed, claimed
+}
+
+// settle mark the event claimed as key done once handled, or release it for
+// the next redelivery when handling failed with err
+func settle(key string, err error) {
+	if err != nil {
+		_ = store.Release(handler, key)
+		return
+	}
+	_ = store.Done(handler, key)
+}
+
+// header return the value of header name of r, whatever its case
+func header(r ProxyRequest, name string) string {
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// unfurl attach a card with the issue summary, status, assignee and reporter
+// to every Jira issue link in the message
+func unfurl(event Event) error {
+	jira := tracker.NewJira()
+	unfurls := map[string]interface{}{}
+	for _, link := range event.Links {
+		u, err := url.Parse(link.URL)
+		if err != nil || u.Host != jira.Host {
+			continue
+		}
+		match := jiraKey.FindStringSubmatch(u.Path)
+		if match == nil {
+			continue
+		}
+		issue, err := jira.Issue(match[1])
+		if err != nil {
+			log.Printf("%s.unfurl - issue: %s, error: %v", handler, match[1], err)
+			continue
+		}
+		assignee, reporter := "Unassigned", "Unknown"
+		if issue.Fields.Assignee != nil {
+			assignee = issue.Fields.Assignee.DisplayName
+		}
+		if issue.Fields.Reporter != nil {
+			reporter = issue.Fields.Reporter.DisplayName
+		}
+		unfurls[link.URL] = map[string]interface{}{
+			"title":      fmt.Sprintf("%s: %s", issue.Key, issue.Fields.Summary),
+			"title_link": link.URL,
+			"color":      "#0052CC",
+			"fields": []map[string]interface{}{
+				{"title": "Status", "value": issue.Fields.StatusName(), "short": true},
+				{"title": "Assignee", "value": assignee, "short": true},
+				{"title": "Reporter", "value": reporter, "short": true},
+			},
+		}
+	}
+	if len(unfurls) == 0 {
+		return nil
+	}
+	err := slack.Default.Unfurl(event.Channel, event.MessageTS, unfurls)
+	log.Printf("%s.unfurl - links: %d, error: %v", handler, len(unfurls), err)
+	return err
+}
+
+// execute file the bug of a "File a Kanobug" workflow step of team and
+// complete the step with its issue
+func execute(team string, step workflow.Step) {
+	bug := workflow.ToBug(step)
+	bug.TeamID = team
+	if len(bug.Summary) == 0 {
+		_ = workflow.Fail(step.ExecuteID, "A summary is required to file a Kanobug")
+		return
+	}
+	if err := pipeline.Store(&bug); err != nil {
+		log.Printf("%s.execute - store error: %v", handler, err)
+		_ = workflow.Fail(step.ExecuteID, "The bug could not be stored, please try again")
+		return
+	}
+	outputs := map[string]string{"bug_id": bug.ID}
+	for _, issue := range pipeline.File(bug, "") {
+		if len(issue.Key) > 0 && len(outputs["issue_key"]) == 0 {
+			outputs["issue_key"], outputs["issue_url"] = issue.Key, issue.URL
+		}
+	}
+	_ = workflow.Complete(step.ExecuteID, outputs)
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Slack, config.SlackRequests, config.Trackers)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+func TestEventKey(t *testing.T) {
+	body := `{"type":"event_callback","event_id":"Ev0123ABC","event":{"type":"link_shared"}}`
+	tests := []struct {
+		name    string
+		request Request
+		body    string
+		want    string
+	}{
+		{"event id", Request{EventID: "Ev0123ABC"}, body, "Ev0123ABC"},
+		{"event id whatever the body", Request{EventID: "Ev0123ABC"}, body + "\n", "Ev0123ABC"},
+		{"payload hash without event id", Request{}, body, store.PayloadKey(body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventKey(tt.request, tt.body); got != tt.want {
+				t.Errorf("eventKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	gqlhandler "github.com/99designs/gqlgen/handler"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/graph"
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+const (
+	handler = "KanobugGraphQL"
+
+	// complexityLimit bounds a single query, every aggregation pages through
+	// the matching bugs
+	complexityLimit = 200
+)
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+var server = gqlhandler.GraphQL(
+	graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}),
+	gqlhandler.ComplexityLimit(complexityLimit),
+)
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// API keys are enforced by API Gateway before it is reached
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %s %s", handler, r.HTTPMethod, r.Path)
+	req, err := http.NewRequest(r.HTTPMethod, target(r), strings.NewReader(r.Body))
+	if err != nil {
+		log.Printf("%s.Handler - request error: %v", handler, err)
+		return Response{
+			StatusCode:      400,
+			IsBase64Encoded: false,
+			Body:            `{"error":"invalid request"}`,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+		}, nil
+	}
+	req = req.WithContext(ctx)
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := &responseWriter{header: http.Header{}}
+	server.ServeHTTP(w, req)
+	return Response{
+		StatusCode:      w.status(),
+		IsBase64Encoded: false,
+		Body:            w.body.String(),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// target rebuild the request url, re-encoding the query parameters API
+// Gateway has already decoded
+func target(r ProxyRequest) string {
+	u := url.URL{Scheme: "https", Host: "localhost", Path: r.Path}
+	if len(r.QueryStringParameters) > 0 {
+		query := url.Values{}
+		for k, v := range r.QueryStringParameters {
+			query.Set(k, v)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// responseWriter buffer the gqlgen response for the proxy integration
+type responseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *responseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+func main() {
+	config.MustLoad(handler, config.Table)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	w := &responseWriter{header: http.Header{}}
+	if w.status() != http.StatusOK {
+		t.Errorf("status() = %d before WriteHeader, want 200", w.status())
+	}
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"errors":[]}`))
+	if w.status() != http.StatusUnprocessableEntity {
+		t.Errorf("status() = %d, want the first code written", w.status())
+	}
+	if w.body.String() != `{"errors":[]}` {
+		t.Errorf("body = %q", w.body.String())
+	}
+}
+
+func TestTarget(t *testing.T) {
+	r := ProxyRequest{
+		Path: "/graphql",
+		QueryStringParameters: map[string]string{
+			"query":     `{ bugs(product: "a&b=c") { id } }`,
+			"variables": `{"x":1}`,
+		},
+	}
+	req, err := http.NewRequest("GET", target(r), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := req.URL.Query()
+	if len(query) != 2 {
+		t.Fatalf("query = %v, want 2 parameters", query)
+	}
+	for k, v := range r.QueryStringParameters {
+		if query.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, query.Get(k), v)
+		}
+	}
+	if req.URL.Path != "/graphql" {
+		t.Errorf("path = %q", req.URL.Path)
+	}
+}
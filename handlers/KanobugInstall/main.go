@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	handler     = "KanobugInstall"
+	authorize   = "https://slack.com/oauth/v2/authorize"
+	oauthAccess = "https://slack.com/api/oauth.v2.access"
+)
+
+// stateTTL is how long an install started at /slack/install may take
+const stateTTL = 10 * time.Minute
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// access is the oauth.v2.access reply, Team being empty for org-wide installs
+type access struct {
+	OK                  bool   `json:"ok"`
+	Error               string `json:"error"`
+	AccessToken         string `json:"access_token"`
+	Scope               string `json:"scope"`
+	BotUserID           string `json:"bot_user_id"`
+	IsEnterpriseInstall bool   `json:"is_enterprise_install"`
+	Team                *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+	Enterprise *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"enterprise"`
+	AuthedUser struct {
+		ID string `json:"id"`
+	} `json:"authed_user"`
+}
+
+func respond(status int, text string) Response {
+	return Response{
+		StatusCode: status,
+		Body:       "<!DOCTYPE html><html><body><p>" + html.EscapeString(text) + "</p></body></html>",
+		Headers: map[string]string{
+			"Content-Type": "text/html; charset=utf-8",
+		},
+	}
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// installing kanobug in a Slack workspace or, when an Enterprise Grid admin
+// installs it org-wide, in every workspace of the org: /slack/install sends
+// the installer to Slack and /slack/oauth stores the bot token of the install
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	if len(config.Get().SlackClientID) == 0 || len(config.Get().SlackClientSecret) == 0 {
+		return respond(404, "Installing is not set up."), nil
+	}
+	if strings.HasSuffix(r.Path, "/install") {
+		query := url.Values{
+			"client_id": {config.Get().SlackClientID},
+			"scope":     {os.Getenv("SLACK_SCOPES")},
+			"state":     {state(time.Now())},
+		}
+		if redirect := config.Get().SlackRedirectURL; len(redirect) > 0 {
+			query.Set("redirect_uri", redirect)
+		}
+		return Response{
+			StatusCode: http.StatusFound,
+			Headers:    map[string]string{"Location": authorize + "?" + query.Encode()},
+		}, nil
+	}
+	if reason := r.QueryStringParameters["error"]; len(reason) > 0 {
+		log.Printf("%s.Handler - denied: %s", handler, reason)
+		return respond(200, "kanobug was not installed."), nil
+	}
+	if !validState(r.QueryStringParameters["state"], time.Now()) {
+		return respond(400, "This install link has expired, please start again."), nil
+	}
+	reply, err := exchange(r.QueryStringParameters["code"])
+	if err != nil {
+		log.Printf("%s.Handler - oauth.v2.access error: %v", handler, err)
+		return respond(502, "Installing failed, please try again."), nil
+	}
+	installation := store.Installation{
+		OrgWide:     reply.IsEnterpriseInstall,
+		BotUserID:   reply.BotUserID,
+		BotToken:    reply.AccessToken,
+		Scope:       reply.Scope,
+		InstalledBy: reply.AuthedUser.ID,
+		InstalledAt: time.Now(),
+	}
+	if reply.Enterprise != nil {
+		installation.EnterpriseID, installation.Name = reply.Enterprise.ID, reply.Enterprise.Name
+	}
+	if reply.Team != nil && !reply.IsEnterpriseInstall {
+		installation.TeamID, installation.Name = reply.Team.ID, reply.Team.Name
+	}
+	if err = store.PutInstallation(installation); err != nil {
+		return respond(500, "Installing failed, please try again."), nil
+	}
+	log.Printf("%s.Handler - installed: %s/%s, org wide: %t, by: %s", handler, installation.EnterpriseID, installation.TeamID, installation.OrgWide, installation.InstalledBy)
+	return respond(200, fmt.Sprintf("kanobug is installed in %s.", installation.Name)), nil
+}
+
+// state return the state of an install started at, signed with
+// SLACK_CLIENT_SECRET so only installs started here are completed
+func state(at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(config.Get().SlackClientSecret))
+	mac.Write([]byte(ts))
+	return ts + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validState report whether s is the state of an install started within
+// stateTTL of now
+func validState(s string, now time.Time) bool {
+	parts := strings.SplitN(s, ".", 2)
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 || now.Sub(time.Unix(ts, 0)) > stateTTL {
+		return false
+	}
+	return hmac.Equal([]byte(s), []byte(state(time.Unix(ts, 0))))
+}
+
+// exchange trade the code of a completed install for its bot token
+func exchange(code string) (reply access, err error) {
+	form := url.Values{
+		"code":          {code},
+		"client_id":     {config.Get().SlackClientID},
+		"client_secret": {config.Get().SlackClientSecret},
+	}
+	if redirect := config.Get().SlackRedirectURL; len(redirect) > 0 {
+		form.Set("redirect_uri", redirect)
+	}
+	defer func() { emf.Slack("oauth.v2.access", err) }()
+	req, err := http.NewRequest("POST", oauthAccess, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := outbound.Do(outbound.Slack, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return
+	}
+	if !reply.OK {
+		err = fmt.Errorf("oauth.v2.access: %s", reply.Error)
+	}
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Table)
+	lambda.Start(Handler)
+}
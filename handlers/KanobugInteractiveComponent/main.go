@@ -1,33 +1,64 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
-	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanobug/internal/authz"
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/failure"
+	"github.com/anzellai/kanobug/internal/flags"
+	"github.com/anzellai/kanobug/internal/form"
+	"github.com/anzellai/kanobug/internal/health"
+	"github.com/anzellai/kanobug/internal/i18n"
+	"github.com/anzellai/kanobug/internal/markup"
+	"github.com/anzellai/kanobug/internal/mattermost"
+	"github.com/anzellai/kanobug/internal/orchestrate"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pii"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/quiet"
+	"github.com/anzellai/kanobug/internal/recovery"
+	"github.com/anzellai/kanobug/internal/scopes"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+	"github.com/anzellai/kanobug/internal/validate"
+	"github.com/anzellai/kanobug/internal/workflow"
 )
 
 const (
-	handler     = "KanobugInteractiveComponent"
-	apiEndpoint = "https://slack.com/api/dialog.open"
-	apiWebhook  = "https://hooks.slack.com/services/%s"
-	jiraHost    = "https://%s/rest/api/2/issue/"
+	handler    = "KanobugInteractiveComponent"
+	apiWebhook = "https://hooks.slack.com/services/%s"
+
+	// editRetries is how often an edit is re-merged into a bug changed under it
+	editRetries = 3
+	// shortcutID is the callback ID of the "Report a bug" message shortcut
+	shortcutID = "report-message"
+	// sprintAction is the action ID of the triage post's sprint button and
+	// of the select of sprints it may answer with
+	sprintAction = "sprint"
+	// recurAction and verifyAction are the action IDs of the resolution
+	// DM's No and Yes buttons
+	recurAction  = "recur"
+	verifyAction = "verify"
 )
 
+// issueKey matches a Jira issue key such as IQ-123
+var issueKey = regexp.MustCompile(`^[A-Z][A-Z0-9]+-[0-9]+$`)
+
 // Response is of type APIGatewayProxyResponse since we're leveraging the
 // AWS Lambda Proxy Request functionality (default behavior)
 //
@@ -46,127 +77,607 @@ type Request struct {
 	ActionTS    string     `json:"action_ts"`
 	Token       string     `json:"token"`
 	ResponseURL string     `json:"response_url"`
+	State       string     `json:"state"`
+	Team        struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	// Enterprise is the Enterprise Grid org of the workspace, whose payloads
+	// of org-wide installs carry the workspace in User.TeamID only
+	Enterprise struct {
+		ID string `json:"id"`
+	} `json:"enterprise"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+
+	// TriggerID, WorkflowStep and View are set for modal submissions and
+	// workflow step edits and saves
+	TriggerID    string        `json:"trigger_id"`
+	WorkflowStep workflow.Step `json:"workflow_step"`
+	View         struct {
+		CallbackID      string `json:"callback_id"`
+		PrivateMetadata string `json:"private_metadata"`
+		State           struct {
+			Values map[string]map[string]viewValue `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+
+	// ActionID and Value are the select and the text typed into it of an
+	// options request
+	ActionID string `json:"action_id"`
+	Value    string `json:"value"`
+
+	// Actions are the buttons and selects used on a message, once at a time
+	Actions []struct {
+		ActionID       string `json:"action_id"`
+		Value          string `json:"value"`
+		SelectedOption *struct {
+			Value string `json:"value"`
+		} `json:"selected_option"`
+	} `json:"actions"`
+
+	// Message is the message the message shortcut was used on
+	Message struct {
+		Text  string `json:"text"`
+		Files []struct {
+			Name       string `json:"name"`
+			URLPrivate string `json:"url_private"`
+			Size       int64  `json:"size"`
+		} `json:"files"`
+	} `json:"message"`
+
+	// Platform is slack or mattermost, Email is only known up front for mattermost
+	Platform string `json:"-"`
+	Email    string `json:"-"`
+	// Locale is the reporter's, carried in the modal's metadata
+	Locale string `json:"-"`
+	// BugID is the bug an edit modal was opened for, Version and Checksum
+	// the bug as the modal showed it
+	BugID    string `json:"-"`
+	Version  int64  `json:"-"`
+	Checksum string `json:"-"`
+	// Files are those of the message a report was started from
+	Files []orchestrate.File `json:"-"`
+	// ResponseIssued is when a modal's ResponseURL was issued, zero for the
+	// fresh response url of a message interaction
+	ResponseIssued time.Time `json:"-"`
 }
 
 type submission struct {
-	Summary string `json:"summary"`
-	Product string `json:"product"`
-	Details string `json:"details"`
+	Summary     string   `json:"summary"`
+	Product     string   `json:"product"`
+	Severity    string   `json:"severity"`
+	Security    string   `json:"security"`
+	Customer    string   `json:"customer_impacting"`
+	Details     string   `json:"details"`
+	Environment string   `json:"environment"`
+	Link        string   `json:"link"`
+	Versions    []string `json:"versions"`
+	Tags        []string `json:"tags"`
+	OtherTags   string   `json:"other_tags"`
+	Date        string   `json:"occurred_date"`
+	Time        string   `json:"occurred_time"`
+	CC          []string `json:"cc"`
+	Anonymous   bool     `json:"anonymous"`
 }
 
-type user struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+// viewValue is a modal input state, the field set depends on the element type
+type viewValue struct {
+	Value          string   `json:"value"`
+	SelectedDate   string   `json:"selected_date"`
+	SelectedTime   string   `json:"selected_time"`
+	SelectedUsers  []string `json:"selected_users"`
+	SelectedOption *struct {
+		Value string `json:"value"`
+	} `json:"selected_option"`
+	SelectedOptions []struct {
+		Value string `json:"value"`
+	} `json:"selected_options"`
 }
 
-// Bug is the BUG struct type ...
-type Bug struct {
-	UserID    string    `json:"user_id"`
-	UserName  string    `json:"user_name"`
-	Summary   string    `json:"summary"`
-	Product   string    `json:"product"`
-	Details   string    `json:"details"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	TTL       int64     `json:"ttl"`
+// value return the block's single value, text input or selected option
+func (request Request) value(block string) string {
+	v := request.View.State.Values[block][block]
+	switch {
+	case v.SelectedOption != nil:
+		return v.SelectedOption.Value
+	case len(v.SelectedDate) > 0:
+		return v.SelectedDate
+	case len(v.SelectedTime) > 0:
+		return v.SelectedTime
+	}
+	return v.Value
 }
 
-// ProductName return title case product
-func (bug Bug) ProductName() string {
-	return strings.ToTitle(strings.Replace(bug.Product, "_", " ", -1))
+// fromView fill the submission, response url and state from the report modal
+func (request *Request) fromView() {
+	var metadata struct {
+		ResponseURL string             `json:"response_url"`
+		Product     string             `json:"product"`
+		BugID       string             `json:"bug_id"`
+		Version     int64              `json:"version"`
+		Checksum    string             `json:"checksum"`
+		Locale      string             `json:"locale"`
+		Issued      time.Time          `json:"issued"`
+		Files       []orchestrate.File `json:"files"`
+	}
+	_ = json.Unmarshal([]byte(request.View.PrivateMetadata), &metadata)
+	request.ResponseURL, request.ResponseIssued = metadata.ResponseURL, metadata.Issued
+	request.Locale, request.Files = metadata.Locale, metadata.Files
+	request.State = metadata.Product
+	request.BugID = metadata.BugID
+	request.Version, request.Checksum = metadata.Version, metadata.Checksum
+	request.Submission = submission{
+		Summary:     request.value("summary"),
+		Product:     request.value("product"),
+		Severity:    request.value("severity"),
+		Security:    request.value("security"),
+		Customer:    request.value("customer_impacting"),
+		Details:     request.value("details"),
+		Environment: request.value("environment"),
+		Link:        request.value("link"),
+		Date:        request.value("occurred_date"),
+		Time:        request.value("occurred_time"),
+		CC:          request.View.State.Values["cc"]["cc"].SelectedUsers,
+		Anonymous:   len(request.View.State.Values["anonymous"]["anonymous"].SelectedOptions) > 0,
+	}
+	for _, o := range request.View.State.Values["versions"]["versions"].SelectedOptions {
+		request.Submission.Versions = append(request.Submission.Versions, o.Value)
+	}
+	for _, o := range request.View.State.Values["tags"]["tags"].SelectedOptions {
+		request.Submission.Tags = append(request.Submission.Tags, o.Value)
+	}
+	request.Submission.OtherTags = request.value("other_tags")
 }
 
-// GetDB return DDB handle
-func GetDB() (srv *dynamodb.DynamoDB, err error) {
-	region := os.Getenv("REGION")
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
-	if err != nil {
-		return
+// tags return the picked and typed in tags, normalized
+func (s submission) tags() []string {
+	return store.NormalizeTags(append(s.Tags, strings.Split(s.OtherTags, ",")...))
+}
+
+// draft return the report modal state as a draft, empty when nothing was entered
+func (request Request) draft() store.Draft {
+	draft := store.Draft{UserID: request.User.ID, Values: map[string][]string{}}
+	for block, actions := range request.View.State.Values {
+		v := actions[block]
+		var values []string
+		for _, o := range v.SelectedOptions {
+			values = append(values, o.Value)
+		}
+		values = append(values, v.SelectedUsers...)
+		if single := request.value(block); len(single) > 0 {
+			values = append(values, single)
+		}
+		if len(values) > 0 {
+			draft.Values[block] = values
+		}
 	}
-	srv = dynamodb.New(sess)
-	return
+	return draft
+}
+
+// workflowValues return the view state as workflow step inputs
+func (request Request) workflowValues() map[string]map[string]workflow.Input {
+	values := map[string]map[string]workflow.Input{}
+	for block := range request.View.State.Values {
+		values[block] = map[string]workflow.Input{block: {Value: request.value(block)}}
+	}
+	return values
+}
+
+type user struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	TeamID string `json:"team_id"`
 }
 
 // ToBug transform request details to Bug
-func (request Request) ToBug() Bug {
+func (request Request) ToBug() store.Bug {
 	details := request.Submission.Details
 	if len(details) == 0 {
 		details = "N/A"
 	}
+	if request.Platform == "slack" {
+		details = markup.ResolveMentions(details, userName)
+	}
+	if len(request.Submission.Environment) > 0 {
+		details += "\n\nEnvironment: " + request.Submission.Environment
+	}
+	if len(request.Submission.Link) > 0 {
+		details += "\nLink: " + request.Submission.Link
+	}
+	product := request.product()
 	now := time.Now()
-	bug := Bug{
-		UserID:    request.User.ID,
-		UserName:  request.User.Name,
-		Summary:   request.Submission.Summary,
-		Product:   request.Submission.Product,
-		Details:   details,
-		CreatedAt: now,
-		UpdatedAt: now,
+	var occurredAt *time.Time
+	if len(request.Submission.Date) > 0 {
+		occurredAt = occurred(request.Submission.Date, request.Submission.Time, userTimezone(request.User.ID))
+	}
+	bug := store.Bug{
+		TeamID:            request.Team.ID,
+		Source:            request.Platform,
+		UserID:            request.User.ID,
+		UserName:          request.User.Name,
+		Summary:           request.Submission.Summary,
+		Product:           product,
+		Severity:          request.Submission.Severity,
+		Security:          request.Submission.Security == "yes",
+		CustomerImpacting: request.Submission.Customer == "yes",
+		Details:           details,
+		Versions:          request.Submission.Versions,
+		Tags:              request.Submission.tags(),
+		OccurredAt:        occurredAt,
+		CC:                request.Submission.CC,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	bug.Locale = request.Locale
+	bug.Epic = form.ChannelEpic(request.channelID())
+	if request.Submission.Anonymous && flags.Enabled(flags.Anonymous, request.Team.ID, bug.Product) {
+		bug.UserID = store.AnonymousID(request.User.ID)
+		bug.UserName = store.AnonymousName
+		bug.Anonymous = true
 	}
 	return bug
 }
 
-// PutItem upsert BUG instance to db
-func (request Request) PutItem() (err error) {
-	bug := request.ToBug()
-	defer log.Printf(
-		"%s.PutItem (%s/%s/%s/%s) - error: %v",
-		handler,
-		bug.UserID,
-		bug.UserName,
-		bug.Summary,
-		bug.Product,
-		err,
-	)
-	srv, err := GetDB()
+// occurred parse the picked date and optional time in the reporter's
+// timezone tz, falling back to UTC when it is unknown
+func occurred(date, clock, tz string) *time.Time {
+	location, err := time.LoadLocation(tz)
+	if err != nil || len(tz) == 0 {
+		location = time.UTC
+	}
+	if len(clock) == 0 {
+		clock = "00:00"
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, location)
 	if err != nil {
-		return
+		log.Printf("%s.occurred - date: %s, time: %s, error: %v", handler, date, clock, err)
+		return nil
+	}
+	return &at
+}
+
+// product return the submitted product, or the one carried in the dialog
+// state by channels with a hidden product select
+func (request Request) product() string {
+	if len(request.Submission.Product) == 0 && request.Platform == "slack" {
+		return request.State
+	}
+	return request.Submission.Product
+}
+
+// validationErrors return the platform's inline form error response for an
+// invalid submission, empty when it is valid
+func (request Request) validationErrors() string {
+	errs := validate.Errors(validate.Form{
+		Summary:     request.Submission.Summary,
+		Product:     request.product(),
+		Details:     request.Submission.Details,
+		Environment: request.Submission.Environment,
+		Link:        request.Submission.Link,
+		Locale:      request.Locale,
+	})
+	if len(errs) == 0 {
+		return ""
+	}
+	var body []byte
+	if request.Platform == "mattermost" {
+		body, _ = json.Marshal(map[string]interface{}{"errors": errs})
+		return string(body)
+	}
+	body, _ = json.Marshal(map[string]interface{}{"response_action": "errors", "errors": errs})
+	return string(body)
+}
+
+// channelID return the channel the form was opened from, carried in the
+// modal metadata for Slack
+func (request Request) channelID() string {
+	if len(request.Channel.ID) > 0 {
+		return request.Channel.ID
+	}
+	var metadata struct {
+		ChannelID string `json:"channel_id"`
+	}
+	_ = json.Unmarshal([]byte(request.View.PrivateMetadata), &metadata)
+	return metadata.ChannelID
+}
+
+// formError return the platform's response showing message on the form
+func (request Request) formError(message string) string {
+	var body []byte
+	if request.Platform == "mattermost" {
+		body, _ = json.Marshal(map[string]string{"error": message})
+		return string(body)
 	}
-	bug.TTL = bug.UpdatedAt.AddDate(0, 0, 7).Unix()
-	item, err := dynamodbattribute.MarshalMap(bug)
+	field := "summary"
+	if request.View.CallbackID == "close-bug" {
+		field = "resolution"
+	}
+	body, _ = json.Marshal(map[string]interface{}{"response_action": "errors", "errors": map[string]string{field: message}})
+	return string(body)
+}
+
+// slackRequest parse and verify a Slack modal submission
+func slackRequest(body string) (request Request, err error) {
+	request.Platform = "slack"
+	query, err := url.ParseQuery(body)
 	if err != nil {
-		return
+		return request, failure.New(failure.BadRequest, "error.submission.unreadable", err)
+	}
+	payload := query.Get("payload")
+	if len(payload) == 0 {
+		return request, failure.New(failure.BadRequest, "error.submission.no_payload", nil)
+	}
+	if err = json.Unmarshal([]byte(payload), &request); err != nil {
+		return request, failure.New(failure.BadRequest, "error.submission.payload", err)
 	}
-	input := &dynamodb.PutItemInput{
-		Item:      item,
-		TableName: aws.String(os.Getenv("TABLE_NAME")),
+	if !slack.IsVerificationToken(request.Token) {
+		return request, failure.New(failure.Unauthorized, "error.token", nil)
 	}
-	_, err = srv.PutItem(input)
 	return
 }
 
-// Handler is our lambda handler invoked by the `lambda.Start` function call
-func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
-	log.Printf("%s.Handler - submitted: %+v", handler, r)
-	form, err := url.Parse("?" + r.Body)
-	if err != nil {
-		log.Printf("%s.Handler - unmarhsal body error: %+v", handler, err)
+// mattermostRequest parse and verify a Mattermost dialog submission into
+// its Slack shaped Request
+func mattermostRequest(body string) (request Request, err error) {
+	request.Platform = "mattermost"
+	var submitted mattermost.Submission
+	if err = json.Unmarshal([]byte(body), &submitted); err != nil {
+		return request, failure.New(failure.BadRequest, "error.submission.unreadable", err)
+	}
+	if err = json.Unmarshal(submitted.Submission, &request.Submission); err != nil {
+		return request, failure.New(failure.BadRequest, "error.submission.form", err)
+	}
+	if request.ResponseURL, request.ResponseIssued, err = mattermost.VerifyState(submitted.State, submitted.UserID); err != nil {
+		return request, failure.New(failure.Unauthorized, "error.submission.state", err)
 	}
-	query, _ := url.ParseQuery(form.RawQuery)
-	payload := query["payload"][0]
-	request := Request{}
-	err = json.Unmarshal([]byte(payload), &request)
+	request.Type = submitted.Type
+	request.CallbackID = submitted.CallbackID
+	request.Team.ID = submitted.TeamID
+	request.Channel.ID = submitted.ChannelID
+	request.User.ID = submitted.UserID
+	request.User.Name, request.Email, err = mattermost.User(submitted.UserID)
 	if err != nil {
-		log.Printf("%s.Handler - unmarhsal payload error: %+v", handler, err)
+		log.Printf("%s.Handler - mattermost user lookup error: %v", handler, err)
+		request.User.Name, err = submitted.UserID, nil
 	}
-	if request.Token != os.Getenv("SLACK_VERIFICATION_TOKEN") {
-		err = errors.New("invalid verification token")
+	return
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	if health.IsPing(r.HTTPMethod, r.Path) {
+		report := health.Run(handler)
 		return Response{
-			StatusCode:      400,
+			StatusCode:      report.Status(),
 			IsBase64Encoded: false,
-			Body:            fmt.Sprintf("%s submitting - error: %v", handler, err),
+			Body:            report.JSON(),
 			Headers: map[string]string{
 				"Content-Type": "application/json",
 			},
-		}, err
+		}, nil
+	}
+	log.Printf("%s.Handler - submitted: %+v", handler, r)
+	var request Request
+	var err error
+	if mattermost.IsSubmission(r.Body) {
+		request, err = mattermostRequest(r.Body)
+	} else {
+		request, err = slackRequest(r.Body)
+	}
+	if err != nil {
+		return fail(request, err), nil
+	}
+
+	if len(request.Team.ID) == 0 {
+		request.Team.ID = request.User.TeamID
+	}
+	store.UseWorkspace(request.Enterprise.ID, request.Team.ID)
+	if request.Type != "workflow_step_edit" && request.Type != "view_closed" && request.View.CallbackID != workflow.CallbackID {
+		if blocked := authz.RequireChannel(request.Team.ID, request.channelID()); len(blocked) > 0 {
+			log.Printf("%s.Handler - blocked: %s/%s", handler, request.Team.ID, request.channelID())
+			resp := ok()
+			resp.Body = request.formError(blocked)
+			return resp, nil
+		}
 	}
 
-	defer createIssue(request)
+	switch {
+	case request.Type == "message_action" && request.CallbackID == shortcutID:
+		err = reportMessage(request)
+		log.Printf("%s.Handler - message shortcut: %s, files: %d, error: %v", handler, request.User.ID, len(request.Message.Files), err)
+		if err != nil {
+			return fail(request, failure.New(failure.Unavailable, "error.form.unavailable", err)), nil
+		}
+		return ok(), nil
+	case request.Type == "block_suggestion" && request.ActionID == "versions":
+		request.fromView()
+		resp := ok()
+		resp.Body = versionOptions(request.product(), request.Value)
+		return resp, nil
+	case request.Type == "block_actions" && len(request.Actions) > 0 && request.Actions[0].ActionID == sprintAction:
+		sprint(request)
+		return ok(), nil
+	case request.Type == "block_actions" && len(request.Actions) > 0 && request.Actions[0].ActionID == recurAction:
+		recur(request)
+		return ok(), nil
+	case request.Type == "block_actions" && len(request.Actions) > 0 && request.Actions[0].ActionID == verifyAction:
+		verify(request)
+		return ok(), nil
+	case request.Type == "workflow_step_edit":
+		err = workflow.OpenConfig(request.TriggerID, request.WorkflowStep.Inputs)
+		log.Printf("%s.Handler - workflow step edit: %s, error: %v", handler, request.WorkflowStep.EditID, err)
+		return ok(), nil
+	case request.Type == "view_submission" && request.View.CallbackID == workflow.CallbackID:
+		err = workflow.Save(request.WorkflowStep.EditID, request.workflowValues())
+		log.Printf("%s.Handler - workflow step save: %s, error: %v", handler, request.WorkflowStep.EditID, err)
+		return ok(), nil
+	case request.Type == "view_submission" && request.View.CallbackID == "edit-bug":
+		request.fromView()
+		if errs := request.validationErrors(); len(errs) > 0 {
+			resp := ok()
+			resp.Body = errs
+			return resp, nil
+		}
+		resp := ok()
+		if problem := editBug(request); len(problem) > 0 {
+			resp.Body = request.formError(problem)
+		}
+		return resp, nil
+	case request.Type == "view_submission" && request.View.CallbackID == "close-bug":
+		request.fromView()
+		resolution, duplicateOf := request.value("resolution"), strings.ToUpper(strings.TrimSpace(request.value("duplicate_of")))
+		if resolution == store.ResolutionDuplicate && !issueKey.MatchString(duplicateOf) {
+			resp := ok()
+			body, _ := json.Marshal(map[string]interface{}{
+				"response_action": "errors",
+				"errors":          map[string]string{"duplicate_of": "Please enter the Jira key of the original, e.g. IQ-123."},
+			})
+			resp.Body = string(body)
+			return resp, nil
+		}
+		if resolution != store.ResolutionDuplicate {
+			duplicateOf = ""
+		}
+		closeBug(request, resolution, duplicateOf)
+		return ok(), nil
+	case request.Type == "view_submission" && request.View.CallbackID == "notify-preferences":
+		request.fromView()
+		saveNotify(request)
+		return ok(), nil
+	case request.Type == "view_closed" && request.View.CallbackID == "report-bug":
+		if draft := request.draft(); len(draft.Values) > 0 && store.ConfigEnabled() {
+			_ = store.PutDraft(draft)
+		}
+		return ok(), nil
+	case request.Type == "view_submission":
+		request.fromView()
+		if store.ConfigEnabled() {
+			defer func() { _ = store.DeleteDraft(request.User.ID) }()
+		}
+	}
+
+	if errs := request.validationErrors(); len(errs) > 0 {
+		resp := ok()
+		resp.Body = errs
+		return resp, nil
+	}
 
-	err = request.PutItem()
+	bug := request.ToBug()
+	if orchestrate.Enabled(bug.Product) && orchestrated(request, bug) {
+		return ok(), nil
+	}
+	err = pipeline.Store(&bug)
 	log.Printf("%s.Handler - submitted: %+v, error: %v", handler, request, err)
+	if err != nil {
+		return fail(request, failure.New(failure.Unavailable, "error.report.unsaved", err)), nil
+	}
+	if store.ConfigEnabled() && !bug.Anonymous {
+		_ = store.PutPreference(store.Preference{UserID: bug.UserID, Product: bug.Product, Severity: bug.Severity})
+	}
+	createIssue(request, bug)
+
+	return ok(), nil
+}
+
+// versionOptions return the options request response listing the versions of
+// product matching query
+func versionOptions(product, query string) string {
+	options := []map[string]interface{}{}
+	for _, o := range catalog.MatchVersions(product, query) {
+		options = append(options, map[string]interface{}{
+			"text":  map[string]string{"type": "plain_text", "text": o.Label},
+			"value": o.Value,
+		})
+	}
+	body, _ := json.Marshal(map[string]interface{}{"options": options})
+	return string(body)
+}
+
+// reportMessage open the report form for the message the shortcut was used
+// on, its first line as the summary and the whole as the details, carrying
+// its files to attach to the filed issues
+func reportMessage(request Request) error {
+	profile := userProfile(request.User.ID)
+	summary := strings.TrimSpace(strings.SplitN(request.Message.Text, "\n", 2)[0])
+	if runes := []rune(summary); len(runes) > validate.MaxSummary {
+		summary = string(runes[:validate.MaxSummary])
+	}
+	channel := form.ChannelProduct(request.Channel.ID)
+	dialog := form.Report(summary, channel, form.UserPreference(request.User.ID), profile.Locale)
+	if !flags.Enabled(flags.Anonymous, request.Team.ID, channel.Product) {
+		dialog.Remove("anonymous")
+	}
+	if details := dialog.Element("details"); details != nil {
+		details.Value = request.Message.Text
+	}
+	form.SuggestSeverity(&dialog, summary, request.Message.Text)
+	form.InferProduct(&dialog, request.Message.Text, channel, profile.Locale)
+	metadata := form.Metadata{
+		ResponseURL: request.ResponseURL,
+		ChannelID:   request.Channel.ID,
+		Product:     dialog.State,
+		Locale:      profile.Locale,
+	}
+	for _, f := range request.Message.Files {
+		metadata.Files = append(metadata.Files, orchestrate.File{Name: f.Name, URL: f.URLPrivate, Size: f.Size})
+	}
+	return form.Open(request.TriggerID, form.Modal(dialog, metadata))
+}
+
+// orchestrated start the submission state machine for bug, saving the
+// reporter's preferences, and report whether it started; the bug is stored
+// and filed inline otherwise
+func orchestrated(request Request, bug store.Bug) bool {
+	bug.ID = store.NewID()
+	if err := orchestrate.Start(orchestrate.Submission{
+		Bug:         bug,
+		Platform:    request.Platform,
+		ReporterID:  request.User.ID,
+		ResponseURL: request.ResponseURL,
+		Issued:      request.ResponseIssued,
+		Email:       reporterEmail(request, bug),
+		Files:       request.Files,
+	}); err != nil {
+		return false
+	}
+	if store.ConfigEnabled() && !bug.Anonymous {
+		_ = store.PutPreference(store.Preference{UserID: bug.UserID, Product: bug.Product, Severity: bug.Severity})
+	}
+	return true
+}
 
-	resp := Response{
+// reporterEmail return the email a customer impacting bug's ticket is opened
+// for, the reporter's Slack email unless the request carries one
+func reporterEmail(request Request, bug store.Bug) string {
+	if bug.CustomerImpacting && request.Platform == "slack" && !bug.Anonymous {
+		return userEmail(bug.UserID)
+	}
+	return request.Email
+}
+
+// fail return the response telling the user about err: on the submitted form,
+// or as an ephemeral message for other interactions
+func fail(request Request, err error) Response {
+	f := failure.From(err)
+	log.Printf("%s.Handler - %s, error: %v", handler, f.Class, f)
+	resp := ok()
+	resp.StatusCode = f.Status()
+	if request.Type == "view_submission" || request.Platform == "mattermost" {
+		resp.Body = request.formError(f.Text(request.Locale))
+		return resp
+	}
+	body, _ := json.Marshal(map[string]string{
+		"response_type": "ephemeral",
+		"text":          f.Text(request.Locale),
+	})
+	resp.Body = string(body)
+	return resp
+}
+
+func ok() Response {
+	return Response{
 		StatusCode:      200,
 		IsBase64Encoded: false,
 		Body:            "",
@@ -174,83 +685,478 @@ func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
 			"Content-Type": "application/json",
 		},
 	}
-
-	return resp, nil
 }
 
-func createIssue(request Request) {
-	bug := request.ToBug()
-
-	jiraURL := fmt.Sprintf(jiraHost, os.Getenv("JIRA_API_HOST"))
-	jiraUser := os.Getenv("JIRA_API_USER")
-	jiraToken := os.Getenv("JIRA_API_TOKEN")
-
-	inputQueue := map[string]interface{}{
-		"fields": map[string]interface{}{
-			"project":     map[string]string{"key": "IQ"},
-			"summary":     bug.Summary,
-			"description": fmt.Sprintf("Product: %s\nReporter: %s\n\n%s", bug.ProductName(), bug.UserName, bug.Details),
-			"issuetype":   map[string]string{"name": "Bug"},
-			"labels":      []string{"slack"},
-			"priority":    map[string]string{"name": "Not Yet Prioritized"},
-		},
+func createIssue(request Request, bug store.Bug) {
+	var lines []string
+	issues := pipeline.File(bug, reporterEmail(request, bug))
+	for _, issue := range issues {
+		lines = append(lines, issue.TextIn(bug.Locale))
+	}
+	if len(lines) == 0 && bug.Queued {
+		lines = append(lines, pipeline.QueuedText(bug))
+	}
+	if len(lines) == 0 && len(tracker.ForProduct(bug.Product)) > 0 {
+		lines = append(lines, i18n.T(bug.Locale, "confirm.not_filed", bug.Summary))
 	}
+	if len(request.Files) > 0 && bug.Queued {
+		lines = append(lines, i18n.T(bug.Locale, "confirm.queued_files"))
+	} else if skipped := pipeline.Attach(issues, request.Files); len(skipped) > 0 {
+		lines = append(lines, i18n.T(bug.Locale, "confirm.not_attached", strings.Join(skipped, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	cc(bug, issues, strings.Join(lines, "\n"))
+	if bug.Anonymous {
+		err := dm(request.User.ID, strings.Join(lines, "\n"))
+		log.Printf("%s.Handler - anonymous confirmation, bug: %s, error: %v", handler, bug.ID, err)
+		return
+	}
+
+	respond(request, strings.Join(lines, "\n"))
+}
 
-	iq, err := json.Marshal(inputQueue)
-	log.Printf("%s.Handler - inputQueue: %+v, error: %v", handler, inputQueue, err)
+// sprint move the Jira issue of the bug of the triage post's button into
+// the active sprint of its project, asking the triager which sprint when
+// several are active, their pick comes back as "<bug ID>/<sprint ID>"
+func sprint(request Request) {
+	action := request.Actions[0]
+	value := action.Value
+	if action.SelectedOption != nil {
+		value = action.SelectedOption.Value
+	}
+	parts := strings.SplitN(value, "/", 2)
+	if denied := authz.Require(request.User.ID, authz.Triager, "plan bugs into sprints"); len(denied) > 0 {
+		respond(request, denied)
+		return
+	}
+	bug, err := store.FindBug(parts[0])
 	if err != nil {
+		log.Printf("%s.sprint - bug: %s, error: %v", handler, parts[0], err)
+		respond(request, fmt.Sprintf("Could not find bug %s.", parts[0]))
 		return
 	}
+	jira := tracker.NewJira()
+	var issue tracker.Issue
+	for _, i := range bug.Issues {
+		if i.Tracker == jira.Name() {
+			issue = i
+			break
+		}
+	}
+	if len(issue.Key) == 0 {
+		respond(request, fmt.Sprintf("Bug %s has no Jira issue yet, try again once it is filed.", bug.ID))
+		return
+	}
+	var sprintID int
+	if len(parts) == 2 {
+		sprintID, _ = strconv.Atoi(parts[1])
+	}
+	name := ""
+	if sprintID == 0 {
+		sprints, err := jira.Sprints(jira.Project(bug.Product))
+		switch {
+		case err != nil:
+			respond(request, fmt.Sprintf("Could not read the sprints of %s: %v", jira.Project(bug.Product), err))
+			return
+		case len(sprints) == 0:
+			respond(request, fmt.Sprintf("No sprint of %s is active.", jira.Project(bug.Product)))
+			return
+		case len(sprints) > 1:
+			var options []map[string]interface{}
+			for _, s := range sprints {
+				options = append(options, map[string]interface{}{
+					"text":  map[string]string{"type": "plain_text", "text": s.Name},
+					"value": fmt.Sprintf("%s/%d", bug.ID, s.ID),
+				})
+			}
+			respondBlocks(request, "Which sprint?", []map[string]interface{}{{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("Which sprint should %s go into?", issue.Key)},
+				"accessory": map[string]interface{}{
+					"type":        "static_select",
+					"action_id":   sprintAction,
+					"placeholder": map[string]string{"type": "plain_text", "text": "Active sprints"},
+					"options":     options,
+				},
+			}})
+			return
+		}
+		sprintID, name = sprints[0].ID, sprints[0].Name
+	}
+	if err = jira.MoveToSprint(issue, sprintID); err != nil {
+		respond(request, fmt.Sprintf("Could not move %s into the sprint: %v", issue.Key, err))
+		return
+	}
+	if len(name) == 0 {
+		name = "the sprint"
+	}
+	respond(request, fmt.Sprintf("<@%s> moved %s into %s: %s", request.User.ID, issue.Key, name, issue.URL))
+}
 
-	r, err := http.NewRequest("POST", jiraURL, bytes.NewBuffer(iq))
+// verify record the reporter or a user CC'd on the bug of the resolution
+// DM's Yes button confirming the fix
+func verify(request Request) {
+	bug, err := store.FindBug(request.Actions[0].Value)
 	if err != nil {
-		log.Printf("%s.Handler - newRequest: %+v, error: %v", handler, inputQueue, err)
+		log.Printf("%s.verify - bug: %s, error: %v", handler, request.Actions[0].Value, err)
+		respond(request, fmt.Sprintf("Could not find bug %s.", request.Actions[0].Value))
+		return
+	}
+	cc := false
+	for _, userID := range bug.CC {
+		cc = cc || userID == request.User.ID
+	}
+	switch {
+	case !bug.ReportedBy(request.User.ID) && !cc:
+		respond(request, "Only the reporter or a user CC'd on the bug can verify its fix.")
+		return
+	case len(bug.VerifiedBy) > 0:
+		respond(request, fmt.Sprintf("Bug %s was verified fixed already, thanks.", bug.ID))
+		return
+	case bug.Status != store.StatusResolved && bug.Status != store.StatusClosed:
+		respond(request, fmt.Sprintf("Bug %s is open again, the team is on it.", bug.ID))
 		return
 	}
-	r.SetBasicAuth(jiraUser, jiraToken)
-	r.Header.Set("Content-Type", "application/json")
-	c := &http.Client{}
-	rr, err := c.Do(r)
+	name := request.User.Name
+	if bug.Anonymous && bug.ReportedBy(request.User.ID) {
+		name = "the anonymous reporter"
+	}
+	_, err = pipeline.Verify(bug, request.User.ID, name)
+	log.Printf("%s.verify - bug: %s, user: %s, error: %v", handler, bug.ID, request.User.ID, err)
 	if err != nil {
-		log.Printf("%s.Handler - createIssue: %+v, error: %v", handler, inputQueue, err)
+		respond(request, fmt.Sprintf("Could not verify bug %s, please try again.", bug.ID))
 		return
 	}
+	settle(request, fmt.Sprintf("Thanks for confirming bug %s is fixed.", bug.ID))
+}
 
-	var issue struct {
-		ID   string `json:"id"`
-		Key  string `json:"key"`
-		Self string `json:"self"`
+// recur reopen the bug of the resolution DM's No button for its reporter,
+// a user CC'd on it or a triager
+func recur(request Request) {
+	bug, err := store.FindBug(request.Actions[0].Value)
+	if err != nil {
+		log.Printf("%s.recur - bug: %s, error: %v", handler, request.Actions[0].Value, err)
+		respond(request, fmt.Sprintf("Could not find bug %s.", request.Actions[0].Value))
+		return
+	}
+	cc := false
+	for _, userID := range bug.CC {
+		cc = cc || userID == request.User.ID
+	}
+	if !bug.ReportedBy(request.User.ID) && !cc {
+		if denied := authz.Require(request.User.ID, authz.Triager, "reopen bugs"); len(denied) > 0 {
+			respond(request, denied)
+			return
+		}
 	}
-	err = json.NewDecoder(rr.Body).Decode(&issue)
-	log.Printf("%s.Handler - issue: %+v, error: %v", handler, issue, err)
+	if bug.Status != store.StatusResolved && bug.Status != store.StatusClosed {
+		respond(request, fmt.Sprintf("Bug %s is open again already, the team is on it.", bug.ID))
+		return
+	}
+	updated, detail, err := pipeline.Recur(bug, request.User.ID)
+	log.Printf("%s.recur - bug: %s, user: %s, error: %v", handler, bug.ID, request.User.ID, err)
 	if err != nil {
+		respond(request, fmt.Sprintf("Could not reopen bug %s, please try again.", bug.ID))
 		return
 	}
-	defer rr.Body.Close()
+	text := fmt.Sprintf("Thanks, bug %s is open again and the team has been told.", updated.ID)
+	switch {
+	case detail.Issue != nil && detail.Regression:
+		text += fmt.Sprintf(" It is tracked as regression %s: %s", detail.Issue.Key, detail.Issue.URL)
+	case detail.Issue != nil:
+		text += fmt.Sprintf(" %s was reopened: %s", detail.Issue.Key, detail.Issue.URL)
+	}
+	settle(request, text)
+}
+
+// respond post text to the request's response url
+func respond(request Request, text string) {
+	respondBlocks(request, text, nil)
+}
 
-	payload, _ := json.Marshal(map[string]interface{}{
-		"text": fmt.Sprintf("Bug submitted - ID: %s, Key: %s, Issue Link: %s",
-			issue.ID, issue.Key, fmt.Sprintf("https://%s/projects/IQ/issues/%s", os.Getenv("JIRA_API_HOST"), issue.Key)),
+// respondBlocks post blocks to the request's response url, text being
+// their notification fallback. Text is sent to the user as a DM instead once
+// the response url expired, e.g. for a modal left open half an hour
+func respondBlocks(request Request, text string, blocks []map[string]interface{}) {
+	responder := slack.NewResponder(request.ResponseURL, request.Platform)
+	responder.Issued = request.ResponseIssued
+	err := responder.Send(slack.Message{Text: text, Blocks: blocks})
+	log.Printf("%s.respondBlocks - error: %v", handler, err)
+	if err != nil && request.Platform == "slack" {
+		err = dm(request.User.ID, text)
+		log.Printf("%s.respondBlocks - dm: %s, error: %v", handler, request.User.ID, err)
+	}
+}
+
+// settle replace the message whose button was pressed with its text followed
+// by text, so its buttons are not pressed again, or respond with text when
+// it cannot be replaced
+func settle(request Request, text string) {
+	replaced := text
+	if len(request.Message.Text) > 0 {
+		replaced = request.Message.Text + "\n" + text
+	}
+	err := slack.NewResponder(request.ResponseURL, request.Platform).Replace(replaced, nil)
+	log.Printf("%s.settle - error: %v", handler, err)
+	if err != nil {
+		respond(request, text)
+	}
+}
+
+// editBug save the reporter's edits to the bug and its Jira issues, leaving
+// an audit comment on the issues, and confirm through the response url.
+// The edits are merged into a bug changed since the modal opened as long as
+// its summary, product, severity and details are untouched, otherwise the
+// returned problem asks the user to start over
+func editBug(request Request) (problem string) {
+	var bug, before store.Bug
+	var err error
+	for attempt := 0; ; attempt++ {
+		bug, err = store.FindBug(request.BugID)
+		if err != nil || len(authz.RequireBug(request.User.ID, bug, "change this bug")) > 0 {
+			log.Printf("%s.editBug - bug: %s, user: %s, error: %v", handler, request.BugID, request.User.ID, err)
+			return
+		}
+		if bug.Version != request.Version && bug.Checksum() != request.Checksum {
+			return "Someone else edited this bug meanwhile, close this form and run `/kanobug edit` again to see their changes."
+		}
+		before = bug
+		bug.Summary = request.Submission.Summary
+		bug.Product = request.Submission.Product
+		bug.Severity = request.Submission.Severity
+		bug.Tags = request.Submission.tags()
+		bug.Details = request.Submission.Details
+		if len(bug.Details) == 0 {
+			bug.Details = "N/A"
+		}
+		bug.Details = markup.ResolveMentions(bug.Details, userName)
+		if pii.Enabled(bug.Product) {
+			bug.Details = pii.Redact(bug.Details)
+		}
+		updated, updateErr := store.UpdateBug(bug)
+		if updateErr == nil {
+			bug = updated
+			break
+		}
+		if updateErr != store.ErrConflict {
+			return
+		}
+		if attempt == editRetries {
+			return "The bug keeps changing under this edit, please try again."
+		}
+		log.Printf("%s.editBug - bug: %s, version %d conflict, retrying", handler, bug.ID, bug.Version)
+	}
+	_ = store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   request.User.ID,
+		Action:  store.AuditUpdate,
+		Before:  store.Audited(before),
+		After:   store.Audited(bug),
 	})
-	req, reqErr := http.NewRequest("POST", request.ResponseURL, bytes.NewBuffer(payload))
-	if reqErr != nil {
-		log.Printf("%s.Handler - error sending dialog response url: %v", handler, reqErr)
+	var changed []string
+	for field, values := range map[string][2]string{
+		"summary":  {before.Summary, bug.Summary},
+		"product":  {before.ProductName(), bug.ProductName()},
+		"severity": {before.Severity, bug.Severity},
+		"details":  {before.Details, bug.Details},
+		"tags":     {strings.Join(before.Tags, ","), strings.Join(bug.Tags, ",")},
+	} {
+		if values[0] != values[1] {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("SLACK_ACCESS_TOKEN"))
-	client := &http.Client{}
-	resp, respErr := client.Do(req)
-	if respErr != nil {
-		log.Printf("%s.Handler - error receiving dialog response from response url: %v", handler, reqErr)
+	sort.Strings(changed)
+	// credit the editor in Jira and the threads, unless they reported the bug
+	// anonymously: the threads include every subscribed channel
+	name, editor := request.User.Name, fmt.Sprintf("<@%s>", request.User.ID)
+	if bug.Anonymous && bug.ReportedBy(request.User.ID) {
+		name, editor = store.AnonymousName, store.AnonymousName
+	}
+	comment := fmt.Sprintf("%s edited this bug from Slack, changing the %s.", markup.EscapeWiki(name), strings.Join(changed, ", "))
+	if before.Summary != bug.Summary {
+		comment += "\nPrevious summary: " + markup.EscapeWiki(before.Summary)
+	}
+	jira := tracker.NewJira()
+	var lines []string
+	for _, issue := range bug.Issues {
+		if issue.Tracker != jira.Name() {
+			continue
+		}
+		if err = jira.Update(issue, before, bug); err == nil {
+			err = jira.Comment(issue, comment)
+		}
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("Could not update %s: %v", issue.Key, err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Updated %s: %s", issue.Key, issue.URL))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("Updated bug %s", bug.ID))
+	}
+	note := fmt.Sprintf("%s edited this bug, changing the %s.", editor, strings.Join(changed, ", "))
+	for _, thread := range bug.Threads() {
+		if err := post(thread.Channel, thread.TS, note); err != nil {
+			log.Printf("%s.editBug - thread: %+v, error: %v", handler, thread, err)
+		}
+	}
+	respond(request, strings.Join(lines, "\n"))
+	return
+}
+
+// saveNotify store the notification preferences submitted from the modal of
+// `/kanobug notify`, products left as the default follow its mode
+func saveNotify(request Request) {
+	preference := store.NotifyPreference{UserID: request.User.ID, Mode: request.value("mode"), Products: map[string]string{}}
+	for block := range request.View.State.Values {
+		product := strings.TrimPrefix(block, "product:")
+		if mode := request.value(block); product != block && store.ValidNotifyMode(mode) {
+			preference.Products[product] = mode
+		}
+	}
+	text := "Your notification preferences are saved."
+	if err := store.PutNotifyPreference(preference); err != nil {
+		text = "Sorry, your notification preferences could not be saved."
+	}
+	respond(request, text)
+}
+
+// closeBug close the reporter's bug with resolution, transitioning its Jira
+// issues, and confirm through the response url
+func closeBug(request Request, resolution, duplicateOf string) {
+	bug, err := store.FindBug(request.BugID)
+	if err != nil || len(authz.RequireBug(request.User.ID, bug, "change this bug")) > 0 {
+		log.Printf("%s.closeBug - bug: %s, user: %s, error: %v", handler, request.BugID, request.User.ID, err)
 		return
 	}
-	var respBody map[string]interface{}
-	_ = json.NewDecoder(resp.Body).Decode(&respBody)
-	log.Printf("%s.Handler - error receiving dialog response Body: %v", handler, respBody)
-	defer resp.Body.Close()
+	jira := tracker.NewJira()
+	var lines []string
+	for _, issue := range bug.Issues {
+		if issue.Tracker != jira.Name() {
+			continue
+		}
+		if err = jira.Close(issue, resolution, duplicateOf); err != nil {
+			lines = append(lines, fmt.Sprintf("Could not close %s: %v", issue.Key, err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Closed %s: %s", issue.Key, issue.URL))
+	}
+	if _, err = pipeline.SetStatus(bug, store.StatusClosed, resolution, request.User.ID); err != nil {
+		lines = append(lines, fmt.Sprintf("Could not update bug %s: %v", bug.ID, err))
+	} else if len(lines) == 0 {
+		lines = append(lines, fmt.Sprintf("Closed bug %s", bug.ID))
+	}
+	respond(request, strings.Join(lines, "\n"))
+}
+
+// cc send the CC'd users the confirmation and add them as watchers of the
+// bug's Jira issues when their Slack email matches a Jira user
+func cc(bug store.Bug, issues []tracker.Issue, confirmation string) {
+	reporter := fmt.Sprintf("<@%s>", bug.UserID)
+	if bug.Anonymous {
+		reporter = "An anonymous reporter"
+	}
+	text := fmt.Sprintf("%s CC'd you on a bug: %s\n%s", reporter, bug.Summary, confirmation)
+	jira := tracker.NewJira()
+	for _, userID := range bug.CC {
+		if userID == bug.UserID {
+			continue
+		}
+		var err error
+		mode, held := quiet.Hold(userID, bug, time.Now(), text, nil)
+		if !held {
+			err = dm(userID, text)
+		}
+		log.Printf("%s.cc - user: %s, bug: %s, mode: %s, error: %v", handler, userID, bug.ID, mode, err)
+		email := userEmail(userID)
+		for _, issue := range issues {
+			if issue.Tracker == jira.Name() && len(email) > 0 {
+				_ = jira.AddWatcher(issue, email)
+			}
+		}
+	}
+}
+
+// dm send text to the Slack user as a direct message from the app
+func dm(userID, text string) error {
+	return post(userID, "", text)
+}
+
+// post send text to a Slack channel, as a reply in the thread of threadTS
+// unless empty
+func post(channel, threadTS, text string) (err error) {
+	message := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+	}
+	if len(threadTS) > 0 {
+		message["thread_ts"] = threadTS
+	}
+	_, err = slack.Default.PostMessage(message)
+	return
+}
+
+// userEmail look up the Slack user's email, empty when unavailable
+func userEmail(userID string) string {
+	return userProfile(userID).Profile.Email
+}
+
+// userTimezone look up the Slack user's IANA timezone, empty when unavailable
+func userTimezone(userID string) string {
+	return userProfile(userID).TZ
+}
+
+// userName look up the Slack user's display name, falling back to the real name
+func userName(userID string) string {
+	return userProfile(userID).Name()
+}
+
+// userProfile look up the Slack user's profile, timezone and locale, empty when unavailable
+func userProfile(userID string) slack.User {
+	user, err := slack.Default.UserInfo(userID)
+	if err != nil {
+		log.Printf("%s.userProfile - error: %v", handler, err)
+	}
+	return user
+}
+
+// recovered serve r with Handler, answering a panic on a report submission
+// with the error on the form and keeping what the user entered as their draft
+func recovered(ctx context.Context, r ProxyRequest) (resp Response, err error) {
+	defer func() {
+		if !recovery.Recovered(handler, recover()) {
+			return
+		}
+		resp, err = ok(), nil
+		query, _ := url.ParseQuery(r.Body)
+		var request Request
+		if json.Unmarshal([]byte(query.Get("payload")), &request) != nil || request.Type != "view_submission" {
+			return
+		}
+		request.Platform = "slack"
+		request.fromView()
+		if request.View.CallbackID == "report-bug" && store.ConfigEnabled() {
+			store.UseWorkspace(request.Enterprise.ID, request.Team.ID)
+			draftErr := store.PutDraft(request.draft())
+			log.Printf("%s.Handler - draft: %s, error: %v", handler, request.User.ID, draftErr)
+		}
+		resp.Body = request.formError(i18n.T(request.Locale, "confirm.recovered"))
+	}()
+	return Handler(ctx, r)
 }
 
 func main() {
-	lambda.Start(Handler)
+	needs := []config.Need{config.Table, config.SlackRequests, config.Trackers}
+	if flags.Offered(flags.Anonymous) {
+		needs = append(needs, config.Anonymous)
+	}
+	config.MustLoad(handler, needs...)
+	health.Warm()
+	scopes.Audit(handler)
+	lambda.Start(recovered)
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/failure"
+	"github.com/anzellai/kanobug/internal/outbound/outboundtest"
+)
+
+func TestMain(m *testing.M) {
+	// the configuration is read once, before any test runs
+	os.Setenv("SLACK_VERIFICATION_TOKEN", "verification")
+	os.Exit(m.Run())
+}
+
+// recorded return the form body Slack posts the recorded payload in
+func recorded(t *testing.T, path string) string {
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.Values{"payload": {string(payload)}}.Encode()
+}
+
+func TestSlackViewSubmission(t *testing.T) {
+	request, err := slackRequest(recorded(t, "testdata/view_submission.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Type != "view_submission" || request.View.CallbackID != "report-bug" {
+		t.Fatalf("slackRequest() = %s/%s, want view_submission/report-bug", request.Type, request.View.CallbackID)
+	}
+	request.fromView()
+	want := submission{
+		Summary:     "Checkout button does nothing",
+		Severity:    "major",
+		Security:    "no",
+		Customer:    "yes",
+		Details:     "Clicking *Pay* shows a spinner forever, <@U0GRACE> sees it too",
+		Environment: "Chrome 69",
+		Versions:    []string{"2.3.0"},
+		Tags:        []string{"checkout"},
+		OtherTags:   "Payments, mobile web",
+		Date:        "2018-09-17",
+		Time:        "13:30",
+		CC:          []string{"U0GRACE"},
+	}
+	if !reflect.DeepEqual(request.Submission, want) {
+		t.Errorf("fromView() submission = %+v, want %+v", request.Submission, want)
+	}
+	if request.ResponseURL != "https://hooks.slack.com/commands/T0KANOBUG/123/abc" || request.channelID() != "C0BUGS" || request.Locale != "en" {
+		t.Errorf("fromView() metadata = %s, %s, %s", request.ResponseURL, request.channelID(), request.Locale)
+	}
+	if errs := request.validationErrors(); len(errs) > 0 {
+		t.Errorf("validationErrors() = %s", errs)
+	}
+
+	outboundtest.Replay(t, "testdata/users_info.json")
+	bug := request.ToBug()
+	if bug.Product != "web_app" || bug.TeamID != "T0KANOBUG" || bug.UserID != "U0ADA" || bug.Security || !bug.CustomerImpacting {
+		t.Errorf("ToBug() = %+v", bug)
+	}
+	if details := "Clicking *Pay* shows a spinner forever, <@U0GRACE|Grace Hopper> sees it too\n\nEnvironment: Chrome 69"; bug.Details != details {
+		t.Errorf("ToBug() details = %q, want %q", bug.Details, details)
+	}
+	if tags := []string{"checkout", "payments", "mobile-web"}; !reflect.DeepEqual(bug.Tags, tags) {
+		t.Errorf("ToBug() tags = %v, want %v", bug.Tags, tags)
+	}
+	// 13:30 in the reporter's Europe/London, on summer time
+	if occurred := time.Date(2018, 9, 17, 12, 30, 0, 0, time.UTC); bug.OccurredAt == nil || !bug.OccurredAt.Equal(occurred) {
+		t.Errorf("ToBug() occurred at = %v, want %v", bug.OccurredAt, occurred)
+	}
+}
+
+func TestSlackRequestUnverified(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want failure.Class
+	}{
+		{"other token", url.Values{"payload": {`{"type":"view_submission","token":"other"}`}}.Encode(), failure.Unauthorized},
+		{"no token", url.Values{"payload": {`{"type":"view_submission"}`}}.Encode(), failure.Unauthorized},
+		{"no payload", "type=view_submission", failure.BadRequest},
+		{"not json", url.Values{"payload": {`{"type":`}}.Encode(), failure.BadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := slackRequest(tt.body)
+			if got := failure.From(err).Class; got != tt.want {
+				t.Errorf("slackRequest() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
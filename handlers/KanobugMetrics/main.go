@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const handler = "KanobugMetrics"
+
+// image convert a stream image to table attribute values, both share the
+// DynamoDB JSON encoding
+func image(attributes map[string]events.DynamoDBAttributeValue) (item map[string]*dynamodb.AttributeValue, err error) {
+	if len(attributes) == 0 {
+		return
+	}
+	body, err := json.Marshal(attributes)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &item)
+	return
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// adding the submissions, syncs and resolutions in a batch of table stream
+// records to the daily counters of their team. Failed records are logged and
+// skipped rather than retried, which would count the rest of the batch twice;
+// kanobugctl stats -rebuild recounts from the bugs
+func Handler(ctx context.Context, event events.DynamoDBEvent) error {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %d record(s)", handler, len(event.Records))
+	for _, record := range event.Records {
+		before, err := image(record.Change.OldImage)
+		if err != nil {
+			log.Printf("%s.Handler - record: %s, old image error: %v", handler, record.EventID, err)
+			continue
+		}
+		after, err := image(record.Change.NewImage)
+		if err != nil {
+			log.Printf("%s.Handler - record: %s, new image error: %v", handler, record.EventID, err)
+			continue
+		}
+		team, counted, err := store.StreamEvents(before, after, record.Change.ApproximateCreationDateTime.Time)
+		if err != nil || len(counted) == 0 {
+			if err != nil {
+				log.Printf("%s.Handler - record: %s, error: %v", handler, record.EventID, err)
+			}
+			continue
+		}
+		if err = store.ForTeam(team).Count(counted); err != nil {
+			log.Printf("%s.Handler - record: %s, team: %s, count error: %v", handler, record.EventID, team, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	config.MustLoad(handler, config.Table)
+	lambda.Start(Handler)
+}
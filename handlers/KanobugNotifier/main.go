@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/escalate"
+	"github.com/anzellai/kanobug/internal/eventbus"
+	"github.com/anzellai/kanobug/internal/flags"
+	"github.com/anzellai/kanobug/internal/mattermost"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/quiet"
+	"github.com/anzellai/kanobug/internal/scopes"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const handler = "KanobugNotifier"
+
+// message return the notification text for a bus event, empty when the event
+// is not notified
+func message(event events.CloudWatchEvent) (bug store.Bug, text string, err error) {
+	switch event.DetailType {
+	case eventbus.BugSubmitted:
+		var detail eventbus.BugDetail
+		if err = json.Unmarshal(event.Detail, &detail); err != nil {
+			return
+		}
+		bug = detail.Bug
+		text = fmt.Sprintf("New %s bug for %s from %s: %s (%s)", bug.Severity, bug.ProductName(), bug.UserName, bug.Summary, bug.ID)
+	case eventbus.StatusChanged:
+		var detail eventbus.StatusDetail
+		if err = json.Unmarshal(event.Detail, &detail); err != nil {
+			return
+		}
+		bug = detail.Bug
+		text = fmt.Sprintf("%s bug %s is now %s: %s", bug.ProductName(), bug.ID, bug.Status, bug.Summary)
+		if len(bug.Resolution) > 0 {
+			text += fmt.Sprintf(" (%s)", bug.Resolution)
+		}
+		if len(bug.MergedInto) > 0 {
+			text += fmt.Sprintf(", merged into %s", bug.MergedInto)
+		}
+	case eventbus.BugRecurred:
+		var detail eventbus.RecurredDetail
+		if err = json.Unmarshal(event.Detail, &detail); err != nil {
+			return
+		}
+		bug = detail.Bug
+		who := "Someone"
+		if len(detail.Actor) > 0 {
+			who = fmt.Sprintf("<@%s>", detail.Actor)
+		}
+		text = fmt.Sprintf(":repeat: %s says %s bug %s happened again (%d times now): %s", who, bug.ProductName(), bug.ID, bug.Recurrences, bug.Summary)
+		switch {
+		case detail.Issue != nil && detail.Regression:
+			text += fmt.Sprintf("\nFiled regression %s: %s", detail.Issue.Key, detail.Issue.URL)
+		case detail.Issue != nil:
+			text += fmt.Sprintf("\nReopened %s: %s", detail.Issue.Key, detail.Issue.URL)
+		}
+	case eventbus.BugEscalated:
+		var detail eventbus.EscalatedDetail
+		if err = json.Unmarshal(event.Detail, &detail); err != nil {
+			return
+		}
+		bug = detail.Bug
+		text = fmt.Sprintf(":rotating_light: %s %s bug %s has been %s for %s: %s", bug.ProductName(), bug.Severity, bug.ID, detail.Condition, detail.After, bug.Summary)
+	}
+	return
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// fanning bus events out to the channels subscribed to the bug's product and
+// status changes to the users CC'd on the bug. Once a bug is announced in a
+// channel, its updates there are replies in the announcement's thread
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %s %s", handler, event.DetailType, event.ID)
+	if event.DetailType == eventbus.AuthFailed {
+		return authAlert(event)
+	}
+	if event.DetailType == eventbus.ScopesMissing {
+		return scopesAlert(event)
+	}
+	bug, text, err := message(event)
+	if err != nil || len(text) == 0 {
+		log.Printf("%s.Handler - skipped: %s, error: %v", handler, event.DetailType, err)
+		return nil
+	}
+	// subscriptions and triage threads are kept with the bug's team
+	store.UseWorkspace(bug.EnterpriseID, bug.TeamID)
+	if event.DetailType == eventbus.BugEscalated {
+		escalation(event, bug, text)
+		return nil
+	}
+	if event.DetailType == eventbus.BugSubmitted && bug.Severity == store.SeverityBlocker {
+		page(bug, text)
+	}
+	if event.DetailType == eventbus.BugRecurred && bug.Thread == nil {
+		triage(bug, text)
+	}
+	// the reporter and CC'd users of a merged bug follow the bug it was
+	// merged into, only its closing as a duplicate is sent to them
+	redirected := len(bug.MergedInto) > 0 && bug.Status != store.StatusClosed
+	if redirected {
+		log.Printf("%s.Handler - bug: %s, merged into: %s, not sent to cc", handler, bug.ID, bug.MergedInto)
+	}
+	if event.DetailType == eventbus.StatusChanged && strings.HasPrefix(bug.Source, "slack") && !redirected {
+		var blocks []map[string]interface{}
+		if fixed(event, bug) {
+			blocks = verifyBlocks(bug, text)
+			if !bug.Anonymous {
+				mode, err := notify(bug.UserID, bug, event.Time, text, blocks)
+				log.Printf("%s.Handler - reporter: %s, bug: %s, mode: %s, error: %v", handler, bug.UserID, bug.ID, mode, err)
+			}
+		}
+		for _, userID := range bug.CC {
+			if userID == bug.UserID {
+				continue
+			}
+			mode, err := notify(userID, bug, event.Time, text, blocks)
+			log.Printf("%s.Handler - cc: %s, bug: %s, mode: %s, error: %v", handler, userID, bug.ID, mode, err)
+		}
+	}
+	var subscriptions []store.Subscription
+	if store.ConfigEnabled() {
+		if subscriptions, err = store.ListSubscriptions(bug.Product); err != nil {
+			return err
+		}
+	}
+	// Blockers are posted as they change, KanobugDigest posts the rest
+	digested := map[string]bool{}
+	for _, subscription := range subscriptions {
+		if event.DetailType == eventbus.StatusChanged && subscription.Digest > 0 && bug.Severity != store.SeverityBlocker {
+			digested[subscription.ChannelID] = true
+		}
+	}
+	// updates reply in the threads of the bug's triage post and
+	// announcements rather than posting anew
+	replied := map[string]bool{}
+	if event.DetailType != eventbus.BugSubmitted {
+		for _, thread := range bug.Threads() {
+			if digested[thread.Channel] {
+				continue
+			}
+			broadcast := event.DetailType == eventbus.BugRecurred || os.Getenv("THREAD_BROADCAST") == "true"
+			_, err = send(map[string]interface{}{"channel": thread.Channel, "text": text, "thread_ts": thread.TS, "reply_broadcast": broadcast})
+			log.Printf("%s.Handler - thread: %s/%s, bug: %s, error: %v", handler, thread.Channel, thread.TS, bug.ID, err)
+			replied[thread.Channel] = err == nil
+		}
+	}
+	var announced []store.Thread
+	for _, subscription := range subscriptions {
+		if replied[subscription.ChannelID] {
+			continue
+		}
+		if digested[subscription.ChannelID] {
+			err = store.AddDigest(store.DigestEntry{
+				TeamID:     bug.TeamID,
+				ChannelID:  subscription.ChannelID,
+				Platform:   subscription.Platform,
+				Interval:   subscription.Digest,
+				BugID:      bug.ID,
+				Product:    bug.ProductName(),
+				Summary:    bug.Summary,
+				Status:     bug.Status,
+				Resolution: bug.Resolution,
+				At:         event.Time,
+			})
+			log.Printf("%s.Handler - channel: %s, bug: %s, held for digest, error: %v", handler, subscription.ChannelID, bug.ID, err)
+			continue
+		}
+		if subscription.Platform == "mattermost" {
+			err = mattermost.Post(subscription.ChannelID, text)
+		} else {
+			var ts string
+			if ts, err = post(subscription.ChannelID, text); err == nil && event.DetailType == eventbus.BugSubmitted {
+				announced = append(announced, store.Thread{Channel: subscription.ChannelID, TS: ts})
+			}
+		}
+		log.Printf("%s.Handler - channel: %s, bug: %s, error: %v", handler, subscription.ChannelID, bug.ID, err)
+	}
+	if len(announced) > 0 {
+		_ = store.SetAnnouncements(bug, append(bug.Announced, announced...))
+	}
+	return nil
+}
+
+// authAlert post a tracker's rejection of the kanobug credentials to
+// OPS_CHANNEL, with what its response suggests is wrong
+func authAlert(event events.CloudWatchEvent) error {
+	var detail eventbus.AuthFailedDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		log.Printf("%s.authAlert - event: %s, error: %v", handler, event.ID, err)
+		return nil
+	}
+	channel := config.Get().OpsChannel
+	if len(channel) == 0 {
+		log.Printf("%s.authAlert - %s auth failed, no OPS_CHANNEL to alert", handler, detail.Tracker)
+		return nil
+	}
+	text := fmt.Sprintf(":closed_lock_with_key: %s rejected the kanobug credentials (%s auth as %s, status %d",
+		detail.Tracker, detail.Mode, detail.User, detail.Status)
+	if len(detail.Reason) > 0 {
+		text += ", " + detail.Reason
+	}
+	text += "): " + detail.Hint
+	_, err := post(channel, text)
+	log.Printf("%s.authAlert - channel: %s, tracker: %s, error: %v", handler, channel, detail.Tracker, err)
+	return nil
+}
+
+// scopesAlert post the scopes a Lambda found the bot token lacks on cold
+// start to OPS_CHANNEL
+func scopesAlert(event events.CloudWatchEvent) error {
+	var detail eventbus.ScopesDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		log.Printf("%s.scopesAlert - event: %s, error: %v", handler, event.ID, err)
+		return nil
+	}
+	channel := config.Get().OpsChannel
+	if len(channel) == 0 {
+		log.Printf("%s.scopesAlert - scopes missing, no OPS_CHANNEL to alert", handler)
+		return nil
+	}
+	var lines []string
+	for _, scope := range scopes.Sorted(detail.Missing) {
+		lines = append(lines, fmt.Sprintf("• `%s` for %s", scope, strings.Join(detail.Missing[scope], ", ")))
+	}
+	text := fmt.Sprintf(":closed_lock_with_key: %s found the kanobug bot token lacks scopes, add them to the Slack app and reinstall it:\n%s",
+		detail.Handler, strings.Join(lines, "\n"))
+	_, err := post(channel, text)
+	log.Printf("%s.scopesAlert - channel: %s, missing: %d, error: %v", handler, channel, len(detail.Missing), err)
+	return nil
+}
+
+// routes parse a "product=value;default=value" env variable and return the
+// entry for product, falling back to default
+func routes(name, product string) string {
+	m := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(name), ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	if value, ok := m[product]; ok {
+		return value
+	}
+	return m["default"]
+}
+
+// page post a Blocker bug to the product's triage channel mentioning its
+// on-call user group, and DM the group members when ONCALL_DM is set
+func page(bug store.Bug, text string) {
+	channel := routes("TRIAGE_CHANNELS", bug.Product)
+	group := routes("ONCALL_GROUPS", bug.Product)
+	if len(group) > 0 {
+		text = fmt.Sprintf("<!subteam^%s> %s", group, text)
+	}
+	if len(channel) > 0 {
+		var blocks []map[string]interface{}
+		if flags.Enabled(flags.Sprint, bug.TeamID, bug.Product) {
+			blocks = sprintBlocks(bug, text)
+		}
+		ts, err := postBlocks(channel, text, blocks)
+		log.Printf("%s.page - triage channel: %s, bug: %s, error: %v", handler, channel, bug.ID, err)
+		if err == nil {
+			// later triage replies, e.g. assignments, go to this thread
+			_ = store.SetThread(bug, store.Thread{Channel: channel, TS: ts})
+		}
+	}
+	if len(group) > 0 && os.Getenv("ONCALL_DM") == "true" {
+		dmGroup(group, bug, text)
+	}
+}
+
+// escalation notify a BugEscalated event in the bug's triage thread, or its
+// product's triage channel, mentioning the triage group for a ping and the
+// product's on-call group for a page, whose members are DM'd when ONCALL_DM
+// is set
+func escalation(event events.CloudWatchEvent, bug store.Bug, text string) {
+	var detail eventbus.EscalatedDetail
+	_ = json.Unmarshal(event.Detail, &detail)
+	group := os.Getenv("KANOBUG_TRIAGE_GROUP")
+	if detail.Action == escalate.Page {
+		group = routes("ONCALL_GROUPS", bug.Product)
+	}
+	log.Printf("%s.escalation - bug: %s, rule: %s, group: %s", handler, bug.ID, detail.Rule, group)
+	if len(group) > 0 {
+		text = fmt.Sprintf("<!subteam^%s> %s", group, text)
+	}
+	triage(bug, text)
+	if detail.Action == escalate.Page && len(group) > 0 && os.Getenv("ONCALL_DM") == "true" {
+		dmGroup(group, bug, text)
+	}
+}
+
+// dmGroup DM text about bug to the members of a Slack user group
+func dmGroup(group string, bug store.Bug, text string) {
+	members, err := groupMembers(group)
+	if err != nil {
+		log.Printf("%s.dmGroup - group: %s, error: %v", handler, group, err)
+		return
+	}
+	for _, member := range members {
+		mode, err := notify(member, bug, time.Now(), text, nil)
+		log.Printf("%s.dmGroup - dm: %s, bug: %s, mode: %s, error: %v", handler, member, bug.ID, mode, err)
+	}
+}
+
+// notify DM text to userID as their notification preferences for the
+// product of bug and their quiet hours say, see quiet.Hold, returning the
+// mode applied
+func notify(userID string, bug store.Bug, at time.Time, text string, blocks []map[string]interface{}) (mode string, err error) {
+	mode, held := quiet.Hold(userID, bug, at, text, blocks)
+	if !held {
+		_, err = postBlocks(userID, text, blocks)
+	}
+	return
+}
+
+// sprintBlocks return the triage post of bug with a button moving its Jira
+// issue into the active sprint, handled by KanobugInteractiveComponent
+func sprintBlocks(bug store.Bug, text string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		},
+		{
+			"type": "actions",
+			"elements": []map[string]interface{}{{
+				"type":      "button",
+				"action_id": "sprint",
+				"text":      map[string]string{"type": "plain_text", "text": "Add to current sprint"},
+				"value":     bug.ID,
+			}},
+		},
+	}
+}
+
+// verifyBlocks return the resolution DM of bug asking whether it was fixed,
+// Yes verifying the fix and No reporting it as happening again, handled by
+// KanobugInteractiveComponent
+func verifyBlocks(bug store.Bug, text string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text + "\n*Did this fix it?*"},
+		},
+		{
+			"type": "actions",
+			"elements": []map[string]interface{}{
+				{
+					"type":      "button",
+					"action_id": "verify",
+					"text":      map[string]string{"type": "plain_text", "text": "Yes, it's fixed"},
+					"style":     "primary",
+					"value":     bug.ID,
+				},
+				{
+					"type":      "button",
+					"action_id": "recur",
+					"text":      map[string]string{"type": "plain_text", "text": "No, it still happens"},
+					"value":     bug.ID,
+				},
+			},
+		},
+	}
+}
+
+// fixed report whether a StatusChanged event moved bug to done as fixed:
+// resolved, or closed as fixed, from an open status and not verified yet
+func fixed(event events.CloudWatchEvent, bug store.Bug) bool {
+	var detail eventbus.StatusDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil || len(bug.VerifiedBy) > 0 {
+		return false
+	}
+	if detail.PreviousStatus == store.StatusResolved || detail.PreviousStatus == store.StatusClosed {
+		return false
+	}
+	return bug.Status == store.StatusResolved || (bug.Status == store.StatusClosed && bug.Resolution == store.ResolutionFixed)
+}
+
+// triage post text to the triage channel of the bug's product, in the thread
+// of its triage post when it has one
+func triage(bug store.Bug, text string) {
+	channel := routes("TRIAGE_CHANNELS", bug.Product)
+	if bug.Thread != nil {
+		channel = bug.Thread.Channel
+	}
+	if len(channel) == 0 {
+		log.Printf("%s.triage - bug: %s, no triage channel", handler, bug.ID)
+		return
+	}
+	message := map[string]interface{}{"channel": channel, "text": text}
+	if bug.Thread != nil {
+		message["thread_ts"], message["reply_broadcast"] = bug.Thread.TS, true
+	}
+	_, err := send(message)
+	log.Printf("%s.triage - channel: %s, bug: %s, error: %v", handler, channel, bug.ID, err)
+}
+
+// groupMembers return the user IDs of a Slack user group
+func groupMembers(group string) (users []string, err error) {
+	return slack.Default.GroupMembers(group)
+}
+
+// post send text to a Slack channel, returning the message ts
+func post(channel, text string) (ts string, err error) {
+	return postBlocks(channel, text, nil)
+}
+
+// postBlocks send blocks to a Slack channel with text as the notification
+// fallback, returning the message ts
+func postBlocks(channel, text string, blocks []map[string]interface{}) (ts string, err error) {
+	message := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+	}
+	if len(blocks) > 0 {
+		message["blocks"] = blocks
+	}
+	return send(message)
+}
+
+// send post a chat.postMessage message, returning its ts
+func send(message map[string]interface{}) (ts string, err error) {
+	return slack.Default.PostMessage(message)
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Slack)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/flags"
+	"github.com/anzellai/kanobug/internal/i18n"
+	"github.com/anzellai/kanobug/internal/orchestrate"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/quiet"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+	"github.com/anzellai/kanobug/internal/validate"
+)
+
+const (
+	handler = "KanobugPipeline"
+)
+
+// Invalid is returned by the validate step, the state machine does not retry
+// it and goes straight to the failure notification
+type Invalid struct {
+	Fields map[string]string
+}
+
+func (invalid Invalid) Error() string {
+	var problems []string
+	for field, problem := range invalid.Fields {
+		problems = append(problems, field+": "+problem)
+	}
+	return strings.Join(problems, "; ")
+}
+
+// NotFiled is returned by the create-issue step when every tracker failed,
+// nothing was filed so the step is safe to retry
+type NotFiled struct {
+	Trackers int
+}
+
+func (notFiled NotFiled) Error() string {
+	return fmt.Sprintf("all %d tracker(s) failed", notFiled.Trackers)
+}
+
+// Task is the input of a state machine task, the step to run and the
+// submission state so far
+type Task struct {
+	Step       string                 `json:"step"`
+	Submission orchestrate.Submission `json:"submission"`
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// running one step of the submission state machine and returning the state
+// the next step gets
+func Handler(ctx context.Context, task Task) (submission orchestrate.Submission, err error) {
+	outbound.Use(ctx)
+	submission = task.Submission
+	defer func() {
+		log.Printf("%s.Handler - step: %s, bug: %s, error: %v", handler, task.Step, submission.Bug.ID, err)
+	}()
+	// the bug's team, submissions are started by the interactive handler
+	store.UseWorkspace(submission.Bug.EnterpriseID, submission.Bug.TeamID)
+	switch task.Step {
+	case orchestrate.StepValidate:
+		err = validateBug(submission)
+	case orchestrate.StepDedupe:
+		var original store.Bug
+		var found bool
+		if !flags.Enabled(flags.Dedupe, submission.Bug.TeamID, submission.Bug.Product) {
+			break
+		}
+		if original, found, err = pipeline.Duplicate(submission.Bug); found {
+			submission.DuplicateOf = original.ID
+			if len(original.IssueKey) > 0 {
+				submission.DuplicateOf = original.IssueKey
+			}
+		}
+	case orchestrate.StepStore:
+		err = storeBug(&submission)
+	case orchestrate.StepCreateIssue:
+		err = createIssue(&submission)
+	case orchestrate.StepAttachFiles:
+		submission.Skipped = pipeline.Attach(submission.Issues, submission.Files)
+	case orchestrate.StepNotify:
+		notify(submission)
+	case orchestrate.StepFailed:
+		notifyFailure(submission)
+	default:
+		err = fmt.Errorf("unknown step: %s", task.Step)
+	}
+	return
+}
+
+// validateBug check the submitted fields again, the form may have been
+// submitted by an older app version
+func validateBug(submission orchestrate.Submission) error {
+	bug := submission.Bug
+	fields := validate.Errors(validate.Form{Summary: bug.Summary, Product: bug.Product, Details: bug.Details, Locale: bug.Locale})
+	if len(bug.Product) == 0 {
+		fields["product"] = "Please pick a product."
+	}
+	if len(fields) > 0 {
+		return Invalid{Fields: fields}
+	}
+	return nil
+}
+
+// storeBug persist the bug, a retry of a bug already stored reads it back
+// rather than failing on the existing item
+func storeBug(submission *orchestrate.Submission) (err error) {
+	err = pipeline.Store(&submission.Bug)
+	if err == store.ErrConflict {
+		submission.Bug, err = store.GetBug(submission.Bug.ID)
+	}
+	return
+}
+
+// createIssue file the stored bug, failing only when no routed tracker
+// created an issue; queued bugs are filed by KanobugRetry
+func createIssue(submission *orchestrate.Submission) error {
+	submission.Issues = pipeline.File(submission.Bug, submission.Email)
+	trackers := len(tracker.ForProduct(submission.Bug.Product))
+	if len(submission.Issues) == 0 && trackers > 0 && !submission.Bug.Queued {
+		return NotFiled{Trackers: trackers}
+	}
+	return nil
+}
+
+// notify confirm the filed issues, or the earlier report of a duplicate, to
+// the reporter and the CC'd users
+func notify(submission orchestrate.Submission) {
+	bug := submission.Bug
+	var lines []string
+	if len(submission.DuplicateOf) > 0 {
+		lines = append(lines, fmt.Sprintf("You already reported this a moment ago as %s, it was not filed again.", submission.DuplicateOf))
+	}
+	for _, issue := range submission.Issues {
+		lines = append(lines, issue.TextIn(bug.Locale))
+	}
+	if len(lines) == 0 && bug.Queued {
+		lines = append(lines, pipeline.QueuedText(bug))
+	}
+	if len(submission.Skipped) > 0 {
+		lines = append(lines, i18n.T(bug.Locale, "confirm.not_attached", strings.Join(submission.Skipped, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	text := strings.Join(lines, "\n")
+	if len(submission.Issues) > 0 {
+		cc(bug, submission.Issues, text)
+	}
+	if bug.Anonymous || len(submission.ResponseURL) == 0 {
+		err := dm(submission.ReporterID, text)
+		log.Printf("%s.notify - dm: bug: %s, error: %v", handler, bug.ID, err)
+		return
+	}
+	respond(submission, text)
+}
+
+// notifyFailure tell the reporter their bug was not filed, and OPS_CHANNEL
+// which step failed
+func notifyFailure(submission orchestrate.Submission) {
+	bug := submission.Bug
+	cause, text := "unknown error", "Sorry, your bug could not be filed, the team has been told."
+	if failure := submission.Failure; failure != nil {
+		cause = failure.Error + ": " + failure.Message()
+		if failure.Error == "Invalid" {
+			text = "Sorry, your bug could not be filed: " + failure.Message()
+		}
+	}
+	if bug.Anonymous || len(submission.ResponseURL) == 0 {
+		err := dm(submission.ReporterID, text)
+		log.Printf("%s.notifyFailure - dm: bug: %s, error: %v", handler, bug.ID, err)
+	} else {
+		respond(submission, text)
+	}
+	if channel := config.Get().OpsChannel; len(channel) > 0 {
+		err := dm(channel, fmt.Sprintf(":warning: %s bug %s (%s) failed the submission pipeline: %s", bug.ProductName(), bug.ID, bug.Summary, cause))
+		log.Printf("%s.notifyFailure - channel: %s, bug: %s, error: %v", handler, channel, bug.ID, err)
+	}
+}
+
+// cc send the CC'd users the confirmation and add them as watchers of the
+// bug's Jira issues when their Slack email matches a Jira user
+func cc(bug store.Bug, issues []tracker.Issue, confirmation string) {
+	reporter := fmt.Sprintf("<@%s>", bug.UserID)
+	if bug.Anonymous {
+		reporter = "An anonymous reporter"
+	}
+	text := fmt.Sprintf("%s CC'd you on a bug: %s\n%s", reporter, bug.Summary, confirmation)
+	jira := tracker.NewJira()
+	for _, userID := range bug.CC {
+		if userID == bug.UserID {
+			continue
+		}
+		var err error
+		mode, held := quiet.Hold(userID, bug, time.Now(), text, nil)
+		if !held {
+			err = dm(userID, text)
+		}
+		log.Printf("%s.cc - user: %s, bug: %s, mode: %s, error: %v", handler, userID, bug.ID, mode, err)
+		email := userEmail(userID)
+		for _, issue := range issues {
+			if issue.Tracker == jira.Name() && len(email) > 0 {
+				_ = jira.AddWatcher(issue, email)
+			}
+		}
+	}
+}
+
+// respond post text to the submission's response url, or DM it to the
+// reporter once the response url expired, e.g. after a long tracker outage
+func respond(submission orchestrate.Submission, text string) {
+	responder := slack.NewResponder(submission.ResponseURL, submission.Platform)
+	responder.Issued = submission.Issued
+	err := responder.Reply(text)
+	log.Printf("%s.respond - error: %v", handler, err)
+	if err != nil && submission.Platform == "slack" {
+		err = dm(submission.ReporterID, text)
+		log.Printf("%s.respond - dm: %s, error: %v", handler, submission.ReporterID, err)
+	}
+}
+
+// dm send text to a Slack user or channel as a message from the app
+func dm(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+// userEmail look up the Slack user's email, empty when unavailable
+func userEmail(userID string) string {
+	user, err := slack.Default.UserInfo(userID)
+	if err != nil {
+		log.Printf("%s.userEmail - error: %v", handler, err)
+		return ""
+	}
+	return user.Profile.Email
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Slack, config.Trackers)
+	lambda.Start(Handler)
+}
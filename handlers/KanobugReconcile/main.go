@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/markup"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+	"github.com/anzellai/kanobug/internal/translate"
+)
+
+const (
+	handler = "KanobugReconcile"
+
+	// maxReported is how many discrepancies are listed in the OPS_CHANNEL
+	// report, the rest are only counted
+	maxReported = 20
+)
+
+// Kinds of discrepancies, the Kind dimension of ReconcileDiscrepancies
+const (
+	linkRepaired = "LinkRepaired"
+	refiled      = "Refiled"
+	issueMissing = "IssueMissing"
+	statusDrift  = "StatusDrift"
+	summaryDrift = "SummaryDrift"
+)
+
+// discrepancy is a difference found between a bug and its Jira issue
+type discrepancy struct {
+	kind string
+	text string
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+// nightly, comparing the filed bugs of every team with their Jira issues.
+// Lost links are repaired from the issue labelled with the bug ID, open bugs
+// whose issue is gone are filed again, and status and summary drift is
+// reported to OPS_CHANNEL with everything repaired
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	bugs, err := store.ScanBugs()
+	if err != nil {
+		return err
+	}
+	jira := tracker.NewJira()
+	var found []discrepancy
+	checked, failed := 0, 0
+	for _, bug := range bugs {
+		// new bugs are queued for KanobugRetry, merged ones follow another
+		if bug.Status == store.StatusNew || len(bug.MergedInto) > 0 {
+			continue
+		}
+		// product routing is configured per team
+		store.UseWorkspace(bug.EnterpriseID, bug.TeamID)
+		if !routed(jira, bug) {
+			continue
+		}
+		discrepancies, err := reconcile(jira, bug)
+		if err != nil {
+			log.Printf("%s.Handler - bug: %s/%s, error: %v", handler, bug.TeamID, bug.ID, err)
+			failed++
+			continue
+		}
+		checked++
+		for _, d := range discrepancies {
+			emf.Count(emf.ReconcileDiscrepancies, emf.Dimensions{"Kind": d.kind})
+		}
+		found = append(found, discrepancies...)
+	}
+	log.Printf("%s.Handler - checked: %d, failed: %d, discrepancies: %d", handler, checked, failed, len(found))
+	report(checked, failed, found)
+	return nil
+}
+
+// routed report whether bug is filed to Jira
+func routed(jira tracker.Jira, bug store.Bug) bool {
+	for _, t := range tracker.ForProduct(bug.Product) {
+		if t.Name() == jira.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcile compare bug with its Jira issue, repairing what can be repaired,
+// and return the discrepancies found
+func reconcile(jira tracker.Jira, bug store.Bug) (found []discrepancy, err error) {
+	at := -1
+	for i, issue := range bug.Issues {
+		if issue.Tracker == jira.Name() && len(issue.Key) > 0 {
+			at = i
+			break
+		}
+	}
+	open := bug.Status != store.StatusResolved && bug.Status != store.StatusClosed
+	if at < 0 {
+		issue, ok, err := jira.FindByBug(bug.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if err = store.LinkIssues(bug, append(bug.Issues, issue)); err != nil {
+				return nil, err
+			}
+			bug.Issues = append(bug.Issues, issue)
+			at = len(bug.Issues) - 1
+			found = append(found, discrepancy{linkRepaired, fmt.Sprintf("bug %s was linked to %s again", bug.ID, issue.Key)})
+		} else if open {
+			issue, err = refile(jira, bug, -1)
+			if err != nil {
+				return nil, err
+			}
+			return append(found, discrepancy{refiled, fmt.Sprintf("bug %s had no Jira issue, filed as %s", bug.ID, issue.Key)}), nil
+		} else {
+			return append(found, discrepancy{issueMissing, fmt.Sprintf("bug %s (%s) has no Jira issue", bug.ID, bug.Status)}), nil
+		}
+	}
+	issue := bug.Issues[at]
+	current, err := jira.Issue(issue.Key)
+	switch {
+	case err == tracker.ErrNoIssue && open:
+		refiledAs, err := refile(jira, bug, at)
+		if err != nil {
+			return nil, err
+		}
+		return append(found, discrepancy{refiled, fmt.Sprintf("%s of bug %s is gone, filed again as %s", issue.Key, bug.ID, refiledAs.Key)}), nil
+	case err == tracker.ErrNoIssue:
+		return append(found, discrepancy{issueMissing, fmt.Sprintf("%s of %s bug %s is gone", issue.Key, bug.Status, bug.ID)}), nil
+	case err != nil:
+		return nil, err
+	}
+	if done := current.Fields.Done(); done == open {
+		found = append(found, discrepancy{statusDrift, fmt.Sprintf("%s is %s in Jira, bug %s is %s", issue.Key, current.Fields.StatusName(), bug.ID, bug.Status)})
+	}
+	// translated summaries differ by design
+	if !translate.Enabled(bug.Product) && strings.TrimSpace(current.Fields.Summary) != strings.TrimSpace(markup.Summary(bug.Summary)) {
+		found = append(found, discrepancy{summaryDrift, fmt.Sprintf("%s is titled %q in Jira, bug %s %q", issue.Key, current.Fields.Summary, bug.ID, bug.Summary)})
+	}
+	return
+}
+
+// refile file bug to Jira again, replacing its issue at (appending when
+// negative), and return the new issue
+func refile(jira tracker.Jira, bug store.Bug, at int) (issue tracker.Issue, err error) {
+	if issue, err = jira.CreateIssue(bug); err != nil {
+		return
+	}
+	issues := append([]tracker.Issue{}, bug.Issues...)
+	if at < 0 {
+		issues = append(issues, issue)
+	} else {
+		issues[at] = issue
+	}
+	err = store.LinkIssues(bug, issues)
+	log.Printf("%s.refile - bug: %s, issue: %s, error: %v", handler, bug.ID, issue.Key, err)
+	return
+}
+
+// report post the reconciliation summary to OPS_CHANNEL, nothing when every
+// bug matched its issue
+func report(checked, failed int, found []discrepancy) {
+	channel := config.Get().OpsChannel
+	if len(channel) == 0 || (len(found) == 0 && failed == 0) {
+		return
+	}
+	counts := map[string]int{}
+	for _, d := range found {
+		counts[d.kind]++
+	}
+	lines := []string{fmt.Sprintf(":mag: Reconciled %d bug(s) with Jira: %d link(s) repaired, %d re-filed, %d missing, %d status and %d summary drift(s), %d not checked.",
+		checked, counts[linkRepaired], counts[refiled], counts[issueMissing], counts[statusDrift], counts[summaryDrift], failed)}
+	for i, d := range found {
+		if i == maxReported {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(found)-maxReported))
+			break
+		}
+		lines = append(lines, "• "+d.text)
+	}
+	err := post(channel, strings.Join(lines, "\n"))
+	log.Printf("%s.report - channel: %s, error: %v", handler, channel, err)
+}
+
+// post send text to a Slack channel
+func post(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
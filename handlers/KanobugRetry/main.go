@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/quiet"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	handler = "KanobugRetry"
+)
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+// on a schedule, filing the bugs queued while a tracker was unavailable
+// once its breaker lets calls through again. Reporters of the bugs filed
+// are told, and OPS_CHANNEL when the queue is drained
+func Handler(ctx context.Context, event events.CloudWatchEvent) error {
+	outbound.Use(ctx)
+	queued, err := store.ListQueued()
+	if err != nil || len(queued) == 0 {
+		return err
+	}
+	log.Printf("%s.Handler - queued: %d", handler, len(queued))
+	filed, remaining := 0, 0
+	for _, q := range queued {
+		store.UseTeam(q.TeamID)
+		bug, err := store.GetBug(q.BugID)
+		if err != nil {
+			log.Printf("%s.Handler - bug: %s/%s, error: %v", handler, q.TeamID, q.BugID, err)
+			remaining++
+			continue
+		}
+		// the bug's org holds the install of Grid workspaces
+		store.UseWorkspace(bug.EnterpriseID, bug.TeamID)
+		if bug.Status != store.StatusNew || bug.DeletedAt != nil {
+			// filed by kanobugctl replay, or deleted meanwhile
+			_ = store.Dequeue(q)
+			continue
+		}
+		if unavailable := pipeline.Unavailable(bug.Product); len(unavailable) > 0 {
+			log.Printf("%s.Handler - bug: %s, still unavailable: %v", handler, bug.ID, unavailable)
+			remaining++
+			continue
+		}
+		issues := pipeline.File(bug, "")
+		if len(issues) == 0 {
+			remaining++
+			continue
+		}
+		filed++
+		_ = store.Dequeue(q)
+		var lines []string
+		for _, issue := range issues {
+			lines = append(lines, issue.TextIn(bug.Locale))
+		}
+		if strings.HasPrefix(bug.Source, "slack") && !bug.Anonymous {
+			text := fmt.Sprintf("Your queued bug %s has been filed:\n%s", bug.Summary, strings.Join(lines, "\n"))
+			mode, held := quiet.Hold(bug.UserID, bug, time.Now(), text, nil)
+			if !held {
+				err = post(bug.UserID, text)
+			}
+			log.Printf("%s.Handler - reporter: %s, bug: %s, mode: %s, error: %v", handler, bug.UserID, bug.ID, mode, err)
+		}
+	}
+	log.Printf("%s.Handler - filed: %d, remaining: %d", handler, filed, remaining)
+	if filed > 0 && remaining == 0 {
+		if channel := config.Get().OpsChannel; len(channel) > 0 {
+			err = post(channel, fmt.Sprintf(":white_check_mark: Trackers are available again, %d queued bug(s) filed.", filed))
+			log.Printf("%s.Handler - recovery notice: %s, error: %v", handler, channel, err)
+		}
+	}
+	return nil
+}
+
+// post send text to a Slack user or channel
+func post(channel, text string) (err error) {
+	_, err = slack.Default.PostMessage(map[string]interface{}{"channel": channel, "text": text})
+	return
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
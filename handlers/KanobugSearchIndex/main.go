@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/search"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const handler = "KanobugSearchIndex"
+
+// ready is set once the index was created with its mapping by this
+// container, indexing into a missing one would map the keywords as text
+var ready bool
+
+// image convert a stream image to table attribute values, both share the
+// DynamoDB JSON encoding
+func image(attributes map[string]events.DynamoDBAttributeValue) (item map[string]*dynamodb.AttributeValue, err error) {
+	if len(attributes) == 0 {
+		return
+	}
+	body, err := json.Marshal(attributes)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &item)
+	return
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call,
+// mirroring the bugs written in a batch of table stream records into the
+// SEARCH_ENDPOINT index: stored bugs are indexed, deleted and expired ones
+// removed. Failed records are logged and skipped, the next write of the bug
+// or kanobugctl reindex catches up
+func Handler(ctx context.Context, event events.DynamoDBEvent) error {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %d record(s)", handler, len(event.Records))
+	if !search.Enabled() {
+		return nil
+	}
+	if !ready {
+		if err := search.Setup(); err != nil {
+			return err
+		}
+		ready = true
+	}
+	for _, record := range event.Records {
+		attributes := record.Change.NewImage
+		if record.EventName == string(events.DynamoDBOperationTypeRemove) {
+			attributes = record.Change.OldImage
+		}
+		item, err := image(attributes)
+		if err != nil {
+			log.Printf("%s.Handler - record: %s, image error: %v", handler, record.EventID, err)
+			continue
+		}
+		bug, ok, err := store.StreamBug(item)
+		if err != nil || !ok {
+			if err != nil {
+				log.Printf("%s.Handler - record: %s, error: %v", handler, record.EventID, err)
+			}
+			continue
+		}
+		if record.EventName == string(events.DynamoDBOperationTypeRemove) || bug.DeletedAt != nil {
+			_ = search.Remove(bug.TeamID, bug.ID)
+			continue
+		}
+		_ = search.Index(bug)
+	}
+	return nil
+}
+
+func main() {
+	config.MustLoad(handler)
+	lambda.Start(Handler)
+}
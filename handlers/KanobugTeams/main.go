@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/teams"
+)
+
+const (
+	handler    = "KanobugTeams"
+	callbackID = "report-bug"
+)
+
+// mention matches the bot @mention Teams prepends to channel messages
+var mention = regexp.MustCompile(`<at>[^<]*</at>`)
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// Submission is the Adaptive Card form data sent back on submit
+type Submission struct {
+	CallbackID string `json:"callback_id"`
+	Summary    string `json:"summary"`
+	Product    string `json:"product"`
+	Severity   string `json:"severity"`
+	Security   string `json:"security"`
+	Customer   string `json:"customer_impacting"`
+	Details    string `json:"details"`
+}
+
+var client = &teams.Client{
+	AppID:       os.Getenv("MICROSOFT_APP_ID"),
+	AppPassword: os.Getenv("MICROSOFT_APP_PASSWORD"),
+}
+
+// ToBug transform the submission from activity sender to Bug
+func (submission Submission) ToBug(activity teams.Activity) store.Bug {
+	details := submission.Details
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	userID := activity.From.AADObjectID
+	if len(userID) == 0 {
+		userID = activity.From.ID
+	}
+	now := time.Now()
+	return store.Bug{
+		Source:            "teams",
+		UserID:            userID,
+		UserName:          activity.From.Name,
+		Summary:           submission.Summary,
+		Product:           submission.Product,
+		Severity:          submission.Severity,
+		Security:          submission.Security == "yes",
+		CustomerImpacting: submission.Customer == "yes",
+		Details:           details,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %+v", handler, r)
+	resp := Response{
+		StatusCode:      200,
+		IsBase64Encoded: false,
+		Body:            "",
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+
+	activity := teams.Activity{}
+	if err := json.Unmarshal([]byte(r.Body), &activity); err != nil {
+		log.Printf("%s.Handler - unmarshal activity error: %+v", handler, err)
+		resp.StatusCode = 400
+		return resp, nil
+	}
+	if err := teams.Verify(header(r, "Authorization"), client.AppID, activity.ServiceURL); err != nil {
+		log.Printf("%s.Handler - verify error: %v", handler, err)
+		resp.StatusCode = 401
+		resp.Body = fmt.Sprintf("%s - error: %v", handler, err)
+		return resp, nil
+	}
+	if activity.Type != "message" {
+		return resp, nil
+	}
+
+	var err error
+	if len(activity.Value) > 0 {
+		err = submit(activity)
+	} else {
+		text := strings.TrimSpace(mention.ReplaceAllString(activity.Text, ""))
+		text = strings.TrimSpace(strings.TrimPrefix(text, "bug"))
+		err = client.Reply(activity, teams.Activity{
+			Type: "message",
+			Attachments: []teams.Attachment{
+				teams.Attachment{ContentType: teams.AdaptiveCardType, Content: reportCard(text)},
+			},
+		})
+	}
+	log.Printf("%s.Handler - activity: %s, error: %v", handler, activity.ID, err)
+	return resp, nil
+}
+
+func submit(activity teams.Activity) error {
+	submission := Submission{}
+	if err := json.Unmarshal(activity.Value, &submission); err != nil {
+		return err
+	}
+	if submission.CallbackID != callbackID {
+		return nil
+	}
+	if len(strings.TrimSpace(submission.Summary)) == 0 || len(submission.Product) == 0 {
+		return client.Reply(activity, teams.Activity{
+			Type: "message",
+			Text: "Please fill in the summary and pick a product.",
+		})
+	}
+
+	bug := submission.ToBug(activity)
+	err := pipeline.Store(&bug)
+	log.Printf("%s.submit - bug: %+v, error: %v", handler, bug, err)
+
+	var email string
+	if bug.CustomerImpacting {
+		email = client.Email(activity)
+	}
+	var lines []string
+	for _, issue := range pipeline.File(bug, email) {
+		lines = append(lines, issue.Text())
+	}
+	if len(lines) == 0 && bug.Queued {
+		lines = append(lines, pipeline.QueuedText(bug))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Thanks, your bug has been received.")
+	}
+	return client.Reply(activity, teams.Activity{
+		Type: "message",
+		Text: strings.Join(lines, "\n\n"),
+	})
+}
+
+// reportCard return the Adaptive Card equivalent of the Slack report dialog
+func reportCard(summary string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.2",
+		"body": []map[string]interface{}{
+			{"type": "TextBlock", "text": "Report a Bug", "weight": "bolder", "size": "medium"},
+			{"type": "TextBlock", "text": "Summarise the Problem", "wrap": true},
+			{"type": "Input.Text", "id": "summary", "value": summary, "placeholder": "A sentence to summarise the problem"},
+			{"type": "TextBlock", "text": "Product", "wrap": true},
+			{"type": "Input.ChoiceSet", "id": "product", "style": "compact", "choices": choices(catalog.ProductOptions())},
+			{"type": "TextBlock", "text": "Severity", "wrap": true},
+			{"type": "Input.ChoiceSet", "id": "severity", "style": "compact", "value": catalog.DefaultSeverity, "choices": choices(catalog.Severities)},
+			{"type": "Input.Toggle", "id": "security", "title": "Security sensitive - keep it confidential", "valueOn": "yes", "valueOff": "no", "value": "no"},
+			{"type": "Input.Toggle", "id": "customer_impacting", "title": "Customer impacting - also open a support ticket", "valueOn": "yes", "valueOff": "no", "value": "no"},
+			{"type": "TextBlock", "text": "Any more details?", "wrap": true},
+			{"type": "Input.Text", "id": "details", "isMultiline": true, "placeholder": "If you can help us reproduce the bug, that'd be grand."},
+		},
+		"actions": []map[string]interface{}{
+			{"type": "Action.Submit", "title": "Submit", "data": map[string]string{"callback_id": callbackID}},
+		},
+	}
+}
+
+func choices(from []catalog.Option) (list []map[string]string) {
+	for _, o := range from {
+		list = append(list, map[string]string{"title": o.Label, "value": o.Value})
+	}
+	return
+}
+
+func header(r ProxyRequest, name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
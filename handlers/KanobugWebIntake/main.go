@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/pipeline"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+const (
+	handler          = "KanobugWebIntake"
+	captchaVerifyURL = "https://hcaptcha.com/siteverify"
+)
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Report a Bug</title>
+<script src="https://js.hcaptcha.com/1/api.js" async defer></script>
+<style>body{font-family:sans-serif;max-width:40em;margin:2em auto}label{display:block;margin-top:1em}input,select,textarea{width:100%}</style>
+</head>
+<body>
+<h1>Report a Bug</h1>
+{{if .Error}}<p style="color:#c00">{{.Error}}</p>{{end}}
+{{if .Issues}}<p>Thanks, your bug was submitted:</p><ul>{{range .Issues}}<li>{{if .URL}}<a href="{{.URL}}">{{.Key}}</a>{{else}}{{.Tracker}}{{end}}</li>{{end}}</ul>{{end}}
+{{if .Submitted}}{{if not .Issues}}<p>Thanks, your bug has been received.</p>{{end}}{{else}}
+<form method="post">
+<label>Your name <input name="reporter" required></label>
+<label>Your email <input name="email" type="email" required></label>
+<label>Summarise the Problem <input name="summary" maxlength="150" required></label>
+<label>Product <select name="product" required>{{range .Products}}<option value="{{.Value}}">{{.Label}}</option>{{end}}</select></label>
+<label>Severity <select name="severity">{{range .Severities}}<option value="{{.Value}}"{{if eq .Value $.DefaultSeverity}} selected{{end}}>{{.Label}}</option>{{end}}</select></label>
+<label>Any more details? <textarea name="details" rows="8" placeholder="If you can help us reproduce the bug, that'd be grand."></textarea></label>
+<div class="h-captcha" data-sitekey="{{.SiteKey}}" style="margin-top:1em"></div>
+<p><button type="submit">Submit</button></p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest event type ...
+type ProxyRequest events.APIGatewayProxyRequest
+
+// Submission is a bug report posted as JSON or from the web form
+type Submission struct {
+	Reporter string `json:"reporter"`
+	Email    string `json:"email"`
+	Summary  string `json:"summary"`
+	Product  string `json:"product"`
+	Severity string `json:"severity"`
+	Details  string `json:"details"`
+}
+
+type view struct {
+	Products        []catalog.Option
+	Severities      []catalog.Option
+	DefaultSeverity string
+	SiteKey         string
+	Error           string
+	Submitted       bool
+	Issues          []tracker.Issue
+}
+
+// ToBug transform the submission to Bug
+func (submission Submission) ToBug() store.Bug {
+	details := submission.Details
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	severity := submission.Severity
+	if len(severity) == 0 {
+		severity = catalog.DefaultSeverity
+	}
+	now := time.Now()
+	return store.Bug{
+		Source:    "web",
+		UserID:    strings.ToLower(submission.Email),
+		UserName:  submission.Reporter,
+		Summary:   submission.Summary,
+		Product:   submission.Product,
+		Severity:  severity,
+		Details:   details,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// validate check the fields every submission needs
+func (submission Submission) validate() error {
+	switch {
+	case len(strings.TrimSpace(submission.Summary)) == 0:
+		return errors.New("summary is required")
+	case len(strings.TrimSpace(submission.Email)) == 0:
+		return errors.New("email is required")
+	}
+	for _, p := range catalog.ProductOptions() {
+		if p.Value == submission.Product {
+			return nil
+		}
+	}
+	return errors.New("unknown product")
+}
+
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	outbound.Use(ctx)
+	log.Printf("%s.Handler - invoke: %s %s", handler, r.HTTPMethod, r.Path)
+	v := view{
+		Products:        catalog.ProductOptions(),
+		Severities:      catalog.Severities,
+		DefaultSeverity: catalog.DefaultSeverity,
+		SiteKey:         os.Getenv("CAPTCHA_SITE_KEY"),
+	}
+	if r.HTTPMethod == "GET" {
+		return render(200, v), nil
+	}
+
+	body := r.Body
+	if r.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return respondJSON(400, map[string]string{"error": "invalid body"}), nil
+		}
+		body = string(decoded)
+	}
+
+	if strings.HasPrefix(header(r, "Content-Type"), "application/json") {
+		if !validAPIKey(header(r, "X-API-Key")) {
+			return respondJSON(401, map[string]string{"error": "invalid api key"}), nil
+		}
+		submission := Submission{}
+		if err := json.Unmarshal([]byte(body), &submission); err != nil {
+			return respondJSON(400, map[string]string{"error": "invalid json"}), nil
+		}
+		if err := submission.validate(); err != nil {
+			return respondJSON(422, map[string]string{"error": err.Error()}), nil
+		}
+		issues, err := submit(submission)
+		if err != nil {
+			return respondJSON(500, map[string]string{"error": "could not store bug"}), nil
+		}
+		return respondJSON(201, map[string]interface{}{"issues": issues}), nil
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		v.Error = "Could not read the form, please try again."
+		return render(400, v), nil
+	}
+	if err = verifyCaptcha(form.Get("h-captcha-response"), header(r, "X-Forwarded-For")); err != nil {
+		log.Printf("%s.Handler - captcha error: %v", handler, err)
+		v.Error = "Please complete the captcha."
+		return render(400, v), nil
+	}
+	submission := Submission{
+		Reporter: form.Get("reporter"),
+		Email:    form.Get("email"),
+		Summary:  form.Get("summary"),
+		Product:  form.Get("product"),
+		Severity: form.Get("severity"),
+		Details:  form.Get("details"),
+	}
+	if err = submission.validate(); err != nil {
+		v.Error = "Please check the form: " + err.Error()
+		return render(422, v), nil
+	}
+	if v.Issues, err = submit(submission); err != nil {
+		v.Error = "Sorry, we could not save your bug, please try again."
+		return render(500, v), nil
+	}
+	v.Submitted = true
+	return render(201, v), nil
+}
+
+func submit(submission Submission) (issues []tracker.Issue, err error) {
+	bug := submission.ToBug()
+	err = pipeline.Store(&bug)
+	log.Printf("%s.submit - bug: %+v, error: %v", handler, bug, err)
+	if err != nil {
+		return
+	}
+	issues = pipeline.File(bug, submission.Email)
+	return
+}
+
+// validAPIKey check key against the comma separated INTAKE_API_KEYS
+func validAPIKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, k := range strings.Split(os.Getenv("INTAKE_API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); len(k) > 0 && subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyCaptcha check the hCaptcha response token with the siteverify API
+func verifyCaptcha(token, remoteIP string) error {
+	if len(token) == 0 {
+		return errors.New("missing captcha response")
+	}
+	form := url.Values{}
+	form.Set("secret", os.Getenv("CAPTCHA_SECRET"))
+	form.Set("response", token)
+	if len(remoteIP) > 0 {
+		form.Set("remoteip", strings.TrimSpace(strings.Split(remoteIP, ",")[0]))
+	}
+	req, err := http.NewRequest("POST", captchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := outbound.Do(outbound.Captcha, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("captcha rejected: " + strings.Join(result.ErrorCodes, ","))
+	}
+	return nil
+}
+
+func render(status int, v view) Response {
+	var body bytes.Buffer
+	if err := page.Execute(&body, v); err != nil {
+		log.Printf("%s.render - error: %v", handler, err)
+	}
+	return Response{
+		StatusCode:      status,
+		IsBase64Encoded: false,
+		Body:            body.String(),
+		Headers: map[string]string{
+			"Content-Type": "text/html; charset=utf-8",
+		},
+	}
+}
+
+func respondJSON(status int, body interface{}) Response {
+	payload, _ := json.Marshal(body)
+	return Response{
+		StatusCode:      status,
+		IsBase64Encoded: false,
+		Body:            string(payload),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+func header(r ProxyRequest, name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func main() {
+	config.MustLoad(handler, config.Table, config.Trackers)
+	lambda.Start(Handler)
+}
@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Product is the analytics of a product over a window: how many of its bugs
+// were submitted, triaged and resolved, and the median time from submission
+// to triage, the first move past filed, and to resolution in seconds
+type Product struct {
+	Product           string `json:"product"`
+	Submitted         int64  `json:"submitted"`
+	Triaged           int64  `json:"triaged"`
+	Resolved          int64  `json:"resolved"`
+	TriageSeconds     int64  `json:"median_time_to_triage_seconds"`
+	ResolutionSeconds int64  `json:"median_time_to_resolution_seconds"`
+}
+
+// Report return the analytics of the UTC days from since to until per
+// product, sorted by product, from the daily counters
+func Report(since, until time.Time) (products []Product, err error) {
+	counters, err := store.ListCounters(since, until)
+	if err != nil {
+		return
+	}
+	return Summarize(counters), nil
+}
+
+// Summarize return the analytics of counters per product, sorted by product
+func Summarize(counters []store.Counter) (products []Product) {
+	byProduct := map[string]*Product{}
+	triage := map[string][]int64{}
+	resolution := map[string][]int64{}
+	var order []string
+	for _, c := range counters {
+		p, ok := byProduct[c.Product]
+		if !ok {
+			p = &Product{Product: c.Product}
+			byProduct[c.Product] = p
+			order = append(order, c.Product)
+		}
+		p.Submitted += c.Submitted
+		p.Triaged += c.Triaged
+		p.Resolved += c.Resolved
+		triage[c.Product] = append(triage[c.Product], c.TriageSeconds...)
+		resolution[c.Product] = append(resolution[c.Product], c.ResolutionSeconds...)
+	}
+	sort.Strings(order)
+	for _, product := range order {
+		p := byProduct[product]
+		p.TriageSeconds = median(triage[product])
+		p.ResolutionSeconds = median(resolution[product])
+		products = append(products, *p)
+	}
+	return
+}
+
+// Format return the report of products from since until until as Slack text
+func Format(products []Product, since, until time.Time) string {
+	lines := []string{fmt.Sprintf(":bar_chart: Bug cycle times from %s until %s:", since.Format("2006-01-02"), until.Format("2006-01-02"))}
+	if len(products) == 0 {
+		return lines[0] + " no bugs."
+	}
+	for _, p := range products {
+		lines = append(lines, fmt.Sprintf("• %s: %d submitted, %d triaged in a median %s, %d resolved in a median %s",
+			store.Bug{Product: p.Product}.ProductName(), p.Submitted, p.Triaged, duration(p.TriageSeconds), p.Resolved, duration(p.ResolutionSeconds)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// median return the median of seconds, 0 when there are none
+func median(seconds []int64) int64 {
+	if len(seconds) == 0 {
+		return 0
+	}
+	sorted := append([]int64{}, seconds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// duration return seconds in days and hours, or hours and minutes when
+// shorter than a day
+func duration(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	}
+	return fmt.Sprintf("%dh %dm", d/time.Hour, d%time.Hour/time.Minute)
+}
@@ -0,0 +1,227 @@
+package ask
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go/service/lambda"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/search"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Bounds of the bugs an answer is drawn from
+const (
+	// maxBugs is the most relevant bugs given to the model
+	maxBugs = 40
+	// maxDetails is how much of the details of each bug it reads, in bytes
+	maxDetails = 300
+)
+
+// planPrompt asks the model to turn a question into a search, answered in
+// JSON only
+const planPrompt = `You turn questions about a bug tracker into a search of its bugs.
+Today is %s. The products are: %s.
+Reply with a single JSON object and nothing else, with these keys, empty when the question does not say:
+"words": the words to look for in bug summaries and details, leaving out products, statuses and dates,
+"product": the value of the product asked about,
+"status": one of %s,
+"since": the first day bugs were created on, YYYY-MM-DD,
+"until": the day after the last day bugs were created on, YYYY-MM-DD.`
+
+// answerPrompt asks the model to answer from the bugs found only
+const answerPrompt = `You answer questions about the bugs of a bug tracker, using only the bugs listed below.
+%d bugs match the search, the %d most relevant are listed, one per line: key | created | product | status | severity | summary | details.
+Answer in a few sentences for a Slack message. Cite every bug you rely on by its key in square brackets, e.g. [HP-123].
+When the list does not answer the question, say so rather than guessing. A count of matching bugs is the number above, not the length of the list.`
+
+// citation matches a key cited in square brackets
+var citation = regexp.MustCompile(`\[([A-Za-z][A-Za-z0-9_]*-[0-9]+|[0-9A-Za-z]{20,})\]`)
+
+// Question is a question asked with `/kanobug ask`, answered on ResponseURL
+// by the ASK_FUNCTION Lambda. Security sensitive bugs are only read for
+// triagers and their reporter
+type Question struct {
+	EnterpriseID string `json:"enterprise_id,omitempty"`
+	TeamID       string `json:"team_id"`
+	UserID       string `json:"user_id"`
+	Triager      bool   `json:"triager"`
+	Text         string `json:"text"`
+	ResponseURL  string `json:"response_url"`
+}
+
+// plan is the search the model made of a question
+type plan struct {
+	Words   string `json:"words"`
+	Product string `json:"product"`
+	Status  string `json:"status"`
+	Since   string `json:"since"`
+	Until   string `json:"until"`
+}
+
+// Enabled report whether ASK_MODEL_ID names a Bedrock model and the bugs are
+// indexed for search
+func Enabled() bool {
+	return len(os.Getenv("ASK_MODEL_ID")) > 0 && search.Enabled()
+}
+
+// Queue invoke ASK_FUNCTION with question without waiting for the answer,
+// which takes longer than Slack waits for a command
+func Queue(question Question) (err error) {
+	defer func() {
+		log.Printf("ask.Queue (%s/%s) - error: %v", question.TeamID, question.UserID, err)
+	}()
+	function := os.Getenv("ASK_FUNCTION")
+	if len(function) == 0 {
+		return errors.New("ASK_FUNCTION is not set")
+	}
+	payload, err := json.Marshal(question)
+	if err != nil {
+		return
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	_, err = lambda.New(sess).Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(function),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        payload,
+	})
+	return
+}
+
+// Answer question from the bugs of its team: the model turns it into a
+// search, then answers from the most relevant bugs found, citing their keys,
+// which are linked to their Jira issues
+func Answer(question Question) (answer string, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("ask.Answer (%s, %q) - error: %v", question.TeamID, question.Text, err)
+		}
+	}()
+	p, err := makePlan(question.Text)
+	if err != nil {
+		return
+	}
+	filter := search.Filter{Product: p.Product, Status: p.Status}
+	filter.Since, _ = time.Parse("2006-01-02", p.Since)
+	filter.Until, _ = time.Parse("2006-01-02", p.Until)
+	total, err := search.Count(question.TeamID, p.Words, filter)
+	if err != nil {
+		return
+	}
+	hits, err := search.Search(question.TeamID, p.Words, filter, maxBugs)
+	if err != nil {
+		return
+	}
+	var lines []string
+	links := map[string]string{}
+	for _, hit := range hits {
+		bug, err := store.GetBug(hit.ID)
+		if err != nil || (bug.Security && !question.Triager && !bug.ReportedBy(question.UserID)) {
+			continue
+		}
+		key := bug.ID
+		if len(bug.IssueKey) > 0 {
+			key = bug.IssueKey
+		}
+		for _, issue := range bug.Issues {
+			if issue.Key == key && len(issue.URL) > 0 {
+				links[key] = issue.URL
+			}
+		}
+		lines = append(lines, strings.Join([]string{
+			key, bug.CreatedAt.Format("2006-01-02"), bug.Product, bug.Status, bug.Severity,
+			oneLine(bug.Summary, maxDetails), oneLine(bug.Details, maxDetails),
+		}, " | "))
+	}
+	log.Printf("ask.Answer (%s, %q) - plan: %+v, matches: %d, read: %d", question.TeamID, question.Text, p, total, len(lines))
+	if total == 0 || len(lines) == 0 {
+		return "No bugs match that question.", nil
+	}
+	answer, err = converse(fmt.Sprintf(answerPrompt, total, len(lines)), question.Text+"\n\nBugs:\n"+strings.Join(lines, "\n"))
+	if err != nil {
+		return
+	}
+	return citation.ReplaceAllStringFunc(answer, func(cited string) string {
+		key := strings.Trim(cited, "[]")
+		if link, ok := links[key]; ok {
+			return fmt.Sprintf("<%s|%s>", link, key)
+		}
+		return cited
+	}), nil
+}
+
+// makePlan ask the model for the search answering text
+func makePlan(text string) (p plan, err error) {
+	var products []string
+	for _, o := range catalog.ProductOptions() {
+		products = append(products, fmt.Sprintf("%s (%s)", o.Value, o.Label))
+	}
+	reply, err := converse(fmt.Sprintf(planPrompt, time.Now().Format("2006-01-02 (Monday)"), strings.Join(products, ", "), strings.Join(store.Statuses, ", ")), text)
+	if err != nil {
+		return
+	}
+	// tolerate prose around the object
+	if start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}"); start >= 0 && end > start {
+		reply = reply[start : end+1]
+	}
+	if err = json.Unmarshal([]byte(reply), &p); err != nil {
+		return p, fmt.Errorf("unreadable plan %q: %v", reply, err)
+	}
+	if !store.ValidStatus(p.Status) {
+		p.Status = ""
+	}
+	return p, nil
+}
+
+// converse send text to the ASK_MODEL_ID model with the system prompt and
+// return its reply
+func converse(system, text string) (reply string, err error) {
+	sess, err := outbound.Session(outbound.Bedrock)
+	if err != nil {
+		return
+	}
+	out, err := bedrockruntime.New(sess).Converse(&bedrockruntime.ConverseInput{
+		ModelId: aws.String(os.Getenv("ASK_MODEL_ID")),
+		System:  []*bedrockruntime.SystemContentBlock{{Text: aws.String(system)}},
+		Messages: []*bedrockruntime.Message{{
+			Role:    aws.String(bedrockruntime.ConversationRoleUser),
+			Content: []*bedrockruntime.ContentBlock{{Text: aws.String(text)}},
+		}},
+		InferenceConfig: &bedrockruntime.InferenceConfiguration{
+			MaxTokens:   aws.Int64(800),
+			Temperature: aws.Float64(0),
+		},
+	})
+	if err != nil {
+		return
+	}
+	if out.Output == nil || out.Output.Message == nil {
+		return "", errors.New("empty reply")
+	}
+	for _, block := range out.Output.Message.Content {
+		reply += aws.StringValue(block.Text)
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// oneLine return s on a single line, cut to max bytes
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		s = strings.ToValidUTF8(s[:max], "") + "…"
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package authz
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Role of a chat user, each role has the permissions of the ones before it
+type Role int
+
+// Roles, from least to most privileged
+const (
+	Reporter Role = iota
+	Triager
+	Admin
+)
+
+func (role Role) String() string {
+	switch role {
+	case Triager:
+		return "triager"
+	case Admin:
+		return "admin"
+	}
+	return "reporter"
+}
+
+// ParseRole return the role named name
+func ParseRole(name string) (role Role, ok bool) {
+	for _, r := range []Role{Reporter, Triager, Admin} {
+		if strings.EqualFold(r.String(), name) {
+			return r, true
+		}
+	}
+	return Reporter, false
+}
+
+// Of return the role of userID: admins are listed in KANOBUG_ADMINS, members
+// of the Slack user group KANOBUG_ADMIN_GROUP or granted the role in the config
+// table, triagers are members of KANOBUG_TRIAGE_GROUP or granted the role,
+// everybody else reports
+func Of(userID string) Role {
+	if len(userID) == 0 {
+		return Reporter
+	}
+	for _, admin := range strings.Split(os.Getenv("KANOBUG_ADMINS"), ",") {
+		if strings.TrimSpace(admin) == userID {
+			return Admin
+		}
+	}
+	granted := Reporter
+	if store.ConfigEnabled() {
+		grant, err := store.GetGrant(userID)
+		if err != nil && err != store.ErrNotFound {
+			log.Printf("authz.Of (%s) - grant error: %v", userID, err)
+		}
+		granted, _ = ParseRole(grant.Role)
+	}
+	if granted == Admin || inGroup(os.Getenv("KANOBUG_ADMIN_GROUP"), userID) {
+		return Admin
+	}
+	if granted == Triager || inGroup(os.Getenv("KANOBUG_TRIAGE_GROUP"), userID) {
+		return Triager
+	}
+	return Reporter
+}
+
+// Require return the "not permitted" reply when userID lacks role for
+// action, e.g. "assign bugs", empty when permitted
+func Require(userID string, role Role, action string) string {
+	if Of(userID) >= role {
+		return ""
+	}
+	return denied(action, role)
+}
+
+// RequireBug return the "not permitted" reply unless userID reported bug or
+// is at least a triager, empty when permitted
+func RequireBug(userID string, bug store.Bug, action string) string {
+	if bug.ReportedBy(userID) {
+		return ""
+	}
+	if Of(userID) >= Triager {
+		return ""
+	}
+	return fmt.Sprintf("Sorry, you are not permitted to %s, only its reporter and triagers are.", action)
+}
+
+func denied(action string, role Role) string {
+	return fmt.Sprintf("Sorry, you are not permitted to %s, it needs the %s role.", action, role)
+}
+
+// inGroup report whether userID is a member of the Slack user group
+func inGroup(group, userID string) bool {
+	if len(group) == 0 {
+		return false
+	}
+	members, err := slack.Default.GroupMembers(group)
+	log.Printf("authz.inGroup (%s) - members: %d, error: %v", group, len(members), err)
+	for _, member := range members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireChannel return the reply redirecting the user when kanobug may not
+// be used from channelID in workspace teamID, empty when permitted. Channels
+// and workspaces (Enterprise Grid team IDs) are checked against the comma
+// separated DENIED_CHANNELS, DENIED_TEAMS, ALLOWED_CHANNELS and ALLOWED_TEAMS,
+// empty allowlists allowing everything not denied
+func RequireChannel(teamID, channelID string) string {
+	blocked := listed("DENIED_TEAMS", teamID) ||
+		listed("DENIED_CHANNELS", channelID) ||
+		(len(os.Getenv("ALLOWED_TEAMS")) > 0 && !listed("ALLOWED_TEAMS", teamID)) ||
+		(len(os.Getenv("ALLOWED_CHANNELS")) > 0 && !listed("ALLOWED_CHANNELS", channelID))
+	if !blocked {
+		return ""
+	}
+	if redirect := os.Getenv("REDIRECT_CHANNEL"); len(redirect) > 0 {
+		return fmt.Sprintf("Sorry, kanobug is not available here, please report bugs in <#%s>.", redirect)
+	}
+	return "Sorry, kanobug is not available in this channel."
+}
+
+// listed report whether value is in the comma separated env variable name
+func listed(name, value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
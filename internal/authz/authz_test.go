@@ -0,0 +1,127 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// fakeSlack answers usergroups.users.list from groups
+type fakeSlack struct {
+	slack.API
+	groups map[string][]string
+}
+
+func (f fakeSlack) GroupMembers(group string) ([]string, error) {
+	return f.groups[group], nil
+}
+
+func useGroups(t *testing.T, groups map[string][]string) {
+	api := slack.Default
+	slack.Default = fakeSlack{groups: groups}
+	t.Cleanup(func() { slack.Default = api })
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   Role
+		wantOK bool
+	}{
+		{"admin", Admin, true},
+		{"Triager", Triager, true},
+		{"reporter", Reporter, true},
+		{"owner", Reporter, false},
+		{"", Reporter, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseRole(tt.name); got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseRole(%q) = %s, %v, want %s, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	t.Setenv("KANOBUG_ADMINS", "U0ADMIN, U0ROOT")
+	t.Setenv("KANOBUG_ADMIN_GROUP", "S0ADMINS")
+	t.Setenv("KANOBUG_TRIAGE_GROUP", "S0TRIAGE")
+	useGroups(t, map[string][]string{
+		"S0ADMINS": {"U0LEAD"},
+		"S0TRIAGE": {"U0QA", "U0LEAD"},
+	})
+	tests := []struct {
+		user string
+		want Role
+	}{
+		{"U0ADMIN", Admin},
+		{"U0ROOT", Admin},
+		{"U0LEAD", Admin},
+		{"U0QA", Triager},
+		{"U0DEV", Reporter},
+		{"", Reporter},
+	}
+	for _, tt := range tests {
+		if got := Of(tt.user); got != tt.want {
+			t.Errorf("Of(%q) = %s, want %s", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	t.Setenv("KANOBUG_ADMINS", "U0ADMIN")
+	t.Setenv("KANOBUG_TRIAGE_GROUP", "S0TRIAGE")
+	useGroups(t, map[string][]string{"S0TRIAGE": {"U0QA"}})
+	bug := store.Bug{ID: "b1", UserID: "U0DEV"}
+	anonymous := store.Bug{ID: "b2", UserID: store.AnonymousID("U0DEV"), Anonymous: true}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"admin assigns", Require("U0ADMIN", Triager, "assign bugs"), ""},
+		{"triager assigns", Require("U0QA", Triager, "assign bugs"), ""},
+		{"triager configures", Require("U0QA", Admin, "configure products"), "Sorry, you are not permitted to configure products, it needs the admin role."},
+		{"reporter assigns", Require("U0DEV", Triager, "assign bugs"), "Sorry, you are not permitted to assign bugs, it needs the triager role."},
+		{"reporter closes own", RequireBug("U0DEV", bug, "close this bug"), ""},
+		{"reporter closes own anonymous", RequireBug("U0DEV", anonymous, "close this bug"), ""},
+		{"triager closes", RequireBug("U0QA", bug, "close this bug"), ""},
+		{"other closes", RequireBug("U0OTHER", bug, "close this bug"), "Sorry, you are not permitted to close this bug, only its reporter and triagers are."},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRequireChannel(t *testing.T) {
+	const unavailable = "Sorry, kanobug is not available in this channel."
+	tests := []struct {
+		name    string
+		env     map[string]string
+		team    string
+		channel string
+		want    string
+	}{
+		{"open", nil, "T1", "C1", ""},
+		{"denied channel", map[string]string{"DENIED_CHANNELS": "C0, C1"}, "T1", "C1", unavailable},
+		{"denied team", map[string]string{"DENIED_TEAMS": "T1"}, "T1", "C2", unavailable},
+		{"allowed channel", map[string]string{"ALLOWED_CHANNELS": "C1"}, "T1", "C1", ""},
+		{"not allowed channel", map[string]string{"ALLOWED_CHANNELS": "C1"}, "T1", "C2", unavailable},
+		{"not allowed team", map[string]string{"ALLOWED_TEAMS": "T1"}, "T2", "C1", unavailable},
+		{"denied over allowed", map[string]string{"ALLOWED_CHANNELS": "C1", "DENIED_CHANNELS": "C1"}, "T1", "C1", unavailable},
+		{"redirected", map[string]string{"DENIED_CHANNELS": "C1", "REDIRECT_CHANNEL": "C0BUGS"}, "T1", "C1",
+			"Sorry, kanobug is not available here, please report bugs in <#C0BUGS>."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DENIED_CHANNELS", "DENIED_TEAMS", "ALLOWED_CHANNELS", "ALLOWED_TEAMS", "REDIRECT_CHANNEL"} {
+				t.Setenv(name, tt.env[name])
+			}
+			if got := RequireChannel(tt.team, tt.channel); got != tt.want {
+				t.Errorf("RequireChannel(%s, %s) = %q, want %q", tt.team, tt.channel, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package catalog
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+// MaxOptions is the most options a Slack static select accepts
+const MaxOptions = 100
+
+// Option is a selectable value in the report form
+type Option struct {
+	Label string
+	Value string
+}
+
+// Products offered in the report form
+var Products = []Option{
+	Option{
+		Label: "Harry Potter Coding Kit",
+		Value: "harry_potter_coding_kit",
+	},
+	Option{
+		Label: "Computer Kit Touch",
+		Value: "computer_kit_touch",
+	},
+	Option{
+		Label: "Computer Kit 2018",
+		Value: "computer_kit_2018",
+	},
+	Option{
+		Label: "Pixel Kit",
+		Value: "pixel_kit",
+	},
+	Option{
+		Label: "Motion Sensor Kit",
+		Value: "motion_sensor_kit",
+	},
+}
+
+// ProductOptions return the products configured in the config table, falling
+// back to the built in Products when the table is absent or empty
+func ProductOptions() []Option {
+	if !store.ConfigEnabled() {
+		return Products
+	}
+	products, err := store.ListProducts()
+	if err != nil || len(products) == 0 {
+		if err != nil {
+			log.Printf("catalog.ProductOptions - error: %v", err)
+		}
+		return Products
+	}
+	options := make([]Option, 0, len(products))
+	for _, p := range products {
+		options = append(options, Option{Label: p.Label, Value: p.Value})
+	}
+	return options
+}
+
+// Template return the details template configured for product, empty when
+// it has none
+func Template(product string) string {
+	if !store.ConfigEnabled() || len(product) == 0 {
+		return ""
+	}
+	configured, err := store.GetProduct(product)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("catalog.Template (%s) - error: %v", product, err)
+	}
+	return configured.Template
+}
+
+// defaultVersionsTTL is how long the versions of a Jira project are kept
+// unless VERSIONS_TTL says otherwise
+const defaultVersionsTTL = 5 * time.Minute
+
+// fetchedVersions are the versions of a Jira project and when they were read
+type fetchedVersions struct {
+	options []Option
+	at      time.Time
+}
+
+var (
+	versionsMu sync.Mutex
+	versions   = map[string]fetchedVersions{}
+)
+
+// Versions return the firmware/app versions a bug of product can affect,
+// from the comma separated AFFECTED_VERSIONS or else the versions of the Jira
+// project product is filed into, unreleased ones labelled so. Jira versions
+// are cached for VERSIONS_TTL, the last read kept while Jira fails
+func Versions(product string) (options []Option) {
+	if configured := os.Getenv("AFFECTED_VERSIONS"); len(configured) > 0 {
+		for _, v := range strings.Split(configured, ",") {
+			if v = strings.TrimSpace(v); len(v) > 0 {
+				options = append(options, Option{Label: v, Value: v})
+			}
+		}
+		return
+	}
+	jira := tracker.NewJira()
+	if len(jira.Host) == 0 {
+		return
+	}
+	project := jira.Project(product)
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	cached, ok := versions[project]
+	if ok && time.Since(cached.at) < versionsTTL() {
+		return cached.options
+	}
+	fetched, err := jira.Versions(project)
+	if err != nil {
+		log.Printf("catalog.Versions (%s) - error: %v", project, err)
+		return cached.options
+	}
+	for _, v := range fetched {
+		label := v.Name
+		if !v.Released {
+			label += " (unreleased)"
+		}
+		options = append(options, Option{Label: label, Value: v.Name})
+	}
+	versions[project] = fetchedVersions{options: options, at: time.Now()}
+	return
+}
+
+// MatchVersions return the first MaxOptions versions of product containing
+// query, case insensitively, for the select typed into
+func MatchVersions(product, query string) (options []Option) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, o := range Versions(product) {
+		if len(options) == MaxOptions {
+			break
+		}
+		if strings.Contains(strings.ToLower(o.Label), query) {
+			options = append(options, o)
+		}
+	}
+	return
+}
+
+// versionsTTL return VERSIONS_TTL or defaultVersionsTTL
+func versionsTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("VERSIONS_TTL")); err == nil {
+		return ttl
+	}
+	return defaultVersionsTTL
+}
+
+// Tags return the managed tags offered in the report form, none when the
+// config table is absent
+func Tags() (options []Option) {
+	if !store.ConfigEnabled() {
+		return
+	}
+	tags, err := store.ListTags()
+	if err != nil {
+		log.Printf("catalog.Tags - error: %v", err)
+		return
+	}
+	for _, t := range tags {
+		if len(options) == MaxOptions {
+			break
+		}
+		options = append(options, Option{Label: t.Name, Value: t.Name})
+	}
+	return
+}
+
+// SeedProducts copy the built in Products to an empty config table, so the
+// first add or remove edits the offered list rather than replacing it
+func SeedProducts() error {
+	configured, err := store.ListProducts()
+	if err != nil || len(configured) > 0 {
+		return err
+	}
+	for _, p := range Products {
+		if err = store.PutProduct(store.Product{Value: p.Value, Label: p.Label}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultSeverity is pre-selected in the report form
+const DefaultSeverity = store.SeverityMajor
+
+// Severities offered in the report form, most severe first
+var Severities = []Option{
+	Option{
+		Label: "Blocker - nothing works",
+		Value: store.SeverityBlocker,
+	},
+	Option{
+		Label: "Critical - a key feature is broken",
+		Value: store.SeverityCritical,
+	},
+	Option{
+		Label: "Major - something is broken but there's a workaround",
+		Value: store.SeverityMajor,
+	},
+	Option{
+		Label: "Minor - it's annoying",
+		Value: store.SeverityMinor,
+	},
+	Option{
+		Label: "Trivial - cosmetic",
+		Value: store.SeverityTrivial,
+	},
+}
+
+// Resolutions offered when a reporter closes a bug
+var Resolutions = []Option{
+	Option{
+		Label: "Fixed",
+		Value: store.ResolutionFixed,
+	},
+	Option{
+		Label: "Not a bug",
+		Value: store.ResolutionNotABug,
+	},
+	Option{
+		Label: "Duplicate of…",
+		Value: store.ResolutionDuplicate,
+	},
+}
@@ -0,0 +1,95 @@
+package catalog
+
+import (
+	"os"
+	"strings"
+)
+
+// Confidence of an inferred product
+const (
+	High   = "high"
+	Medium = "medium"
+	Low    = "low"
+)
+
+// Inference is the product a report most likely is about, why and how sure
+// that is
+type Inference struct {
+	Product    string
+	Confidence string
+	// Reason is the label or keyword the text mentions, or "channel"
+	Reason string
+}
+
+// keywords return the words mentioning each product beyond its label, from
+// PRODUCT_KEYWORDS ("pixel_kit=pixel,led board;motion_sensor_kit=motion")
+func keywords() map[string][]string {
+	m := map[string][]string{}
+	for _, pair := range strings.Split(os.Getenv("PRODUCT_KEYWORDS"), ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		for _, keyword := range strings.Split(kv[1], ",") {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); len(keyword) > 0 {
+				m[strings.TrimSpace(kv[0])] = append(m[strings.TrimSpace(kv[0])], keyword)
+			}
+		}
+	}
+	return m
+}
+
+// Infer return the product of the offered ones text most likely is about:
+// a label mentioned counts twice, a PRODUCT_KEYWORDS keyword once, and the
+// product of the channel twice, which wins a tie. Confidence is high for a
+// score of 3 or more without a rival, low when another product scores as
+// well, false when nothing points to a product
+func Infer(text, channelProduct string) (inference Inference, ok bool) {
+	text = strings.ToLower(text)
+	extra := keywords()
+	options := ProductOptions()
+	scores, reasons := map[string]int{}, map[string]string{}
+	for _, p := range options {
+		if label := strings.ToLower(p.Label); len(label) > 0 && strings.Contains(text, label) {
+			scores[p.Value] += 2
+			reasons[p.Value] = p.Label
+		}
+		for _, keyword := range extra[p.Value] {
+			if strings.Contains(text, keyword) {
+				scores[p.Value]++
+				if len(reasons[p.Value]) == 0 {
+					reasons[p.Value] = keyword
+				}
+			}
+		}
+		if p.Value == channelProduct {
+			scores[p.Value] += 2
+			if len(reasons[p.Value]) == 0 {
+				reasons[p.Value] = "channel"
+			}
+		}
+	}
+	best, rival := 0, 0
+	for _, p := range options {
+		switch score := scores[p.Value]; {
+		case score > best, score == best && score > 0 && p.Value == channelProduct:
+			rival, best = best, score
+			inference.Product = p.Value
+		case score > rival:
+			rival = score
+		}
+	}
+	if best == 0 {
+		return
+	}
+	inference.Reason = reasons[inference.Product]
+	switch {
+	case rival == best:
+		inference.Confidence = Low
+	case best >= 3 && rival == 0:
+		inference.Confidence = High
+	default:
+		inference.Confidence = Medium
+	}
+	return inference, true
+}
@@ -0,0 +1,109 @@
+package classify
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Suggestion is the severity a classifier suggests for a report and why
+type Suggestion struct {
+	Severity   string `json:"severity"`
+	Reason     string `json:"reason"`
+	Classifier string `json:"classifier"`
+}
+
+// Classifier is implemented by every way of suggesting a severity, a model
+// behind Bedrock can be added next to the keyword rules through ByName
+type Classifier interface {
+	Name() string
+	Classify(summary, details string) (Suggestion, bool)
+}
+
+// defaultRules are the keyword rules unless SEVERITY_RULES sets others,
+// tried in order so the more severe match first
+var defaultRules = []Rule{
+	{Keyword: "data loss", Severity: store.SeverityBlocker},
+	{Keyword: "lost data", Severity: store.SeverityBlocker},
+	{Keyword: "deleted my", Severity: store.SeverityBlocker},
+	{Keyword: "security", Severity: store.SeverityBlocker},
+	{Keyword: "crash", Severity: store.SeverityCritical},
+	{Keyword: "freez", Severity: store.SeverityCritical},
+	{Keyword: "won't start", Severity: store.SeverityCritical},
+	{Keyword: "can't log in", Severity: store.SeverityCritical},
+	{Keyword: "typo", Severity: store.SeverityTrivial},
+	{Keyword: "spelling", Severity: store.SeverityTrivial},
+	{Keyword: "cosmetic", Severity: store.SeverityMinor},
+	{Keyword: "alignment", Severity: store.SeverityMinor},
+	{Keyword: "misaligned", Severity: store.SeverityMinor},
+}
+
+// Rule suggests Severity for reports mentioning Keyword
+type Rule struct {
+	Keyword  string
+	Severity string
+}
+
+// Rules suggests the severity of the first rule whose keyword the summary or
+// details mention, case insensitively
+type Rules []Rule
+
+// NewRules return the keyword rules of SEVERITY_RULES
+// ("data loss=blocker;crash=critical;typo=trivial"), or else the defaults
+func NewRules() Rules {
+	var rules Rules
+	for _, pair := range strings.Split(os.Getenv("SEVERITY_RULES"), ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && len(strings.TrimSpace(kv[0])) > 0 {
+			rules = append(rules, Rule{Keyword: strings.ToLower(strings.TrimSpace(kv[0])), Severity: strings.TrimSpace(kv[1])})
+		}
+	}
+	if len(rules) == 0 {
+		return defaultRules
+	}
+	return rules
+}
+
+// Name return classifier name
+func (rules Rules) Name() string {
+	return "rules"
+}
+
+// Classify return the severity of the first rule matching the report
+func (rules Rules) Classify(summary, details string) (Suggestion, bool) {
+	text := strings.ToLower(summary + "\n" + details)
+	for _, rule := range rules {
+		if strings.Contains(text, rule.Keyword) {
+			return Suggestion{Severity: rule.Severity, Reason: rule.Keyword, Classifier: rules.Name()}, true
+		}
+	}
+	return Suggestion{}, false
+}
+
+// ByName return the classifier registered as name, nil when unknown
+func ByName(name string) Classifier {
+	switch name {
+	case "rules":
+		return NewRules()
+	}
+	log.Printf("classify.ByName - unknown classifier: %s", name)
+	return nil
+}
+
+// Suggest return the severity CLASSIFIER suggests for a report, false when
+// no classifier is configured or it has no suggestion
+func Suggest(summary, details string) (suggestion Suggestion, ok bool) {
+	name := os.Getenv("CLASSIFIER")
+	if len(name) == 0 || len(strings.TrimSpace(summary+details)) == 0 {
+		return
+	}
+	classifier := ByName(name)
+	if classifier == nil {
+		return
+	}
+	suggestion, ok = classifier.Classify(summary, details)
+	log.Printf("classify.Suggest (%s) - suggestion: %+v, ok: %v", name, suggestion, ok)
+	return
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Config is the environment shared by the Lambdas and kanobugctl, read once
+// per container. Variables of a single feature are read where it is
+// implemented, see README
+type Config struct {
+	Stage       Stage
+	Region      string
+	Table       string
+	DefaultTeam string
+	EventBus    string
+	OpsChannel  string
+
+	SlackToken             string
+	SlackVerificationToken string
+	SlackClientID          string
+	SlackClientSecret      string
+	SlackRedirectURL       string
+
+	JiraHost         string
+	JiraAuth         string
+	JiraUser         string
+	JiraToken        string
+	JiraProjects     map[string]string
+	JiraSmokeProject string
+	// JiraSandbox is the project every bug is filed into on a Stage other
+	// than prod, JIRA_SANDBOX_PROJECT
+	JiraSandbox string
+
+	// Trackers are the trackers of TRACKERS, jira unless set, and Routes
+	// those of TRACKER_ROUTES by product
+	Trackers []string
+	Routes   map[string][]string
+
+	SearchEndpoint string
+}
+
+var (
+	current     Config
+	currentOnce sync.Once
+)
+
+// Get return the Config of the environment
+func Get() Config {
+	currentOnce.Do(func() { current = Load() })
+	return current
+}
+
+// Load read the Config from the environment, unlike Get every time
+func Load() Config {
+	c := Config{
+		Stage:       ParseStage(os.Getenv("STAGE")),
+		Region:      os.Getenv("REGION"),
+		Table:       os.Getenv("TABLE_NAME"),
+		DefaultTeam: os.Getenv("DEFAULT_TEAM_ID"),
+		EventBus:    os.Getenv("EVENT_BUS_NAME"),
+		OpsChannel:  os.Getenv("OPS_CHANNEL"),
+
+		SlackToken:             os.Getenv("SLACK_ACCESS_TOKEN"),
+		SlackVerificationToken: os.Getenv("SLACK_VERIFICATION_TOKEN"),
+		SlackClientID:          os.Getenv("SLACK_CLIENT_ID"),
+		SlackClientSecret:      os.Getenv("SLACK_CLIENT_SECRET"),
+		SlackRedirectURL:       os.Getenv("SLACK_REDIRECT_URL"),
+
+		JiraHost:         os.Getenv("JIRA_API_HOST"),
+		JiraAuth:         os.Getenv("JIRA_AUTH"),
+		JiraUser:         os.Getenv("JIRA_API_USER"),
+		JiraToken:        os.Getenv("JIRA_API_TOKEN"),
+		JiraProjects:     Map(os.Getenv("JIRA_PROJECTS")),
+		JiraSmokeProject: os.Getenv("JIRA_SMOKE_PROJECT"),
+		JiraSandbox:      os.Getenv("JIRA_SANDBOX_PROJECT"),
+
+		Trackers: List(os.Getenv("TRACKERS")),
+		Routes:   map[string][]string{},
+
+		SearchEndpoint: os.Getenv("SEARCH_ENDPOINT"),
+	}
+	if len(c.Trackers) == 0 {
+		c.Trackers = []string{"jira"}
+	}
+	for product, names := range Map(os.Getenv("TRACKER_ROUTES")) {
+		c.Routes[product] = List(names)
+	}
+	return c
+}
+
+// Stage is the kind of deploy a Lambda belongs to, of STAGE
+type Stage string
+
+// Stages
+const (
+	Prod    Stage = "prod"
+	Staging Stage = "staging"
+	Dev     Stage = "dev"
+)
+
+// ParseStage return the Stage of value: prod when empty, as for the deploys
+// predating stages, and dev when neither prod nor staging, e.g. the stage of
+// a developer
+func ParseStage(value string) Stage {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "prod", "production":
+		return Prod
+	case "staging", "stage":
+		return Staging
+	}
+	return Dev
+}
+
+// Prod report whether s is production, the only stage writing to the Jira
+// projects of JIRA_PROJECTS
+func (s Stage) Prod() bool {
+	return s == Prod
+}
+
+// Map parse a "key=value;key=value" variable
+func Map(value string) map[string]string {
+	m := map[string]string{}
+	for _, pair := range strings.Split(value, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return m
+}
+
+// List parse a comma separated variable
+func List(value string) (list []string) {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// Need is a part of the environment a Lambda can not serve a request without
+type Need string
+
+// Needs of the Lambdas
+const (
+	// Table is the DynamoDB table, TABLE_NAME in REGION
+	Table Need = "table"
+	// Slack is the bot token calling the Web API
+	Slack Need = "slack"
+	// SlackRequests is the verification token requests from Slack carry
+	SlackRequests Need = "slack requests"
+	// Trackers are the credentials of every tracker bugs are filed to
+	Trackers Need = "trackers"
+	// Anonymous is ANONYMOUS_SALT, keying the hash of anonymous reporters,
+	// needed wherever the anonymous flag is on
+	Anonymous Need = "anonymous"
+)
+
+// trackerVariables are the variables each tracker needs
+var trackerVariables = map[string][]string{
+	"jira":       {"JIRA_API_HOST", "JIRA_API_TOKEN"},
+	"webhook":    {"WEBHOOK_URLS"},
+	"linear":     {"LINEAR_API_KEY"},
+	"gitlab":     {"GITLAB_HOST", "GITLAB_TOKEN_SECRET"},
+	"azure":      {"AZURE_DEVOPS_ORG", "AZURE_DEVOPS_PROJECT", "AZURE_DEVOPS_PAT"},
+	"servicenow": {"SERVICENOW_INSTANCE", "SERVICENOW_USER", "SERVICENOW_PASSWORD"},
+	"email":      {"EMAIL_FROM"},
+	"trello":     {"TRELLO_API_KEY", "TRELLO_TOKEN"},
+	"asana":      {"ASANA_TOKEN"},
+}
+
+// projectKey matches a Jira project key, e.g. IQ or HW2
+var projectKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+// Validate return what is wrong with c for a Lambda with needs: the
+// variables they require that are empty, and the variables set that are
+// malformed, whatever the needs
+func (c Config) Validate(needs ...Need) (problems []string) {
+	missing := func(names ...string) {
+		for _, name := range names {
+			if len(os.Getenv(name)) == 0 {
+				problems = append(problems, name+" is not set")
+			}
+		}
+	}
+	for _, need := range needs {
+		switch need {
+		case Table:
+			missing("REGION", "TABLE_NAME")
+		case Slack:
+			missing("SLACK_ACCESS_TOKEN")
+		case SlackRequests:
+			missing("SLACK_VERIFICATION_TOKEN")
+		case Anonymous:
+			if len(os.Getenv("ANONYMOUS_SALT")) == 0 {
+				problems = append(problems, "ANONYMOUS_SALT is not set, anonymous reporters could be told apart by hashing their user ID")
+			}
+		case Trackers:
+			for _, name := range c.trackers() {
+				missing(trackerVariables[name]...)
+				if name == "jira" && c.JiraAuth != "pat" {
+					missing("JIRA_API_USER")
+				}
+				if name == "jira" && !c.Stage.Prod() && len(c.JiraSandbox) == 0 {
+					problems = append(problems, fmt.Sprintf("JIRA_SANDBOX_PROJECT is not set, required on stage %s", c.Stage))
+				}
+			}
+		}
+	}
+	for _, name := range c.trackers() {
+		if _, ok := trackerVariables[name]; !ok {
+			problems = append(problems, fmt.Sprintf("TRACKERS or TRACKER_ROUTES names unknown tracker %q", name))
+		}
+		if name == "webhook" && len(os.Getenv("WEBHOOK_SECRET")) == 0 {
+			problems = append(problems, "WEBHOOK_SECRET is not set, webhook payloads can not be signed without it")
+		}
+	}
+	if len(c.JiraHost) > 0 && (strings.Contains(c.JiraHost, "://") || strings.Contains(c.JiraHost, "/")) {
+		problems = append(problems, fmt.Sprintf("JIRA_API_HOST must be a host name like example.atlassian.net, not %q", c.JiraHost))
+	}
+	switch c.JiraAuth {
+	case "", "cloud", "pat", "basic":
+	default:
+		problems = append(problems, fmt.Sprintf("JIRA_AUTH must be cloud, pat or basic, not %q", c.JiraAuth))
+	}
+	for product, route := range c.JiraProjects {
+		if key := strings.TrimSpace(strings.SplitN(route, "/", 2)[0]); len(key) > 0 && !projectKey.MatchString(key) {
+			problems = append(problems, fmt.Sprintf("JIRA_PROJECTS routes %s to %q, not a project key", product, key))
+		}
+	}
+	if len(c.JiraSmokeProject) > 0 && !projectKey.MatchString(c.JiraSmokeProject) {
+		problems = append(problems, fmt.Sprintf("JIRA_SMOKE_PROJECT %q is not a project key", c.JiraSmokeProject))
+	}
+	if len(c.JiraSandbox) > 0 && !projectKey.MatchString(c.JiraSandbox) {
+		problems = append(problems, fmt.Sprintf("JIRA_SANDBOX_PROJECT %q is not a project key", c.JiraSandbox))
+	}
+	if len(c.SlackRedirectURL) > 0 {
+		problems = append(problems, httpsURL("SLACK_REDIRECT_URL", c.SlackRedirectURL)...)
+	}
+	if len(c.SearchEndpoint) > 0 {
+		problems = append(problems, httpsURL("SEARCH_ENDPOINT", c.SearchEndpoint)...)
+	}
+	for _, hook := range List(os.Getenv("WEBHOOK_URLS")) {
+		problems = append(problems, httpsURL("WEBHOOK_URLS", hook)...)
+	}
+	return
+}
+
+// trackers return the names of every tracker bugs may be filed to, sorted
+func (c Config) trackers() (names []string) {
+	seen := map[string]bool{}
+	add := func(list []string) {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(c.Trackers)
+	for _, list := range c.Routes {
+		add(list)
+	}
+	sort.Strings(names)
+	return
+}
+
+// httpsURL return the problem of variable name not being the https URL value
+func httpsURL(name, value string) []string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return []string{fmt.Sprintf("%s must be an https URL, not %q", name, value)}
+	}
+	return nil
+}
+
+// MustLoad validate the environment of handler for needs, called first thing
+// in main so a Lambda missing any of it fails its cold start, logging every
+// problem at once, rather than requests failing one variable at a time
+func MustLoad(handler string, needs ...Need) Config {
+	c := Get()
+	problems := c.Validate(needs...)
+	if len(problems) == 0 {
+		return c
+	}
+	log.Fatalf("config.MustLoad (%s) - %d problem(s): %s", handler, len(problems), strings.Join(problems, "; "))
+	return c
+}
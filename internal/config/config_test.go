@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Stage
+	}{
+		{"", Prod},
+		{"prod", Prod},
+		{" Production ", Prod},
+		{"staging", Staging},
+		{"STAGE", Staging},
+		{"dev", Dev},
+		{"anzel", Dev},
+	}
+	for _, tt := range tests {
+		if got := ParseStage(tt.value); got != tt.want {
+			t.Errorf("ParseStage(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{"", map[string]string{}},
+		{"web_app=WEB/Checkout,Payments; ios = IOS", map[string]string{"web_app": "WEB/Checkout,Payments", "ios": "IOS"}},
+		{"url=https://x.io/?a=b;broken;", map[string]string{"url": "https://x.io/?a=b"}},
+	}
+	for _, tt := range tests {
+		if got := Map(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Map(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"jira", []string{"jira"}},
+		{" jira, ,gitlab ,", []string{"jira", "gitlab"}},
+	}
+	for _, tt := range tests {
+		if got := List(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		trackers string
+		routes   string
+		secret   string
+		problem  bool
+	}{
+		{"jira only", "jira", "", "", false},
+		{"webhook signed", "jira,webhook", "", "shh", false},
+		{"webhook unsigned", "jira,webhook", "", "", true},
+		{"routed webhook unsigned", "jira", "pixel_kit=webhook", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRACKERS", tt.trackers)
+			t.Setenv("TRACKER_ROUTES", tt.routes)
+			t.Setenv("WEBHOOK_SECRET", tt.secret)
+			problems := Load().Validate()
+			found := false
+			for _, problem := range problems {
+				found = found || strings.HasPrefix(problem, "WEBHOOK_SECRET")
+			}
+			if found != tt.problem {
+				t.Errorf("Validate() = %q, want a WEBHOOK_SECRET problem: %v", problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateAnonymousSalt(t *testing.T) {
+	tests := []struct {
+		name    string
+		needs   []Need
+		salt    string
+		problem bool
+	}{
+		{"not needed", nil, "", false},
+		{"salted", []Need{Anonymous}, "pepper", false},
+		{"unsalted", []Need{Anonymous}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ANONYMOUS_SALT", tt.salt)
+			problems := Load().Validate(tt.needs...)
+			found := false
+			for _, problem := range problems {
+				found = found || strings.HasPrefix(problem, "ANONYMOUS_SALT")
+			}
+			if found != tt.problem {
+				t.Errorf("Validate() = %q, want an ANONYMOUS_SALT problem: %v", problems, tt.problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package dlq
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// ErrNotConfigured is returned when DLQ_URL is not set
+var ErrNotConfigured = errors.New("DLQ_URL is not set")
+
+// hold is how long received messages stay hidden from other readers, a
+// message that is not replayed in time shows up again
+const hold = 30 * time.Second
+
+// Message is an asynchronous invocation of the NOTIFIER_FUNCTION worker that
+// failed every retry, as Lambda parks it on the dead-letter queue
+type Message struct {
+	ID        string    `json:"id"`
+	RequestID string    `json:"request_id"`
+	Error     string    `json:"error"`
+	Payload   string    `json:"payload"`
+	SentAt    time.Time `json:"sent_at"`
+	Receives  int       `json:"receives"`
+	receipt   string
+}
+
+func queue() (url string, err error) {
+	if url = os.Getenv("DLQ_URL"); len(url) == 0 {
+		err = ErrNotConfigured
+	}
+	return
+}
+
+func newSession() (*session.Session, error) {
+	return outbound.Session(outbound.AWS)
+}
+
+// Depth return the approximate number of messages on the queue
+func Depth() (depth int, err error) {
+	url, err := queue()
+	if err != nil {
+		return
+	}
+	sess, err := newSession()
+	if err != nil {
+		return
+	}
+	out, err := sqs.New(sess).GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(url),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return
+	}
+	return strconv.Atoi(aws.StringValue(out.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]))
+}
+
+// Receive return up to limit messages, hiding them from other readers for a
+// while; messages are left on the queue until replayed or deleted
+func Receive(limit int) (messages []Message, err error) {
+	for len(messages) < limit {
+		var batch []Message
+		if batch, err = receive(limit-len(messages), hold); err != nil || len(batch) == 0 {
+			return
+		}
+		messages = append(messages, batch...)
+	}
+	return
+}
+
+// Peek return up to 10 messages without hiding them, e.g. to sample the
+// errors in an alert
+func Peek(limit int) ([]Message, error) {
+	return receive(limit, 0)
+}
+
+// receive return a batch of at most limit (and 10) messages, hidden for
+// visibility
+func receive(limit int, visibility time.Duration) (messages []Message, err error) {
+	url, err := queue()
+	if err != nil {
+		return
+	}
+	sess, err := newSession()
+	if err != nil {
+		return
+	}
+	if limit > 10 {
+		limit = 10
+	}
+	out, err := sqs.New(sess).ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(url),
+		MaxNumberOfMessages:   aws.Int64(int64(limit)),
+		VisibilityTimeout:     aws.Int64(int64(visibility / time.Second)),
+		AttributeNames:        []*string{aws.String(sqs.MessageSystemAttributeNameSentTimestamp), aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount)},
+		MessageAttributeNames: []*string{aws.String("All")},
+	})
+	if err != nil {
+		return
+	}
+	for _, m := range out.Messages {
+		messages = append(messages, message(m))
+	}
+	return
+}
+
+// message convert a received SQS message, Lambda records the failed request
+// and its error as attributes
+func message(m *sqs.Message) Message {
+	message := Message{
+		ID:      aws.StringValue(m.MessageId),
+		Payload: aws.StringValue(m.Body),
+		receipt: aws.StringValue(m.ReceiptHandle),
+	}
+	if attribute, ok := m.MessageAttributes["RequestID"]; ok {
+		message.RequestID = aws.StringValue(attribute.StringValue)
+	}
+	if attribute, ok := m.MessageAttributes["ErrorMessage"]; ok {
+		message.Error = aws.StringValue(attribute.StringValue)
+	}
+	if sent, err := strconv.ParseInt(aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64); err == nil {
+		message.SentAt = time.Unix(0, sent*int64(time.Millisecond))
+	}
+	message.Receives, _ = strconv.Atoi(aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	return message
+}
+
+// DetailType return the detail type of the bus event a message failed on,
+// empty when the payload is not a bus event
+func (message Message) DetailType() string {
+	var event struct {
+		DetailType string `json:"detail-type"`
+	}
+	_ = json.Unmarshal([]byte(message.Payload), &event)
+	return event.DetailType
+}
+
+// Replay invoke NOTIFIER_FUNCTION asynchronously with the payload of a
+// received message and delete it from the queue once Lambda accepted it. A
+// replay failing again lands back on the queue as a new message
+func Replay(message Message) (err error) {
+	defer func() {
+		log.Printf("dlq.Replay (%s) - error: %v", message.ID, err)
+	}()
+	function := os.Getenv("NOTIFIER_FUNCTION")
+	if len(function) == 0 {
+		return errors.New("NOTIFIER_FUNCTION is not set")
+	}
+	sess, err := newSession()
+	if err != nil {
+		return
+	}
+	_, err = lambda.New(sess).Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(function),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        []byte(message.Payload),
+	})
+	if err != nil {
+		return
+	}
+	return Delete(message)
+}
+
+// Delete remove a received message from the queue without replaying it
+func Delete(message Message) (err error) {
+	url, err := queue()
+	if err != nil {
+		return
+	}
+	sess, err := newSession()
+	if err != nil {
+		return
+	}
+	_, err = sqs.New(sess).DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(url),
+		ReceiptHandle: aws.String(message.receipt),
+	})
+	return
+}
@@ -0,0 +1,89 @@
+package emf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Metrics emitted by the handlers
+const (
+	Submissions      = "Submissions"
+	JiraLatency      = "JiraLatency"
+	JiraFailures     = "JiraFailures"
+	SlackAPIFailures = "SlackAPIFailures"
+	DedupeHits       = "DedupeHits"
+	SimilarHits      = "SimilarHits"
+	Panics           = "Panics"
+
+	AttachmentsRejected    = "AttachmentsRejected"
+	ReconcileDiscrepancies = "ReconcileDiscrepancies"
+)
+
+// defaultNamespace is the namespace metrics go to unless METRICS_NAMESPACE is set
+const defaultNamespace = "Kanobug"
+
+// Units of the emitted values
+const (
+	unitCount        = "Count"
+	unitMilliseconds = "Milliseconds"
+)
+
+// Dimensions name the series a metric value belongs to, e.g. {"Source": "slack"}
+type Dimensions map[string]string
+
+// Emit print value of metric name as a CloudWatch embedded metric format log
+// line, which CloudWatch Logs turns into a metric of the METRICS_NAMESPACE
+// namespace ("Kanobug" by default) without any API call
+func Emit(name string, value float64, unit string, dimensions Dimensions) {
+	namespace := os.Getenv("METRICS_NAMESPACE")
+	if len(namespace) == 0 {
+		namespace = defaultNamespace
+	}
+	keys := []string{}
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	line := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+			"CloudWatchMetrics": []map[string]interface{}{
+				{
+					"Namespace":  namespace,
+					"Dimensions": [][]string{keys},
+					"Metrics":    []map[string]string{{"Name": name, "Unit": unit}},
+				},
+			},
+		},
+		name: value,
+	}
+	for key, dimension := range dimensions {
+		line[key] = dimension
+	}
+	body, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	// a line of its own, the log package prefix would hide it from CloudWatch
+	fmt.Fprintln(os.Stdout, string(body))
+}
+
+// Count emit one occurrence of metric name
+func Count(name string, dimensions Dimensions) {
+	Emit(name, 1, unitCount, dimensions)
+}
+
+// Latency emit the milliseconds elapsed since start as metric name
+func Latency(name string, start time.Time, dimensions Dimensions) {
+	Emit(name, float64(time.Since(start))/float64(time.Millisecond), unitMilliseconds, dimensions)
+}
+
+// Slack count a failed Slack API call of method, doing nothing when err is nil
+func Slack(method string, err error) {
+	if err != nil {
+		Count(SlackAPIFailures, Dimensions{"Method": method})
+	}
+}
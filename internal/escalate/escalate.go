@@ -0,0 +1,121 @@
+package escalate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Conditions a rule escalates open bugs on, once After has passed since
+// they were reported
+const (
+	// Unassigned bugs nobody was assigned to
+	Unassigned = "unassigned"
+	// Unresolved bugs not resolved or closed
+	Unresolved = "unresolved"
+)
+
+// Actions of a rule, carried out by KanobugNotifier
+const (
+	// Ping mentions KANOBUG_TRIAGE_GROUP in the product's triage channel
+	Ping = "ping"
+	// Page mentions the product's ONCALL_GROUPS group, DMing its members
+	// when ONCALL_DM is set
+	Page = "page"
+)
+
+// defaultLadder is the escalation ladder unless ESCALATIONS sets another
+const defaultLadder = "unassigned:24h=ping;unresolved:blocker:4h=page"
+
+// Rule escalates the open bugs matching Condition, and Severity unless
+// empty, After they were reported. Name is the rule as configured, e.g.
+// unresolved:blocker:4h, recorded on the bugs it escalated
+type Rule struct {
+	Name      string
+	Condition string
+	Severity  string
+	After     time.Duration
+	Action    string
+}
+
+// Rules return the escalation ladder of ESCALATIONS, rules as
+// <condition>[:<severity>]:<after>=<action> separated by semicolons, e.g.
+// unassigned:24h=ping;unresolved:blocker:4h=page, the default. It is empty
+// when ESCALATIONS is off
+func Rules() (rules []Rule, err error) {
+	ladder := os.Getenv("ESCALATIONS")
+	switch ladder {
+	case "off":
+		return
+	case "":
+		ladder = defaultLadder
+	}
+	for _, entry := range strings.Split(ladder, ";") {
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+		rule, err := parse(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// parse return the rule of an ESCALATIONS entry
+func parse(entry string) (rule Rule, err error) {
+	kv := strings.SplitN(entry, "=", 2)
+	if len(kv) != 2 {
+		return rule, fmt.Errorf("escalation %q: expected <condition>[:<severity>]:<after>=<action>", entry)
+	}
+	parts := strings.Split(kv[0], ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return rule, fmt.Errorf("escalation %q: expected <condition>[:<severity>]:<after>", entry)
+	}
+	rule.Name, rule.Condition, rule.Action = kv[0], parts[0], kv[1]
+	if len(parts) == 3 {
+		rule.Severity = parts[1]
+	}
+	if rule.After, err = time.ParseDuration(parts[len(parts)-1]); err != nil || rule.After <= 0 {
+		return rule, fmt.Errorf("escalation %q: invalid duration %q", entry, parts[len(parts)-1])
+	}
+	if rule.Condition != Unassigned && rule.Condition != Unresolved {
+		return rule, fmt.Errorf("escalation %q: unknown condition %q", entry, rule.Condition)
+	}
+	if rule.Action != Ping && rule.Action != Page {
+		return rule, fmt.Errorf("escalation %q: unknown action %q", entry, rule.Action)
+	}
+	return
+}
+
+// Matches report whether rule escalates bug at now
+func (rule Rule) Matches(bug store.Bug, now time.Time) bool {
+	if bug.Status == store.StatusResolved || bug.Status == store.StatusClosed || bug.DeletedAt != nil || len(bug.MergedInto) > 0 {
+		return false
+	}
+	if len(rule.Severity) > 0 && rule.Severity != bug.Severity {
+		return false
+	}
+	if rule.Condition == Unassigned && len(bug.Assignee) > 0 {
+		return false
+	}
+	return now.Sub(bug.CreatedAt) >= rule.After
+}
+
+// Due return the rules escalating bug at now it was not escalated by yet
+func Due(bug store.Bug, rules []Rule, now time.Time) (due []Rule) {
+	escalated := map[string]bool{}
+	for _, name := range bug.Escalated {
+		escalated[name] = true
+	}
+	for _, rule := range rules {
+		if !escalated[rule.Name] && rule.Matches(bug, now) {
+			due = append(due, rule)
+		}
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package escalate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		ladder  string
+		want    []Rule
+		wantErr bool
+	}{
+		{"default", "", []Rule{
+			{Name: "unassigned:24h", Condition: Unassigned, After: 24 * time.Hour, Action: Ping},
+			{Name: "unresolved:blocker:4h", Condition: Unresolved, Severity: "blocker", After: 4 * time.Hour, Action: Page},
+		}, false},
+		{"off", "off", nil, false},
+		{"spaced and trailing", " unresolved:30m=ping ; ", []Rule{
+			{Name: "unresolved:30m", Condition: Unresolved, After: 30 * time.Minute, Action: Ping},
+		}, false},
+		{"no action", "unassigned:24h", nil, true},
+		{"no duration", "unassigned=ping", nil, true},
+		{"bad duration", "unassigned:1d=ping", nil, true},
+		{"negative duration", "unassigned:-1h=ping", nil, true},
+		{"unknown condition", "stale:24h=ping", nil, true},
+		{"unknown action", "unassigned:24h=email", nil, true},
+		{"too many parts", "unresolved:blocker:web:4h=page", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ESCALATIONS", tt.ladder)
+			got, err := Rules()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rules() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Source is the EventBridge source of every kanobug event
+const Source = "kanobug"
+
+// Detail types published to the bus, see docs/events.md
+const (
+	BugSubmitted  = "BugSubmitted"
+	IssueCreated  = "IssueCreated"
+	IssueResolved = "IssueResolved"
+	StatusChanged = "StatusChanged"
+	SyncFailed    = "SyncFailed"
+	AuthFailed    = "TrackerAuthFailed"
+	BugRecurred   = "BugRecurred"
+	BugEscalated  = "BugEscalated"
+	ScopesMissing = "SlackScopesMissing"
+)
+
+// BugDetail is the detail of BugSubmitted events
+type BugDetail struct {
+	Bug store.Bug `json:"bug"`
+}
+
+// StatusDetail is the detail of StatusChanged events
+type StatusDetail struct {
+	Bug            store.Bug `json:"bug"`
+	PreviousStatus string    `json:"previous_status"`
+}
+
+// IssueDetail is the detail of IssueCreated and IssueResolved events
+type IssueDetail struct {
+	Bug        store.Bug `json:"bug"`
+	Tracker    string    `json:"tracker"`
+	IssueID    string    `json:"issue_id"`
+	IssueKey   string    `json:"issue_key"`
+	IssueURL   string    `json:"issue_url"`
+	Resolution string    `json:"resolution,omitempty"`
+}
+
+// RecurredDetail is the detail of BugRecurred events, Actor being empty for
+// anonymous bugs and Issue the reopened Jira issue or the regression filed
+// for it
+type RecurredDetail struct {
+	Bug        store.Bug    `json:"bug"`
+	Actor      string       `json:"actor"`
+	Issue      *store.Issue `json:"issue,omitempty"`
+	Regression bool         `json:"regression,omitempty"`
+}
+
+// EscalatedDetail is the detail of BugEscalated events, Rule being the
+// escalation rule the bug matched, see internal/escalate
+type EscalatedDetail struct {
+	Bug       store.Bug `json:"bug"`
+	Rule      string    `json:"rule"`
+	Condition string    `json:"condition"`
+	After     string    `json:"after"`
+	Action    string    `json:"action"`
+}
+
+// SyncFailedDetail is the detail of SyncFailed events
+type SyncFailedDetail struct {
+	Bug     store.Bug `json:"bug"`
+	Tracker string    `json:"tracker"`
+	Error   string    `json:"error"`
+}
+
+// AuthFailedDetail is the detail of TrackerAuthFailed events, Hint being
+// what the response suggests is wrong with the credentials
+type AuthFailedDetail struct {
+	Tracker string `json:"tracker"`
+	Mode    string `json:"mode"`
+	User    string `json:"user"`
+	Status  int    `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Hint    string `json:"hint"`
+}
+
+// ScopesDetail is the detail of SlackScopesMissing events, Missing being the
+// scopes the bot token lacks with the features needing them
+type ScopesDetail struct {
+	Handler string              `json:"handler"`
+	Missing map[string][]string `json:"missing"`
+}
+
+// Publish put an event on the EVENT_BUS_NAME bus, it is a no-op when no bus is configured
+func Publish(detailType string, detail interface{}) (err error) {
+	bus := config.Get().EventBus
+	if len(bus) == 0 {
+		return
+	}
+	defer func() {
+		log.Printf("eventbus.Publish (%s/%s) - error: %v", bus, detailType, err)
+	}()
+	body, err := json.Marshal(detail)
+	if err != nil {
+		return
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	out, err := eventbridge.New(sess).PutEvents(&eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(bus),
+				Source:       aws.String(Source),
+				DetailType:   aws.String(detailType),
+				Detail:       aws.String(string(body)),
+				Time:         aws.Time(time.Now()),
+			},
+		},
+	})
+	if err == nil && aws.Int64Value(out.FailedEntryCount) > 0 {
+		err = errorf(out.Entries)
+	}
+	return
+}
+
+func errorf(entries []*eventbridge.PutEventsResultEntry) error {
+	for _, entry := range entries {
+		if entry.ErrorCode != nil {
+			return fmt.Errorf("%s: %s", aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+		}
+	}
+	return errors.New("put events failed")
+}
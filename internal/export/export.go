@@ -0,0 +1,121 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Header is the CSV column row
+var Header = []string{
+	"id", "created_at", "updated_at", "source", "user_id", "user_name", "product", "severity",
+	"status", "resolution", "security", "customer_impacting", "summary", "details", "issues",
+}
+
+// Write encode bugs to w in format
+func Write(w io.Writer, format string, bugs []store.Bug) error {
+	switch format {
+	case FormatJSON:
+		if bugs == nil {
+			bugs = []store.Bug{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(bugs)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Header); err != nil {
+			return err
+		}
+		for _, bug := range bugs {
+			if err := writer.Write(row(bug)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown export format: %s", format)
+}
+
+func row(bug store.Bug) []string {
+	var issues []string
+	for _, issue := range bug.Issues {
+		link := issue.URL
+		if len(link) == 0 {
+			link = issue.Key
+		}
+		issues = append(issues, issue.Tracker+":"+link)
+	}
+	return []string{
+		bug.ID,
+		bug.CreatedAt.Format(time.RFC3339),
+		bug.UpdatedAt.Format(time.RFC3339),
+		bug.Source,
+		bug.UserID,
+		bug.UserName,
+		bug.Product,
+		bug.Severity,
+		bug.Status,
+		bug.Resolution,
+		strconv.FormatBool(bug.Security),
+		strconv.FormatBool(bug.CustomerImpacting),
+		bug.Summary,
+		bug.Details,
+		strings.Join(issues, " "),
+	}
+}
+
+// Upload write bugs in format to EXPORT_BUCKET and return a presigned
+// download link valid for ttl
+func Upload(format string, bugs []store.Bug, ttl time.Duration) (link string, err error) {
+	var body bytes.Buffer
+	if err = Write(&body, format, bugs); err != nil {
+		return
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	srv := s3.New(sess)
+	bucket := aws.String(os.Getenv("EXPORT_BUCKET"))
+	key := aws.String(fmt.Sprintf("exports/%s-%s.%s", time.Now().UTC().Format("20060102T150405Z"), store.NewID(), format))
+	contentType := "text/csv"
+	if format == FormatJSON {
+		contentType = "application/json"
+	}
+	_, err = srv.PutObject(&s3.PutObjectInput{
+		Bucket:      bucket,
+		Key:         key,
+		Body:        bytes.NewReader(body.Bytes()),
+		ContentType: aws.String(contentType),
+	})
+	log.Printf("export.Upload (%s/%d bugs) - key: %s, error: %v", format, len(bugs), *key, err)
+	if err != nil {
+		return
+	}
+	req, _ := srv.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: bucket,
+		Key:    key,
+	})
+	return req.Presign(ttl)
+}
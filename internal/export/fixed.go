@@ -0,0 +1,66 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Release notes formats
+const (
+	// FormatMarkdown links issues as [KEY](url), for release notes
+	FormatMarkdown = "markdown"
+	// FormatSlack links issues as <url|KEY>, for a Slack reply
+	FormatSlack = "slack"
+)
+
+// Fixed return the bugs of product fixed from since until until, going by
+// their status history, in the order they were fixed. Bugs are created
+// before they are fixed, so only those created until until are read
+func Fixed(product string, since, until time.Time) (fixed []store.Bug, err error) {
+	bugs, err := store.AllBugs(store.Filter{Product: product, Until: until})
+	if err != nil {
+		return
+	}
+	for _, bug := range bugs {
+		if at, ok := bug.FixedAt(); ok && !at.Before(since) && at.Before(until) {
+			fixed = append(fixed, bug)
+		}
+	}
+	sort.Slice(fixed, func(i, j int) bool {
+		a, _ := fixed[i].FixedAt()
+		b, _ := fixed[j].FixedAt()
+		return a.Before(b)
+	})
+	return
+}
+
+// ReleaseNotes return a line per fixed bug, its issue key linked to the
+// issue in format, and the summary. Security sensitive bugs are named
+// without their summary
+func ReleaseNotes(bugs []store.Bug, format string) string {
+	var lines []string
+	for _, bug := range bugs {
+		ref := bug.ID
+		for _, issue := range bug.Issues {
+			if issue.Key != bug.IssueKey || len(issue.URL) == 0 {
+				continue
+			}
+			if format == FormatSlack {
+				ref = fmt.Sprintf("<%s|%s>", issue.URL, issue.Key)
+			} else {
+				ref = fmt.Sprintf("[%s](%s)", issue.Key, issue.URL)
+			}
+			break
+		}
+		summary := bug.Summary
+		if bug.Security {
+			summary = "Security fix"
+		}
+		lines = append(lines, fmt.Sprintf("- %s %s", ref, summary))
+	}
+	return strings.Join(lines, "\n")
+}
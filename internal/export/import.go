@@ -0,0 +1,229 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+// SourceImport is the source of bugs imported without one
+const SourceImport = "import"
+
+// Options of an import, Keep being how long the imported bugs are kept (0 as
+// BUG_RETENTION says) and, when Jira is set, Batch how many Jira issues are
+// created before pausing for Pause, keeping within Jira's rate limits
+type Options struct {
+	Keep   time.Duration
+	Jira   bool
+	Batch  int
+	Pause  time.Duration
+	DryRun bool
+}
+
+// Result counts the bugs of an import, Errors naming the ones not imported
+// or not filed and why
+type Result struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Filed    int      `json:"filed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Read decode the bugs of a csv or json export, of kanobug or another system
+// shaped alike: a JSON array of bugs, or CSV with a Header row naming the
+// columns in any order, those missing left empty
+func Read(r io.Reader, format string) (bugs []store.Bug, err error) {
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&bugs)
+		return
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for line := 2; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return bugs, nil
+			}
+			if err != nil {
+				return bugs, err
+			}
+			bug, err := fromRow(columns, record)
+			if err != nil {
+				return bugs, fmt.Errorf("line %d: %v", line, err)
+			}
+			bugs = append(bugs, bug)
+		}
+	}
+	return nil, fmt.Errorf("unknown import format: %s", format)
+}
+
+// fromRow return the bug of a CSV record, the inverse of row
+func fromRow(columns map[string]int, record []string) (bug store.Bug, err error) {
+	value := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	bug = store.Bug{
+		ID:         value("id"),
+		Source:     value("source"),
+		UserID:     value("user_id"),
+		UserName:   value("user_name"),
+		Product:    value("product"),
+		Severity:   value("severity"),
+		Status:     value("status"),
+		Resolution: value("resolution"),
+		Summary:    value("summary"),
+		Details:    value("details"),
+	}
+	for name, at := range map[string]*time.Time{"created_at": &bug.CreatedAt, "updated_at": &bug.UpdatedAt} {
+		if v := value(name); len(v) > 0 {
+			if *at, err = time.Parse(time.RFC3339, v); err != nil {
+				return bug, fmt.Errorf("invalid %s: %s", name, v)
+			}
+		}
+	}
+	bug.Security, _ = strconv.ParseBool(value("security"))
+	bug.CustomerImpacting, _ = strconv.ParseBool(value("customer_impacting"))
+	// tracker:link, space separated
+	for _, issue := range strings.Fields(value("issues")) {
+		parts := strings.SplitN(issue, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		link := parts[1]
+		i := store.Issue{Tracker: parts[0], Key: link[strings.LastIndex(link, "/")+1:]}
+		if strings.HasPrefix(link, "http") {
+			i.URL = link
+		}
+		bug.Issues = append(bug.Issues, i)
+	}
+	return
+}
+
+// prepare fill in what an imported bug lacks: an ID, the import source,
+// its timestamps and a status, filed when it has issues, and validate it
+func prepare(bug *store.Bug) error {
+	if len(strings.TrimSpace(bug.Summary)) == 0 {
+		return fmt.Errorf("summary is required")
+	}
+	if len(bug.Product) == 0 {
+		return fmt.Errorf("product is required")
+	}
+	if len(bug.ID) == 0 {
+		bug.ID = store.NewID()
+	}
+	if len(bug.Source) == 0 {
+		bug.Source = SourceImport
+	}
+	if len(bug.Severity) == 0 {
+		bug.Severity = store.SeverityMajor
+	}
+	if bug.CreatedAt.IsZero() {
+		bug.CreatedAt = time.Now()
+	}
+	if bug.UpdatedAt.IsZero() {
+		bug.UpdatedAt = bug.CreatedAt
+	}
+	if len(bug.Status) == 0 {
+		bug.Status = store.StatusNew
+		if len(bug.Issues) > 0 {
+			bug.Status = store.StatusFiled
+		}
+	}
+	if !store.ValidStatus(bug.Status) {
+		return fmt.Errorf("invalid status %s", bug.Status)
+	}
+	for _, issue := range bug.Issues {
+		if len(issue.Key) > 0 && len(bug.IssueKey) == 0 {
+			bug.IssueKey = issue.Key
+		}
+	}
+	if len(bug.History) == 0 {
+		bug.History = []store.Change{{Status: bug.Status, Resolution: bug.Resolution, At: bug.UpdatedAt}}
+	}
+	bug.Version, bug.DeletedAt, bug.Queued = 0, nil, false
+	return nil
+}
+
+// Import store bugs in the team the store points at, skipping those whose ID
+// is stored already so an import can be run again, and with options.Jira
+// file the ones without a Jira issue, closing it for resolved and closed
+// bugs. Bugs are stored as they are, no event is published for them
+func Import(bugs []store.Bug, options Options) (result Result) {
+	jira := tracker.NewJira()
+	filed := 0
+	for _, bug := range bugs {
+		if err := prepare(&bug); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", bug.ID, err))
+			continue
+		}
+		if options.DryRun {
+			result.Imported++
+			continue
+		}
+		err := store.ImportBug(bug, options.Keep)
+		if err == store.ErrConflict {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", bug.ID, err))
+			continue
+		}
+		result.Imported++
+		if !options.Jira || hasIssue(bug, jira.Name()) {
+			continue
+		}
+		if options.Batch > 0 && filed > 0 && filed%options.Batch == 0 {
+			log.Printf("export.Import - filed: %d, pausing %s", filed, options.Pause)
+			time.Sleep(options.Pause)
+		}
+		filed++
+		issue, err := jira.CreateIssue(bug)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: jira: %v", bug.ID, err))
+			continue
+		}
+		if bug.Status == store.StatusResolved || bug.Status == store.StatusClosed {
+			if err = jira.Close(issue, bug.Resolution, ""); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: closing %s: %v", bug.ID, issue.Key, err))
+			}
+		}
+		if err = store.LinkIssues(bug, append(bug.Issues, issue)); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: linking %s: %v", bug.ID, issue.Key, err))
+			continue
+		}
+		result.Filed++
+	}
+	log.Printf("export.Import (%d bugs) - result: %+v", len(bugs), result)
+	return
+}
+
+// hasIssue report whether bug has an issue of tracker name
+func hasIssue(bug store.Bug, name string) bool {
+	for _, issue := range bug.Issues {
+		if issue.Tracker == name {
+			return true
+		}
+	}
+	return false
+}
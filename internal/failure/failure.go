@@ -0,0 +1,78 @@
+package failure
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/anzellai/kanobug/internal/i18n"
+)
+
+// Class is the kind of failure of a request, which decides what the user is
+// told and the status code of the response
+type Class string
+
+// Failure classes
+const (
+	// BadRequest is a request that could not be parsed or misses fields
+	BadRequest Class = "bad_request"
+	// Unauthorized is a request failing verification
+	Unauthorized Class = "unauthorized"
+	// Unavailable is a dependency, Slack, the table or a tracker, failing
+	Unavailable Class = "unavailable"
+	// Internal is any other failure, a bug of ours
+	Internal Class = "internal"
+)
+
+// Error is a failure of class with the i18n key of the message to show the
+// user, wrapping the error that caused it
+type Error struct {
+	Class   Class
+	Message string
+	Err     error
+}
+
+// New return a failure of class shown to the user as message, an i18n key,
+// caused by err
+func New(class Class, message string, err error) *Error {
+	return &Error{Class: class, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	message := i18n.T(i18n.Default, e.Message)
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", e.Class, message)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Class, message, e.Err)
+}
+
+// Unwrap return the error that caused the failure
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status return the status code to respond with. Slack shows the body of
+// 200 responses only, so failures the user can act on are 200 and a request
+// that is malformed or unverified gets its 4xx
+func (e *Error) Status() int {
+	switch e.Class {
+	case BadRequest:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	}
+	return http.StatusOK
+}
+
+// Text return what to tell the user about the failure in locale, followed by
+// the hint of its class
+func (e *Error) Text(locale string) string {
+	return fmt.Sprintf(":warning: %s %s", i18n.T(locale, e.Message), i18n.T(locale, "failure."+string(e.Class)))
+}
+
+// From return err as a failure, an Internal one unless it is already typed
+func From(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return New(Internal, "error.internal", err)
+}
@@ -0,0 +1,147 @@
+package fault
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/anzellai/kanobug/internal/config"
+)
+
+// Kinds of fault besides a status code, e.g. 500
+const (
+	// Timeout holds the call until its timeout or the invocation runs out
+	Timeout = "timeout"
+	// Throttle answers as a rate limited call: 429 with a Retry-After, or
+	// for DynamoDB the ProvisionedThroughputExceededException its SDK retries
+	Throttle = "throttle"
+	// Error fails the call as a dropped connection would
+	Error = "error"
+)
+
+// ErrInjected is the error of Error faults
+var ErrInjected = errors.New("injected fault: connection reset by peer")
+
+// Fault is a failure injected into the calls to Dependency, a Rate of them
+type Fault struct {
+	Dependency string
+	Kind       string
+	Rate       float64
+}
+
+var (
+	faults     map[string]Fault
+	faultsOnce sync.Once
+)
+
+// Enabled report whether faults may be injected, only on a STAGE other than
+// prod so a FAULTS left set can not take production down
+func Enabled() bool {
+	return !config.Get().Stage.Prod()
+}
+
+// Faults return the faults of FAULTS by dependency, e.g.
+// jira=500;slack=timeout:0.2;dynamodb=throttle:0.5, the rate being 1 unless
+// given, read once per container. None are returned unless Enabled
+func Faults() map[string]Fault {
+	faultsOnce.Do(func() {
+		faults = map[string]Fault{}
+		value := os.Getenv("FAULTS")
+		if len(value) == 0 {
+			return
+		}
+		if !Enabled() {
+			log.Printf("fault.Faults (%s) - FAULTS ignored on stage: %q", value, config.Get().Stage)
+			return
+		}
+		for _, entry := range strings.Split(value, ";") {
+			kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			spec := strings.SplitN(strings.TrimSpace(kv[1]), ":", 2)
+			fault := Fault{Dependency: strings.ToLower(strings.TrimSpace(kv[0])), Kind: strings.ToLower(spec[0]), Rate: 1}
+			if len(spec) == 2 {
+				rate, err := strconv.ParseFloat(spec[1], 64)
+				if err != nil || rate < 0 || rate > 1 {
+					log.Printf("fault.Faults (%s) - invalid rate: %q", entry, spec[1])
+					continue
+				}
+				fault.Rate = rate
+			}
+			if _, err := strconv.Atoi(fault.Kind); err != nil && fault.Kind != Timeout && fault.Kind != Throttle && fault.Kind != Error {
+				log.Printf("fault.Faults (%s) - invalid kind: %q", entry, fault.Kind)
+				continue
+			}
+			faults[fault.Dependency] = fault
+		}
+		log.Printf("fault.Faults (%s) - stage: %s, injecting: %d", value, config.Get().Stage, len(faults))
+	})
+	return faults
+}
+
+// Active report whether calls to dependency may fail by design
+func Active(dependency string) bool {
+	_, ok := Faults()[dependency]
+	return ok
+}
+
+// Transport return next with the fault of dependency injected into a Rate of
+// its requests, next itself when there is none
+func Transport(dependency string, next http.RoundTripper) http.RoundTripper {
+	fault, ok := Faults()[dependency]
+	if !ok {
+		return next
+	}
+	return transport{fault: fault, next: next}
+}
+
+type transport struct {
+	fault Fault
+	next  http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() >= t.fault.Rate {
+		return t.next.RoundTrip(req)
+	}
+	log.Printf("fault.Transport (%s) - injected: %s, %s %s", t.fault.Dependency, t.fault.Kind, req.Method, req.URL.Host)
+	switch t.fault.Kind {
+	case Timeout:
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	case Error:
+		return nil, ErrInjected
+	case Throttle:
+		if t.fault.Dependency == "dynamodb" {
+			return respond(req, http.StatusBadRequest, `{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"injected fault"}`), nil
+		}
+		resp := respond(req, http.StatusTooManyRequests, `{"ok":false,"error":"ratelimited"}`)
+		resp.Header.Set("Retry-After", "1")
+		return resp, nil
+	}
+	status, _ := strconv.Atoi(t.fault.Kind)
+	// both the Slack and the Jira error bodies, so either decodes it
+	return respond(req, status, `{"ok":false,"error":"injected_fault","errorMessages":["injected fault"]}`), nil
+}
+
+// respond return a JSON response to req with status and body
+func respond(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,161 @@
+package flags
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// Flags consulted by the handlers
+const (
+	// Anonymous offers reporting anonymously on the report form
+	Anonymous = "anonymous"
+	// Dedupe checks orchestrated submissions for a duplicate of a recent one
+	Dedupe = "dedupe"
+	// Sprint offers triagers a button on the triage post moving the bug's
+	// Jira issue into the active sprint
+	Sprint = "sprint"
+	// Similar links filed bugs to a probable duplicate with a similar summary
+	Similar = "similar"
+)
+
+// defaults are the values of flags the parameter doesn't set, trackers not
+// listed are on
+var defaults = map[string]bool{
+	Anonymous: true,
+	Dedupe:    true,
+	Sprint:    false,
+	Similar:   true,
+}
+
+// defaultTTL is how long the parameter is cached unless FLAGS_TTL says otherwise
+const defaultTTL = time.Minute
+
+// Flag is how a flag is set: per product of a team, per product, per team
+// and for everyone else, the most specific setting winning
+type Flag struct {
+	Default      *bool           `json:"default,omitempty"`
+	Teams        map[string]bool `json:"teams,omitempty"`
+	Products     map[string]bool `json:"products,omitempty"`
+	TeamProducts map[string]bool `json:"team_products,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	cached  map[string]Flag
+	fetched time.Time
+)
+
+// Tracker return the flag turning tracker name on or off
+func Tracker(name string) string {
+	return "tracker_" + name
+}
+
+// Enabled report whether flag is on for product of team, as set by the JSON
+// object of flags stored in the FLAGS_PARAMETER SSM parameter, e.g.
+// {"anonymous": {"default": false, "teams": {"T123": true}}}, or else the
+// flag's default
+func Enabled(flag, team, product string) bool {
+	f, ok := load()[flag]
+	if ok {
+		if on, ok := f.TeamProducts[team+"/"+product]; ok && len(product) > 0 {
+			return on
+		}
+		if on, ok := f.Products[product]; ok && len(product) > 0 {
+			return on
+		}
+		if on, ok := f.Teams[team]; ok && len(team) > 0 {
+			return on
+		}
+		if f.Default != nil {
+			return *f.Default
+		}
+	}
+	if on, ok := defaults[flag]; ok {
+		return on
+	}
+	return true
+}
+
+// Offered report whether flag is on anywhere: by default or for any team or
+// product
+func Offered(flag string) bool {
+	f, ok := load()[flag]
+	if !ok || f.Default == nil {
+		if on, ok := defaults[flag]; !ok || on {
+			return true
+		}
+	} else if *f.Default {
+		return true
+	}
+	for _, settings := range []map[string]bool{f.Teams, f.Products, f.TeamProducts} {
+		for _, on := range settings {
+			if on {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// load return the flags, fetching the parameter again once the cached copy is
+// older than FLAGS_TTL. The last fetched flags are kept when fetching fails,
+// the defaults apply until one succeeds
+func load() map[string]Flag {
+	name := os.Getenv("FLAGS_PARAMETER")
+	if len(name) == 0 {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if cached != nil && time.Since(fetched) < ttl() {
+		return cached
+	}
+	flags, err := fetch(name)
+	if err != nil {
+		log.Printf("flags.load (%s) - error: %v", name, err)
+		// retry once the ttl is up rather than on every lookup
+		fetched = time.Now()
+		if cached == nil {
+			cached = map[string]Flag{}
+		}
+		return cached
+	}
+	cached, fetched = flags, time.Now()
+	return cached
+}
+
+// ttl return how long fetched flags are used, FLAGS_TTL or defaultTTL
+func ttl() time.Duration {
+	if value := os.Getenv("FLAGS_TTL"); len(value) > 0 {
+		if ttl, err := time.ParseDuration(value); err == nil {
+			return ttl
+		}
+	}
+	return defaultTTL
+}
+
+// fetch read the flags from SSM parameter name
+func fetch(name string) (flags map[string]Flag, err error) {
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	out, err := ssm.New(sess).GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return
+	}
+	flags = map[string]Flag{}
+	err = json.Unmarshal([]byte(aws.StringValue(out.Parameter.Value)), &flags)
+	return
+}
@@ -0,0 +1,35 @@
+package flags
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOffered(t *testing.T) {
+	off, on := false, true
+	tests := []struct {
+		name  string
+		flags map[string]Flag
+		flag  string
+		want  bool
+	}{
+		{"default on", map[string]Flag{}, Anonymous, true},
+		{"default off", map[string]Flag{}, Sprint, false},
+		{"unknown flag", map[string]Flag{}, "unknown", true},
+		{"off everywhere", map[string]Flag{Anonymous: {Default: &off}}, Anonymous, false},
+		{"off with one team on", map[string]Flag{Anonymous: {Default: &off, Teams: map[string]bool{"T1": true}}}, Anonymous, true},
+		{"off with one team product on", map[string]Flag{Anonymous: {Default: &off, TeamProducts: map[string]bool{"T1/kit": true}}}, Anonymous, true},
+		{"off with teams off", map[string]Flag{Anonymous: {Default: &off, Teams: map[string]bool{"T1": false}}}, Anonymous, false},
+		{"set on", map[string]Flag{Sprint: {Default: &on}}, Sprint, true},
+		{"default off with product on", map[string]Flag{Sprint: {Products: map[string]bool{"kit": true}}}, Sprint, true},
+	}
+	t.Setenv("FLAGS_PARAMETER", "/test/flags")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached, fetched = tt.flags, time.Now()
+			if got := Offered(tt.flag); got != tt.want {
+				t.Errorf("Offered(%s) = %v, want %v", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+package form
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/classify"
+	"github.com/anzellai/kanobug/internal/i18n"
+	"github.com/anzellai/kanobug/internal/orchestrate"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Payload struct type ...
+type Payload struct {
+	TriggerID string `json:"trigger_id"`
+	View      View   `json:"view"`
+}
+
+// Dialog describes the report form independent of platform, converted to a
+// Slack modal by Modal, and to a Mattermost dialog by the slash command
+type Dialog struct {
+	Title       string
+	CallbackID  string
+	SubmitLabel string
+	State       string
+	Elements    []Element
+}
+
+// Element is a form field, Type is text, textarea, select, multi_select,
+// date, time, users or checkbox
+type Element struct {
+	Label    string
+	Type     string
+	SubType  string
+	Name     string
+	Value    string
+	Values   []string
+	Hint     string
+	Options  []Option
+	Optional bool
+	// External selects load their options as typed into, from the
+	// interactive component endpoint
+	External bool
+}
+
+// Element return the element named name, nil when absent
+func (dialog *Dialog) Element(name string) *Element {
+	for i := range dialog.Elements {
+		if dialog.Elements[i].Name == name {
+			return &dialog.Elements[i]
+		}
+	}
+	return nil
+}
+
+// Remove drop the element named name
+func (dialog *Dialog) Remove(name string) {
+	for i := range dialog.Elements {
+		if dialog.Elements[i].Name == name {
+			dialog.Elements = append(dialog.Elements[:i], dialog.Elements[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasOption report whether value is one of the element's options
+func (element Element) HasOption(value string) bool {
+	for _, o := range element.Options {
+		if o.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// View is a Slack modal view
+type View struct {
+	Type            string                   `json:"type"`
+	CallbackID      string                   `json:"callback_id"`
+	Title           text                     `json:"title"`
+	Submit          text                     `json:"submit"`
+	Close           text                     `json:"close"`
+	NotifyOnClose   bool                     `json:"notify_on_close"`
+	PrivateMetadata string                   `json:"private_metadata"`
+	Blocks          []map[string]interface{} `json:"blocks"`
+}
+
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func plainText(s string) text {
+	return text{Type: "plain_text", Text: s}
+}
+
+// Metadata is carried through the modal's private_metadata to the
+// submission, Product is set when the channel hides the product select and
+// BugID when editing a bug, with the Version and Checksum the edit started from
+type Metadata struct {
+	ResponseURL string `json:"response_url"`
+	ChannelID   string `json:"channel_id"`
+	Product     string `json:"product,omitempty"`
+	BugID       string `json:"bug_id,omitempty"`
+	Version     int64  `json:"version,omitempty"`
+	Checksum    string `json:"checksum,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	// Issued is when ResponseURL was issued, set by Modal
+	Issued time.Time `json:"issued"`
+	// Files are those of the message a report was started from with the
+	// message shortcut, attached to the filed issues
+	Files []orchestrate.File `json:"files,omitempty"`
+}
+
+// Option struct type ...
+type Option struct {
+	Label string
+	Value string
+}
+
+// Options convert catalog options to form options
+func Options(from []catalog.Option) (opts []Option) {
+	for _, o := range from {
+		opts = append(opts, Option{Label: o.Label, Value: o.Value})
+	}
+	return
+}
+
+// Report return the bug report dialog in locale, pre-filling the summary with
+// summary, the product and severity with the user's last used ones and the
+// product with the channel's, carrying it in the dialog state when the
+// channel hides the product select
+func Report(summary string, channel store.Channel, preference store.Preference, locale string) Dialog {
+	t := func(key string) string { return i18n.T(locale, key) }
+	product := channel.Product
+	if len(product) == 0 {
+		product = preference.Product
+	}
+	dialog := Dialog{
+		Title:       t("report.title"),
+		CallbackID:  "report-bug",
+		SubmitLabel: t("report.submit"),
+		Elements: []Element{
+			Element{
+				Label: t("report.summary.label"),
+				Type:  "text",
+				Name:  "summary",
+				Value: summary,
+				Hint:  t("report.summary.hint"),
+			},
+			Element{
+				Label:   t("report.product.label"),
+				Type:    "select",
+				Name:    "product",
+				Options: Options(catalog.ProductOptions()),
+			},
+			Element{
+				Label:   t("report.severity.label"),
+				Type:    "select",
+				Name:    "severity",
+				Value:   catalog.DefaultSeverity,
+				Options: Options(catalog.Severities),
+			},
+			Element{
+				Label: t("report.security.label"),
+				Type:  "select",
+				Name:  "security",
+				Value: "no",
+				Hint:  t("report.security.hint"),
+				Options: []Option{
+					Option{
+						Label: t("report.security.no"),
+						Value: "no",
+					},
+					Option{
+						Label: t("report.security.yes"),
+						Value: "yes",
+					},
+				},
+			},
+			Element{
+				Label: t("report.customer.label"),
+				Type:  "select",
+				Name:  "customer_impacting",
+				Value: "no",
+				Hint:  t("report.customer.hint"),
+				Options: []Option{
+					Option{
+						Label: t("report.customer.no"),
+						Value: "no",
+					},
+					Option{
+						Label: t("report.customer.yes"),
+						Value: "yes",
+					},
+				},
+			},
+			Element{
+				Label:    t("report.versions.label"),
+				Type:     "multi_select",
+				Name:     "versions",
+				Hint:     t("report.versions.hint"),
+				Options:  Options(catalog.Versions(product)),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.tags.label"),
+				Type:     "multi_select",
+				Name:     "tags",
+				Options:  Options(catalog.Tags()),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.other_tags.label"),
+				Type:     "text",
+				Name:     "other_tags",
+				Hint:     t("report.other_tags.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.occurred_date.label"),
+				Type:     "date",
+				Name:     "occurred_date",
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.occurred_time.label"),
+				Type:     "time",
+				Name:     "occurred_time",
+				Hint:     t("report.occurred_time.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.cc.label"),
+				Type:     "users",
+				Name:     "cc",
+				Hint:     t("report.cc.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.anonymous.label"),
+				Type:     "checkbox",
+				Name:     "anonymous",
+				Options:  []Option{{Label: t("report.anonymous.option"), Value: "yes"}},
+				Hint:     t("report.anonymous.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.details.label"),
+				Type:     "textarea",
+				Name:     "details",
+				Hint:     t("report.details.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.environment.label"),
+				Type:     "text",
+				Name:     "environment",
+				Hint:     t("report.environment.hint"),
+				Optional: true,
+			},
+			Element{
+				Label:    t("report.link.label"),
+				Type:     "text",
+				SubType:  "url",
+				Name:     "link",
+				Hint:     t("report.link.hint"),
+				Optional: true,
+			},
+		},
+	}
+	if versions := dialog.Element("versions"); len(versions.Options) > catalog.MaxOptions {
+		// too many for a static select, typed into instead
+		versions.Options, versions.External = nil, true
+	}
+	for _, name := range []string{"versions", "tags"} {
+		if e := dialog.Element(name); len(e.Options) == 0 && !e.External {
+			dialog.Remove(name)
+		}
+	}
+	if len(preference.Severity) > 0 {
+		dialog.Element("severity").Value = preference.Severity
+	}
+	if len(channel.Product) == 0 {
+		dialog.Element("product").Value = preference.Product
+		return dialog
+	}
+	if channel.Hidden {
+		dialog.State = channel.Product
+		dialog.Remove("product")
+		return dialog
+	}
+	dialog.Element("product").Value = channel.Product
+	return dialog
+}
+
+// Modal convert dialog to a Slack modal carrying metadata
+func Modal(dialog Dialog, metadata Metadata) View {
+	if metadata.Issued.IsZero() && len(metadata.ResponseURL) > 0 {
+		metadata.Issued = time.Now()
+	}
+	encoded, _ := json.Marshal(metadata)
+	view := View{
+		Type:            "modal",
+		CallbackID:      dialog.CallbackID,
+		Title:           plainText(dialog.Title),
+		Submit:          plainText(dialog.SubmitLabel),
+		Close:           plainText(i18n.T(metadata.Locale, "report.cancel")),
+		NotifyOnClose:   true,
+		PrivateMetadata: string(encoded),
+	}
+	for _, e := range dialog.Elements {
+		element := map[string]interface{}{"action_id": e.Name}
+		option := func(o Option) map[string]interface{} {
+			return map[string]interface{}{"text": plainText(o.Label), "value": o.Value}
+		}
+		switch e.Type {
+		case "text", "textarea":
+			element["type"] = "plain_text_input"
+			element["multiline"] = e.Type == "textarea"
+			if e.SubType == "url" {
+				element = map[string]interface{}{"action_id": e.Name, "type": "url_text_input"}
+			}
+			if len(e.Value) > 0 {
+				element["initial_value"] = e.Value
+			}
+		case "date":
+			element["type"] = "datepicker"
+			if len(e.Value) > 0 {
+				element["initial_date"] = e.Value
+			}
+		case "time":
+			element["type"] = "timepicker"
+			if len(e.Value) > 0 {
+				element["initial_time"] = e.Value
+			}
+		case "users":
+			element["type"] = "multi_users_select"
+			if len(e.Values) > 0 {
+				element["initial_users"] = e.Values
+			}
+		case "select", "multi_select", "checkbox":
+			element["type"] = map[string]string{
+				"select":       "static_select",
+				"multi_select": "multi_static_select",
+				"checkbox":     "checkboxes",
+			}[e.Type]
+			var opts, selected []map[string]interface{}
+			for _, o := range e.Options {
+				opts = append(opts, option(o))
+				if o.Value == e.Value {
+					element["initial_option"] = option(o)
+				}
+				for _, v := range e.Values {
+					if o.Value == v {
+						selected = append(selected, option(o))
+					}
+				}
+			}
+			element["options"] = opts
+			if len(selected) > 0 {
+				element["initial_options"] = selected
+			}
+			if e.External {
+				element = map[string]interface{}{"action_id": e.Name, "type": "multi_external_select", "min_query_length": 0}
+				for _, v := range e.Values {
+					selected = append(selected, option(Option{Label: v, Value: v}))
+				}
+				if len(selected) > 0 {
+					element["initial_options"] = selected
+				}
+			}
+		}
+		block := map[string]interface{}{
+			"type":     "input",
+			"block_id": e.Name,
+			"label":    plainText(e.Label),
+			"element":  element,
+			"optional": e.Optional,
+		}
+		if len(e.Hint) > 0 {
+			block["hint"] = plainText(e.Hint)
+		}
+		view.Blocks = append(view.Blocks, block)
+	}
+	return view
+}
+
+// UserPreference return the last used form values of userID, empty when unknown
+func UserPreference(userID string) (preference store.Preference) {
+	if !store.ConfigEnabled() {
+		return
+	}
+	preference, err := store.GetPreference(userID)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("form.UserPreference (%s) - error: %v", userID, err)
+	}
+	return
+}
+
+// RestoreDraft pre-fill dialog with the draft the user last closed, keeping
+// a summary given as command text
+func RestoreDraft(dialog *Dialog, userID string) {
+	if !store.ConfigEnabled() {
+		return
+	}
+	draft, err := store.GetDraft(userID)
+	if err != nil {
+		if err != store.ErrNotFound {
+			log.Printf("form.RestoreDraft (%s) - error: %v", userID, err)
+		}
+		return
+	}
+	for i := range dialog.Elements {
+		e := &dialog.Elements[i]
+		values, ok := draft.Values[e.Name]
+		if !ok || len(values) == 0 || (e.Name == "summary" && len(e.Value) > 0) {
+			continue
+		}
+		switch e.Type {
+		case "multi_select", "users", "checkbox":
+			e.Values = values
+		default:
+			e.Value = values[0]
+		}
+	}
+}
+
+// ChannelProduct return the product mapping of channelID, empty when unmapped
+func ChannelProduct(channelID string) (channel store.Channel) {
+	if !store.ConfigEnabled() {
+		return
+	}
+	channel, err := store.GetChannel(channelID)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("form.ChannelProduct (%s) - error: %v", channelID, err)
+	}
+	return
+}
+
+// SuggestSeverity pre-select the severity the classifier suggests for the
+// summary and details in d, leaving it be without a suggestion
+func SuggestSeverity(d *Dialog, summary, details string) {
+	severity := d.Element("severity")
+	if severity == nil {
+		return
+	}
+	if suggestion, ok := classify.Suggest(summary, details); ok && severity.HasOption(suggestion.Severity) {
+		severity.Value = suggestion.Severity
+	}
+}
+
+// InferProduct pre-select in d the product text most likely is about, see
+// catalog.Infer, saying in its hint what gave it away and how sure that is
+// so the reporter can correct it. A product hidden by the channel is left be
+func InferProduct(d *Dialog, text string, channel store.Channel, locale string) {
+	product := d.Element("product")
+	if product == nil || len(strings.TrimSpace(text)) == 0 {
+		return
+	}
+	inference, ok := catalog.Infer(text, channel.Product)
+	if !ok || inference.Reason == "channel" || !product.HasOption(inference.Product) {
+		return
+	}
+	product.Value = inference.Product
+	product.Hint = i18n.T(locale, "report.product.inferred", inference.Reason, i18n.T(locale, "report.confidence."+inference.Confidence))
+	log.Printf("form.InferProduct (%s) - inference: %+v", channel.ID, inference)
+}
+
+// ApplyTemplate pre-fill the empty details of d with the template of the
+// product pre-selected or carried in the dialog state, before RestoreDraft
+// so a draft wins
+func ApplyTemplate(d *Dialog) {
+	details := d.Element("details")
+	if details == nil || len(details.Value) > 0 {
+		return
+	}
+	product := d.State
+	if e := d.Element("product"); e != nil {
+		product = e.Value
+	}
+	details.Value = catalog.Template(product)
+}
+
+// ChannelEpic return the epic of the bug bash running in channelID, empty
+// when none is
+func ChannelEpic(channelID string) string {
+	if !store.ConfigEnabled() || len(channelID) == 0 {
+		return ""
+	}
+	bash, err := store.GetBash(channelID)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("form.ChannelEpic (%s) - error: %v", channelID, err)
+	}
+	return bash.Epic
+}
+
+// Open open view as a Slack modal for triggerID
+func Open(triggerID string, view View) error {
+	return slack.Default.OpenView(triggerID, view)
+}
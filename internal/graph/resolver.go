@@ -0,0 +1,188 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen
+
+import (
+	"context"
+	"sort"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Resolver is the root resolver backed by the bugs table and its indexes
+type Resolver struct{}
+
+// Bug return the Bug object resolver
+func (r *Resolver) Bug() BugResolver {
+	return &bugResolver{r}
+}
+
+// Product return the Product object resolver
+func (r *Resolver) Product() ProductResolver {
+	return &productResolver{r}
+}
+
+// Query return the root query resolver
+func (r *Resolver) Query() QueryResolver {
+	return &queryResolver{r}
+}
+
+type bugResolver struct{ *Resolver }
+
+func (r *bugResolver) Product(ctx context.Context, obj *store.Bug) (*catalog.Option, error) {
+	return product(obj.Product), nil
+}
+
+type productResolver struct{ *Resolver }
+
+func (r *productResolver) Bugs(ctx context.Context, obj *catalog.Option, filter *BugFilter, limit *int, cursor *string) (*BugPage, error) {
+	f := toFilter(filter)
+	f.Product = obj.Value
+	return page(f, limit, cursor)
+}
+
+func (r *productResolver) Stats(ctx context.Context, obj *catalog.Option, filter *BugFilter) (*Stats, error) {
+	f := toFilter(filter)
+	f.Product = obj.Value
+	return stats(f)
+}
+
+type queryResolver struct{ *Resolver }
+
+func (r *queryResolver) Bug(ctx context.Context, id string) (*store.Bug, error) {
+	bug, err := store.GetBug(id)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bug, nil
+}
+
+func (r *queryResolver) Bugs(ctx context.Context, filter *BugFilter, limit *int, cursor *string) (*BugPage, error) {
+	return page(toFilter(filter), limit, cursor)
+}
+
+func (r *queryResolver) Products(ctx context.Context) (products []*catalog.Option, err error) {
+	for _, o := range catalog.ProductOptions() {
+		option := o
+		products = append(products, &option)
+	}
+	return
+}
+
+func (r *queryResolver) Reporters(ctx context.Context, filter *BugFilter) (reporters []*Reporter, err error) {
+	bugs, err := store.AllBugs(toFilter(filter))
+	if err != nil {
+		return
+	}
+	byUser := map[string]*Reporter{}
+	for _, bug := range bugs {
+		reporter, ok := byUser[bug.UserID]
+		if !ok {
+			reporter = &Reporter{UserID: bug.UserID, UserName: bug.UserName}
+			byUser[bug.UserID] = reporter
+			reporters = append(reporters, reporter)
+		}
+		reporter.Count++
+	}
+	sort.SliceStable(reporters, func(i, j int) bool { return reporters[i].Count > reporters[j].Count })
+	return
+}
+
+func (r *queryResolver) Stats(ctx context.Context, filter *BugFilter) (*Stats, error) {
+	return stats(toFilter(filter))
+}
+
+// product return the built in catalog option for value, keeping configured
+// and unknown products queryable under their raw value
+func product(value string) *catalog.Option {
+	for _, o := range catalog.Products {
+		if o.Value == value {
+			option := o
+			return &option
+		}
+	}
+	return &catalog.Option{Label: store.Bug{Product: value}.ProductName(), Value: value}
+}
+
+func toFilter(filter *BugFilter) (f store.Filter) {
+	if filter == nil {
+		return
+	}
+	if filter.UserID != nil {
+		f.UserID = *filter.UserID
+	}
+	if filter.Product != nil {
+		f.Product = *filter.Product
+	}
+	if filter.Status != nil {
+		f.Status = *filter.Status
+	}
+	if filter.Severity != nil {
+		f.Severity = *filter.Severity
+	}
+	if filter.Since != nil {
+		f.Since = *filter.Since
+	}
+	if filter.Until != nil {
+		f.Until = *filter.Until
+	}
+	return
+}
+
+func page(filter store.Filter, limit *int, cursor *string) (*BugPage, error) {
+	if limit != nil {
+		filter.Limit = int64(*limit)
+	}
+	if cursor != nil {
+		filter.Cursor = *cursor
+	}
+	bugs, next, err := store.ListBugs(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &BugPage{Bugs: []*store.Bug{}}
+	for i := range bugs {
+		result.Bugs = append(result.Bugs, &bugs[i])
+	}
+	if len(next) > 0 {
+		result.Cursor = &next
+	}
+	return result, nil
+}
+
+// stats aggregate every bug matching filter into counts per dimension
+func stats(filter store.Filter) (*Stats, error) {
+	bugs, err := store.AllBugs(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &Stats{Total: len(bugs)}
+	result.ByProduct = count(bugs, func(bug store.Bug) string { return bug.Product })
+	result.ByStatus = count(bugs, func(bug store.Bug) string { return bug.Status })
+	result.BySeverity = count(bugs, func(bug store.Bug) string { return bug.Severity })
+	result.BySource = count(bugs, func(bug store.Bug) string { return bug.Source })
+	return result, nil
+}
+
+func count(bugs []store.Bug, key func(store.Bug) string) (counts []*Count) {
+	byKey := map[string]*Count{}
+	for _, bug := range bugs {
+		k := key(bug)
+		c, ok := byKey[k]
+		if !ok {
+			c = &Count{Key: k}
+			byKey[k] = c
+			counts = append(counts, c)
+		}
+		c.Count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	if counts == nil {
+		counts = []*Count{}
+	}
+	return
+}
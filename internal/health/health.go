@@ -0,0 +1,115 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+const (
+	// Path is the route answered with the readiness report, also the path of
+	// the scheduled warm-up events, see serverless.yml
+	Path     = "/healthz"
+	authTest = "https://slack.com/api/auth.test"
+)
+
+// Check is the outcome of checking a dependency
+type Check struct {
+	Name         string `json:"name"`
+	OK           bool   `json:"ok"`
+	Error        string `json:"error,omitempty"`
+	Milliseconds int64  `json:"ms"`
+}
+
+// Report is the readiness of the Lambda, ready when every check passed
+type Report struct {
+	Handler string  `json:"handler"`
+	Ready   bool    `json:"ready"`
+	Checks  []Check `json:"checks"`
+}
+
+// IsPing report whether an API Gateway request is the health route or a
+// scheduled warm-up event, which carries its method and path
+func IsPing(method, path string) bool {
+	return method == http.MethodGet && strings.HasSuffix(path, Path)
+}
+
+// Warm make the clients of the Lambda ahead of its first invocation, called
+// from main so provisioned concurrency initializes them too
+func Warm() {
+	_, _ = store.GetDB()
+}
+
+// Run check the table, Slack and, when configured, Jira, logging the report
+func Run(handler string) (report Report) {
+	report.Handler, report.Ready = handler, true
+	check := func(name string, ping func() error) {
+		start := time.Now()
+		err := ping()
+		c := Check{Name: name, OK: err == nil, Milliseconds: int64(time.Since(start) / time.Millisecond)}
+		if err != nil {
+			c.Error = err.Error()
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, c)
+	}
+	check(outbound.DynamoDB, store.Ping)
+	check(outbound.Slack, slack)
+	if jira := tracker.NewJira(); len(jira.Host) > 0 {
+		check(jira.Name(), jira.Ping)
+	}
+	log.Printf("health.Run (%s) - ready: %t, checks: %+v", handler, report.Ready, report.Checks)
+	return
+}
+
+// Status return the status code to answer with, 503 unless ready
+func (report Report) Status() int {
+	if report.Ready {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+// JSON return the report as the body of the response
+func (report Report) JSON() string {
+	body, _ := json.Marshal(report)
+	return string(body)
+}
+
+// slack check the bot token with auth.test
+func slack() (err error) {
+	token := config.Get().SlackToken
+	if len(token) == 0 {
+		return errors.New("SLACK_ACCESS_TOKEN is not set")
+	}
+	req, err := http.NewRequest("POST", authTest, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := outbound.Do(outbound.Slack, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return
+	}
+	if !status.OK {
+		err = fmt.Errorf("auth.test: %s", status.Error)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package i18n
+
+// en is the English bundle, holding every key
+var en = map[string]string{
+	// report form
+	"report.title":               "Report a Bug",
+	"report.submit":              "Submit",
+	"report.cancel":              "Cancel",
+	"edit.title":                 "Edit bug",
+	"edit.submit":                "Save",
+	"report.summary.label":       "Summarise the Problem",
+	"report.summary.hint":        "A sentence to summarise the problem",
+	"report.product.label":       "Product",
+	"report.product.inferred":    "Guessed from “%s” with %s confidence, change it if it's wrong",
+	"report.confidence.high":     "high",
+	"report.confidence.medium":   "medium",
+	"report.confidence.low":      "low",
+	"report.severity.label":      "Severity",
+	"report.security.label":      "Security sensitive?",
+	"report.security.hint":       "Security sensitive bugs are filed as confidential where the tracker supports it.",
+	"report.security.no":         "No",
+	"report.security.yes":        "Yes - keep it confidential",
+	"report.customer.label":      "Customer impacting?",
+	"report.customer.hint":       "Customer impacting bugs also open a support ticket.",
+	"report.customer.no":         "No",
+	"report.customer.yes":        "Yes - customers are affected",
+	"report.versions.label":      "Affected versions",
+	"report.versions.hint":       "Every firmware or app version you have seen it on.",
+	"report.tags.label":          "Tags",
+	"report.other_tags.label":    "Other tags",
+	"report.other_tags.hint":     "Comma separated, e.g. wifi, onboarding",
+	"report.occurred_date.label": "When did this happen?",
+	"report.occurred_time.label": "At what time?",
+	"report.occurred_time.hint":  "In your Slack timezone, leave empty if you only know the day.",
+	"report.cc.label":            "CC teammates",
+	"report.cc.hint":             "They get the confirmation, watch the Jira issue and hear about status changes.",
+	"report.anonymous.label":     "Privacy",
+	"report.anonymous.option":    "Report anonymously",
+	"report.anonymous.hint":      "Trackers will not show your name and the confirmation comes as a DM.",
+	"report.details.label":       "Any more details?",
+	"report.details.hint":        "If you can help us reproduce the bug, that'd be grand.",
+	"report.environment.label":   "Environment",
+	"report.environment.hint":    "OS and app version, e.g. Kano OS 4.1, Make Art 2.3",
+	"report.link.label":          "Link",
+	"report.link.hint":           "A screenshot, recording or the page where it happened",
+
+	// validation
+	"validate.summary.short": "Please describe the problem in at least %d characters.",
+	"validate.summary.long":  "Please keep the summary under %d characters, add the rest to the details.",
+	"validate.link":          "Please enter a full http(s) link.",
+	"validate.environment":   "This product needs the OS and app version to reproduce the bug.",
+	"validate.banned":        "Please remove %q, it is not allowed in bug reports.",
+
+	// confirmations
+	"confirm.submitted":    "Bug submitted to %s",
+	"confirm.issue":        "Bug submitted - ID: %s, Key: %s, Issue Link: %s",
+	"confirm.queued":       "%s is unavailable, your bug %s is queued and will be filed automatically.",
+	"confirm.tracker":      "the tracker",
+	"confirm.and":          " and ",
+	"confirm.not_filed":    ":warning: Your bug %s was saved but could not be filed to the tracker, the KanoBUG admins can file it with `kanobugctl replay`.",
+	"confirm.recovered":    "Something went wrong, your report was saved locally. Run `/kanobug` again to pick up where you left off.",
+	"confirm.not_attached": "Not attached: %s",
+	"confirm.queued_files": "Files are not kept for queued bugs, please add them to the issue once it is filed.",
+
+	// failures, by class then message
+	"failure.bad_request":         "Please check the request and try again.",
+	"failure.unauthorized":        "This request could not be verified, check the app's configuration.",
+	"failure.unavailable":         "Please try again in a few minutes.",
+	"failure.internal":            "Please try again, and tell the KanoBUG admins if it keeps failing.",
+	"error.internal":              "Something went wrong.",
+	"error.command.unreadable":    "The command could not be read.",
+	"error.command.no_user":       "The command has no team or user.",
+	"error.token":                 "Invalid verification token.",
+	"error.form.unavailable":      "The report form could not be opened.",
+	"error.submission.unreadable": "The submission could not be read.",
+	"error.submission.no_payload": "The submission has no payload.",
+	"error.submission.payload":    "The submission payload could not be read.",
+	"error.submission.form":       "The submitted form could not be read.",
+	"error.submission.state":      "The dialog state could not be verified.",
+	"error.report.unsaved":        "Your report could not be saved.",
+}
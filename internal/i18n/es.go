@@ -0,0 +1,81 @@
+package i18n
+
+// es is the Spanish bundle, keys it lacks fall back along the chain
+var es = map[string]string{
+	// report form
+	"report.title":               "Informar de un error",
+	"report.submit":              "Enviar",
+	"report.cancel":              "Cancelar",
+	"edit.title":                 "Editar error",
+	"edit.submit":                "Guardar",
+	"report.summary.label":       "Resume el problema",
+	"report.summary.hint":        "Una frase que resuma el problema",
+	"report.product.label":       "Producto",
+	"report.product.inferred":    "Deducido de “%s” con confianza %s, cámbialo si no es correcto",
+	"report.confidence.high":     "alta",
+	"report.confidence.medium":   "media",
+	"report.confidence.low":      "baja",
+	"report.severity.label":      "Gravedad",
+	"report.security.label":      "¿Afecta a la seguridad?",
+	"report.security.hint":       "Los errores de seguridad se registran como confidenciales cuando el gestor lo permite.",
+	"report.security.no":         "No",
+	"report.security.yes":        "Sí - mantenerlo confidencial",
+	"report.customer.label":      "¿Afecta a los clientes?",
+	"report.customer.hint":       "Los errores que afectan a clientes también abren un ticket de soporte.",
+	"report.customer.no":         "No",
+	"report.customer.yes":        "Sí - hay clientes afectados",
+	"report.versions.label":      "Versiones afectadas",
+	"report.versions.hint":       "Todas las versiones de firmware o de la app en las que lo has visto.",
+	"report.tags.label":          "Etiquetas",
+	"report.other_tags.label":    "Otras etiquetas",
+	"report.other_tags.hint":     "Separadas por comas, p. ej. wifi, onboarding",
+	"report.occurred_date.label": "¿Cuándo ocurrió?",
+	"report.occurred_time.label": "¿A qué hora?",
+	"report.occurred_time.hint":  "En tu zona horaria de Slack, déjalo vacío si solo sabes el día.",
+	"report.cc.label":            "Copiar a compañeros",
+	"report.cc.hint":             "Reciben la confirmación, siguen la incidencia de Jira y se enteran de los cambios de estado.",
+	"report.anonymous.label":     "Privacidad",
+	"report.anonymous.option":    "Informar de forma anónima",
+	"report.anonymous.hint":      "Los gestores no mostrarán tu nombre y la confirmación llega por mensaje directo.",
+	"report.details.label":       "¿Algún detalle más?",
+	"report.details.hint":        "Si nos ayudas a reproducir el error, mejor que mejor.",
+	"report.environment.label":   "Entorno",
+	"report.environment.hint":    "Sistema operativo y versión de la app, p. ej. Kano OS 4.1, Make Art 2.3",
+	"report.link.label":          "Enlace",
+	"report.link.hint":           "Una captura, una grabación o la página donde ocurrió",
+
+	// validation
+	"validate.summary.short": "Describe el problema en al menos %d caracteres.",
+	"validate.summary.long":  "El resumen debe tener menos de %d caracteres, añade el resto en los detalles.",
+	"validate.link":          "Introduce un enlace http(s) completo.",
+	"validate.environment":   "Este producto necesita el sistema operativo y la versión de la app para reproducir el error.",
+	"validate.banned":        "Elimina %q, no está permitido en los informes de errores.",
+
+	// confirmations
+	"confirm.submitted":    "Error enviado a %s",
+	"confirm.issue":        "Error enviado - ID: %s, clave: %s, enlace: %s",
+	"confirm.queued":       "%s no está disponible, tu error %s está en cola y se registrará automáticamente.",
+	"confirm.tracker":      "el gestor",
+	"confirm.and":          " y ",
+	"confirm.not_filed":    ":warning: Tu error %s se ha guardado pero no se ha podido registrar en el gestor, los administradores de KanoBUG pueden registrarlo con `kanobugctl replay`.",
+	"confirm.recovered":    "Algo ha fallado, tu informe se ha guardado. Vuelve a ejecutar `/kanobug` para continuar donde lo dejaste.",
+	"confirm.not_attached": "Sin adjuntar: %s",
+	"confirm.queued_files": "Los archivos no se guardan para los errores en cola, añádelos a la incidencia cuando se registre.",
+
+	// failures, by class then message
+	"failure.bad_request":         "Revisa la solicitud y vuelve a intentarlo.",
+	"failure.unauthorized":        "No se ha podido verificar la solicitud, revisa la configuración de la app.",
+	"failure.unavailable":         "Vuelve a intentarlo en unos minutos.",
+	"failure.internal":            "Vuelve a intentarlo y avisa a los administradores de KanoBUG si sigue fallando.",
+	"error.internal":              "Algo ha fallado.",
+	"error.command.unreadable":    "No se ha podido leer el comando.",
+	"error.command.no_user":       "El comando no indica equipo ni usuario.",
+	"error.token":                 "Token de verificación no válido.",
+	"error.form.unavailable":      "No se ha podido abrir el formulario.",
+	"error.submission.unreadable": "No se ha podido leer el envío.",
+	"error.submission.no_payload": "El envío no tiene contenido.",
+	"error.submission.payload":    "No se ha podido leer el contenido del envío.",
+	"error.submission.form":       "No se ha podido leer el formulario enviado.",
+	"error.submission.state":      "No se ha podido verificar el estado del diálogo.",
+	"error.report.unsaved":        "No se ha podido guardar tu informe.",
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Default is the locale every lookup falls back to last
+const Default = "en"
+
+// bundles are the strings of each locale keyed by message, see en.go for
+// every key
+var bundles = map[string]map[string]string{
+	"en": en,
+	"es": es,
+}
+
+// Chain return the locales a lookup for locale tries in order: the locale,
+// e.g. es-ES, its language, DEFAULT_LOCALE and then Default
+func Chain(locale string) (chain []string) {
+	add := func(l string) {
+		if len(l) == 0 {
+			return
+		}
+		for _, c := range chain {
+			if c == l {
+				return
+			}
+		}
+		chain = append(chain, l)
+	}
+	locale = strings.Replace(locale, "_", "-", -1)
+	add(locale)
+	if i := strings.Index(locale, "-"); i > 0 {
+		add(strings.ToLower(locale[:i]))
+	}
+	add(os.Getenv("DEFAULT_LOCALE"))
+	add(Default)
+	return
+}
+
+// T return the message key in locale formatted with args, from the first
+// locale of its chain having it, the key itself when none has
+func T(locale, key string, args ...interface{}) string {
+	for _, l := range Chain(locale) {
+		if message, ok := bundles[l][key]; ok {
+			if len(args) == 0 {
+				return message
+			}
+			return fmt.Sprintf(message, args...)
+		}
+	}
+	if len(args) == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s %v", key, args)
+}
@@ -0,0 +1,199 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Ref refers to a project, issue type, priority, version, component,
+// resolution or issue by whichever of its ID, key or name is set
+type Ref struct {
+	ID   string `json:"id,omitempty"`
+	Key  string `json:"key,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// User is a Jira user, by account ID on Cloud
+type User struct {
+	AccountID    string `json:"accountId,omitempty"`
+	DisplayName  string `json:"displayName,omitempty"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+}
+
+// Status is the workflow status of an issue, its category being new,
+// indeterminate or done
+type Status struct {
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+// Fields are the fields of an issue kanobug sets or reads, left out of a
+// request when empty
+type Fields struct {
+	Project     *Ref     `json:"project,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Description string   `json:"description,omitempty"`
+	IssueType   *Ref     `json:"issuetype,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Priority    *Ref     `json:"priority,omitempty"`
+	Versions    []Ref    `json:"versions,omitempty"`
+	Components  []Ref    `json:"components,omitempty"`
+	Parent      *Ref     `json:"parent,omitempty"`
+	Resolution  *Ref     `json:"resolution,omitempty"`
+	Status      *Status  `json:"status,omitempty"`
+	Assignee    *User    `json:"assignee,omitempty"`
+	Reporter    *User    `json:"reporter,omitempty"`
+	// Custom are the other fields by ID, e.g. customfield_10042, sent along
+	// with the rest and not read back
+	Custom map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encode the fields with Custom alongside
+func (fields Fields) MarshalJSON() ([]byte, error) {
+	type plain Fields
+	body, err := json.Marshal(plain(fields))
+	if err != nil || len(fields.Custom) == 0 {
+		return body, err
+	}
+	all := map[string]interface{}{}
+	if err = json.Unmarshal(body, &all); err != nil {
+		return nil, err
+	}
+	for id, value := range fields.Custom {
+		all[id] = value
+	}
+	return json.Marshal(all)
+}
+
+// StatusName return the name of the status, empty when not fetched
+func (fields Fields) StatusName() string {
+	if fields.Status == nil {
+		return ""
+	}
+	return fields.Status.Name
+}
+
+// Done report whether the status is in the done category
+func (fields Fields) Done() bool {
+	return fields.Status != nil && fields.Status.StatusCategory.Key == "done"
+}
+
+// Issue is a Jira issue, as created, fetched or searched for
+type Issue struct {
+	ID     string `json:"id,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Self   string `json:"self,omitempty"`
+	Fields Fields `json:"fields"`
+}
+
+// Operation adds or removes a value of a multi-value field, e.g. a label
+type Operation struct {
+	Add    string `json:"add,omitempty"`
+	Remove string `json:"remove,omitempty"`
+}
+
+// Update edits an issue, setting Fields and applying the operations of
+// Update by field
+type Update struct {
+	Fields *Fields                `json:"fields,omitempty"`
+	Update map[string][]Operation `json:"update,omitempty"`
+}
+
+// Transition is a transition available to an issue, To the status it moves
+// it into and Fields those of its screen
+type Transition struct {
+	ID     string                     `json:"id"`
+	Name   string                     `json:"name,omitempty"`
+	To     Status                     `json:"to"`
+	Fields map[string]TransitionField `json:"fields,omitempty"`
+}
+
+// TransitionField is a field of the screen of a transition
+type TransitionField struct {
+	Required      bool  `json:"required"`
+	AllowedValues []Ref `json:"allowedValues"`
+}
+
+// DoTransition moves an issue through Transition, setting Fields of its screen
+type DoTransition struct {
+	Transition Ref     `json:"transition"`
+	Fields     *Fields `json:"fields,omitempty"`
+}
+
+// Comment is a wiki markup comment on an issue
+type Comment struct {
+	ID      string `json:"id,omitempty"`
+	Body    string `json:"body"`
+	Author  *User  `json:"author,omitempty"`
+	Created string `json:"created,omitempty"`
+}
+
+// IssueLink links InwardIssue to OutwardIssue, Type by name
+type IssueLink struct {
+	Type         Ref `json:"type"`
+	InwardIssue  Ref `json:"inwardIssue"`
+	OutwardIssue Ref `json:"outwardIssue"`
+}
+
+// Error is the body Jira answers a rejected request with: Messages about the
+// request and, for a create or edit, Errors by the ID of each field it
+// rejected, e.g. summary or customfield_10042
+type Error struct {
+	Status   string            `json:"-"`
+	Code     int               `json:"-"`
+	Messages []string          `json:"errorMessages"`
+	Errors   map[string]string `json:"errors"`
+}
+
+// Error return the status followed by what Jira said was wrong, fields in
+// order
+func (e *Error) Error() string {
+	reasons := append([]string{}, e.Messages...)
+	var ids []string
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		reasons = append(reasons, id+": "+e.Errors[id])
+	}
+	if len(reasons) == 0 {
+		return "unexpected status: " + e.Status
+	}
+	return fmt.Sprintf("unexpected status: %s: %s", e.Status, strings.Join(reasons, "; "))
+}
+
+// Field return what Jira said was wrong with the field of id, empty when it
+// took it
+func (e *Error) Field(id string) string {
+	return e.Errors[id]
+}
+
+// maxError is how much of an error body is read, Jira answering some with
+// an HTML page
+const maxError = 64 << 10
+
+// CheckResponse return nil when resp has one of the statuses want, 200 when
+// none are given, and else an *Error decoded from its body
+func CheckResponse(resp *http.Response, want ...int) error {
+	if len(want) == 0 {
+		want = []int{http.StatusOK}
+	}
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	e := &Error{Status: resp.Status, Code: resp.StatusCode}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxError))
+	// not JSON, e.g. a proxy's error page, leaves the status alone
+	_ = json.Unmarshal(body, e)
+	return e
+}
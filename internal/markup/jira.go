@@ -0,0 +1,78 @@
+package markup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Jira field limits
+const (
+	MaxSummary     = 255
+	MaxDescription = 32767
+)
+
+const truncated = "… (truncated)"
+
+// wikiSpecial are the characters Jira wiki markup gives a meaning to, each is
+// backslash escaped in user text
+const wikiSpecial = `\{}[]*_-+^~?|!#`
+
+// blockStart matches line prefixes Jira renders as headings, quotes or lists
+var blockStart = regexp.MustCompile(`(?m)^(\s*)(h[1-6]|bq)\.`)
+
+// EscapeWiki make user text render literally in a Jira wiki markup field,
+// neutralising {code}/{noformat} blocks, [~mentions], links, images, tables
+// and inline formatting, and dropping control characters that break ADF
+func EscapeWiki(text string) string {
+	text = clean(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(wikiSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return blockStart.ReplaceAllString(b.String(), `$1$2\.`)
+}
+
+// Summary return text as a single line Jira summary within MaxSummary
+func Summary(text string) string {
+	text = strings.Join(strings.Fields(clean(text)), " ")
+	return Truncate(text, MaxSummary)
+}
+
+// Truncate cut text to at most max characters, marking the cut
+func Truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	keep := max - utf8.RuneCountInString(truncated)
+	if keep < 0 {
+		keep = 0
+	}
+	runes := []rune(text)
+	// never leave a dangling escape behind
+	for keep > 0 && runes[keep-1] == '\\' {
+		keep--
+	}
+	return string(runes[:keep]) + truncated
+}
+
+// clean drop invalid UTF-8, control characters other than tabs and newlines
+// and the unicode line separators
+func clean(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+			return -1
+		}
+		return r
+	}, text)
+}
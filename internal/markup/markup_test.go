@@ -0,0 +1,79 @@
+package markup
+
+import "testing"
+
+func TestEscapeWiki(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"a*b*c", `a\*b\*c`},
+		{"{code}rm -rf{code}", `\{code\}rm \-rf\{code\}`},
+		{"[~admin] see [link|http://evil.io]", `\[\~admin\] see \[link\|http://evil.io\]`},
+		{"h1. Title\n  bq. quote", "h1\\. Title\n  bq\\. quote"},
+		{"tab\tbell\x07 line\u2028end", "tab\tbell lineend"},
+	}
+	for _, tt := range tests {
+		if got := EscapeWiki(tt.text); got != tt.want {
+			t.Errorf("EscapeWiki(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	if got := Summary("  Checkout\r\n  fails\tsilently "); got != "Checkout fails silently" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short", 20, "short"},
+		{"abcdefghijklmnopqrstuvwxy", 20, "abcdefg… (truncated)"},
+		{`abcdef\*ghijklmnopqrstu`, 20, "abcdef… (truncated)"},
+		{"abcdefghijklmnopqrstuvwxy", 5, "… (truncated)"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.text, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSlackToWiki(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"emphasis", "*bold* and _it_ and ~gone~", "*bold* and _it_ and -gone-"},
+		{"snake case", "call snake_case_name", `call snake\_case\_name`},
+		{"link", "see <https://example.com/a?b=1&amp;c=2|the page>", "see [the page|https://example.com/a?b=1&c=2]"},
+		{"bare link", "<https://example.com>", "[https://example.com]"},
+		{"mentions", "<@U123|ada> in <#C1|bugs> <!here>", `@ada in \#bugs @here`},
+		{"quote", "&gt; it broke\nagain", "bq. it broke\nagain"},
+		{"inline code", "run `a*b`", `run {{a\*b}}`},
+		{"code block", "```\nx := *y* {noformat}\n```", "{noformat}\nx := *y* { noformat}\n{noformat}"},
+		{"escaped", "1 - 2 {x}", `1 \- 2 \{x\}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SlackToWiki(tt.text); got != tt.want {
+				t.Errorf("SlackToWiki(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	names := map[string]string{"U1": "Ada"}
+	got := ResolveMentions("hi <@U1>, <@U2> and <@U1|Ada L>", func(id string) string { return names[id] })
+	if want := "hi <@U1|Ada>, <@U2> and <@U1|Ada L>"; got != want {
+		t.Errorf("ResolveMentions() = %q, want %q", got, want)
+	}
+}
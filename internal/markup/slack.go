@@ -0,0 +1,138 @@
+package markup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// codeBlock matches ``` fenced blocks, which may span lines
+	codeBlock = regexp.MustCompile("(?s)```\\n?(.*?)```")
+	// inline matches angle bracket references, `code` and *bold*, _italic_
+	// and ~strike~ spans within a line
+	inline = regexp.MustCompile("<([^<>\\n]+)>|`([^`\\n]+)`|\\*([^*\\n]+)\\*|_([^_\\n]+)_|~([^~\\n]+)~")
+	// mention matches bare <@U123> user mentions
+	mention = regexp.MustCompile(`<@([UW][A-Z0-9]+)>`)
+)
+
+// entities are the characters Slack HTML escapes in message text
+var entities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// ResolveMentions label bare <@U123> mentions in text with the name returned
+// by name, as <@U123|name>, leaving them bare when name returns ""
+func ResolveMentions(text string, name func(userID string) string) string {
+	return mention.ReplaceAllStringFunc(text, func(m string) string {
+		id := mention.FindStringSubmatch(m)[1]
+		if n := name(id); len(n) > 0 {
+			return "<@" + id + "|" + n + ">"
+		}
+		return m
+	})
+}
+
+// SlackToWiki convert Slack mrkdwn to Jira wiki markup: bold, italic, strike,
+// inline code, fenced code blocks, quotes, links and labelled user and channel
+// mentions keep their formatting, everything else is escaped as EscapeWiki
+func SlackToWiki(text string) string {
+	text = clean(text)
+	var b strings.Builder
+	last := 0
+	for _, m := range codeBlock.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(lines(text[last:m[0]]))
+		code := entities.Replace(text[m[2]:m[3]])
+		code = strings.Replace(code, "{noformat}", "{ noformat}", -1)
+		b.WriteString("{noformat}\n" + strings.TrimSuffix(code, "\n") + "\n{noformat}")
+		last = m[1]
+	}
+	b.WriteString(lines(text[last:]))
+	return b.String()
+}
+
+// lines convert mrkdwn text outside code blocks, line by line for quotes
+func lines(text string) string {
+	split := strings.Split(text, "\n")
+	for i, line := range split {
+		switch {
+		case strings.HasPrefix(line, "&gt; "):
+			split[i] = "bq. " + spans(strings.TrimPrefix(line, "&gt; "))
+		case strings.HasPrefix(line, "> "):
+			split[i] = "bq. " + spans(strings.TrimPrefix(line, "> "))
+		default:
+			split[i] = spans(line)
+		}
+	}
+	return strings.Join(split, "\n")
+}
+
+// spans convert the inline formatting of a single line
+func spans(line string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range inline.FindAllStringSubmatchIndex(line, -1) {
+		// emphasis needs word boundaries, snake_case_names stay text
+		if m[2] < 0 && m[4] < 0 && (boundary(line, m[0]-1) || boundary(line, m[1])) {
+			continue
+		}
+		b.WriteString(escapeText(line[last:m[0]]))
+		switch {
+		case m[2] >= 0:
+			b.WriteString(reference(line[m[2]:m[3]]))
+		case m[4] >= 0:
+			b.WriteString("{{" + EscapeWiki(entities.Replace(line[m[4]:m[5]])) + "}}")
+		case m[6] >= 0:
+			b.WriteString("*" + escapeText(line[m[6]:m[7]]) + "*")
+		case m[8] >= 0:
+			b.WriteString("_" + escapeText(line[m[8]:m[9]]) + "_")
+		case m[10] >= 0:
+			b.WriteString("-" + escapeText(line[m[10]:m[11]]) + "-")
+		}
+		last = m[1]
+	}
+	b.WriteString(escapeText(line[last:]))
+	return b.String()
+}
+
+// boundary report whether the rune at i makes an emphasis marker part of a
+// word, i.e. it is a letter or digit
+func boundary(line string, i int) bool {
+	if i < 0 || i >= len(line) {
+		return false
+	}
+	r := rune(line[i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// reference convert <url|label>, <@U123|name>, <#C123|name> and <!here>
+func reference(ref string) string {
+	target, label := ref, ""
+	if i := strings.Index(ref, "|"); i >= 0 {
+		target, label = ref[:i], ref[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(target, "@"):
+		if len(label) == 0 {
+			label = target[1:]
+		}
+		return escapeText("@" + label)
+	case strings.HasPrefix(target, "#"):
+		if len(label) == 0 {
+			label = target[1:]
+		}
+		return escapeText("#" + label)
+	case strings.HasPrefix(target, "!"):
+		return escapeText("@" + strings.TrimPrefix(strings.SplitN(target, "^", 2)[0], "!"))
+	}
+	target = entities.Replace(target)
+	if strings.ContainsAny(target, "|[]") {
+		return escapeText(target)
+	}
+	if len(label) == 0 {
+		return "[" + target + "]"
+	}
+	return "[" + escapeText(label) + "|" + target + "]"
+}
+
+func escapeText(text string) string {
+	return EscapeWiki(entities.Replace(text))
+}
@@ -0,0 +1,176 @@
+package mattermost
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// Dialog is a Mattermost interactive dialog
+type Dialog struct {
+	CallbackID  string    `json:"callback_id"`
+	Title       string    `json:"title"`
+	SubmitLabel string    `json:"submit_label"`
+	State       string    `json:"state"`
+	Elements    []Element `json:"elements"`
+}
+
+// Element is a Mattermost dialog element
+type Element struct {
+	DisplayName string   `json:"display_name"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	SubType     string   `json:"subtype,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	HelpText    string   `json:"help_text,omitempty"`
+	Optional    bool     `json:"optional"`
+	Options     []Option `json:"options,omitempty"`
+}
+
+// Option is a Mattermost select option
+type Option struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// Submission is the payload Mattermost POSTs as JSON when a dialog is submitted
+type Submission struct {
+	Type       string          `json:"type"`
+	CallbackID string          `json:"callback_id"`
+	State      string          `json:"state"`
+	UserID     string          `json:"user_id"`
+	ChannelID  string          `json:"channel_id"`
+	TeamID     string          `json:"team_id"`
+	Submission json.RawMessage `json:"submission"`
+	Cancelled  bool            `json:"cancelled"`
+}
+
+// IsCommandToken report whether token is the configured Mattermost slash command token
+func IsCommandToken(token string) bool {
+	expected := os.Getenv("MATTERMOST_COMMAND_TOKEN")
+	return len(expected) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// IsSubmission report whether an interactive request body is a Mattermost
+// dialog submission, which is sent as JSON rather than Slack's form payload
+func IsSubmission(body string) bool {
+	return strings.HasPrefix(strings.TrimSpace(body), "{")
+}
+
+// StateTTL is how long a dialog state is accepted after it is signed, the
+// lifetime of the response URL it carries
+const StateTTL = 30 * time.Minute
+
+// SignState return dialog state carrying responseURL, signed for userID at
+// issued since Mattermost dialog submissions have no verification token of
+// their own
+func SignState(responseURL, userID string, issued time.Time) string {
+	timestamp := strconv.FormatInt(issued.Unix(), 10)
+	return sign(responseURL, userID, timestamp) + "|" + timestamp + "|" + responseURL
+}
+
+// VerifyState return the responseURL of state and when it was issued once its
+// signature is checked for userID, refusing states over StateTTL old
+func VerifyState(state, userID string) (responseURL string, issued time.Time, err error) {
+	parts := strings.SplitN(state, "|", 3)
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[0]), []byte(sign(parts[2], userID, parts[1]))) {
+		return "", issued, errors.New("invalid dialog state")
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", issued, errors.New("invalid dialog state")
+	}
+	issued = time.Unix(unix, 0)
+	if age := time.Since(issued); age > StateTTL || age < -time.Minute {
+		return "", issued, errors.New("expired dialog state")
+	}
+	return parts[2], issued, nil
+}
+
+func sign(responseURL, userID, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("MATTERMOST_COMMAND_TOKEN")))
+	mac.Write([]byte(userID + ":" + timestamp + ":" + responseURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OpenDialog open dialog for triggerID, submitting to MATTERMOST_SUBMIT_URL
+func OpenDialog(triggerID string, dialog Dialog) (err error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"trigger_id": triggerID,
+		"url":        os.Getenv("MATTERMOST_SUBMIT_URL"),
+		"dialog":     dialog,
+	})
+	if err != nil {
+		return
+	}
+	resp, err := do("POST", "/api/v4/actions/dialogs/open", body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	log.Printf("mattermost.OpenDialog - trigger_id: %s, error: %v", triggerID, err)
+	return
+}
+
+// Post create a post with message in channelID
+func Post(channelID, message string) (err error) {
+	body, err := json.Marshal(map[string]string{
+		"channel_id": channelID,
+		"message":    message,
+	})
+	if err != nil {
+		return
+	}
+	resp, err := do("POST", "/api/v4/posts", body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	log.Printf("mattermost.Post - channel_id: %s, error: %v", channelID, err)
+	return
+}
+
+// User return the username and email of a Mattermost user
+func User(userID string) (name, email string, err error) {
+	resp, err := do("GET", "/api/v4/users/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var user struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	return user.Username, user.Email, err
+}
+
+func do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(os.Getenv("MATTERMOST_URL"), "/")+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("MATTERMOST_ACCESS_TOKEN"))
+	return outbound.Do(outbound.Mattermost, req)
+}
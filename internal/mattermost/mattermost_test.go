@@ -0,0 +1,48 @@
+package mattermost
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyState(t *testing.T) {
+	t.Setenv("MATTERMOST_COMMAND_TOKEN", "command-token")
+	const responseURL = "https://mattermost.example.com/hooks/commands/abc"
+	now := time.Now()
+	tampered := func(state string) string {
+		parts := strings.SplitN(state, "|", 3)
+		return parts[0] + "|" + parts[1] + "|https://evil.example.com/"
+	}
+	restamped := func(state string) string {
+		parts := strings.SplitN(state, "|", 3)
+		return parts[0] + "|" + "9999999999" + "|" + parts[2]
+	}
+	tests := []struct {
+		name   string
+		state  string
+		userID string
+		valid  bool
+	}{
+		{"fresh", SignState(responseURL, "u1", now), "u1", true},
+		{"nearly expired", SignState(responseURL, "u1", now.Add(-StateTTL+time.Minute)), "u1", true},
+		{"expired", SignState(responseURL, "u1", now.Add(-StateTTL-time.Minute)), "u1", false},
+		{"from the future", SignState(responseURL, "u1", now.Add(time.Hour)), "u1", false},
+		{"other user", SignState(responseURL, "u1", now), "u2", false},
+		{"other response url", tampered(SignState(responseURL, "u1", now)), "u1", false},
+		{"other timestamp", restamped(SignState(responseURL, "u1", now)), "u1", false},
+		{"unsigned", "|" + responseURL, "u1", false},
+		{"empty", "", "u1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := VerifyState(tt.state, tt.userID)
+			if (err == nil) != tt.valid {
+				t.Fatalf("VerifyState() = %v, want valid: %v", err, tt.valid)
+			}
+			if tt.valid && got != responseURL {
+				t.Errorf("VerifyState() = %s, want %s", got, responseURL)
+			}
+		})
+	}
+}
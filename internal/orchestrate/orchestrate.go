@@ -0,0 +1,116 @@
+package orchestrate
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Steps of the submission state machine, each a task of the KanobugPipeline
+// Lambda, see serverless.yml
+const (
+	StepValidate    = "validate"
+	StepDedupe      = "dedupe-check"
+	StepStore       = "store"
+	StepCreateIssue = "create-issue"
+	StepAttachFiles = "attach-files"
+	StepNotify      = "notify"
+	StepFailed      = "notify-failure"
+)
+
+// ErrNotConfigured is returned when STATE_MACHINE_ARN is not set
+var ErrNotConfigured = errors.New("STATE_MACHINE_ARN is not set")
+
+// File is a file to attach to the filed issues, downloaded from URL with
+// the Slack token, Size is what Slack reports when known
+type File struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// Failure is the error a step failed with, as caught by the state machine
+type Failure struct {
+	Error string `json:"Error"`
+	Cause string `json:"Cause"`
+}
+
+// Message return the error message of the failed step, Lambda task causes
+// are the JSON error the function returned
+func (failure Failure) Message() string {
+	var lambdaError struct {
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal([]byte(failure.Cause), &lambdaError); err == nil && len(lambdaError.ErrorMessage) > 0 {
+		return lambdaError.ErrorMessage
+	}
+	return failure.Cause
+}
+
+// Submission is the state passed from step to step: the submitted bug and
+// who to confirm it to, then what the steps found and filed
+type Submission struct {
+	Bug         store.Bug     `json:"bug"`
+	Platform    string        `json:"platform"`
+	ReporterID  string        `json:"reporter_id"`
+	ResponseURL string        `json:"response_url,omitempty"`
+	Email       string        `json:"email,omitempty"`
+	Files       []File        `json:"files,omitempty"`
+	DuplicateOf string        `json:"duplicate_of,omitempty"`
+	Issues      []store.Issue `json:"issues,omitempty"`
+	Skipped     []string      `json:"skipped,omitempty"`
+	Failure     *Failure      `json:"failure,omitempty"`
+	// Issued is when ResponseURL was issued, confirmations past its expiry
+	// being sent as DMs
+	Issued time.Time `json:"issued"`
+}
+
+// Enabled report whether submissions of product go through the state
+// machine, ORCHESTRATED_PRODUCTS lists them comma separated or "*" for all
+func Enabled(product string) bool {
+	if len(os.Getenv("STATE_MACHINE_ARN")) == 0 {
+		return false
+	}
+	for _, p := range strings.Split(os.Getenv("ORCHESTRATED_PRODUCTS"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "*" || (p == product && len(p) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// Start run the state machine for submission, named after the bug ID so a
+// submission is never orchestrated twice
+func Start(submission Submission) (err error) {
+	defer func() {
+		log.Printf("orchestrate.Start (%s) - error: %v", submission.Bug.ID, err)
+	}()
+	arn := os.Getenv("STATE_MACHINE_ARN")
+	if len(arn) == 0 {
+		return ErrNotConfigured
+	}
+	input, err := json.Marshal(submission)
+	if err != nil {
+		return
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	_, err = sfn.New(sess).StartExecution(&sfn.StartExecutionInput{
+		StateMachineArn: aws.String(arn),
+		Name:            aws.String(submission.Bug.ID),
+		Input:           aws.String(string(input)),
+	})
+	return
+}
@@ -0,0 +1,109 @@
+package outbound
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/anzellai/kanobug/internal/config"
+)
+
+// ErrEgress is returned for a call to a host its dependency may not call,
+// e.g. a spoofed response_url or serviceUrl pointing into the VPC
+var ErrEgress = errors.New("outbound: host is not allowed")
+
+// amazonaws allows the endpoints of every AWS service
+const amazonaws = ".amazonaws.com"
+
+var (
+	egress     map[string][]string
+	egressOnce sync.Once
+)
+
+// Hosts return the hosts dependency may call, read once per container: those
+// of its service or, self-hosted, of its configuration, and any EGRESS_HOSTS
+// adds, e.g. "gitlab=gitlab.example.com;webhook=hooks.example.com". A host
+// with a leading dot allows its subdomains
+func Hosts(dependency string) []string {
+	egressOnce.Do(func() {
+		c := config.Get()
+		egress = map[string][]string{
+			Slack:        {"slack.com", "hooks.slack.com", "files.slack.com"},
+			Jira:         {c.JiraHost},
+			JiraVersions: {c.JiraHost},
+			Mattermost:   {urlHost(os.Getenv("MATTERMOST_URL"))},
+			Teams:        {".botframework.com", ".trafficmanager.net", ".teams.microsoft.com"},
+			TeamsAuth:    {"login.botframework.com", "login.microsoftonline.com"},
+			Discord:      {"discord.com"},
+			Captcha:      {"hcaptcha.com", "api.hcaptcha.com"},
+			Search:       {urlHost(c.SearchEndpoint)},
+			DynamoDB:     {amazonaws},
+			AWS:          {amazonaws},
+			Bedrock:      {amazonaws},
+			"gitlab":     {"gitlab.com", os.Getenv("GITLAB_HOST")},
+			"linear":     {"api.linear.app"},
+			"azure":      {"dev.azure.com"},
+			"trello":     {"api.trello.com"},
+			"asana":      {"app.asana.com"},
+		}
+		if instance := os.Getenv("SERVICENOW_INSTANCE"); len(instance) > 0 {
+			egress["servicenow"] = []string{instance + ".service-now.com"}
+		}
+		if subdomain := os.Getenv("ZENDESK_SUBDOMAIN"); len(subdomain) > 0 {
+			egress["zendesk"] = []string{subdomain + ".zendesk.com"}
+		}
+		for _, hook := range config.List(os.Getenv("WEBHOOK_URLS")) {
+			egress["webhook"] = append(egress["webhook"], urlHost(hook))
+		}
+		for dependency, hosts := range config.Map(os.Getenv("EGRESS_HOSTS")) {
+			egress[dependency] = append(egress[dependency], config.List(hosts)...)
+		}
+	})
+	return egress[dependency]
+}
+
+// urlHost return the host of raw, empty when it is not a URL
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Allowed report whether u may be called for dependency: over https, on one
+// of its Hosts
+func Allowed(dependency string, u *url.URL) bool {
+	if u == nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range Hosts(dependency) {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case len(allowed) == 0 || allowed == ".":
+		case host == allowed, strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed):
+			return true
+		}
+	}
+	return false
+}
+
+// egressTransport refuses the requests of dependency to hosts it may not
+// call, redirects included, before they leave the Lambda
+type egressTransport struct {
+	dependency string
+	next       http.RoundTripper
+}
+
+func (t egressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Allowed(t.dependency, req.URL) {
+		log.Printf("outbound.egressTransport (%s) - refused: %s://%s", t.dependency, req.URL.Scheme, req.URL.Host)
+		return nil, ErrEgress
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,36 @@
+package outbound
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		dependency string
+		raw        string
+		want       bool
+	}{
+		{Slack, "https://hooks.slack.com/actions/T1/1/x", true},
+		{Slack, "http://hooks.slack.com/actions/T1/1/x", false},
+		{Slack, "https://hooks.slack.com.evil.io/x", false},
+		{Teams, "https://smba.trafficmanager.net/emea/", true},
+		{Teams, "https://169.254.169.254/latest/meta-data", false},
+		{TeamsAuth, "https://login.botframework.com/v1/.well-known/keys", true},
+		{TeamsAuth, "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token", true},
+		{TeamsAuth, "https://smba.trafficmanager.net/emea/", false},
+		{Captcha, "https://hcaptcha.com/siteverify", true},
+		{Captcha, "https://evil.io/siteverify", false},
+		{Discord, "https://discord.com/api/v10/webhooks/1/x", true},
+		{"unknown", "https://discord.com/", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Allowed(tt.dependency, u); got != tt.want {
+			t.Errorf("Allowed(%s, %s) = %v, want %v", tt.dependency, tt.raw, got, tt.want)
+		}
+	}
+}
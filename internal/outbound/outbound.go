@@ -0,0 +1,166 @@
+package outbound
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/fault"
+)
+
+// Dependencies with a timeout of their own, trackers use their name
+const (
+	Slack      = "slack"
+	Mattermost = "mattermost"
+	Teams      = "teams"
+	// TeamsAuth is the Bot Framework's sign in: its OpenID keys verifying
+	// requests and the token endpoint of replies
+	TeamsAuth = "teams_auth"
+	Discord   = "discord"
+	// Captcha is hCaptcha's siteverify API of the web intake form
+	Captcha = "captcha"
+	Jira    = "jira"
+	// JiraVersions is the version list of the report form, which has to
+	// open before Slack gives up on the trigger
+	JiraVersions = "jira_versions"
+	DynamoDB     = "dynamodb"
+	AWS          = "aws"
+	// Search is the OpenSearch domain of SEARCH_ENDPOINT
+	Search = "search"
+	// Bedrock is the model answering `/kanobug ask`, which writes for
+	// longer than most services take to reply
+	Bedrock = "bedrock"
+)
+
+// defaultTimeouts bound a call to a dependency unless TIMEOUT_<DEPENDENCY>,
+// e.g. TIMEOUT_JIRA=20s, says otherwise
+var defaultTimeouts = map[string]time.Duration{
+	Slack:        5 * time.Second,
+	Jira:         10 * time.Second,
+	JiraVersions: 2 * time.Second,
+	DynamoDB:     5 * time.Second,
+	Bedrock:      60 * time.Second,
+}
+
+// defaultTimeout bounds calls to every other dependency
+const defaultTimeout = 10 * time.Second
+
+// invocation is the context of the Lambda invocation being served, cancelled
+// at its deadline
+var invocation = context.Background()
+
+// Use make ctx the context every outbound call is made with. A Lambda serves
+// one invocation at a time, so handlers call it with the context of each
+// invocation before calling out, like store.UseTeam
+func Use(ctx context.Context) {
+	invocation = ctx
+	slackToken = ""
+}
+
+// slackToken is the bot token of the Slack install serving the invocation,
+// empty for SLACK_ACCESS_TOKEN
+var slackToken string
+
+// UseToken make token authorize the Slack calls of the invocation in place of
+// SLACK_ACCESS_TOKEN, see store.UseWorkspace. Use resets it
+func UseToken(token string) {
+	slackToken = token
+}
+
+// Timeout return how long a call to dependency may take
+func Timeout(dependency string) time.Duration {
+	name := "TIMEOUT_" + strings.ToUpper(dependency)
+	if value := os.Getenv(name); len(value) > 0 {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout > 0 {
+			return timeout
+		}
+		log.Printf("outbound.Timeout (%s) - invalid %s: %q, error: %v", dependency, name, value, err)
+	}
+	if timeout, ok := defaultTimeouts[dependency]; ok {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// Context return the invocation context bounded by the timeout of
+// dependency, cancel releases it
+func Context(dependency string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(invocation, Timeout(dependency))
+}
+
+// Do send req to dependency within its timeout and the invocation, the
+// timeout is released once the response body is closed. Authorized Slack
+// calls carry the token of UseToken when set
+func Do(dependency string, req *http.Request) (*http.Response, error) {
+	if dependency == Slack && len(slackToken) > 0 && strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		req.Header.Set("Authorization", "Bearer "+slackToken)
+	}
+	ctx, cancel := Context(dependency)
+	resp, err := client(dependency).Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = body{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// client return the HTTP client of dependency, calling its Hosts only and
+// failing by design when FAULTS injects faults into its calls, see
+// internal/fault
+func client(dependency string) *http.Client {
+	return &http.Client{Transport: egressTransport{
+		dependency: dependency,
+		next:       fault.Transport(dependency, http.DefaultTransport),
+	}}
+}
+
+// body releases the timeout of a response once closed
+type body struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b body) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// sessions are the AWS sessions made so far by dependency, kept for the
+// lifetime of the Lambda container
+var (
+	sessions   = map[string]*session.Session{}
+	sessionsMu sync.Mutex
+)
+
+// Session return an AWS session whose requests are made within the timeout
+// of dependency and the invocation, made once per container
+func Session(dependency string) (*session.Session, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	if sess, ok := sessions[dependency]; ok {
+		return sess, nil
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(config.Get().Region), HTTPClient: client(dependency)})
+	if err != nil {
+		return nil, err
+	}
+	sess.Handlers.Send.PushFront(func(r *request.Request) {
+		ctx, cancel := Context(dependency)
+		r.SetContext(ctx)
+		r.Handlers.Complete.PushBack(func(*request.Request) { cancel() })
+	})
+	sessions[dependency] = sess
+	return sess, nil
+}
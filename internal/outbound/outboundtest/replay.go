@@ -0,0 +1,128 @@
+// Package outboundtest replays recorded calls to the services kanobug
+// depends on, for contract tests of what it sends them and how it reads
+// their replies
+package outboundtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Interaction is a recorded call: the request made, by method and URL, the
+// JSON body it is expected to carry when Request is set, and the reply
+type Interaction struct {
+	Method string `json:"method"`
+	// URL is matched on its host, path and the query parameters it has
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Cassette replays its interactions in order, failing the test on a
+// request out of order, to another URL or with another body
+type Cassette struct {
+	t            *testing.T
+	mu           sync.Mutex
+	interactions []Interaction
+	played       int
+}
+
+// Replay serve the interactions recorded in the cassette at path in place
+// of http.DefaultTransport for the rest of the test, failing it unless all
+// were played
+func Replay(t *testing.T, path string) *Cassette {
+	t.Helper()
+	recorded, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("outboundtest.Replay (%s) - error: %v", path, err)
+	}
+	c := &Cassette{t: t}
+	if err = json.Unmarshal(recorded, &c.interactions); err != nil {
+		t.Fatalf("outboundtest.Replay (%s) - error: %v", path, err)
+	}
+	transport := http.DefaultTransport
+	http.DefaultTransport = c
+	t.Cleanup(func() {
+		http.DefaultTransport = transport
+		if c.played < len(c.interactions) {
+			t.Errorf("%s: %d of %d interactions played, next: %s %s", path, c.played, len(c.interactions),
+				c.interactions[c.played].Method, c.interactions[c.played].URL)
+		}
+	})
+	return c
+}
+
+// RoundTrip answer req with the reply of the next interaction
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.played == len(c.interactions) {
+		c.t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+		return reply(http.StatusNotImplemented, nil, req), nil
+	}
+	next := c.interactions[c.played]
+	c.played++
+	if !matches(next, req) {
+		c.t.Errorf("request %d: %s %s, want %s %s", c.played, req.Method, req.URL, next.Method, next.URL)
+	}
+	if len(next.Request) > 0 {
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+		}
+		if !sameJSON(body, next.Request) {
+			c.t.Errorf("request %d to %s:\n%s\nwant:\n%s", c.played, req.URL.Path, body, next.Request)
+		}
+	}
+	status := next.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return reply(status, next.Response, req), nil
+}
+
+// matches report whether req is the request of interaction
+func matches(interaction Interaction, req *http.Request) bool {
+	want, err := url.Parse(interaction.URL)
+	if err != nil || req.Method != interaction.Method || req.URL.Host != want.Host || req.URL.Path != want.Path {
+		return false
+	}
+	query := req.URL.Query()
+	for key, values := range want.Query() {
+		if !reflect.DeepEqual(query[key], values) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameJSON report whether a and b encode the same JSON value
+func sameJSON(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// reply return the response of status with the JSON body to req
+func reply(status int, body []byte, req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
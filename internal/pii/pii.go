@@ -0,0 +1,103 @@
+package pii
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/comprehend"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// maxComprehend is the most text, in bytes, DetectPiiEntities accepts
+const maxComprehend = 100000
+
+var (
+	email = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phone = regexp.MustCompile(`\+?\(?\d[\d ().-]{7,}\d`)
+	// date keeps ISO dates and timestamps, which look like phone numbers
+	date = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	// defaultSerial is used unless PII_SERIAL_PATTERN is set
+	defaultSerial = `(?i)\b(?:serial(?: number)?|s/n|sn)[:#]?\s*[A-Z0-9][A-Z0-9-]{5,}`
+)
+
+// Enabled report whether product is listed in the comma separated
+// PII_REDACT_PRODUCTS, "*" enabling every product
+func Enabled(product string) bool {
+	for _, p := range strings.Split(os.Getenv("PII_REDACT_PRODUCTS"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "*" || (p == product && len(p) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact mask emails, phone numbers and serial numbers in text and, with
+// PII_COMPREHEND=true, any other PII Amazon Comprehend detects
+func Redact(text string) string {
+	text = email.ReplaceAllString(text, "[email]")
+	text = phone.ReplaceAllStringFunc(text, func(m string) string {
+		digits := 0
+		for _, r := range m {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits < 9 || digits > 15 || date.MatchString(m) {
+			return m
+		}
+		return "[phone]"
+	})
+	pattern := os.Getenv("PII_SERIAL_PATTERN")
+	if len(pattern) == 0 {
+		pattern = defaultSerial
+	}
+	if serial, err := regexp.Compile(pattern); err == nil {
+		text = serial.ReplaceAllString(text, "[serial]")
+	} else {
+		log.Printf("pii.Redact - serial pattern error: %v", err)
+	}
+	if os.Getenv("PII_COMPREHEND") == "true" && len(text) > 0 && len(text) <= maxComprehend {
+		text = comprehendRedact(text)
+	}
+	return text
+}
+
+// comprehendRedact mask the PII entities Comprehend detects in text, leaving
+// text as is when detection fails
+func comprehendRedact(text string) string {
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return text
+	}
+	out, err := comprehend.New(sess).DetectPiiEntities(&comprehend.DetectPiiEntitiesInput{
+		Text:         aws.String(text),
+		LanguageCode: aws.String(comprehend.LanguageCodeEn),
+	})
+	if err != nil {
+		log.Printf("pii.comprehendRedact - error: %v", err)
+		return text
+	}
+	// offsets count characters, replace from the end to keep them valid
+	entities := out.Entities
+	sort.Slice(entities, func(i, j int) bool {
+		return aws.Int64Value(entities[i].BeginOffset) > aws.Int64Value(entities[j].BeginOffset)
+	})
+	runes := []rune(text)
+	end := int64(len(runes))
+	for _, e := range entities {
+		begin, finish := aws.Int64Value(e.BeginOffset), aws.Int64Value(e.EndOffset)
+		if begin < 0 || finish > end || begin >= finish {
+			continue
+		}
+		mask := []rune("[" + strings.ToLower(aws.StringValue(e.Type)) + "]")
+		runes = append(runes[:begin], append(mask, runes[finish:]...)...)
+		end = begin
+	}
+	return string(runes)
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/orchestrate"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+// MaxAttachment is the largest file uploaded to an issue unless
+// ATTACHMENT_MAX_BYTES says otherwise, Jira Cloud's default attachment size
+// limit
+const MaxAttachment = 10 << 20
+
+// Attach upload the Slack files passing Screen to every issue whose tracker
+// accepts files and return the names of those that were skipped, with why
+func Attach(issues []tracker.Issue, files []orchestrate.File) (skipped []string) {
+	for _, file := range files {
+		if file.Size > maxAttachment() {
+			skipped = append(skipped, file.Name+" (too large)")
+			continue
+		}
+		content, err := download(file.URL)
+		if err != nil {
+			log.Printf("pipeline.Attach - file: %s, error: %v", file.Name, err)
+			skipped = append(skipped, file.Name+" (download failed)")
+			continue
+		}
+		if reason := Screen(file.Name, content); len(reason) > 0 {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", file.Name, reason))
+			continue
+		}
+		for _, issue := range issues {
+			attacher, ok := tracker.ByName(issue.Tracker).(tracker.Attacher)
+			if !ok {
+				continue
+			}
+			if err = attacher.Attach(issue, file.Name, bytes.NewReader(content)); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s (%s upload failed)", file.Name, issue.Tracker))
+			}
+		}
+	}
+	return
+}
+
+// download fetch a private Slack file with the bot token, reading at most one
+// byte over the size cap
+func download(fileURL string) (content []byte, err error) {
+	req, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+config.Get().SlackToken)
+	resp, err := outbound.Do(outbound.Slack, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, io.LimitReader(resp.Body, maxAttachment()+1))
+	return buf.Bytes(), err
+}
@@ -0,0 +1,291 @@
+package pipeline
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/classify"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/eventbus"
+	"github.com/anzellai/kanobug/internal/flags"
+	"github.com/anzellai/kanobug/internal/i18n"
+	"github.com/anzellai/kanobug/internal/pii"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+	"github.com/anzellai/kanobug/internal/translate"
+)
+
+// Store assign the bug an ID, summary signature and suggested severity,
+// redact PII from its details for products that opt in, mark it queued
+// while a tracker it is routed to is unavailable, persist it, audit its
+// creation by the reporter and publish BugSubmitted, unless a smoke test bug
+func Store(bug *store.Bug) (err error) {
+	if len(bug.ID) == 0 {
+		bug.ID = store.NewID()
+	}
+	bug.SummaryHashes = Signature(bug.Summary)
+	if suggestion, ok := classify.Suggest(bug.Summary, bug.Details); ok {
+		bug.SuggestedSeverity = suggestion.Severity
+		bug.SuggestedBecause = suggestion.Classifier + ": " + suggestion.Reason
+	}
+	bug.Queued = len(Unavailable(bug.Product)) > 0
+	if pii.Enabled(bug.Product) {
+		bug.Details = pii.Redact(bug.Details)
+	}
+	if len(bug.Status) == 0 {
+		bug.Status = store.StatusNew
+		bug.History = []store.Change{{Status: store.StatusNew, At: bug.CreatedAt}}
+	}
+	if err = store.PutBug(*bug); err != nil {
+		return
+	}
+	if bug.Queued {
+		_ = store.Enqueue(*bug)
+	}
+	emf.Count(emf.Submissions, emf.Dimensions{"Source": bug.Source})
+	_ = store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   bug.UserID,
+		Action:  store.AuditCreate,
+		Detail:  bug.Source,
+		After:   store.Audited(*bug),
+	})
+	if !bug.Smoke {
+		_ = eventbus.Publish(eventbus.BugSubmitted, eventbus.BugDetail{Bug: *bug})
+	}
+	return
+}
+
+// dedupeWindow is how recently a bug must have been submitted to be taken
+// for the same report submitted again
+const dedupeWindow = 10 * time.Minute
+
+// Duplicate return the bug the same reporter submitted for the same product
+// with the same summary within dedupeWindow before bug, e.g. a form sent
+// twice, and whether there is one
+func Duplicate(bug store.Bug) (original store.Bug, found bool, err error) {
+	recent, err := store.AllBugs(store.Filter{
+		UserID:  bug.UserID,
+		Product: bug.Product,
+		Since:   bug.CreatedAt.Add(-dedupeWindow),
+		Until:   bug.CreatedAt,
+	})
+	if err != nil {
+		return
+	}
+	summary := strings.ToLower(strings.TrimSpace(bug.Summary))
+	for _, candidate := range recent {
+		if candidate.ID != bug.ID && strings.ToLower(strings.TrimSpace(candidate.Summary)) == summary {
+			return candidate, true, nil
+		}
+	}
+	return
+}
+
+// Unavailable return the names of the trackers routed for product whose
+// breaker is open
+func Unavailable(product string) (names []string) {
+	for _, t := range tracker.ForProduct(product) {
+		if !tracker.Available(t.Name()) {
+			names = append(names, t.Name())
+		}
+	}
+	return
+}
+
+// QueuedText return the confirmation of a bug queued until its trackers
+// are available again, in the reporter's locale
+func QueuedText(bug store.Bug) string {
+	names := Unavailable(bug.Product)
+	if len(names) == 0 {
+		names = []string{i18n.T(bug.Locale, "confirm.tracker")}
+	}
+	return i18n.T(bug.Locale, "confirm.queued", strings.Title(strings.Join(names, i18n.T(bug.Locale, "confirm.and"))), bug.ID)
+}
+
+// File file the stored bug to the trackers routed for its product, opening a
+// linked Zendesk ticket for customer impacting bugs, record the created issues
+// on the bug and return them. Nothing is filed while a routed tracker is
+// unavailable, the bug stays new until KanobugRetry files it. Smoke test bugs
+// are filed to Jira's JIRA_SMOKE_PROJECT only, publishing nothing
+func File(bug store.Bug, reporterEmail string) (issues []tracker.Issue) {
+	if unavailable := Unavailable(bug.Product); len(unavailable) > 0 {
+		log.Printf("pipeline.File - bug: %s, queued, unavailable: %v", bug.ID, unavailable)
+		return
+	}
+	if translate.Enabled(bug.Product) {
+		if translation, ok := translate.Bug(bug); ok {
+			bug.Translation = &translation
+		}
+	}
+	record := func(name string, issue tracker.Issue, err error) {
+		log.Printf("pipeline.File - tracker: %s, issue: %+v, error: %v", name, issue, err)
+		if bug.Smoke {
+			// a smoke test failing in its sandbox project says nothing of the
+			// tracker's health
+			if err == nil {
+				issues = append(issues, issue)
+			}
+			return
+		}
+		tracker.Record(name, err)
+		if err != nil {
+			_ = eventbus.Publish(eventbus.SyncFailed, eventbus.SyncFailedDetail{
+				Bug:     bug,
+				Tracker: name,
+				Error:   err.Error(),
+			})
+			return
+		}
+		_ = eventbus.Publish(eventbus.IssueCreated, eventbus.IssueDetail{
+			Bug:      bug,
+			Tracker:  issue.Tracker,
+			IssueID:  issue.ID,
+			IssueKey: issue.Key,
+			IssueURL: issue.URL,
+		})
+		issues = append(issues, issue)
+	}
+	trackers := tracker.ForProduct(bug.Product)
+	if bug.Smoke {
+		trackers = []tracker.Tracker{tracker.NewJira()}
+	}
+	for _, t := range trackers {
+		if !flags.Enabled(flags.Tracker(t.Name()), bug.TeamID, bug.Product) {
+			log.Printf("pipeline.File - bug: %s, tracker: %s, turned off", bug.ID, t.Name())
+			continue
+		}
+		issue, err := t.CreateIssue(bug)
+		record(t.Name(), issue, err)
+	}
+	if zendesk := tracker.NewZendesk(); !bug.Smoke && bug.CustomerImpacting && zendesk.Enabled() && flags.Enabled(flags.Tracker(zendesk.Name()), bug.TeamID, bug.Product) {
+		ticket, err := zendesk.CreateTicket(bug, issues, reporterEmail)
+		record(zendesk.Name(), ticket, err)
+	}
+	if len(issues) > 0 && len(bug.ID) > 0 {
+		_ = store.SetIssues(bug, issues)
+		if !bug.Smoke && flags.Enabled(flags.Similar, bug.TeamID, bug.Product) {
+			Cluster(bug, issues)
+		}
+	}
+	return
+}
+
+// conflictRetries is how often SetStatus re-reads a bug changed under it
+const conflictRetries = 3
+
+// SetStatus update the status and resolution of bug on behalf of actor,
+// publishing StatusChanged and, on resolution, IssueResolved for each of its
+// issues. A bug changed since it was read is re-read and the status applied
+// to it, store.ErrConflict is returned once conflictRetries are exhausted
+func SetStatus(bug store.Bug, status, resolution, actor string) (updated store.Bug, err error) {
+	for attempt := 0; ; attempt++ {
+		updated, err = store.UpdateStatus(bug, status, resolution, actor)
+		if err != store.ErrConflict || attempt == conflictRetries {
+			break
+		}
+		log.Printf("pipeline.SetStatus (%s) - version %d conflict, retrying", bug.ID, bug.Version)
+		if bug, err = store.GetBug(bug.ID); err != nil {
+			return
+		}
+	}
+	if err != nil {
+		return
+	}
+	action := store.AuditStatus
+	if status == store.StatusClosed {
+		action = store.AuditClose
+	}
+	_ = store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   actor,
+		Action:  action,
+		Before:  store.Audited(bug),
+		After:   store.Audited(updated),
+	})
+	if status != bug.Status {
+		_ = eventbus.Publish(eventbus.StatusChanged, eventbus.StatusDetail{Bug: updated, PreviousStatus: bug.Status})
+	}
+	if status == store.StatusResolved && bug.Status != store.StatusResolved {
+		for _, issue := range updated.Issues {
+			_ = eventbus.Publish(eventbus.IssueResolved, eventbus.IssueDetail{
+				Bug:        updated,
+				Tracker:    issue.Tracker,
+				IssueID:    issue.ID,
+				IssueKey:   issue.Key,
+				IssueURL:   issue.URL,
+				Resolution: resolution,
+			})
+		}
+	}
+	return
+}
+
+// Verify record actor confirming the resolution of bug fixed it, on the
+// bug, in the audit trail and on its Jira issues as the verified label and
+// a comment naming name
+func Verify(bug store.Bug, actor, name string) (updated store.Bug, err error) {
+	if updated, err = store.Verify(bug, actor); err != nil {
+		return
+	}
+	_ = store.Audit(store.AuditEntry{
+		Subject: bug.ID,
+		Actor:   actor,
+		Action:  store.AuditVerify,
+		Before:  store.Audited(bug),
+		After:   store.Audited(updated),
+	})
+	jira := tracker.NewJira()
+	for _, issue := range updated.Issues {
+		if issue.Tracker == jira.Name() && len(issue.Key) > 0 {
+			verifyErr := jira.Verify(issue, name)
+			log.Printf("pipeline.Verify (%s) - issue: %s, error: %v", bug.ID, issue.Key, verifyErr)
+		}
+	}
+	return
+}
+
+// Recur reopen the resolved or closed bug reported as happening again by
+// actor: the bug is filed again with another recurrence counted, its Jira
+// issue reopened or, when the workflow cannot reopen it, a regression issue
+// relating to it filed, and BugRecurred published for the triage channel,
+// naming actor unless the bug is anonymous
+func Recur(bug store.Bug, actor string) (updated store.Bug, detail eventbus.RecurredDetail, err error) {
+	if updated, err = SetStatus(bug, store.StatusFiled, "", actor); err != nil {
+		return
+	}
+	if updated, err = store.Recur(updated); err != nil {
+		return
+	}
+	detail = eventbus.RecurredDetail{Bug: updated, Actor: actor}
+	if bug.Anonymous {
+		// the notice is posted to every thread of the bug
+		detail.Actor = ""
+	}
+	jira := tracker.NewJira()
+	for _, issue := range updated.Issues {
+		if issue.Tracker != jira.Name() || len(issue.Key) == 0 {
+			continue
+		}
+		issue := issue
+		detail.Issue = &issue
+		reopenErr := jira.Reopen(issue)
+		log.Printf("pipeline.Recur (%s) - reopen: %s, error: %v", bug.ID, issue.Key, reopenErr)
+		if reopenErr == nil {
+			_ = jira.Comment(issue, "Reopened by kanobug, the bug was reported as happening again.")
+			break
+		}
+		regression, regressionErr := jira.Regression(issue, updated)
+		log.Printf("pipeline.Recur (%s) - regression: %s, error: %v", bug.ID, regression.Key, regressionErr)
+		if regressionErr == nil && len(regression.Key) > 0 {
+			detail.Issue, detail.Regression = &regression, true
+			_ = store.SetIssues(updated, append(updated.Issues, regression))
+			updated.Issues = append(updated.Issues, regression)
+		}
+		break
+	}
+	detail.Bug = updated
+	_ = eventbus.Publish(eventbus.BugRecurred, detail)
+	return
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Scanners of attachments, ATTACHMENT_SCANNER
+const (
+	// ScannerClamAV runs clamscan of a ClamAV Lambda layer
+	ScannerClamAV = "clamav"
+	// ScannerS3 uploads to SCAN_BUCKET and waits for GuardDuty Malware
+	// Protection for S3 to tag the object
+	ScannerS3 = "s3"
+)
+
+// defaultTypes are the content types attached unless ATTACHMENT_TYPES says
+// otherwise, a trailing /* allowing every subtype
+const defaultTypes = "image/*,video/*,text/plain,application/pdf,application/json,application/zip,application/x-gzip"
+
+// guardDutyTag is the object tag GuardDuty sets to the scan result
+const guardDutyTag = "GuardDutyMalwareScanStatus"
+
+// maxAttachment return the largest file attached, ATTACHMENT_MAX_BYTES or
+// MaxAttachment
+func maxAttachment() int64 {
+	if max, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && max > 0 {
+		return max
+	}
+	return MaxAttachment
+}
+
+// allowedType report whether the content type sniffed from a file is in
+// ATTACHMENT_TYPES
+func allowedType(contentType string) bool {
+	types := os.Getenv("ATTACHMENT_TYPES")
+	if len(types) == 0 {
+		types = defaultTypes
+	}
+	contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	for _, allowed := range strings.Split(types, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == contentType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Screen return why the file name with content must not be attached, empty
+// when it may: over the size cap, of a content type not allowed or, with
+// ATTACHMENT_SCANNER set, found infected or not scanned
+func Screen(name string, content []byte) (reason string) {
+	defer func() {
+		if len(reason) > 0 {
+			log.Printf("pipeline.Screen (%s) - rejected: %s", name, reason)
+			emf.Count(emf.AttachmentsRejected, emf.Dimensions{"Scanner": os.Getenv("ATTACHMENT_SCANNER")})
+		}
+	}()
+	if int64(len(content)) > maxAttachment() {
+		return "too large"
+	}
+	if contentType := http.DetectContentType(content); !allowedType(contentType) {
+		return fmt.Sprintf("%s files are not allowed", strings.SplitN(contentType, ";", 2)[0])
+	}
+	var infected string
+	var err error
+	switch scanner := os.Getenv("ATTACHMENT_SCANNER"); scanner {
+	case "":
+		return
+	case ScannerClamAV:
+		infected, err = clamscan(content)
+	case ScannerS3:
+		infected, err = s3Scan(name, content)
+	default:
+		err = fmt.Errorf("unknown scanner %s", scanner)
+	}
+	switch {
+	case err != nil:
+		log.Printf("pipeline.Screen (%s) - error: %v", name, err)
+		return "could not be scanned"
+	case len(infected) > 0:
+		return "malware found: " + infected
+	}
+	return
+}
+
+// clamscan scan content with the clamscan binary of the ClamAV layer,
+// CLAMSCAN_PATH (/opt/bin/clamscan) with the signatures in CLAMAV_DB
+// (/opt/share/clamav), returning the signature found
+func clamscan(content []byte) (infected string, err error) {
+	path, db := os.Getenv("CLAMSCAN_PATH"), os.Getenv("CLAMAV_DB")
+	if len(path) == 0 {
+		path = "/opt/bin/clamscan"
+	}
+	if len(db) == 0 {
+		db = "/opt/share/clamav"
+	}
+	cmd := exec.Command(path, "--no-summary", "--database="+db, "-")
+	cmd.Stdin = bytes.NewReader(content)
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 {
+		// stdin: Eicar-Test-Signature FOUND
+		found := strings.TrimSpace(strings.TrimPrefix(string(out), "stdin:"))
+		return strings.TrimSpace(strings.TrimSuffix(found, "FOUND")), nil
+	}
+	return "", err
+}
+
+// s3Scan upload content to SCAN_BUCKET and wait up to SCAN_TIMEOUT (20s)
+// for GuardDuty to tag the scan result, removing the object after
+func s3Scan(name string, content []byte) (infected string, err error) {
+	bucket := os.Getenv("SCAN_BUCKET")
+	if len(bucket) == 0 {
+		return "", fmt.Errorf("SCAN_BUCKET is not set")
+	}
+	timeout, parseErr := time.ParseDuration(os.Getenv("SCAN_TIMEOUT"))
+	if parseErr != nil || timeout <= 0 {
+		timeout = 20 * time.Second
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	srv := s3.New(sess)
+	key := "scan/" + store.NewID() + "/" + name
+	if _, err = srv.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	}); err != nil {
+		return
+	}
+	defer func() {
+		_, _ = srv.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	}()
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Second) {
+		tags, tagErr := srv.GetObjectTagging(&s3.GetObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if tagErr != nil {
+			return "", tagErr
+		}
+		for _, tag := range tags.TagSet {
+			if aws.StringValue(tag.Key) != guardDutyTag {
+				continue
+			}
+			switch status := aws.StringValue(tag.Value); status {
+			case "NO_THREATS_FOUND":
+				return "", nil
+			case "THREATS_FOUND":
+				return "threat reported by GuardDuty", nil
+			default:
+				return "", fmt.Errorf("scan status %s", status)
+			}
+		}
+	}
+	return "", fmt.Errorf("no scan result within %s", timeout)
+}
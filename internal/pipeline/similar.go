@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/markup"
+	"github.com/anzellai/kanobug/internal/store"
+	"github.com/anzellai/kanobug/internal/tracker"
+)
+
+// signatureSize is how many min hashes a summary signature keeps, the share
+// of equal ones estimating how much two summaries overlap
+const signatureSize = 32
+
+// similarWindow is how far back bugs of the product are matched unless
+// SIMILAR_WINDOW says otherwise, as long as bugs are kept
+const similarWindow = 7 * 24 * time.Hour
+
+// similarThreshold is the estimated overlap above which a bug is taken for a
+// probable duplicate unless SIMILAR_THRESHOLD says otherwise
+const similarThreshold = 0.6
+
+// stopwords are left out of summaries before they are shingled
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "are": true, "in": true, "on": true, "of": true,
+	"to": true, "and": true, "or": true, "it": true, "when": true, "with": true, "my": true, "not": true,
+}
+
+// normalize lower case summary and keep its words, without punctuation or
+// stopwords
+func normalize(summary string) string {
+	words := strings.FieldsFunc(strings.ToLower(summary), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, word := range words {
+		if !stopwords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// shingles return the character trigrams of the normalized summary
+func shingles(summary string) map[string]bool {
+	runes := []rune(normalize(summary))
+	set := map[string]bool{}
+	if len(runes) < 3 {
+		if len(runes) > 0 {
+			set[string(runes)] = true
+		}
+		return set
+	}
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// Signature return the min hash signature of summary, stored on the bug as
+// summary_hashes, empty when nothing is left of it once normalized
+func Signature(summary string) []int64 {
+	set := shingles(summary)
+	if len(set) == 0 {
+		return nil
+	}
+	signature := make([]int64, signatureSize)
+	for i := range signature {
+		min := uint32(0xffffffff)
+		for shingle := range set {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte{byte(i)})
+			_, _ = h.Write([]byte(shingle))
+			if v := h.Sum32(); v < min {
+				min = v
+			}
+		}
+		signature[i] = int64(min)
+	}
+	return signature
+}
+
+// overlap estimate how much the summaries of two signatures overlap, 0 to 1
+func overlap(a, b []int64) float64 {
+	if len(a) != signatureSize || len(b) != signatureSize {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / signatureSize
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && f > 0 && f <= 1 {
+		return f
+	}
+	return fallback
+}
+
+// Similar return the open bug of the same product reported within
+// SIMILAR_WINDOW before bug whose summary overlaps it the most, as long as
+// that is above SIMILAR_THRESHOLD, and whether there is one
+func Similar(bug store.Bug) (original store.Bug, found bool, err error) {
+	signature := bug.SummaryHashes
+	if len(signature) == 0 {
+		signature = Signature(bug.Summary)
+	}
+	if len(signature) == 0 {
+		return
+	}
+	recent, err := store.AllBugs(store.Filter{
+		Product: bug.Product,
+		Since:   bug.CreatedAt.Add(-envDuration("SIMILAR_WINDOW", similarWindow)),
+		Until:   bug.CreatedAt,
+	})
+	if err != nil {
+		return
+	}
+	best := envFloat("SIMILAR_THRESHOLD", similarThreshold)
+	for _, candidate := range recent {
+		if candidate.ID == bug.ID || candidate.Status == store.StatusClosed || len(candidate.Issues) == 0 {
+			continue
+		}
+		hashes := candidate.SummaryHashes
+		if len(hashes) == 0 {
+			hashes = Signature(candidate.Summary)
+		}
+		if score := overlap(signature, hashes); score >= best {
+			original, found, best = candidate, true, score
+		}
+	}
+	return
+}
+
+// Cluster link a filed bug to the probable duplicate Similar finds for it
+// and comment on the Jira issues of both
+func Cluster(bug store.Bug, issues []tracker.Issue) {
+	original, found, err := Similar(bug)
+	if err != nil || !found {
+		if err != nil {
+			log.Printf("pipeline.Cluster (%s) - error: %v", bug.ID, err)
+		}
+		return
+	}
+	emf.Count(emf.SimilarHits, emf.Dimensions{"Product": bug.Product})
+	err = store.LinkDuplicate(bug, original)
+	log.Printf("pipeline.Cluster (%s) - possible duplicate of: %s, error: %v", bug.ID, original.ID, err)
+	jira := tracker.NewJira()
+	issueOf := func(issues []tracker.Issue) tracker.Issue {
+		for _, issue := range issues {
+			if issue.Tracker == jira.Name() {
+				return issue
+			}
+		}
+		return tracker.Issue{}
+	}
+	filed, earlier := issueOf(issues), issueOf(original.Issues)
+	if len(filed.Key) == 0 || len(earlier.Key) == 0 {
+		return
+	}
+	err = jira.Comment(filed, fmt.Sprintf("Possible duplicate of %s: %s", earlier.Key, markup.EscapeWiki(original.Summary)))
+	log.Printf("pipeline.Cluster (%s) - comment: %s, error: %v", bug.ID, filed.Key, err)
+	err = jira.Comment(earlier, fmt.Sprintf("Possible duplicate reported as %s: %s", filed.Key, markup.EscapeWiki(bug.Summary)))
+	log.Printf("pipeline.Cluster (%s) - comment: %s, error: %v", bug.ID, earlier.Key, err)
+}
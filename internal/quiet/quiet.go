@@ -0,0 +1,121 @@
+package quiet
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// offsets caches the Slack timezone offset of users, in seconds east of UTC,
+// for the life of the container
+var offsets = map[string]int{}
+
+// hours return the start and end of QUIET_HOURS, e.g. 22:00-08:00, as times
+// of day, false when unset or invalid
+func hours() (start, end time.Duration, ok bool) {
+	parts := strings.Split(os.Getenv("QUIET_HOURS"), "-")
+	if len(parts) != 2 {
+		return
+	}
+	from, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return
+	}
+	until, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil || from.Equal(until) {
+		return
+	}
+	return clock(from), clock(until), true
+}
+
+// clock return the time of day of t
+func clock(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// Until return when the quiet hours of a user at offset seconds east of UTC
+// end, false when now is outside them. Quiet hours may span midnight
+func Until(offset int, now time.Time) (end time.Time, ok bool) {
+	start, stop, ok := hours()
+	if !ok {
+		return
+	}
+	local := now.In(time.FixedZone("", offset))
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	at := local.Sub(midnight)
+	switch {
+	case start < stop && at >= start && at < stop:
+		return midnight.Add(stop), true
+	case start > stop && at >= start:
+		return midnight.AddDate(0, 0, 1).Add(stop), true
+	case start > stop && at < stop:
+		return midnight.Add(stop), true
+	}
+	return time.Time{}, false
+}
+
+// Defer schedule a DM of text and blocks to userID for the end of their quiet
+// hours, in their Slack timezone, when now is within them. It reports whether
+// the DM was scheduled, the caller sending it now otherwise
+func Defer(userID, text string, blocks []map[string]interface{}) (postAt time.Time, deferred bool) {
+	if _, _, ok := hours(); !ok {
+		return
+	}
+	offset, err := timezone(userID)
+	if err != nil {
+		log.Printf("quiet.Defer (%s) - timezone error: %v", userID, err)
+		return
+	}
+	if postAt, deferred = Until(offset, time.Now()); !deferred {
+		return
+	}
+	if err = schedule(userID, text, blocks, postAt); err != nil {
+		log.Printf("quiet.Defer (%s, %s) - error: %v", userID, postAt.Format(time.RFC3339), err)
+		return postAt, false
+	}
+	return
+}
+
+// Hold apply the notification preferences of userID, see
+// store.HoldNotification, and then their quiet hours to a DM of text and
+// blocks about bug at at. Blockers are sent through quiet hours. held reports
+// whether the DM is not to be sent now, mode how it was handled
+func Hold(userID string, bug store.Bug, at time.Time, text string, blocks []map[string]interface{}) (mode string, held bool) {
+	if mode, held = store.HoldNotification(userID, bug, at); held || bug.Severity == store.SeverityBlocker {
+		return
+	}
+	if postAt, deferred := Defer(userID, text, blocks); deferred {
+		return mode + " until " + postAt.UTC().Format(time.RFC3339), true
+	}
+	return
+}
+
+// timezone return the Slack timezone offset of userID in seconds east of UTC
+func timezone(userID string) (offset int, err error) {
+	if offset, ok := offsets[userID]; ok {
+		return offset, nil
+	}
+	user, err := slack.Default.UserInfo(userID)
+	if err != nil {
+		return
+	}
+	offsets[userID] = user.TZOffset
+	return user.TZOffset, nil
+}
+
+// schedule post text and blocks to channel at postAt with chat.scheduleMessage
+func schedule(channel, text string, blocks []map[string]interface{}, postAt time.Time) error {
+	message := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+		"post_at": postAt.Unix(),
+	}
+	if len(blocks) > 0 {
+		message["blocks"] = blocks
+	}
+	return slack.Default.Call("chat.scheduleMessage", message, nil)
+}
@@ -0,0 +1,21 @@
+package recovery
+
+import (
+	"log"
+	"runtime/debug"
+
+	"github.com/anzellai/kanobug/internal/emf"
+)
+
+// Recovered log the stack of the panic value recovered from handler and
+// count it, reporting whether there was one. Handlers defer it with
+// recover() to answer with a Slack response rather than a raw 502 from API
+// Gateway
+func Recovered(handler string, value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	log.Printf("%s.Handler - panic: %v\n%s", handler, value, debug.Stack())
+	emf.Count(emf.Panics, emf.Dimensions{"Handler": handler})
+	return true
+}
@@ -0,0 +1,141 @@
+package scopes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/eventbus"
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+const authTest = "https://slack.com/api/auth.test"
+
+// requirement is the bot token scopes a feature needs, checked when enabled
+type requirement struct {
+	feature string
+	scopes  []string
+	enabled func() bool
+}
+
+// requirements are the scopes of every feature, see README
+var requirements = []requirement{
+	{"slash command", []string{"commands"}, always},
+	{"confirmations and DMs", []string{"chat:write", "im:write"}, always},
+	{"message shortcut files", []string{"files:read"}, always},
+	{"CC watchers and assignment", []string{"users:read.email"}, set("JIRA_API_HOST")},
+	{"Jira link unfurling", []string{"links:read", "links:write"}, set("JIRA_API_HOST")},
+	{"quiet hours", []string{"users:read"}, set("QUIET_HOURS")},
+	{"on-call DMs", []string{"usergroups:read"}, func() bool { return os.Getenv("ONCALL_DM") == "true" }},
+	{"triager and admin groups", []string{"usergroups:read"}, func() bool {
+		return set("KANOBUG_TRIAGE_GROUP")() || set("KANOBUG_ADMIN_GROUP")()
+	}},
+}
+
+func always() bool { return true }
+
+// set return whether env variable name is set
+func set(name string) func() bool {
+	return func() bool { return len(os.Getenv(name)) > 0 }
+}
+
+// Required return the scopes the enabled features need, each with the
+// features needing it
+func Required() map[string][]string {
+	required := map[string][]string{}
+	for _, r := range requirements {
+		if !r.enabled() {
+			continue
+		}
+		for _, scope := range r.scopes {
+			required[scope] = append(required[scope], r.feature)
+		}
+	}
+	return required
+}
+
+// Granted return the scopes of the bot token, from the X-OAuth-Scopes header
+// Slack answers auth.test with, apps.permissions.info not being available to
+// apps with granular bot scopes
+func Granted() (granted []string, err error) {
+	token := config.Get().SlackToken
+	if len(token) == 0 {
+		return nil, errors.New("SLACK_ACCESS_TOKEN is not set")
+	}
+	defer func() { emf.Slack("auth.test", err) }()
+	req, err := http.NewRequest("POST", authTest, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := outbound.Do(outbound.Slack, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return
+	}
+	if !status.OK {
+		return nil, fmt.Errorf("auth.test: %s", status.Error)
+	}
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); len(scope) > 0 {
+			granted = append(granted, scope)
+		}
+	}
+	return
+}
+
+// Missing return the required scopes the bot token was not granted, with
+// the features needing them
+func Missing() (missing map[string][]string, err error) {
+	granted, err := Granted()
+	if err != nil {
+		return
+	}
+	has := map[string]bool{}
+	for _, scope := range granted {
+		has[scope] = true
+	}
+	missing = map[string][]string{}
+	for scope, features := range Required() {
+		if !has[scope] {
+			missing[scope] = features
+		}
+	}
+	return
+}
+
+// Audit check the scopes of the bot token, called from main so each Lambda
+// container checks them once on cold start, publishing a SlackScopesMissing
+// event for KanobugNotifier to report to OPS_CHANNEL when some are missing
+func Audit(handler string) {
+	missing, err := Missing()
+	if err != nil || len(missing) == 0 {
+		log.Printf("scopes.Audit (%s) - missing: 0, error: %v", handler, err)
+		return
+	}
+	log.Printf("scopes.Audit (%s) - missing: %s", handler, strings.Join(Sorted(missing), ", "))
+	_ = eventbus.Publish(eventbus.ScopesMissing, eventbus.ScopesDetail{Handler: handler, Missing: missing})
+}
+
+// Sorted return the scopes of missing in order
+func Sorted(missing map[string][]string) (scopes []string) {
+	for scope := range missing {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return
+}
@@ -0,0 +1,281 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// defaultIndex is the OpenSearch index bugs are mirrored into unless
+// SEARCH_INDEX says otherwise
+const defaultIndex = "kanobug"
+
+// mapping of the index: full text summary and details, exact team, product
+// and status to filter on
+const mapping = `{
+  "mappings": {
+    "properties": {
+      "team_id":    {"type": "keyword"},
+      "id":         {"type": "keyword"},
+      "summary":    {"type": "text"},
+      "details":    {"type": "text"},
+      "product":    {"type": "keyword"},
+      "status":     {"type": "keyword"},
+      "issue_key":  {"type": "keyword"},
+      "created_at": {"type": "date"}
+    }
+  }
+}`
+
+// Document is a bug as indexed
+type Document struct {
+	TeamID    string    `json:"team_id"`
+	ID        string    `json:"id"`
+	Summary   string    `json:"summary"`
+	Details   string    `json:"details,omitempty"`
+	Product   string    `json:"product"`
+	Status    string    `json:"status"`
+	IssueKey  string    `json:"issue_key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter narrows a search to a product, a status and bugs created from
+// Since until Until, empty matching any
+type Filter struct {
+	Product string
+	Status  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Hit is a matching bug, most relevant first
+type Hit struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Enabled report whether SEARCH_ENDPOINT names an OpenSearch domain
+func Enabled() bool {
+	return len(config.Get().SearchEndpoint) > 0
+}
+
+// index return the name of the index, SEARCH_INDEX or defaultIndex
+func index() string {
+	if name := os.Getenv("SEARCH_INDEX"); len(name) > 0 {
+		return name
+	}
+	return defaultIndex
+}
+
+// docID return the ID of the document of bug id of team, bug IDs are only
+// unique within a team
+func docID(team, id string) string {
+	return url.PathEscape(team + "#" + id)
+}
+
+// Setup create the index with its mapping, nothing when it exists already
+func Setup() (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("search.Setup (%s) - error: %v", index(), err)
+		}
+	}()
+	status, body, err := do("PUT", "/"+index(), []byte(mapping))
+	if err != nil || status < 300 || strings.Contains(string(body), "resource_already_exists_exception") {
+		return
+	}
+	return fmt.Errorf("status %d: %s", status, body)
+}
+
+// Index put bug into the index, replacing what was indexed of it
+func Index(bug store.Bug) (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("search.Index (%s/%s) - error: %v", bug.TeamID, bug.ID, err)
+		}
+	}()
+	body, err := json.Marshal(document(bug))
+	if err != nil {
+		return
+	}
+	status, reply, err := do("PUT", "/"+index()+"/_doc/"+docID(bug.TeamID, bug.ID), body)
+	if err == nil && status >= 300 {
+		err = fmt.Errorf("status %d: %s", status, reply)
+	}
+	return
+}
+
+// document return bug as indexed. Details encrypted in the table, or to be
+// since DETAILS_KMS_KEY_ID is set, are left out
+func document(bug store.Bug) Document {
+	doc := Document{
+		TeamID:    bug.TeamID,
+		ID:        bug.ID,
+		Summary:   bug.Summary,
+		Details:   bug.Details,
+		Product:   bug.Product,
+		Status:    bug.Status,
+		IssueKey:  bug.IssueKey,
+		CreatedAt: bug.CreatedAt,
+	}
+	if bug.DetailsEncrypted != nil || bug.EncryptedAtRest || len(os.Getenv("DETAILS_KMS_KEY_ID")) > 0 {
+		doc.Details = ""
+	}
+	return doc
+}
+
+// Remove take bug id of team out of the index, nothing when it is not in it
+func Remove(team, id string) (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("search.Remove (%s/%s) - error: %v", team, id, err)
+		}
+	}()
+	status, reply, err := do("DELETE", "/"+index()+"/_doc/"+docID(team, id), nil)
+	if err == nil && status >= 300 && status != http.StatusNotFound {
+		err = fmt.Errorf("status %d: %s", status, reply)
+	}
+	return
+}
+
+// match return the query of the bugs of team matching query and filter, a
+// match in the summary weighing three times one in the details
+func match(team, query string, filter Filter) map[string]interface{} {
+	filters := []map[string]interface{}{{"term": map[string]string{"team_id": team}}}
+	if len(filter.Product) > 0 {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"product": filter.Product}})
+	}
+	if len(filter.Status) > 0 {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"status": filter.Status}})
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		created := map[string]time.Time{}
+		if !filter.Since.IsZero() {
+			created["gte"] = filter.Since
+		}
+		if !filter.Until.IsZero() {
+			created["lt"] = filter.Until
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"created_at": created}})
+	}
+	// no words match every bug of the filter
+	must := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if len(strings.TrimSpace(query)) > 0 {
+		must = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{"summary^3", "details"},
+				"fuzziness": "AUTO",
+			},
+		}
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   must,
+			"filter": filters,
+		},
+	}
+}
+
+// Count return how many bugs of team match query and filter
+func Count(team, query string, filter Filter) (count int, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("search.Count (%s, %q) - error: %v", team, query, err)
+		}
+	}()
+	body, err := json.Marshal(map[string]interface{}{"query": match(team, query, filter)})
+	if err != nil {
+		return
+	}
+	status, reply, err := do("POST", "/"+index()+"/_count", body)
+	if err != nil {
+		return
+	}
+	if status >= 300 {
+		return 0, fmt.Errorf("status %d: %s", status, reply)
+	}
+	var result struct {
+		Count int `json:"count"`
+	}
+	err = json.Unmarshal(reply, &result)
+	return result.Count, err
+}
+
+// Search return up to size bugs of team matching query and filter, ranked
+// by relevance
+func Search(team, query string, filter Filter, size int) (hits []Hit, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("search.Search (%s, %q) - error: %v", team, query, err)
+		}
+	}()
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    size,
+		"_source": false,
+		"query":   match(team, query, filter),
+	})
+	if err != nil {
+		return
+	}
+	status, reply, err := do("POST", "/"+index()+"/_search", body)
+	if err != nil {
+		return
+	}
+	if status >= 300 {
+		return nil, fmt.Errorf("status %d: %s", status, reply)
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID    string  `json:"_id"`
+				Score float64 `json:"_score"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err = json.Unmarshal(reply, &result); err != nil {
+		return
+	}
+	prefix := team + "#"
+	for _, hit := range result.Hits.Hits {
+		hits = append(hits, Hit{ID: strings.TrimPrefix(hit.ID, prefix), Score: hit.Score})
+	}
+	return
+}
+
+// do send a request to the SEARCH_ENDPOINT domain signed with the Lambda's
+// credentials, returning the status and body of the response
+func do(method, path string, body []byte) (status int, reply []byte, err error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(config.Get().SearchEndpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sess, err := outbound.Session(outbound.Search)
+	if err != nil {
+		return
+	}
+	if _, err = v4.NewSigner(sess.Config.Credentials).Sign(req, bytes.NewReader(body), "es", config.Get().Region, time.Now()); err != nil {
+		return
+	}
+	resp, err := outbound.Do(outbound.Search, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	reply, err = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, reply, err
+}
@@ -0,0 +1,29 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+func TestDocumentDetails(t *testing.T) {
+	tests := []struct {
+		name  string
+		bug   store.Bug
+		keyID string
+		want  string
+	}{
+		{"plaintext", store.Bug{Details: "log"}, "", "log"},
+		{"key set", store.Bug{Details: "log"}, "alias/kanobug", ""},
+		{"encrypted", store.Bug{DetailsEncrypted: &store.Encrypted{}}, "", ""},
+		{"decrypted", store.Bug{Details: "log", EncryptedAtRest: true}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DETAILS_KMS_KEY_ID", tt.keyID)
+			if got := document(tt.bug).Details; got != tt.want {
+				t.Errorf("document().Details = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+var (
+	mu    sync.Mutex
+	cache = map[string]string{}
+)
+
+// Get return the secret string stored under id in Secrets Manager, cached
+// for the lifetime of the Lambda container
+func Get(id string) (value string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if value, ok := cache[id]; ok {
+		return value, nil
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	out, err := secretsmanager.New(sess).GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return
+	}
+	value = aws.StringValue(out.SecretString)
+	cache[id] = value
+	return
+}
+
+// Put store value as the current version of secret id, replacing the cached
+// value
+func Put(id, value string) (err error) {
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	_, err = secretsmanager.New(sess).PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(id),
+		SecretString: aws.String(value),
+	})
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	cache[id] = value
+	mu.Unlock()
+	return
+}
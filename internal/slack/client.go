@@ -0,0 +1,231 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// api is the base of the Slack Web API methods
+const api = "https://slack.com/api/"
+
+// Retries of a call Slack rate limited, or that failed and is safe to make
+// again
+const (
+	// maxAttempts is how many times a call is made at most
+	maxAttempts = 3
+	// maxWait is the longest Retry-After waited for, a longer one failing the
+	// call rather than the invocation's deadline
+	maxWait = 10 * time.Second
+	// backoff is the wait before retrying a failed idempotent call, doubled
+	// on each attempt
+	backoff = 200 * time.Millisecond
+)
+
+// API is the Slack Web API methods kanobug calls, Default in production and
+// a fake in tests
+type API interface {
+	// PostMessage send message, a chat.postMessage payload, returning the
+	// ts of the message
+	PostMessage(message map[string]interface{}) (ts string, err error)
+	// OpenView open view, a modal, for the interaction of triggerID
+	OpenView(triggerID string, view interface{}) error
+	// UserInfo return the user of userID with their locale
+	UserInfo(userID string) (User, error)
+	// Unfurl attach unfurls, by link, to the message ts of channel
+	Unfurl(channel, ts string, unfurls map[string]interface{}) error
+	// GroupMembers return the user IDs of a user group
+	GroupMembers(group string) ([]string, error)
+	// Call make any other method with payload, decoding its reply into out
+	// unless nil
+	Call(method string, payload interface{}, out interface{}) error
+}
+
+// Default is the API handlers call
+var Default API = Client{}
+
+// IsVerificationToken report whether token is SLACK_VERIFICATION_TOKEN,
+// which requests from Slack carry, false while it is not set
+func IsVerificationToken(token string) bool {
+	expected := config.Get().SlackVerificationToken
+	return len(expected) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// User is the users.info user
+type User struct {
+	ID       string  `json:"id"`
+	TZ       string  `json:"tz"`
+	TZOffset int     `json:"tz_offset"`
+	Locale   string  `json:"locale"`
+	Profile  Profile `json:"profile"`
+}
+
+// Profile is the profile of a User
+type Profile struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	RealName    string `json:"real_name"`
+}
+
+// Name return the display name of the user, else their real name
+func (user User) Name() string {
+	if len(user.Profile.DisplayName) > 0 {
+		return user.Profile.DisplayName
+	}
+	return user.Profile.RealName
+}
+
+// idempotent are the methods safe to call again after a failure Slack may
+// have acted on, reads only
+var idempotent = map[string]bool{
+	"users.info":            true,
+	"usergroups.users.list": true,
+	"conversations.info":    true,
+	"auth.test":             true,
+}
+
+// Client calls the Web API with the bot token, SLACK_ACCESS_TOKEN or that
+// of the workspace's install, see store.UseWorkspace
+type Client struct{}
+
+// reply is the envelope of every Web API reply
+type reply struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// PostMessage send message with chat.postMessage
+func (c Client) PostMessage(message map[string]interface{}) (ts string, err error) {
+	var out struct {
+		TS string `json:"ts"`
+	}
+	err = c.Call("chat.postMessage", message, &out)
+	return out.TS, err
+}
+
+// OpenView open a modal with views.open
+func (c Client) OpenView(triggerID string, view interface{}) error {
+	return c.Call("views.open", map[string]interface{}{"trigger_id": triggerID, "view": view}, nil)
+}
+
+// UserInfo return the user of userID with users.info
+func (c Client) UserInfo(userID string) (user User, err error) {
+	var out struct {
+		User User `json:"user"`
+	}
+	err = c.get("users.info", url.Values{"user": {userID}, "include_locale": {"true"}}, &out)
+	return out.User, err
+}
+
+// Unfurl attach unfurls to a message with chat.unfurl
+func (c Client) Unfurl(channel, ts string, unfurls map[string]interface{}) error {
+	return c.Call("chat.unfurl", map[string]interface{}{"channel": channel, "ts": ts, "unfurls": unfurls}, nil)
+}
+
+// GroupMembers return the members of group with usergroups.users.list
+func (c Client) GroupMembers(group string) (users []string, err error) {
+	var out struct {
+		Users []string `json:"users"`
+	}
+	err = c.get("usergroups.users.list", url.Values{"usergroup": {group}}, &out)
+	return out.Users, err
+}
+
+// Call post payload as JSON to method
+func (c Client) Call(method string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.do(method, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", api+method, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+		return req, err
+	}, out)
+}
+
+// get call method with query, for the read methods not taking JSON
+func (c Client) get(method string, query url.Values, out interface{}) error {
+	return c.do(method, func() (*http.Request, error) {
+		return http.NewRequest("GET", api+method+"?"+query.Encode(), nil)
+	}, out)
+}
+
+// do make the request of newRequest for method, waiting out the Retry-After
+// of a rate limited call and retrying failed idempotent methods, up to
+// maxAttempts times
+func (c Client) do(method string, newRequest func() (*http.Request, error), out interface{}) (err error) {
+	defer func() { emf.Slack(method, err) }()
+	for attempt := 1; ; attempt++ {
+		var retry time.Duration
+		retry, err = c.attempt(method, newRequest, out)
+		if err == nil || retry < 0 || attempt == maxAttempts {
+			return
+		}
+		if retry == 0 {
+			retry = backoff << uint(attempt-1)
+		}
+		log.Printf("slack.Client.do (%s) - attempt: %d, retry in: %s, error: %v", method, attempt, retry, err)
+		time.Sleep(retry)
+	}
+}
+
+// attempt make the request once, retry being how long to wait before making
+// it again, zero for the backoff and negative when it is not to be
+func (c Client) attempt(method string, newRequest func() (*http.Request, error), out interface{}) (retry time.Duration, err error) {
+	retry = -1
+	req, err := newRequest()
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+config.Get().SlackToken)
+	resp, err := outbound.Do(outbound.Slack, req)
+	if err != nil {
+		if idempotent[method] {
+			retry = 0
+		}
+		return
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// a rate limited call was not made, so any method is retried
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if wait := time.Duration(seconds) * time.Second; wait <= maxWait {
+			retry = wait
+		}
+		return retry, fmt.Errorf("%s: rate limited, retry after %ss", method, resp.Header.Get("Retry-After"))
+	case resp.StatusCode >= 500:
+		if idempotent[method] {
+			retry = 0
+		}
+		return retry, fmt.Errorf("%s: status %d", method, resp.StatusCode)
+	}
+	var raw json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return
+	}
+	var status reply
+	if err = json.Unmarshal(raw, &status); err != nil {
+		return
+	}
+	if !status.OK {
+		return retry, fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if out != nil {
+		err = json.Unmarshal(raw, out)
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/outbound/outboundtest"
+)
+
+func TestPostMessage(t *testing.T) {
+	outboundtest.Replay(t, "testdata/chat_post_message.json")
+	tests := []struct {
+		name    string
+		message map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{"posted", map[string]interface{}{"channel": "C0BUGS", "thread_ts": "1537185600.000100", "text": "Filed WEB-42"}, "1537185612.000200", ""},
+		{"refused", map[string]interface{}{"channel": "C0GONE", "text": "Filed WEB-43"}, "", "chat.postMessage: channel_not_found"},
+	}
+	for _, tt := range tests {
+		ts, err := Client{}.PostMessage(tt.message)
+		if ts != tt.want {
+			t.Errorf("%s: PostMessage() = %q, want %q", tt.name, ts, tt.want)
+		}
+		if got := errString(err); got != tt.wantErr {
+			t.Errorf("%s: PostMessage() error = %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,177 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// MaxResponses is how many messages Slack takes on a response_url, within
+// ResponseTTL of the interaction
+const MaxResponses = 5
+
+// ResponseTTL is how long a response_url takes messages
+const ResponseTTL = 30 * time.Minute
+
+// Response types of a message, Ephemeral showing it to the user only
+const (
+	Ephemeral = "ephemeral"
+	InChannel = "in_channel"
+)
+
+// ErrExhausted is returned once MaxResponses were sent to a response_url
+var ErrExhausted = errors.New("response_url takes no more responses")
+
+// ErrResponseURL is returned for a response_url not on the host of its
+// platform, which kanobug was handed by someone other than Slack
+var ErrResponseURL = errors.New("response_url is not on the host of its platform")
+
+// ErrExpired is returned for a response_url issued over ResponseTTL ago, or
+// that Slack turned down as expired or used up
+var ErrExpired = errors.New("response_url has expired")
+
+// Message is a message sent to a response_url, replacing or deleting the
+// message whose interaction gave it when ReplaceOriginal or DeleteOriginal
+type Message struct {
+	Text            string                   `json:"text,omitempty"`
+	Blocks          []map[string]interface{} `json:"blocks,omitempty"`
+	ResponseType    string                   `json:"response_type,omitempty"`
+	ReplaceOriginal bool                     `json:"replace_original,omitempty"`
+	DeleteOriginal  bool                     `json:"delete_original,omitempty"`
+}
+
+// responses count the messages sent to each response_url by this container
+var (
+	responses   = map[string]int{}
+	responsesMu sync.Mutex
+)
+
+// Responder follows up on a command or interaction through its
+// response_url, up to MaxResponses times. Authorized sends the bot token
+// along, Mattermost's response urls taking none. Issued is when the
+// response_url was issued, zero when just now
+type Responder struct {
+	URL        string
+	Platform   string
+	Authorized bool
+	Issued     time.Time
+}
+
+// NewResponder return the Responder of url, authorized for Slack's
+func NewResponder(url, platform string) Responder {
+	return Responder{URL: url, Platform: platform, Authorized: platform == "slack"}
+}
+
+// ValidResponseURL report whether raw is an https response_url of platform:
+// on hooks.slack.com for Slack, and on the host of MATTERMOST_URL for
+// Mattermost
+func ValidResponseURL(raw, platform string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if platform == "mattermost" {
+		return outbound.Allowed(outbound.Mattermost, u)
+	}
+	return u.Scheme == "https" && u.Hostname() == "hooks.slack.com"
+}
+
+// Expired report whether the response_url takes no more messages by now,
+// callers then reach the user with a DM instead
+func (r Responder) Expired() bool {
+	return !r.Issued.IsZero() && time.Since(r.Issued) > ResponseTTL
+}
+
+// Reply send text as an ephemeral message, seen by the user only
+func (r Responder) Reply(text string) error {
+	return r.Send(Message{Text: text, ResponseType: Ephemeral})
+}
+
+// Announce send text as a message everyone in the channel sees
+func (r Responder) Announce(text string) error {
+	return r.Send(Message{Text: text, ResponseType: InChannel})
+}
+
+// Replace put text and blocks in place of the message interacted with
+func (r Responder) Replace(text string, blocks []map[string]interface{}) error {
+	return r.Send(Message{Text: text, Blocks: blocks, ReplaceOriginal: true})
+}
+
+// Delete remove the message interacted with
+func (r Responder) Delete() error {
+	return r.Send(Message{DeleteOriginal: true})
+}
+
+// Send post message to the response_url
+func (r Responder) Send(message Message) (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("slack.Responder.Send (%s) - error: %v", host(r.URL), err)
+		}
+	}()
+	if len(r.URL) == 0 {
+		return errors.New("no response_url")
+	}
+	if !ValidResponseURL(r.URL, r.Platform) {
+		return ErrResponseURL
+	}
+	if r.Expired() {
+		return ErrExpired
+	}
+	responsesMu.Lock()
+	if responses[r.URL] >= MaxResponses {
+		responsesMu.Unlock()
+		return ErrExhausted
+	}
+	responses[r.URL]++
+	responsesMu.Unlock()
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", r.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Authorized {
+		req.Header.Set("Authorization", "Bearer "+config.Get().SlackToken)
+	}
+	dependency := outbound.Slack
+	if r.Platform == "mattermost" {
+		dependency = outbound.Mattermost
+	}
+	resp, err := outbound.Do(dependency, req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		if reason := strings.TrimSpace(string(body)); reason == "expired_url" || reason == "used_url" {
+			return ErrExpired
+		}
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return
+}
+
+// host return the host of a response_url, its path being a secret
+func host(url string) string {
+	url = strings.TrimPrefix(url, "https://")
+	if i := strings.Index(url, "/"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Actions recorded in the audit table
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditAssign  = "assign"
+	AuditClose   = "close"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditStatus  = "status"
+	AuditAdmin   = "admin"
+	AuditMerge   = "merge"
+	AuditVerify  = "verify"
+)
+
+// ErrAuditFilter is returned when ListAudit is given neither subject nor actor
+var ErrAuditFilter = errors.New("a subject or actor is required")
+
+// AuditEntry is an append-only record of a mutation, Subject is the bug ID
+// or, for admin actions, the config kind and key such as "role/U123".
+// EntryID sorts entries of a subject by time. Bug entries are AUDIT# items
+// of the bug, admin entries of the team
+type AuditEntry struct {
+	Subject string      `json:"subject"`
+	EntryID string      `json:"entry_id"`
+	Actor   string      `json:"actor"`
+	Action  string      `json:"action"`
+	Detail  string      `json:"detail,omitempty"`
+	Before  interface{} `json:"before,omitempty"`
+	After   interface{} `json:"after,omitempty"`
+	At      time.Time   `json:"at"`
+}
+
+// AuditFilter narrows ListAudit results to a subject or an actor, Cursor
+// continues a previous page
+type AuditFilter struct {
+	Subject string
+	Actor   string
+	Since   time.Time
+	Until   time.Time
+	Limit   int64
+	Cursor  string
+}
+
+// auditPartition return the partition key holding the entries of subject
+func (d Dynamo) auditPartition(subject string) string {
+	if strings.Contains(subject, "/") {
+		return teamPrefix + d.team()
+	}
+	return d.partition(bugPrefix + subject)
+}
+
+// Audited return the fields of bug recorded as its audit before and after,
+// details are left out as they may be encrypted or redacted
+func Audited(bug Bug) map[string]interface{} {
+	fields := map[string]interface{}{
+		"summary":  bug.Summary,
+		"product":  bug.Product,
+		"severity": bug.Severity,
+		"status":   bug.Status,
+	}
+	for name, value := range map[string]string{"resolution": bug.Resolution, "assignee": bug.Assignee, "issue_key": bug.IssueKey, "merged_into": bug.MergedInto, "verified_by": bug.VerifiedBy} {
+		if len(value) > 0 {
+			fields[name] = value
+		}
+	}
+	if len(bug.Tags) > 0 {
+		fields["tags"] = bug.Tags
+	}
+	if bug.DeletedAt != nil {
+		fields["deleted_at"] = bug.DeletedAt.Format(time.RFC3339)
+	}
+	return fields
+}
+
+// auditKeys return the table and actor index keys of entry
+func (d Dynamo) auditKeys(entry AuditEntry) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk":              {S: aws.String(d.auditPartition(entry.Subject))},
+		"sk":              {S: aws.String(auditPrefix + entry.EntryID)},
+		ActorIndex + "pk": {S: aws.String(d.partition("ACTOR#" + entry.Actor))},
+		ActorIndex + "sk": {S: aws.String(entry.EntryID)},
+	}
+}
+
+// Audit append entry to the audit trail, entries are never overwritten
+func (d Dynamo) Audit(entry AuditEntry) (err error) {
+	defer func() {
+		log.Printf("store.Audit (%s/%s/%s) - error: %v", entry.Subject, entry.Actor, entry.Action, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	entry.EntryID = entry.At.UTC().Format(time.RFC3339Nano) + "/" + NewID()
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return
+	}
+	for name, value := range d.auditKeys(entry) {
+		item[name] = value
+	}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{
+		TableName:           table(),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	return
+}
+
+// ListAudit return a page of audit entries of a subject or actor, newest
+// first, and the cursor of the next page
+func (d Dynamo) ListAudit(filter AuditFilter) (entries []AuditEntry, cursor string, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	since := filter.Since.UTC().Format(time.RFC3339Nano)
+	// entry IDs of the same instant sort after its timestamp
+	until := "~"
+	if !filter.Until.IsZero() {
+		until = filter.Until.UTC().Format(time.RFC3339Nano) + "/~"
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 table(),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(filter.Limit),
+	}
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		input.Limit = aws.Int64(25)
+	}
+	values := input.ExpressionAttributeValues
+	switch {
+	case len(filter.Subject) > 0:
+		values[":pk"] = &dynamodb.AttributeValue{S: aws.String(d.auditPartition(filter.Subject))}
+		values[":since"] = &dynamodb.AttributeValue{S: aws.String(auditPrefix + since)}
+		values[":until"] = &dynamodb.AttributeValue{S: aws.String(auditPrefix + until)}
+		values[":subject"] = &dynamodb.AttributeValue{S: aws.String(filter.Subject)}
+		input.KeyConditionExpression = aws.String("pk = :pk AND sk BETWEEN :since AND :until")
+		input.ExpressionAttributeNames = map[string]*string{"#subject": aws.String("subject")}
+		input.FilterExpression = aws.String("#subject = :subject")
+		if len(filter.Actor) > 0 {
+			values[":actor"] = &dynamodb.AttributeValue{S: aws.String(filter.Actor)}
+			input.ExpressionAttributeNames["#actor"] = aws.String("actor")
+			input.FilterExpression = and(input.FilterExpression, "#actor = :actor")
+		}
+	case len(filter.Actor) > 0:
+		values[":pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition("ACTOR#" + filter.Actor))}
+		values[":since"] = &dynamodb.AttributeValue{S: aws.String(since)}
+		values[":until"] = &dynamodb.AttributeValue{S: aws.String(until)}
+		input.IndexName = aws.String(ActorIndex)
+		input.KeyConditionExpression = aws.String(ActorIndex + "pk = :pk AND " + ActorIndex + "sk BETWEEN :since AND :until")
+	default:
+		err = ErrAuditFilter
+		return
+	}
+	if len(filter.Cursor) > 0 {
+		if input.ExclusiveStartKey, err = decodeCursor(filter.Cursor); err != nil {
+			return
+		}
+	}
+	out, err := srv.Query(input)
+	if err != nil {
+		return
+	}
+	if err = dynamodbattribute.UnmarshalListOfMaps(out.Items, &entries); err != nil {
+		return
+	}
+	if len(out.LastEvaluatedKey) > 0 {
+		cursor, err = encodeCursor(out.LastEvaluatedKey)
+	}
+	return
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/i18n"
+)
+
+// Severity values offered in the report form
+const (
+	SeverityBlocker  = "blocker"
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityTrivial  = "trivial"
+)
+
+// Status values of a bug
+const (
+	StatusNew        = "new"
+	StatusFiled      = "filed"
+	StatusInProgress = "in_progress"
+	StatusResolved   = "resolved"
+	StatusClosed     = "closed"
+)
+
+// Resolutions a reporter can close a bug with
+const (
+	ResolutionFixed     = "fixed"
+	ResolutionNotABug   = "not_a_bug"
+	ResolutionDuplicate = "duplicate"
+)
+
+// Statuses lists every valid bug status
+var Statuses = []string{StatusNew, StatusFiled, StatusInProgress, StatusResolved, StatusClosed}
+
+// Bug is the BUG struct type ...
+type Bug struct {
+	ID                string     `json:"id"`
+	TeamID            string     `json:"team_id"`
+	EnterpriseID      string     `json:"enterprise_id,omitempty"`
+	Source            string     `json:"source"`
+	UserID            string     `json:"user_id"`
+	UserName          string     `json:"user_name"`
+	Summary           string     `json:"summary"`
+	Product           string     `json:"product"`
+	Severity          string     `json:"severity"`
+	Security          bool       `json:"security"`
+	CustomerImpacting bool       `json:"customer_impacting"`
+	Details           string     `json:"details"`
+	DetailsEncrypted  *Encrypted `json:"details_encrypted,omitempty"`
+	// EncryptedAtRest reports the details were decrypted when read, so they
+	// are not copied anywhere unencrypted
+	EncryptedAtRest bool       `json:"-"`
+	Versions        []string   `json:"versions,omitempty"`
+	Tags            []string   `json:"tags,omitempty"`
+	OccurredAt      *time.Time `json:"occurred_at,omitempty"`
+	CC              []string   `json:"cc,omitempty"`
+	Anonymous       bool       `json:"anonymous,omitempty"`
+	// Smoke marks the synthetic bugs of kanobugctl smoke, filed to
+	// JIRA_SMOKE_PROJECT only, never notified and purged once checked
+	Smoke bool `json:"smoke,omitempty"`
+	// Epic is the issue of the bug bash the bug was reported during
+	Epic string `json:"epic,omitempty"`
+	// SuggestedSeverity is what the classifier made of the report, because
+	// of SuggestedBecause, see internal/classify
+	SuggestedSeverity string `json:"suggested_severity,omitempty"`
+	SuggestedBecause  string `json:"suggested_because,omitempty"`
+	// Translation is the bug in the trackers' language, set while filing
+	// and not stored
+	Translation *Translation `json:"-"`
+	// SummaryHashes is the min hash signature of the summary, see
+	// pipeline.Signature, PossibleDuplicateOf the earlier bug it matched
+	// and Similar the later bugs matching it
+	SummaryHashes       []int64  `json:"summary_hashes,omitempty"`
+	PossibleDuplicateOf string   `json:"possible_duplicate_of,omitempty"`
+	Similar             []string `json:"similar,omitempty"`
+	// MergedInto is the bug a triager merged this duplicate into, whose
+	// status notifications its reporter and CC'd users get instead
+	MergedInto string `json:"merged_into,omitempty"`
+	// Recurrences counts the reports of a resolved bug happening again,
+	// last at RecurredAt
+	Recurrences int        `json:"recurrences,omitempty"`
+	RecurredAt  *time.Time `json:"recurred_at,omitempty"`
+	// VerifiedBy is the reporter or CC'd user who confirmed the resolution
+	// fixed the bug, at VerifiedAt
+	VerifiedBy string     `json:"verified_by,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	// Locale is the reporter's Slack locale, e.g. es-ES, confirmations are in
+	Locale     string     `json:"locale,omitempty"`
+	Queued     bool       `json:"queued,omitempty"`
+	Assignee   string     `json:"assignee,omitempty"`
+	Thread     *Thread    `json:"thread,omitempty"`
+	Announced  []Thread   `json:"announced,omitempty"`
+	Escalated  []string   `json:"escalated,omitempty"`
+	Status     string     `json:"status"`
+	Resolution string     `json:"resolution,omitempty"`
+	IssueKey   string     `json:"issue_key,omitempty"`
+	Issues     []Issue    `json:"issues,omitempty"`
+	History    []Change   `json:"history,omitempty"`
+	Version    int64      `json:"version"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// TTL is when the table expires the bug, never unless BUG_RETENTION is
+	// set, see Expiry
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// Translation is the summary and details of a bug machine translated from
+// Language
+type Translation struct {
+	Language string
+	Summary  string
+	Details  string
+}
+
+// Thread is the chat message a bug was posted to triage as
+type Thread struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// Threads return the messages the updates of bug reply to, one per channel:
+// its triage message first, then its announcements in the channels
+// subscribed to its product (Announced)
+func (bug Bug) Threads() (threads []Thread) {
+	seen := map[string]bool{}
+	if bug.Thread != nil {
+		threads, seen[bug.Thread.Channel] = append(threads, *bug.Thread), true
+	}
+	for _, thread := range bug.Announced {
+		if !seen[thread.Channel] {
+			threads, seen[thread.Channel] = append(threads, thread), true
+		}
+	}
+	return
+}
+
+// Issue is a tracker issue a bug was filed as
+type Issue struct {
+	Tracker string `json:"tracker"`
+	ID      string `json:"id"`
+	Key     string `json:"key"`
+	URL     string `json:"url"`
+}
+
+// Change is a status transition in a bug's history, From the status it
+// left and Actor who made it, empty when kanobug did, e.g. once filed
+type Change struct {
+	Status     string    `json:"status"`
+	From       string    `json:"from,omitempty"`
+	Resolution string    `json:"resolution,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// TimeInStatus return how long bug spent in each status of its history,
+// the current one until now
+func (bug Bug) TimeInStatus(now time.Time) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	for i, change := range bug.History {
+		until := now
+		if i+1 < len(bug.History) {
+			until = bug.History[i+1].At
+		}
+		durations[change.Status] += until.Sub(change.At)
+	}
+	return durations
+}
+
+// CycleTime return how long bug took from being reported until last fixed,
+// false when it is not fixed, see FixedAt
+func (bug Bug) CycleTime() (time.Duration, bool) {
+	at, ok := bug.FixedAt()
+	return at.Sub(bug.CreatedAt), ok
+}
+
+// FixedAt return when bug was last fixed: its change to resolved, or to
+// closed as fixed, since it was last reopened, false when it is open or was
+// closed as not a bug or a duplicate
+func (bug Bug) FixedAt() (at time.Time, ok bool) {
+	for _, change := range bug.History {
+		resolved := change.Status == StatusResolved && change.Resolution != ResolutionNotABug && change.Resolution != ResolutionDuplicate
+		if !resolved && (change.Status != StatusClosed || change.Resolution != ResolutionFixed) {
+			ok = false
+			continue
+		}
+		if !ok {
+			at, ok = change.At, true
+		}
+	}
+	return
+}
+
+// Comment is a note left on a bug, CommentID sorts the comments of a bug by time
+type Comment struct {
+	BugID     string    `json:"bug_id"`
+	CommentID string    `json:"comment_id"`
+	UserID    string    `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductName return title case product
+func (bug Bug) ProductName() string {
+	return strings.ToTitle(strings.Replace(bug.Product, "_", " ", -1))
+}
+
+// Text return the confirmation line for the issue, in the default locale
+func (issue Issue) Text() string {
+	return issue.TextIn(i18n.Default)
+}
+
+// TextIn return the confirmation of the issue in locale
+func (issue Issue) TextIn(locale string) string {
+	if issue.Key == "" {
+		return i18n.T(locale, "confirm.submitted", issue.Tracker)
+	}
+	return i18n.T(locale, "confirm.issue", issue.ID, issue.Key, issue.URL)
+}
+
+// NewID return a random bug ID
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AnonymousName is the reporter name of anonymous bugs
+const AnonymousName = "Anonymous"
+
+// AnonymousID return the ANONYMOUS_SALT keyed hash stored as the user ID of
+// anonymous bugs, stable per user so their reports can still be counted
+func AnonymousID(userID string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("ANONYMOUS_SALT")))
+	mac.Write([]byte(userID))
+	return "anon-" + hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// ReportedBy report whether userID reported the bug, anonymously or not
+func (bug Bug) ReportedBy(userID string) bool {
+	return bug.UserID == userID || (bug.Anonymous && bug.UserID == AnonymousID(userID))
+}
+
+// Checksum return a digest of the fields a reporter edits, telling whether an
+// edit started from the bug as it is now
+func (bug Bug) Checksum() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{bug.Summary, bug.Product, bug.Severity, bug.Details, strings.Join(bug.Tags, ",")}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ValidStatus report whether status is a known bug status
+func ValidStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags lowercase tags and join the words of each with dashes, as
+// tracker labels cannot hold spaces, dropping empty and repeated tags
+func NormalizeTags(tags []string) (normalized []string) {
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return
+}
+
+// Tagged report whether bug carries tag
+func (bug Bug) Tagged(tag string) bool {
+	for _, t := range bug.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
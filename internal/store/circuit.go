@@ -0,0 +1,98 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// circuitPrefix keys the circuit of a tracker, CIRCUIT#<tracker>. Circuits
+// are shared by every team as the trackers are
+const circuitPrefix = "CIRCUIT#"
+
+// Circuit is the run of consecutive failures of a tracker, OpenedAt is the
+// latest failure once the run reached the breaker's threshold
+type Circuit struct {
+	Name      string     `json:"name"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func circuitKey(name string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(circuitPrefix + name)},
+		"sk": {S: aws.String(metadata)},
+	}
+}
+
+// GetCircuit return the circuit of tracker name, a closed circuit when it
+// never failed
+func GetCircuit(name string) (circuit Circuit, err error) {
+	circuit.Name = name
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.GetItem(&dynamodb.GetItemInput{TableName: table(), Key: circuitKey(name)})
+	if err != nil || len(out.Item) == 0 {
+		return
+	}
+	err = dynamodbattribute.UnmarshalMap(out.Item, &circuit)
+	return
+}
+
+// FailCircuit count a failure of tracker name, opening its circuit (again)
+// once threshold failures ran in a row
+func FailCircuit(name string, threshold int) (circuit Circuit, err error) {
+	defer func() {
+		log.Printf("store.FailCircuit (%s/%d) - error: %v", name, circuit.Failures, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                table(),
+		Key:                      circuitKey(name),
+		UpdateExpression:         aws.String("SET #name = :name, updated_at = :now ADD failures :one"),
+		ExpressionAttributeNames: map[string]*string{"#name": aws.String("name")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":name": {S: aws.String(name)},
+			":now":  {S: aws.String(now.Format(time.RFC3339Nano))},
+			":one":  {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		return
+	}
+	if err = dynamodbattribute.UnmarshalMap(out.Attributes, &circuit); err != nil || circuit.Failures < threshold {
+		return
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       circuitKey(name),
+		UpdateExpression:          aws.String("SET opened_at = :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":now": {S: aws.String(now.Format(time.RFC3339Nano))}},
+	})
+	circuit.OpenedAt = &now
+	return
+}
+
+// ResetCircuit close the circuit of tracker name after a success
+func ResetCircuit(name string) (err error) {
+	defer func() {
+		log.Printf("store.ResetCircuit (%s) - error: %v", name, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{TableName: table(), Key: circuitKey(name)})
+	return
+}
@@ -0,0 +1,494 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanobug/internal/config"
+)
+
+// Kinds of config entries, keyed by kind and key within the team partition
+const (
+	KindProduct = "product"
+	KindChannel = "channel"
+	KindUser    = "user"
+	KindDraft   = "draft"
+	KindRole    = "role"
+	KindTag     = "tag"
+	KindBash    = "bash"
+	KindNotify  = "notify"
+
+	// KindSubscription prefixes the product, e.g. "subscription:pixel_kit"
+	KindSubscription = "subscription:"
+)
+
+// Product is a product offered in the report form, Trackers overrides the
+// env routing when set and Template pre-fills the details of its reports
+type Product struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"key"`
+	Label     string    `json:"label"`
+	Trackers  []string  `json:"trackers,omitempty"`
+	Template  string    `json:"template,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Channel maps a chat channel to the product reported from it, Hidden drops
+// the product select from the report form
+type Channel struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"key"`
+	Product   string    `json:"product"`
+	Hidden    bool      `json:"hidden"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Preference is the product and severity a user last reported with
+type Preference struct {
+	Kind      string    `json:"kind"`
+	UserID    string    `json:"key"`
+	Product   string    `json:"product"`
+	Severity  string    `json:"severity"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification modes of a user
+const (
+	// NotifyEvery sends a DM on every change, the default
+	NotifyEvery = "every"
+	// NotifyDigest holds the changes for a daily digest, Blockers are sent
+	// as they change
+	NotifyDigest = "digest"
+	// NotifyMute sends nothing
+	NotifyMute = "mute"
+)
+
+// UserDigest is how often users notified by digest get theirs
+const UserDigest = 24 * time.Hour
+
+// NotifyPreference is how a user is told of changes to the bugs they
+// reported, are CC'd on or are paged for: Mode unless Products overrides it
+// for the bug's product
+type NotifyPreference struct {
+	Kind      string            `json:"kind"`
+	UserID    string            `json:"key"`
+	Mode      string            `json:"mode"`
+	Products  map[string]string `json:"products,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ValidNotifyMode report whether mode is a notification mode
+func ValidNotifyMode(mode string) bool {
+	return mode == NotifyEvery || mode == NotifyDigest || mode == NotifyMute
+}
+
+// ModeOf return how the user is notified of bugs of product, NotifyEvery
+// unless set
+func (preference NotifyPreference) ModeOf(product string) string {
+	if mode, ok := preference.Products[product]; ok && ValidNotifyMode(mode) {
+		return mode
+	}
+	if ValidNotifyMode(preference.Mode) {
+		return preference.Mode
+	}
+	return NotifyEvery
+}
+
+// Subscription opts a channel into the notification feed of a product,
+// Digest batching its status changes into a digest posted that often
+type Subscription struct {
+	Kind      string        `json:"kind"`
+	ChannelID string        `json:"key"`
+	Product   string        `json:"product"`
+	Platform  string        `json:"platform"`
+	UserID    string        `json:"user_id"`
+	Digest    time.Duration `json:"digest,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Grant gives a chat user a role beyond reporter, see internal/authz
+type Grant struct {
+	Kind      string    `json:"kind"`
+	UserID    string    `json:"key"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Tag is a tag offered in the report form, reporters may add others
+type Tag struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"key"`
+	CreatedBy string    `json:"created_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Draft is a report form a user closed before submitting, Values holds the
+// field values by name, single values as one element lists
+type Draft struct {
+	Kind      string              `json:"kind"`
+	UserID    string              `json:"key"`
+	Values    map[string][]string `json:"values"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	TTL       int64               `json:"ttl"`
+}
+
+// Bash is a bug bash running in a channel, the bugs reported from it until
+// ExpiresAt are filed under the Epic issue
+type Bash struct {
+	Kind      string    `json:"kind"`
+	ChannelID string    `json:"key"`
+	Epic      string    `json:"epic"`
+	StartedBy string    `json:"started_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TTL       int64     `json:"ttl"`
+}
+
+// draftTTL is how long an abandoned draft is kept
+const draftTTL = 7 * 24 * time.Hour
+
+// ConfigEnabled report whether a table is deployed to keep config in
+func ConfigEnabled() bool {
+	return len(config.Get().Table) > 0
+}
+
+// configKey return the key of a config entry, a CONFIG# item of the team
+func (d Dynamo) configKey(kind, key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(teamPrefix + d.team())},
+		"sk": {S: aws.String(configPrefix + kind + "#" + key)},
+	}
+}
+
+// ListProducts return the configured products
+func (d Dynamo) ListProducts() (products []Product, err error) {
+	err = d.queryConfig(KindProduct, &products)
+	return
+}
+
+// GetProduct return the configured product value, ErrNotFound when absent
+func (d Dynamo) GetProduct(value string) (product Product, err error) {
+	err = d.getConfig(KindProduct, value, &product)
+	return
+}
+
+// PutProduct upsert product
+func (d Dynamo) PutProduct(product Product) (err error) {
+	defer func() {
+		log.Printf("store.PutProduct (%s/%s/%v) - error: %v", product.Value, product.Label, product.Trackers, err)
+	}()
+	product.Kind = KindProduct
+	product.UpdatedAt = time.Now()
+	return d.putConfig(product)
+}
+
+// DeleteProduct remove product value
+func (d Dynamo) DeleteProduct(value string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteProduct (%s) - error: %v", value, err)
+	}()
+	return d.deleteConfig(KindProduct, value)
+}
+
+// GetChannel return the product mapping of channel id, ErrNotFound when absent
+func (d Dynamo) GetChannel(id string) (channel Channel, err error) {
+	err = d.getConfig(KindChannel, id, &channel)
+	return
+}
+
+// PutChannel upsert the product mapping of a channel
+func (d Dynamo) PutChannel(channel Channel) (err error) {
+	defer func() {
+		log.Printf("store.PutChannel (%s/%s/%t) - error: %v", channel.ID, channel.Product, channel.Hidden, err)
+	}()
+	channel.Kind = KindChannel
+	channel.UpdatedAt = time.Now()
+	return d.putConfig(channel)
+}
+
+// DeleteChannel remove the product mapping of channel id
+func (d Dynamo) DeleteChannel(id string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteChannel (%s) - error: %v", id, err)
+	}()
+	return d.deleteConfig(KindChannel, id)
+}
+
+// GetPreference return the form preferences of userID, ErrNotFound when absent
+func (d Dynamo) GetPreference(userID string) (preference Preference, err error) {
+	err = d.getConfig(KindUser, userID, &preference)
+	return
+}
+
+// PutPreference upsert the form preferences of a user
+func (d Dynamo) PutPreference(preference Preference) (err error) {
+	defer func() {
+		log.Printf("store.PutPreference (%s/%s/%s) - error: %v", preference.UserID, preference.Product, preference.Severity, err)
+	}()
+	preference.Kind = KindUser
+	preference.UpdatedAt = time.Now()
+	return d.putConfig(preference)
+}
+
+// GetNotifyPreference return the notification preferences of userID,
+// ErrNotFound when absent
+func (d Dynamo) GetNotifyPreference(userID string) (preference NotifyPreference, err error) {
+	err = d.getConfig(KindNotify, userID, &preference)
+	return
+}
+
+// PutNotifyPreference upsert the notification preferences of a user
+func (d Dynamo) PutNotifyPreference(preference NotifyPreference) (err error) {
+	defer func() {
+		log.Printf("store.PutNotifyPreference (%s/%s/%v) - error: %v", preference.UserID, preference.Mode, preference.Products, err)
+	}()
+	preference.Kind = KindNotify
+	preference.UpdatedAt = time.Now()
+	return d.putConfig(preference)
+}
+
+// ListSubscriptions return the channels subscribed to product
+func (d Dynamo) ListSubscriptions(product string) (subscriptions []Subscription, err error) {
+	err = d.queryConfig(KindSubscription+product, &subscriptions)
+	return
+}
+
+// PutSubscription subscribe a channel to a product feed
+func (d Dynamo) PutSubscription(subscription Subscription) (err error) {
+	defer func() {
+		log.Printf("store.PutSubscription (%s/%s/%s) - error: %v", subscription.Product, subscription.ChannelID, subscription.Platform, err)
+	}()
+	subscription.Kind = KindSubscription + subscription.Product
+	subscription.UpdatedAt = time.Now()
+	return d.putConfig(subscription)
+}
+
+// GetSubscription return the subscription of channelID to product, ErrNotFound when absent
+func (d Dynamo) GetSubscription(product, channelID string) (subscription Subscription, err error) {
+	err = d.getConfig(KindSubscription+product, channelID, &subscription)
+	return
+}
+
+// DeleteSubscription unsubscribe channelID from the product feed
+func (d Dynamo) DeleteSubscription(product, channelID string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteSubscription (%s/%s) - error: %v", product, channelID, err)
+	}()
+	return d.deleteConfig(KindSubscription+product, channelID)
+}
+
+// ListGrants return every role grant
+func (d Dynamo) ListGrants() (grants []Grant, err error) {
+	err = d.queryConfig(KindRole, &grants)
+	return
+}
+
+// GetGrant return the role grant of userID, ErrNotFound when absent
+func (d Dynamo) GetGrant(userID string) (grant Grant, err error) {
+	err = d.getConfig(KindRole, userID, &grant)
+	return
+}
+
+// PutGrant upsert the role grant of a user
+func (d Dynamo) PutGrant(grant Grant) (err error) {
+	defer func() {
+		log.Printf("store.PutGrant (%s/%s/%s) - error: %v", grant.UserID, grant.Role, grant.GrantedBy, err)
+	}()
+	grant.Kind = KindRole
+	grant.UpdatedAt = time.Now()
+	return d.putConfig(grant)
+}
+
+// DeleteGrant remove the role grant of userID
+func (d Dynamo) DeleteGrant(userID string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteGrant (%s) - error: %v", userID, err)
+	}()
+	return d.deleteConfig(KindRole, userID)
+}
+
+// ListTags return the managed tags
+func (d Dynamo) ListTags() (tags []Tag, err error) {
+	err = d.queryConfig(KindTag, &tags)
+	return
+}
+
+// PutTag upsert a managed tag
+func (d Dynamo) PutTag(tag Tag) (err error) {
+	defer func() {
+		log.Printf("store.PutTag (%s) - error: %v", tag.Name, err)
+	}()
+	tag.Kind = KindTag
+	tag.UpdatedAt = time.Now()
+	return d.putConfig(tag)
+}
+
+// DeleteTag remove managed tag name
+func (d Dynamo) DeleteTag(name string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteTag (%s) - error: %v", name, err)
+	}()
+	return d.deleteConfig(KindTag, name)
+}
+
+// GetDraft return the saved draft of userID, ErrNotFound when absent
+func (d Dynamo) GetDraft(userID string) (draft Draft, err error) {
+	err = d.getConfig(KindDraft, userID, &draft)
+	return
+}
+
+// PutDraft upsert the draft of a user, expiring after a week
+func (d Dynamo) PutDraft(draft Draft) (err error) {
+	defer func() {
+		log.Printf("store.PutDraft (%s) - error: %v", draft.UserID, err)
+	}()
+	draft.Kind = KindDraft
+	draft.UpdatedAt = time.Now()
+	draft.TTL = draft.UpdatedAt.Add(draftTTL).Unix()
+	return d.putConfig(draft)
+}
+
+// DeleteDraft remove the draft of userID
+func (d Dynamo) DeleteDraft(userID string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteDraft (%s) - error: %v", userID, err)
+	}()
+	return d.deleteConfig(KindDraft, userID)
+}
+
+// GetBash return the bug bash running in channelID, ErrNotFound when absent
+// or over
+func (d Dynamo) GetBash(channelID string) (bash Bash, err error) {
+	if err = d.getConfig(KindBash, channelID, &bash); err == nil && time.Now().After(bash.ExpiresAt) {
+		return Bash{}, ErrNotFound
+	}
+	return
+}
+
+// PutBash upsert the bug bash of a channel, expiring with it
+func (d Dynamo) PutBash(bash Bash) (err error) {
+	defer func() {
+		log.Printf("store.PutBash (%s/%s) - error: %v", bash.ChannelID, bash.Epic, err)
+	}()
+	bash.Kind = KindBash
+	bash.UpdatedAt = time.Now()
+	bash.TTL = bash.ExpiresAt.Unix()
+	return d.putConfig(bash)
+}
+
+// DeleteBash end the bug bash of channelID
+func (d Dynamo) DeleteBash(channelID string) (err error) {
+	defer func() {
+		log.Printf("store.DeleteBash (%s) - error: %v", channelID, err)
+	}()
+	return d.deleteConfig(KindBash, channelID)
+}
+
+// queryConfig unmarshal every entry of kind into out, a pointer to a slice
+// queryConfig read the config entries of kind of the team, and those of its
+// org the team has no entry of its own for
+func (d Dynamo) queryConfig(kind string, out interface{}) (err error) {
+	items, err := queryConfigItems(teamPrefix+d.team(), kind)
+	if err != nil {
+		return
+	}
+	if d.inOrg() {
+		own := map[string]bool{}
+		for _, item := range items {
+			own[aws.StringValue(item["sk"].S)] = true
+		}
+		org, err := queryConfigItems(teamPrefix+d.Enterprise, kind)
+		if err != nil {
+			return err
+		}
+		for _, item := range org {
+			if !own[aws.StringValue(item["sk"].S)] {
+				items = append(items, item)
+			}
+		}
+	}
+	return dynamodbattribute.UnmarshalListOfMaps(items, out)
+}
+
+// queryConfigItems return the config items of kind in partition pk
+func queryConfigItems(pk, kind string) (items []map[string]*dynamodb.AttributeValue, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	err = srv.QueryPages(&dynamodb.QueryInput{
+		TableName:              table(),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":     {S: aws.String(pk)},
+			":prefix": {S: aws.String(configPrefix + kind + "#")},
+		},
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return
+}
+
+// inOrg report whether the team of d is a workspace of an Enterprise Grid
+// org, whose config it falls back to
+func (d Dynamo) inOrg() bool {
+	return len(d.Enterprise) > 0 && d.Enterprise != d.team()
+}
+
+func (d Dynamo) getConfig(kind, key string, out interface{}) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	item, err := srv.GetItem(&dynamodb.GetItemInput{
+		TableName: table(),
+		Key:       d.configKey(kind, key),
+	})
+	if err != nil {
+		return
+	}
+	if len(item.Item) == 0 {
+		if d.inOrg() {
+			return Dynamo{Team: d.Enterprise}.getConfig(kind, key, out)
+		}
+		return ErrNotFound
+	}
+	return dynamodbattribute.UnmarshalMap(item.Item, out)
+}
+
+func (d Dynamo) putConfig(in interface{}) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	item, err := dynamodbattribute.MarshalMap(in)
+	if err != nil {
+		return
+	}
+	for name, value := range d.configKey(aws.StringValue(item["kind"].S), aws.StringValue(item["key"].S)) {
+		item[name] = value
+	}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{
+		TableName: table(),
+		Item:      item,
+	})
+	return
+}
+
+func (d Dynamo) deleteConfig(kind, key string) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: table(),
+		Key:       d.configKey(kind, key),
+	})
+	return
+}
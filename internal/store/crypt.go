@@ -0,0 +1,219 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// Encrypted is an envelope encrypted field, AES-256-GCM sealed under a data
+// key which is itself kept encrypted by the DETAILS_KMS_KEY_ID KMS key
+type Encrypted struct {
+	// Version 0 is a data key per bug, bound to it by the KMS encryption
+	// context, sharedKey a data key sealing the details of many bugs, each
+	// bound to its bug by the GCM additional data
+	Version    int    `json:"version,omitempty"`
+	Key        []byte `json:"key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// sharedKey is the Version of details sealed under a cached data key
+const sharedKey = 1
+
+// dataKeyTTL is how long a container seals details under the same data key,
+// and keeps the data keys it decrypted, rather than calling KMS per bug
+const dataKeyTTL = 5 * time.Minute
+
+// maxDataKeys bounds the decrypted data keys kept, bugs stored before keys
+// were shared each have their own
+const maxDataKeys = 1000
+
+// sharedContext is the KMS encryption context of shared data keys
+var sharedContext = map[string]*string{"purpose": aws.String("kanobug details")}
+
+type dataKey struct {
+	plaintext  []byte
+	ciphertext []byte
+	at         time.Time
+}
+
+var (
+	keysMu sync.Mutex
+	// sealing is the data key new details are sealed under, of keyID
+	sealing      *dataKey
+	sealingKeyID string
+	// opened are the decrypted data keys by context and encrypted key
+	opened = map[string]dataKey{}
+)
+
+// newKMS return the KMS client, replaced by the tests
+var newKMS = func() (kmsiface.KMSAPI, error) {
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(sess), nil
+}
+
+// encryptionContext binds the data key to the bug it encrypts, for details
+// sealed before keys were shared
+func encryptionContext(bug Bug) map[string]*string {
+	return map[string]*string{"bug_id": aws.String(bug.ID)}
+}
+
+// encryptDetails replace the details of bug with their envelope encryption
+// when DETAILS_KMS_KEY_ID is set
+func encryptDetails(bug *Bug) (err error) {
+	keyID := os.Getenv("DETAILS_KMS_KEY_ID")
+	if len(keyID) == 0 || len(bug.Details) == 0 {
+		return
+	}
+	key, err := sealingKey(keyID)
+	if err != nil {
+		return
+	}
+	gcm, err := newGCM(key.plaintext)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	bug.DetailsEncrypted = &Encrypted{
+		Version:    sharedKey,
+		Key:        key.ciphertext,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, []byte(bug.Details), []byte(bug.ID)),
+	}
+	bug.Details = ""
+	return
+}
+
+// sealingKey return the data key of keyID details are sealed under,
+// generating one once the current is older than dataKeyTTL
+func sealingKey(keyID string) (key dataKey, err error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if sealing != nil && sealingKeyID == keyID && time.Since(sealing.at) < dataKeyTTL {
+		return *sealing, nil
+	}
+	srv, err := newKMS()
+	if err != nil {
+		return
+	}
+	out, err := srv.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyID),
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: sharedContext,
+	})
+	if err != nil {
+		return
+	}
+	key = dataKey{plaintext: out.Plaintext, ciphertext: out.CiphertextBlob, at: time.Now()}
+	sealing, sealingKeyID = &key, keyID
+	return
+}
+
+// decryptDetails restore the details of an envelope encrypted bug
+func decryptDetails(bug *Bug) (err error) {
+	if bug.DetailsEncrypted == nil {
+		return
+	}
+	context, additional := encryptionContext(*bug), []byte(nil)
+	if bug.DetailsEncrypted.Version == sharedKey {
+		context, additional = sharedContext, []byte(bug.ID)
+	}
+	key, err := openKey(bug.DetailsEncrypted.Key, context)
+	if err != nil {
+		return
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	details, err := gcm.Open(nil, bug.DetailsEncrypted.Nonce, bug.DetailsEncrypted.Ciphertext, additional)
+	if err != nil {
+		return
+	}
+	bug.Details, bug.DetailsEncrypted, bug.EncryptedAtRest = string(details), nil, true
+	return
+}
+
+// openKey return the plaintext of encrypted data key ciphertext, decrypting
+// it with KMS unless it was within dataKeyTTL
+func openKey(ciphertext []byte, context map[string]*string) (plaintext []byte, err error) {
+	cacheKey := string(ciphertext)
+	for k, v := range context {
+		cacheKey += "\x00" + k + "=" + aws.StringValue(v)
+	}
+	keysMu.Lock()
+	key, ok := opened[cacheKey]
+	keysMu.Unlock()
+	if ok && time.Since(key.at) < dataKeyTTL {
+		return key.plaintext, nil
+	}
+	srv, err := newKMS()
+	if err != nil {
+		return
+	}
+	out, err := srv.Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: context,
+	})
+	if err != nil {
+		return
+	}
+	keysMu.Lock()
+	if len(opened) >= maxDataKeys {
+		opened = map[string]dataKey{}
+	}
+	opened[cacheKey] = dataKey{plaintext: out.Plaintext, at: time.Now()}
+	keysMu.Unlock()
+	return out.Plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// unmarshalBug unmarshal a bug item, decrypting its details
+func unmarshalBug(item map[string]*dynamodb.AttributeValue, bug *Bug) (err error) {
+	if err = dynamodbattribute.UnmarshalMap(item, bug); err != nil {
+		return
+	}
+	return decryptDetails(bug)
+}
+
+// unmarshalBugs unmarshal bug items, decrypting their details. A bug whose
+// details fail to decrypt is kept without them, still encrypted, rather
+// than failing the whole list
+func unmarshalBugs(items []map[string]*dynamodb.AttributeValue, bugs *[]Bug) (err error) {
+	if err = dynamodbattribute.UnmarshalListOfMaps(items, bugs); err != nil {
+		return
+	}
+	for i := range *bugs {
+		bug := &(*bugs)[i]
+		if decryptErr := decryptDetails(bug); decryptErr != nil {
+			log.Printf("store.unmarshalBugs (%s) - decrypt error: %v", bug.ID, decryptErr)
+		}
+	}
+	return
+}
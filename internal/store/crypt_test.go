@@ -0,0 +1,176 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// fakeKMS wraps data keys by keeping them, keyed by a random blob and
+// bound to their encryption context like KMS
+type fakeKMS struct {
+	kmsiface.KMSAPI
+	keys      map[string]fakeKey
+	generated int
+	decrypted int
+}
+
+type fakeKey struct {
+	plaintext []byte
+	context   map[string]string
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	srv := &fakeKMS{keys: map[string]fakeKey{}}
+	previous := newKMS
+	newKMS = func() (kmsiface.KMSAPI, error) { return srv, nil }
+	t.Cleanup(func() {
+		newKMS = previous
+		sealing, sealingKeyID, opened = nil, "", map[string]dataKey{}
+	})
+	sealing, sealingKeyID, opened = nil, "", map[string]dataKey{}
+	return srv
+}
+
+func (srv *fakeKMS) GenerateDataKey(in *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	srv.generated++
+	plaintext, blob := make([]byte, 32), make([]byte, 16)
+	rand.Read(plaintext)
+	rand.Read(blob)
+	srv.keys[string(blob)] = fakeKey{plaintext, aws.StringValueMap(in.EncryptionContext)}
+	return &kms.GenerateDataKeyOutput{Plaintext: plaintext, CiphertextBlob: blob}, nil
+}
+
+func (srv *fakeKMS) Decrypt(in *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	srv.decrypted++
+	key, ok := srv.keys[string(in.CiphertextBlob)]
+	if !ok || len(key.context) != len(in.EncryptionContext) {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	for k, v := range key.context {
+		if aws.StringValue(in.EncryptionContext[k]) != v {
+			return nil, errors.New("InvalidCiphertextException")
+		}
+	}
+	return &kms.DecryptOutput{Plaintext: key.plaintext}, nil
+}
+
+func TestEncryptDetails(t *testing.T) {
+	srv := newFakeKMS(t)
+	t.Setenv("DETAILS_KMS_KEY_ID", "alias/kanobug")
+	bugs := []Bug{{ID: "a", Details: "serial 123"}, {ID: "b", Details: "log line"}, {ID: "c"}}
+	for i := range bugs {
+		if err := encryptDetails(&bugs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if srv.generated != 1 {
+		t.Errorf("generated %d data keys, want 1 shared by the container", srv.generated)
+	}
+	if bugs[0].Details != "" || bugs[0].DetailsEncrypted == nil || bugs[0].DetailsEncrypted.Version != sharedKey {
+		t.Fatalf("bug a = %+v, want its details encrypted", bugs[0])
+	}
+	if bytes.Contains(bugs[0].DetailsEncrypted.Ciphertext, []byte("serial 123")) {
+		t.Error("ciphertext contains the details")
+	}
+	if bugs[2].DetailsEncrypted != nil {
+		t.Error("empty details were encrypted")
+	}
+	for _, i := range []int{0, 1} {
+		if err := decryptDetails(&bugs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bugs[0].Details != "serial 123" || bugs[1].Details != "log line" || !bugs[0].EncryptedAtRest {
+		t.Errorf("decrypted %+v", bugs[:2])
+	}
+	if srv.decrypted != 1 {
+		t.Errorf("decrypted %d data keys, want 1 cached", srv.decrypted)
+	}
+}
+
+func TestEncryptDetailsUnset(t *testing.T) {
+	newFakeKMS(t)
+	t.Setenv("DETAILS_KMS_KEY_ID", "")
+	bug := Bug{ID: "a", Details: "plain"}
+	if err := encryptDetails(&bug); err != nil || bug.Details != "plain" || bug.DetailsEncrypted != nil {
+		t.Errorf("encryptDetails() = %v, bug %+v, want it untouched", err, bug)
+	}
+}
+
+func TestDecryptDetailsSwapped(t *testing.T) {
+	newFakeKMS(t)
+	t.Setenv("DETAILS_KMS_KEY_ID", "alias/kanobug")
+	a, b := Bug{ID: "a", Details: "secret of a"}, Bug{ID: "b", Details: "b"}
+	if err := encryptDetails(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptDetails(&b); err != nil {
+		t.Fatal(err)
+	}
+	b.DetailsEncrypted = a.DetailsEncrypted
+	if err := decryptDetails(&b); err == nil {
+		t.Errorf("details of a opened as those of b: %q", b.Details)
+	}
+}
+
+func TestDecryptDetailsPerBugKey(t *testing.T) {
+	srv := newFakeKMS(t)
+	out, _ := srv.GenerateDataKey(&kms.GenerateDataKeyInput{EncryptionContext: encryptionContext(Bug{ID: "old"})})
+	gcm, err := newGCM(out.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	bug := Bug{ID: "old", DetailsEncrypted: &Encrypted{
+		Key:        out.CiphertextBlob,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, []byte("stored before keys were shared"), nil),
+	}}
+	if err = decryptDetails(&bug); err != nil || bug.Details != "stored before keys were shared" {
+		t.Errorf("decryptDetails() = %v, details %q", err, bug.Details)
+	}
+
+	moved := Bug{ID: "other", DetailsEncrypted: &Encrypted{Key: out.CiphertextBlob, Nonce: nonce, Ciphertext: []byte("x")}}
+	if err = decryptDetails(&moved); err == nil {
+		t.Error("a per bug key opened for another bug")
+	}
+}
+
+func TestUnmarshalBugsUndecryptable(t *testing.T) {
+	newFakeKMS(t)
+	t.Setenv("DETAILS_KMS_KEY_ID", "alias/kanobug")
+	good, bad := Bug{ID: "good", Details: "fine"}, Bug{ID: "bad", Details: "lost"}
+	if err := encryptDetails(&good); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptDetails(&bad); err != nil {
+		t.Fatal(err)
+	}
+	bad.DetailsEncrypted.Key = []byte("revoked")
+	var items []map[string]*dynamodb.AttributeValue
+	for _, bug := range []Bug{good, bad} {
+		item, err := dynamodbattribute.MarshalMap(bug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	var bugs []Bug
+	if err := unmarshalBugs(items, &bugs); err != nil {
+		t.Fatalf("unmarshalBugs() = %v, want the list despite one bad item", err)
+	}
+	if len(bugs) != 2 || bugs[0].Details != "fine" {
+		t.Fatalf("bugs = %+v", bugs)
+	}
+	if bugs[1].Details != "" || bugs[1].DetailsEncrypted == nil {
+		t.Errorf("undecryptable bug = %+v, want it kept encrypted", bugs[1])
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// dedupePrefix partitions the claims of payloads being or already handled,
+// DEDUPE#<scope>#<key>, a partition each so claims spread over the table
+const dedupePrefix = "DEDUPE#"
+
+// dedupeTTL is how long a handled payload stays claimed, well past the 3
+// retries Slack makes within minutes of a delivery it saw fail
+const dedupeTTL = time.Hour
+
+// claimLease is how long a claim in progress holds off redeliveries, past
+// the Lambda timeout, so once it is up the handler that claimed it died and
+// a redelivery is handled
+const claimLease = time.Minute
+
+// States of a claim
+const (
+	claimInProgress = "in_progress"
+	claimDone       = "done"
+)
+
+// PayloadKey return the key of payload to Claim it by, its SHA-256
+func PayloadKey(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+func claimKey(scope, key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(dedupePrefix + scope + "#" + key)},
+		"sk": {S: aws.String("CLAIM")},
+	}
+}
+
+// Claim record that scope, e.g. a handler, is handling the payload keyed
+// key: claimed is false when an earlier delivery of it was handled within
+// dedupeTTL, or is being handled within claimLease. The claimer calls Done
+// once it handled the payload, or Release when it failed to
+func Claim(scope, key string) (claimed bool, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("store.Claim (%s/%s) - error: %v", scope, key, err)
+		}
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	item := claimKey(scope, key)
+	item["state"] = &dynamodb.AttributeValue{S: aws.String(claimInProgress)}
+	item["claimed_at"] = &dynamodb.AttributeValue{S: aws.String(now.UTC().Format(time.RFC3339Nano))}
+	item["lease_until"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(claimLease).Unix(), 10))}
+	item["ttl"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(dedupeTTL).Unix(), 10))}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{
+		TableName:           table(),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk) OR #ttl < :now OR (#state = :in_progress AND lease_until < :now)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl":   aws.String("ttl"),
+			"#state": aws.String("state"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":         {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+			":in_progress": {S: aws.String(claimInProgress)},
+		},
+	})
+	if conflict(err) == ErrConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Done record that the payload scope claimed as key was handled, so its
+// redeliveries are skipped for dedupeTTL
+func Done(scope, key string) (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("store.Done (%s/%s) - error: %v", scope, key, err)
+		}
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                table(),
+		Key:                      claimKey(scope, key),
+		UpdateExpression:         aws.String("SET #state = :done, #ttl = :ttl REMOVE lease_until"),
+		ConditionExpression:      aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames: map[string]*string{"#ttl": aws.String("ttl"), "#state": aws.String("state")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":done": {S: aws.String(claimDone)},
+			":ttl":  {N: aws.String(strconv.FormatInt(time.Now().Add(dedupeTTL).Unix(), 10))},
+		},
+	})
+	return
+}
+
+// Release give up the claim of scope on the payload keyed key, which failed
+// to be handled, so its next redelivery is
+func Release(scope, key string) (err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("store.Release (%s/%s) - error: %v", scope, key, err)
+		}
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:                 table(),
+		Key:                       claimKey(scope, key),
+		ConditionExpression:       aws.String("#state = :in_progress"),
+		ExpressionAttributeNames:  map[string]*string{"#state": aws.String("state")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":in_progress": {S: aws.String(claimInProgress)}},
+	})
+	if conflict(err) == ErrConflict {
+		err = nil
+	}
+	return
+}
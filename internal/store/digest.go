@@ -0,0 +1,135 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// digestPartition keeps the status changes waiting for the digest of their
+// channel, <team>#<channel>#<time>/<bug id>. It is shared by every team so
+// the digest schedule reads a single partition
+const digestPartition = "DIGEST"
+
+// digestTTL is how long a status change waits at most, should the digest
+// schedule stop
+const digestTTL = 48 * time.Hour
+
+// DigestEntry is a status change of a bug held for the digest of a
+// subscribed channel, or of a user when User is set, posted once Interval
+// has passed since the oldest
+type DigestEntry struct {
+	Key        string        `json:"sk"`
+	TeamID     string        `json:"team_id"`
+	ChannelID  string        `json:"channel_id"`
+	User       bool          `json:"user,omitempty"`
+	Platform   string        `json:"platform"`
+	Interval   time.Duration `json:"interval"`
+	BugID      string        `json:"bug_id"`
+	Product    string        `json:"product"`
+	Summary    string        `json:"summary"`
+	Status     string        `json:"status"`
+	Resolution string        `json:"resolution,omitempty"`
+	At         time.Time     `json:"at"`
+	TTL        int64         `json:"ttl"`
+}
+
+// AddDigest hold entry for the digest of its channel
+func AddDigest(entry DigestEntry) (err error) {
+	defer func() {
+		log.Printf("store.AddDigest (%s/%s/%s) - error: %v", entry.TeamID, entry.ChannelID, entry.BugID, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	entry.Key = entry.TeamID + "#" + entry.ChannelID + "#" + entry.At.UTC().Format(time.RFC3339Nano) + "/" + entry.BugID
+	entry.TTL = entry.At.Add(digestTTL).Unix()
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return
+	}
+	item["pk"] = &dynamodb.AttributeValue{S: aws.String(digestPartition)}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{TableName: table(), Item: item})
+	return
+}
+
+// HoldNotification apply the notification preferences of userID for the
+// product of bug to a DM about it at at: held reports whether it is not to be
+// sent, held for their daily digest, Blockers excepted, or dropped as muted.
+// A change that could not be held is sent
+func HoldNotification(userID string, bug Bug, at time.Time) (mode string, held bool) {
+	var preference NotifyPreference
+	if ConfigEnabled() {
+		preference, _ = GetNotifyPreference(userID)
+	}
+	switch mode = preference.ModeOf(bug.Product); {
+	case mode == NotifyMute:
+		return mode, true
+	case mode == NotifyDigest && bug.Severity != SeverityBlocker:
+		err := AddDigest(DigestEntry{
+			TeamID:     bug.TeamID,
+			ChannelID:  userID,
+			User:       true,
+			Platform:   "slack",
+			Interval:   UserDigest,
+			BugID:      bug.ID,
+			Product:    bug.ProductName(),
+			Summary:    bug.Summary,
+			Status:     bug.Status,
+			Resolution: bug.Resolution,
+			At:         at,
+		})
+		return mode, err == nil
+	}
+	return mode, false
+}
+
+// PendingDigests return the entries held for every channel, oldest first
+// within a channel
+func PendingDigests() (entries []DigestEntry, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	err = srv.QueryPages(&dynamodb.QueryInput{
+		TableName:                 table(),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":pk": {S: aws.String(digestPartition)}},
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &entries)
+	return
+}
+
+// DeleteDigests remove entries once their digest was posted
+func DeleteDigests(entries []DigestEntry) (err error) {
+	defer func() {
+		log.Printf("store.DeleteDigests (%d) - error: %v", len(entries), err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if _, err = srv.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: table(),
+			Key: map[string]*dynamodb.AttributeValue{
+				"pk": {S: aws.String(digestPartition)},
+				"sk": {S: aws.String(entry.Key)},
+			},
+		}); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// installPartition keeps the Slack installs of every workspace and
+// Enterprise Grid org, keyed by the team ID of a workspace install and the
+// enterprise ID of an org-wide one
+const installPartition = "INSTALL"
+
+// installationTTL is how long a container keeps the install it looked up,
+// a reinstall reaching every container within it
+const installationTTL = 5 * time.Minute
+
+// Installation is kanobug installed in a Slack workspace, or in every
+// workspace of an Enterprise Grid org when OrgWide, with the bot token its
+// Slack calls are made with
+type Installation struct {
+	Key          string    `json:"sk"`
+	EnterpriseID string    `json:"enterprise_id,omitempty"`
+	TeamID       string    `json:"team_id,omitempty"`
+	Name         string    `json:"name"`
+	OrgWide      bool      `json:"org_wide,omitempty"`
+	BotUserID    string    `json:"bot_user_id"`
+	BotToken     string    `json:"bot_token"`
+	Scope        string    `json:"scope"`
+	InstalledBy  string    `json:"installed_by"`
+	InstalledAt  time.Time `json:"installed_at"`
+}
+
+// installations are the installs looked up by this container, by key, a
+// missing install too
+var (
+	installations   = map[string]cachedInstallation{}
+	installationsMu sync.Mutex
+)
+
+type cachedInstallation struct {
+	installation Installation
+	err          error
+	at           time.Time
+}
+
+// PutInstallation store installation, replacing the previous install of its
+// workspace or org
+func PutInstallation(installation Installation) (err error) {
+	installation.Key = installation.TeamID
+	if installation.OrgWide {
+		installation.Key = installation.EnterpriseID
+	}
+	defer func() {
+		log.Printf("store.PutInstallation (%s, org wide: %t) - error: %v", installation.Key, installation.OrgWide, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	item, err := dynamodbattribute.MarshalMap(installation)
+	if err != nil {
+		return
+	}
+	item["pk"] = &dynamodb.AttributeValue{S: aws.String(installPartition)}
+	if _, err = srv.PutItem(&dynamodb.PutItemInput{TableName: table(), Item: item}); err != nil {
+		return
+	}
+	installationsMu.Lock()
+	installations[installation.Key] = cachedInstallation{installation: installation, at: time.Now()}
+	installationsMu.Unlock()
+	return
+}
+
+// FindInstallation return the install serving workspace team of org
+// enterprise: the workspace's own, else the org-wide install of its org
+func FindInstallation(enterprise, team string) (installation Installation, err error) {
+	err = ErrNotFound
+	for _, key := range []string{team, enterprise} {
+		if len(key) == 0 {
+			continue
+		}
+		if installation, err = getInstallation(key); err != ErrNotFound {
+			return
+		}
+	}
+	return
+}
+
+// getInstallation return the install keyed key, from the container's cache
+// when looked up within installationTTL
+func getInstallation(key string) (installation Installation, err error) {
+	installationsMu.Lock()
+	cached, ok := installations[key]
+	installationsMu.Unlock()
+	if ok && time.Since(cached.at) < installationTTL {
+		return cached.installation, cached.err
+	}
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.GetItem(&dynamodb.GetItemInput{
+		TableName: table(),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {S: aws.String(installPartition)},
+			"sk": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		log.Printf("store.getInstallation (%s) - error: %v", key, err)
+		return
+	}
+	if len(out.Item) == 0 {
+		err = ErrNotFound
+	} else {
+		err = dynamodbattribute.UnmarshalMap(out.Item, &installation)
+	}
+	installationsMu.Lock()
+	installations[key] = cachedInstallation{installation: installation, err: err, at: time.Now()}
+	installationsMu.Unlock()
+	return
+}
+
+// UseWorkspace point the package functions at the repository of workspace
+// team of Enterprise Grid org enterprise, like UseTeam, and make its install's
+// bot token authorize the Slack calls of the invocation. Workspaces without an
+// install of their own or of their org use SLACK_ACCESS_TOKEN
+func UseWorkspace(enterprise, team string) {
+	Default = ForWorkspace(enterprise, team)
+	outbound.UseToken("")
+	if !ConfigEnabled() || (len(enterprise) == 0 && len(team) == 0) {
+		return
+	}
+	if installation, err := FindInstallation(enterprise, team); err == nil {
+		outbound.UseToken(installation.BotToken)
+	}
+}
@@ -0,0 +1,364 @@
+//go:build integration
+// +build integration
+
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// The integration tests run against DynamoDB Local or LocalStack, at
+// DYNAMODB_ENDPOINT or else http://localhost:8000, in a table of their own
+// made like DataTable of serverless.yml and deleted once done:
+//
+//	docker run -d -p 8000:8000 amazon/dynamodb-local
+//	go test -tags integration ./internal/store/
+func TestMain(m *testing.M) {
+	endpoint, err := url.Parse(os.Getenv("DYNAMODB_ENDPOINT"))
+	if err != nil || len(endpoint.Host) == 0 {
+		endpoint = &url.URL{Scheme: "http", Host: "localhost:8000"}
+	}
+	// the store keeps calling AWS, the emulator answering in its place
+	http.DefaultTransport = emulator{endpoint: endpoint, next: http.DefaultTransport}
+	for name, value := range map[string]string{
+		"REGION":                "eu-west-1",
+		"TABLE_NAME":            fmt.Sprintf("kanobug-integration-%d", time.Now().UnixNano()),
+		"AWS_ACCESS_KEY_ID":     "local",
+		"AWS_SECRET_ACCESS_KEY": "local",
+	} {
+		os.Setenv(name, value)
+	}
+	if err := createTable(); err != nil {
+		fmt.Fprintf(os.Stderr, "store integration - create table at %s: %v\n", endpoint, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	if srv, err := GetDB(); err == nil {
+		_, _ = srv.DeleteTable(&dynamodb.DeleteTableInput{TableName: table()})
+	}
+	os.Exit(code)
+}
+
+// emulator send the requests to AWS to the emulator at endpoint instead
+type emulator struct {
+	endpoint *url.URL
+	next     http.RoundTripper
+}
+
+// RoundTrip send req to the emulator when for AWS
+func (e emulator) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Hostname(), ".amazonaws.com") {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host, req.Host = e.endpoint.Scheme, e.endpoint.Host, e.endpoint.Host
+	}
+	return e.next.RoundTrip(req)
+}
+
+// createTable create the single table with the key schema and indexes of
+// DataTable
+func createTable() error {
+	srv, err := GetDB()
+	if err != nil {
+		return err
+	}
+	var attributes []*dynamodb.AttributeDefinition
+	for _, name := range []string{"pk", "sk", "gsi1pk", "gsi1sk", "gsi2pk", "gsi2sk", "gsi3pk", "gsi3sk", "gsi4pk"} {
+		attributes = append(attributes, &dynamodb.AttributeDefinition{AttributeName: aws.String(name), AttributeType: aws.String("S")})
+	}
+	keys := func(hash, rng string) []*dynamodb.KeySchemaElement {
+		schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(hash), KeyType: aws.String("HASH")}}
+		if len(rng) > 0 {
+			schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(rng), KeyType: aws.String("RANGE")})
+		}
+		return schema
+	}
+	index := func(name, hash, rng string) *dynamodb.GlobalSecondaryIndex {
+		return &dynamodb.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  keys(hash, rng),
+			Projection: &dynamodb.Projection{ProjectionType: aws.String("ALL")},
+		}
+	}
+	if _, err = srv.CreateTable(&dynamodb.CreateTableInput{
+		TableName:            table(),
+		AttributeDefinitions: attributes,
+		KeySchema:            keys("pk", "sk"),
+		BillingMode:          aws.String(dynamodb.BillingModePayPerRequest),
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			index(UserIndex, "gsi1pk", "gsi1sk"),
+			index(ProductIndex, "gsi2pk", "gsi2sk"),
+			index(StatusIndex, "gsi3pk", "gsi3sk"),
+			index(IssueKeyIndex, "gsi4pk", ""),
+		},
+	}); err != nil {
+		return err
+	}
+	return srv.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: table()})
+}
+
+// newBug return a bug of team reported by user for product, created at
+func newBug(id, user, product string, at time.Time) Bug {
+	return Bug{
+		ID:        id,
+		UserID:    user,
+		UserName:  user,
+		Summary:   "Checkout button does nothing " + id,
+		Product:   product,
+		Severity:  "major",
+		Status:    StatusNew,
+		Details:   "Clicking Pay shows a spinner forever",
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+}
+
+// item return the raw METADATA item of bug id of team
+func item(t *testing.T, team, id string) map[string]*dynamodb.AttributeValue {
+	t.Helper()
+	srv, err := GetDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := srv.GetItem(&dynamodb.GetItemInput{TableName: table(), Key: Dynamo{Team: team}.key(id)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Item
+}
+
+func TestIntegrationPutGetBug(t *testing.T) {
+	repo := Dynamo{Team: "TPUT"}
+	bug := newBug("put1", "U1", "web_app", time.Now().UTC())
+	if err := repo.PutBug(bug); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetBug(bug.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Summary != bug.Summary || got.TeamID != "TPUT" || got.Version != 1 || !got.CreatedAt.Equal(bug.CreatedAt) {
+		t.Errorf("GetBug() = %+v", got)
+	}
+	if err = repo.PutBug(bug); err != ErrConflict {
+		t.Errorf("PutBug() of an existing bug error = %v, want ErrConflict", err)
+	}
+	if _, err = (Dynamo{Team: "TOTHER"}).GetBug(bug.ID); err != ErrNotFound {
+		t.Errorf("GetBug() of another team error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestIntegrationListBugs(t *testing.T) {
+	repo := Dynamo{Team: "TLIST"}
+	start := time.Now().UTC().Add(-time.Hour)
+	bugs := []Bug{
+		newBug("list1", "U1", "web_app", start),
+		newBug("list2", "U1", "ios", start.Add(time.Minute)),
+		newBug("list3", "U2", "web_app", start.Add(2*time.Minute)),
+	}
+	for _, bug := range bugs {
+		if err := repo.PutBug(bug); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"by user newest first", Filter{UserID: "U1"}, []string{"list2", "list1"}},
+		{"by product newest first", Filter{Product: "web_app"}, []string{"list3", "list1"}},
+		{"by user and product", Filter{UserID: "U1", Product: "web_app"}, []string{"list1"}},
+		{"by status", Filter{Status: StatusNew, Since: start.Add(time.Minute)}, []string{"list3", "list2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := repo.ListBugs(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, bug := range got {
+				ids = append(ids, bug.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("ListBugs(%+v) = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrationFindByIssueKey(t *testing.T) {
+	repo := Dynamo{Team: "TFIND"}
+	bug := newBug("find1", "U1", "web_app", time.Now().UTC())
+	if err := repo.PutBug(bug); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetIssues(bug, []Issue{{Tracker: "jira", ID: "10234", Key: "WEB-42"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.FindBug("web-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != bug.ID || got.Status != StatusFiled || got.Version != 2 {
+		t.Errorf("FindBug() = %s, %s, version %d", got.ID, got.Status, got.Version)
+	}
+}
+
+func TestIntegrationVersionConflict(t *testing.T) {
+	repo := Dynamo{Team: "TVERSION"}
+	bug := newBug("version1", "U1", "web_app", time.Now().UTC())
+	if err := repo.PutBug(bug); err != nil {
+		t.Fatal(err)
+	}
+	read, err := repo.GetBug(bug.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read.Summary = "Edited"
+	updated, err := repo.UpdateBug(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Summary != "Edited" || updated.Version != read.Version+1 {
+		t.Errorf("UpdateBug() = %q, version %d", updated.Summary, updated.Version)
+	}
+	// read before the edit, so stale
+	if _, err = repo.UpdateStatus(read, StatusClosed, ResolutionFixed, "U2"); err != ErrConflict {
+		t.Errorf("UpdateStatus() of a stale bug error = %v, want ErrConflict", err)
+	}
+	closed, err := repo.UpdateStatus(updated, StatusClosed, ResolutionFixed, "U2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.Status != StatusClosed || len(closed.History) == 0 {
+		t.Errorf("UpdateStatus() = %s, history %d", closed.Status, len(closed.History))
+	}
+}
+
+func TestIntegrationLinkAndMerge(t *testing.T) {
+	repo := Dynamo{Team: "TMERGE"}
+	at := time.Now().UTC()
+	for _, id := range []string{"merge1", "merge2", "merge3"} {
+		if err := repo.PutBug(newBug(id, "U1", "web_app", at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original, err := repo.GetBug("merge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both read original before either linked to it
+	for _, id := range []string{"merge2", "merge3"} {
+		if err = repo.LinkDuplicate(Bug{ID: id}, original); err != nil {
+			t.Fatal(err)
+		}
+	}
+	linked, err := repo.GetBug("merge1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(linked.Similar) != "[merge2 merge3]" || linked.Version != original.Version+2 {
+		t.Errorf("LinkDuplicate() similar = %v, version %d", linked.Similar, linked.Version)
+	}
+
+	duplicate, err := repo.GetBug("merge2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// original is stale since the links
+	if err = repo.Merge(duplicate, original, []string{"U2"}); err != ErrConflict {
+		t.Errorf("Merge() into a stale bug error = %v, want ErrConflict", err)
+	}
+	if err = repo.Merge(duplicate, linked, []string{"U2"}); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := repo.GetBug("merge2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.MergedInto != "merge1" {
+		t.Errorf("Merge() merged_into = %q", merged.MergedInto)
+	}
+}
+
+func TestIntegrationRetention(t *testing.T) {
+	repo := Dynamo{Team: "TRETENTION"}
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := repo.PutBug(newBug("kept", "U1", "web_app", at)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := item(t, "TRETENTION", "kept")["ttl"]; ok {
+		t.Error("PutBug() without BUG_RETENTION set a ttl")
+	}
+
+	t.Setenv("BUG_RETENTION", "720h")
+	if err := repo.PutBug(newBug("expiring", "U1", "web_app", at)); err != nil {
+		t.Fatal(err)
+	}
+	want := strconv.FormatInt(at.Add(720*time.Hour).Unix(), 10)
+	if ttl := item(t, "TRETENTION", "expiring")["ttl"]; ttl == nil || aws.StringValue(ttl.N) != want {
+		t.Errorf("PutBug() ttl = %v, want %s", ttl, want)
+	}
+	if _, err := ApplyRetention(false); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := item(t, "TRETENTION", "kept")["ttl"]; ttl == nil || aws.StringValue(ttl.N) != want {
+		t.Errorf("ApplyRetention() ttl = %v, want %s", ttl, want)
+	}
+
+	t.Setenv("BUG_RETENTION", "")
+	if _, err := ApplyRetention(false); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"kept", "expiring"} {
+		if _, ok := item(t, "TRETENTION", id)["ttl"]; ok {
+			t.Errorf("ApplyRetention() without BUG_RETENTION kept the ttl of %s", id)
+		}
+	}
+}
+
+func TestIntegrationClaim(t *testing.T) {
+	claim := func(key string) bool {
+		t.Helper()
+		claimed, err := Claim("integration", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimed
+	}
+	if !claim("Ev1") {
+		t.Fatal("Claim() of a new event = false")
+	}
+	if claim("Ev1") {
+		t.Error("Claim() of an event in progress = true")
+	}
+	if err := Release("integration", "Ev1"); err != nil {
+		t.Fatal(err)
+	}
+	if !claim("Ev1") {
+		t.Error("Claim() of a released event = false")
+	}
+	if err := Done("integration", "Ev1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release("integration", "Ev1"); err != nil {
+		t.Fatal(err)
+	}
+	if claim("Ev1") {
+		t.Error("Claim() of a done event = true, Release must leave done claims alone")
+	}
+	if !claim("Ev2") {
+		t.Error("Claim() of another event = false")
+	}
+}
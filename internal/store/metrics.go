@@ -0,0 +1,258 @@
+package store
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Metrics counted per team, day and product
+const (
+	MetricSubmitted = "submitted"
+	MetricSynced    = "synced"
+	MetricResolved  = "resolved"
+	MetricTriaged   = "triaged"
+)
+
+// metricsPartition holds the daily counters of a team, TEAM#<team>#METRICS,
+// keyed by <day>#<product>
+const metricsPartition = "METRICS"
+
+// dayFormat is the UTC day counters are kept by
+const dayFormat = "2006-01-02"
+
+// streamWindow is how recent the submission of a bug inserted into the table
+// must be to count, older inserts are migrations
+const streamWindow = time.Hour
+
+// Counter is the metrics of a product on a UTC day. TriageSeconds and
+// ResolutionSeconds keep how long after their submission each bug triaged or
+// resolved that day was, for medians over any window
+type Counter struct {
+	Day               string  `json:"day"`
+	Product           string  `json:"product"`
+	Submitted         int64   `json:"submitted"`
+	Synced            int64   `json:"synced"`
+	Resolved          int64   `json:"resolved"`
+	Triaged           int64   `json:"triaged"`
+	TriageSeconds     []int64 `json:"triage_seconds,omitempty"`
+	ResolutionSeconds []int64 `json:"resolution_seconds,omitempty"`
+}
+
+// Event is a metric of a bug's product at a time, Since is how long after the
+// bug's submission it happened for triages and resolutions
+type Event struct {
+	Metric  string
+	Product string
+	At      time.Time
+	Since   time.Duration
+}
+
+// durations name the counter attribute keeping the Since of a metric's events
+var durations = map[string]string{
+	MetricTriaged:  "triage_seconds",
+	MetricResolved: "resolution_seconds",
+}
+
+// Events return the metric events of bug, oldest first: its submission, every
+// move into filed, its first move past filed, i.e. its triage, and every move
+// into resolved or closed from an open status
+func (bug Bug) Events() (events []Event) {
+	events = append(events, Event{Metric: MetricSubmitted, Product: bug.Product, At: bug.CreatedAt})
+	previous := StatusNew
+	triaged := false
+	for _, change := range bug.History {
+		done := change.Status == StatusResolved || change.Status == StatusClosed
+		wasDone := previous == StatusResolved || previous == StatusClosed
+		if !triaged && change.Status != StatusNew && change.Status != StatusFiled {
+			triaged = true
+			events = append(events, Event{Metric: MetricTriaged, Product: bug.Product, At: change.At, Since: change.At.Sub(bug.CreatedAt)})
+		}
+		switch {
+		case change.Status == StatusFiled && previous != StatusFiled:
+			events = append(events, Event{Metric: MetricSynced, Product: bug.Product, At: change.At})
+		case done && !wasDone:
+			events = append(events, Event{Metric: MetricResolved, Product: bug.Product, At: change.At, Since: change.At.Sub(bug.CreatedAt)})
+		}
+		previous = change.Status
+	}
+	return
+}
+
+// StreamEvents return the team and the metric events added by a change of a
+// bug item from before to after, the DynamoDB stream images, at the time of
+// the change. Items other than bugs, removals and inserts of bugs submitted
+// long before, i.e. migrations, have none
+func StreamEvents(before, after map[string]*dynamodb.AttributeValue, at time.Time) (team string, events []Event, err error) {
+	if sk, ok := after["sk"]; !ok || aws.StringValue(sk.S) != metadata {
+		return
+	}
+	var old, bug Bug
+	if err = dynamodbattribute.UnmarshalMap(after, &bug); err != nil {
+		return
+	}
+	team, events = bug.TeamID, bug.Events()
+	if len(before) == 0 {
+		if at.Sub(bug.CreatedAt) > streamWindow {
+			events = nil
+		}
+		return
+	}
+	if err = dynamodbattribute.UnmarshalMap(before, &old); err != nil {
+		return
+	}
+	if seen := len(old.Events()); seen < len(events) {
+		events = events[seen:]
+	} else {
+		events = nil
+	}
+	return
+}
+
+// StreamBug return the bug of a table stream image, false for any other
+// item. Encrypted details are left encrypted, stream consumers copy bugs
+// elsewhere
+func StreamBug(item map[string]*dynamodb.AttributeValue) (bug Bug, ok bool, err error) {
+	if sk, found := item["sk"]; !found || aws.StringValue(sk.S) != metadata {
+		return
+	}
+	if err = dynamodbattribute.UnmarshalMap(item, &bug); err != nil {
+		return
+	}
+	return bug, true, nil
+}
+
+// counterKey return the key of the counter of product on day
+func (d Dynamo) counterKey(day, product string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(d.partition(metricsPartition))},
+		"sk": {S: aws.String(day + "#" + product)},
+	}
+}
+
+// Count add each event to the counter of its day and product
+func (d Dynamo) Count(events []Event) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	for _, event := range events {
+		day := event.At.UTC().Format(dayFormat)
+		input := &dynamodb.UpdateItemInput{
+			TableName:                table(),
+			Key:                      d.counterKey(day, event.Product),
+			UpdateExpression:         aws.String("SET #day = :day, product = :product ADD #metric :one"),
+			ExpressionAttributeNames: map[string]*string{"#day": aws.String("day"), "#metric": aws.String(event.Metric)},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":day":     {S: aws.String(day)},
+				":product": {S: aws.String(event.Product)},
+				":one":     {N: aws.String("1")},
+			},
+		}
+		if name, ok := durations[event.Metric]; ok {
+			input.UpdateExpression = aws.String("SET #day = :day, product = :product, #seconds = list_append(if_not_exists(#seconds, :none), :seconds) ADD #metric :one")
+			input.ExpressionAttributeNames["#seconds"] = aws.String(name)
+			input.ExpressionAttributeValues[":none"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+			input.ExpressionAttributeValues[":seconds"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{
+				{N: aws.String(strconv.FormatInt(int64(event.Since.Seconds()), 10))},
+			}}
+		}
+		_, err = srv.UpdateItem(input)
+		log.Printf("store.Count (%s/%s/%s) - error: %v", day, event.Product, event.Metric, err)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// PutCounter overwrite the counter of a product and day
+func (d Dynamo) PutCounter(counter Counter) (err error) {
+	defer func() {
+		log.Printf("store.PutCounter (%s/%s) - error: %v", counter.Day, counter.Product, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	item, err := dynamodbattribute.MarshalMap(counter)
+	if err != nil {
+		return
+	}
+	for name, value := range d.counterKey(counter.Day, counter.Product) {
+		item[name] = value
+	}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{TableName: table(), Item: item})
+	return
+}
+
+// ListCounters return the counters of the UTC days from since to until,
+// oldest first
+func (d Dynamo) ListCounters(since, until time.Time) (counters []Counter, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	err = srv.QueryPages(&dynamodb.QueryInput{
+		TableName:              table(),
+		KeyConditionExpression: aws.String("pk = :pk AND sk BETWEEN :since AND :until"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":    {S: aws.String(d.partition(metricsPartition))},
+			":since": {S: aws.String(since.UTC().Format(dayFormat))},
+			":until": {S: aws.String(until.UTC().Format(dayFormat) + "#~")},
+		},
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &counters)
+	return
+}
+
+// RebuildCounters recount the counters of every day from the stored bugs,
+// e.g. for bugs submitted before the stream processor was deployed. Deleted
+// bugs are left out, and counters of days without bugs are left alone
+func RebuildCounters() (counters []Counter, err error) {
+	bugs, err := AllBugs(Filter{})
+	if err != nil {
+		return
+	}
+	byKey := map[string]*Counter{}
+	for _, bug := range bugs {
+		for _, event := range bug.Events() {
+			day := event.At.UTC().Format(dayFormat)
+			counter, ok := byKey[day+"#"+event.Product]
+			if !ok {
+				counter = &Counter{Day: day, Product: event.Product}
+				byKey[day+"#"+event.Product] = counter
+			}
+			switch event.Metric {
+			case MetricSubmitted:
+				counter.Submitted++
+			case MetricSynced:
+				counter.Synced++
+			case MetricResolved:
+				counter.Resolved++
+				counter.ResolutionSeconds = append(counter.ResolutionSeconds, int64(event.Since.Seconds()))
+			case MetricTriaged:
+				counter.Triaged++
+				counter.TriageSeconds = append(counter.TriageSeconds, int64(event.Since.Seconds()))
+			}
+		}
+	}
+	for _, counter := range byKey {
+		if err = PutCounter(*counter); err != nil {
+			return
+		}
+		counters = append(counters, *counter)
+	}
+	return
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Legacy names the per-kind tables used before the single table, any may be
+// empty to skip it
+type Legacy struct {
+	BugTable    string
+	ConfigTable string
+	AuditTable  string
+}
+
+// legacySource is the attribute of a migrated item holding the key of the
+// legacy item it was copied from
+const legacySource = "legacy_key"
+
+// ErrLegacyCollision is returned when a legacy item would be copied over an
+// item copied from another legacy item, or not migrated at all
+var ErrLegacyCollision = errors.New("an item copied from another legacy item has the same key")
+
+// MigrateLegacy copy every item of the legacy tables into the single table
+// with its new keys in the partitions of the home team, returning how many bugs, config entries and audit
+// entries were copied. Items already copied from the same legacy item are
+// left alone, so a partly failed migration can be run again. Details are
+// copied as stored, still encrypted when they were
+func MigrateLegacy(legacy Legacy, dryRun bool) (copied map[string]int, err error) {
+	copied = map[string]int{}
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	home := Dynamo{}
+	migrate := func(kind, name string, convert func(map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)) error {
+		if len(name) == 0 {
+			return nil
+		}
+		described, err := srv.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(name)})
+		if err != nil {
+			return err
+		}
+		var pageErr error
+		err = srv.ScanPages(&dynamodb.ScanInput{TableName: aws.String(name)}, func(page *dynamodb.ScanOutput, last bool) bool {
+			for _, legacyItem := range page.Items {
+				source := sourceKey(described.Table.KeySchema, legacyItem)
+				item, convertErr := convert(legacyItem)
+				if convertErr != nil {
+					pageErr = convertErr
+					return false
+				}
+				item[legacySource] = &dynamodb.AttributeValue{S: aws.String(source)}
+				if dryRun {
+					copied[kind]++
+					continue
+				}
+				_, putErr := srv.PutItem(&dynamodb.PutItemInput{
+					TableName:           table(),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				})
+				if aerr, ok := putErr.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+					// only an item copied from this one is already migrated
+					existing, getErr := srv.GetItem(&dynamodb.GetItemInput{
+						TableName: table(),
+						Key:       map[string]*dynamodb.AttributeValue{"pk": item["pk"], "sk": item["sk"]},
+					})
+					putErr = getErr
+					if getErr == nil && !sameSource(existing.Item, item) {
+						log.Printf("store.MigrateLegacy (%s/%s) - %s collides with %s", kind, name, source, aws.StringValue(item["pk"].S))
+						putErr = ErrLegacyCollision
+					}
+					if putErr == nil {
+						continue
+					}
+				}
+				if putErr != nil {
+					pageErr = putErr
+					return false
+				}
+				copied[kind]++
+			}
+			return true
+		})
+		if err == nil {
+			err = pageErr
+		}
+		log.Printf("store.MigrateLegacy (%s/%s) - copied: %d, error: %v", kind, name, copied[kind], err)
+		return err
+	}
+	if err = migrate("bugs", legacy.BugTable, home.legacyBug); err != nil {
+		return
+	}
+	if err = migrate("config", legacy.ConfigTable, func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		for name, value := range home.configKey(aws.StringValue(item["kind"].S), aws.StringValue(item["key"].S)) {
+			item[name] = value
+		}
+		return item, nil
+	}); err != nil {
+		return
+	}
+	err = migrate("audit", legacy.AuditTable, func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		var entry AuditEntry
+		if err := dynamodbattribute.UnmarshalMap(item, &entry); err != nil {
+			return nil, err
+		}
+		for name, value := range home.auditKeys(entry) {
+			item[name] = value
+		}
+		return item, nil
+	})
+	return
+}
+
+// legacyBug return the bug of a legacy bug table item. The first tables
+// keyed bugs by user_id and created_at, with no id, status or history: these
+// are filled in, the id derived from that key so a re-run copies the bug to
+// the same item
+func (d Dynamo) legacyBug(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	var bug Bug
+	if err := dynamodbattribute.UnmarshalMap(item, &bug); err != nil {
+		return nil, err
+	}
+	if len(bug.ID) == 0 {
+		sum := sha256.Sum256([]byte(bug.UserID + "#" + attributeString(item["created_at"])))
+		bug.ID = hex.EncodeToString(sum[:8])
+	}
+	if len(bug.Status) == 0 {
+		bug.Status = StatusNew
+	}
+	if len(bug.History) == 0 {
+		bug.History = []Change{{Status: bug.Status, At: bug.CreatedAt}}
+	}
+	return d.bugItem(bug)
+}
+
+// sourceKey return the key of a legacy item, its key attributes in the
+// order of schema
+func sourceKey(schema []*dynamodb.KeySchemaElement, item map[string]*dynamodb.AttributeValue) string {
+	var values []string
+	for _, key := range schema {
+		values = append(values, attributeString(item[aws.StringValue(key.AttributeName)]))
+	}
+	return strings.Join(values, "#")
+}
+
+// sameSource report whether existing was copied from the legacy item item
+// is converted from: by its legacy key, or, copied before legacy keys were
+// kept, by being the same item
+func sameSource(existing, item map[string]*dynamodb.AttributeValue) bool {
+	if existing == nil {
+		return false
+	}
+	if source, ok := existing[legacySource]; ok {
+		return attributeString(source) == attributeString(item[legacySource])
+	}
+	for name, value := range item {
+		if name != legacySource && !reflect.DeepEqual(existing[name], value) {
+			return false
+		}
+	}
+	return len(existing) == len(item)-1
+}
+
+// attributeString return the string or number of value
+func attributeString(value *dynamodb.AttributeValue) string {
+	if value == nil {
+		return ""
+	}
+	if value.S != nil {
+		return aws.StringValue(value.S)
+	}
+	return aws.StringValue(value.N)
+}
+
+// MigrateTeam move the items of the single team layout, bugs partitioned by
+// BUG#<id> and config and admin audit entries by TEAM#default, into the
+// partitions of team, returning how many bugs, comments, config entries and
+// audit entries were moved. Each item is copied before the original is
+// deleted, so a partly failed migration can be run again
+func MigrateTeam(team string, dryRun bool) (moved map[string]int, err error) {
+	moved = map[string]int{}
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	to := Dynamo{Team: team}
+	var pageErr error
+	err = srv.ScanPages(&dynamodb.ScanInput{
+		TableName:        table(),
+		FilterExpression: aws.String("begins_with(pk, :bug) OR pk = :default"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":bug":     {S: aws.String(bugPrefix)},
+			":default": {S: aws.String(teamPrefix + DefaultTeam)},
+		},
+	}, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			kind, converted, convertErr := to.teamItem(item)
+			if convertErr != nil {
+				pageErr = convertErr
+				return false
+			}
+			if dryRun {
+				moved[kind]++
+				continue
+			}
+			if pageErr = move(srv, item, converted); pageErr != nil {
+				return false
+			}
+			moved[kind]++
+		}
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	log.Printf("store.MigrateTeam (%s) - moved: %v, error: %v", team, moved, err)
+	return
+}
+
+// teamItem return the kind of a single team layout item and the item with
+// the keys of the team of d
+func (d Dynamo) teamItem(item map[string]*dynamodb.AttributeValue) (kind string, converted map[string]*dynamodb.AttributeValue, err error) {
+	pk, sk := aws.StringValue(item["pk"].S), aws.StringValue(item["sk"].S)
+	converted = map[string]*dynamodb.AttributeValue{}
+	for name, value := range item {
+		converted[name] = value
+	}
+	switch {
+	case sk == metadata:
+		var bug Bug
+		if err = dynamodbattribute.UnmarshalMap(item, &bug); err != nil {
+			return
+		}
+		kind = "bugs"
+		keys, keysErr := d.bugItem(bug)
+		if keysErr != nil {
+			return kind, nil, keysErr
+		}
+		// only the keys are replaced, details are copied as stored
+		for _, name := range []string{"pk", "team_id", UserIndex + "pk", ProductIndex + "pk", StatusIndex + "pk", IssueKeyIndex + "pk"} {
+			if value, ok := keys[name]; ok {
+				converted[name] = value
+			}
+		}
+	case strings.HasPrefix(sk, commentPrefix):
+		kind = "comments"
+		converted["pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition(pk))}
+	case strings.HasPrefix(sk, auditPrefix):
+		var entry AuditEntry
+		if err = dynamodbattribute.UnmarshalMap(item, &entry); err != nil {
+			return
+		}
+		kind = "audit"
+		for name, value := range d.auditKeys(entry) {
+			converted[name] = value
+		}
+	default:
+		kind = "config"
+		converted["pk"] = &dynamodb.AttributeValue{S: aws.String(teamPrefix + d.team())}
+	}
+	return
+}
+
+// move put converted in place of item, deleting item when its key changed
+func move(srv *dynamodb.DynamoDB, item, converted map[string]*dynamodb.AttributeValue) (err error) {
+	if aws.StringValue(item["pk"].S) == aws.StringValue(converted["pk"].S) {
+		// only index keys changed, e.g. audit entries of the default team
+		_, err = srv.PutItem(&dynamodb.PutItemInput{TableName: table(), Item: converted})
+		return
+	}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{
+		TableName:           table(),
+		Item:                converted,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: table(),
+		Key:       map[string]*dynamodb.AttributeValue{"pk": item["pk"], "sk": item["sk"]},
+	})
+	return
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// baselineBug return an item of the first bug table, keyed by user_id and
+// created_at, with no id, status or history
+func baselineBug(user, created string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"user_id":    {S: aws.String(user)},
+		"user_name":  {S: aws.String("ada")},
+		"summary":    {S: aws.String("Checkout button does nothing")},
+		"product":    {S: aws.String("web_app")},
+		"details":    {S: aws.String("Clicking Pay shows a spinner forever")},
+		"created_at": {S: aws.String(created)},
+		"updated_at": {S: aws.String(created)},
+		"ttl":        {N: aws.String("1537790400")},
+	}
+}
+
+func TestLegacyBug(t *testing.T) {
+	repo := Dynamo{Team: "T1"}
+	convert := func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, Bug) {
+		t.Helper()
+		converted, err := repo.legacyBug(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bug Bug
+		if err = dynamodbattribute.UnmarshalMap(converted, &bug); err != nil {
+			t.Fatal(err)
+		}
+		return converted, bug
+	}
+
+	item, bug := convert(baselineBug("U1", "2018-09-17T12:00:00.123456789Z"))
+	if len(bug.ID) != 16 {
+		t.Errorf("legacyBug() id = %q, want 16 hex characters", bug.ID)
+	}
+	if pk := aws.StringValue(item["pk"].S); pk != "TEAM#T1#BUG#"+bug.ID {
+		t.Errorf("legacyBug() pk = %q", pk)
+	}
+	created := time.Date(2018, 9, 17, 12, 0, 0, 123456789, time.UTC)
+	if bug.Status != StatusNew || len(bug.History) != 1 || bug.History[0].Status != StatusNew || !bug.History[0].At.Equal(created) {
+		t.Errorf("legacyBug() status = %q, history = %+v", bug.Status, bug.History)
+	}
+	if bug.UserID != "U1" || bug.Summary != "Checkout button does nothing" || !bug.CreatedAt.Equal(created) {
+		t.Errorf("legacyBug() = %+v", bug)
+	}
+
+	if _, again := convert(baselineBug("U1", "2018-09-17T12:00:00.123456789Z")); again.ID != bug.ID {
+		t.Errorf("legacyBug() of the same item id = %q, want %q", again.ID, bug.ID)
+	}
+	for _, other := range []map[string]*dynamodb.AttributeValue{
+		baselineBug("U1", "2018-09-17T12:00:01Z"),
+		baselineBug("U2", "2018-09-17T12:00:00.123456789Z"),
+	} {
+		if _, otherBug := convert(other); otherBug.ID == bug.ID {
+			t.Errorf("legacyBug() of %s at %s id = %q, the id of another bug", otherBug.UserID, otherBug.CreatedAt, otherBug.ID)
+		}
+	}
+
+	stored := baselineBug("U1", "2018-09-17T12:00:00Z")
+	stored["id"] = &dynamodb.AttributeValue{S: aws.String("b1")}
+	stored["status"] = &dynamodb.AttributeValue{S: aws.String(StatusResolved)}
+	if _, kept := convert(stored); kept.ID != "b1" || kept.Status != StatusResolved {
+		t.Errorf("legacyBug() of a bug with an id = %q, %q", kept.ID, kept.Status)
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	schema := []*dynamodb.KeySchemaElement{
+		{AttributeName: aws.String("user_id"), KeyType: aws.String("HASH")},
+		{AttributeName: aws.String("created_at"), KeyType: aws.String("RANGE")},
+	}
+	if got := sourceKey(schema, baselineBug("U1", "2018-09-17T12:00:00Z")); got != "U1#2018-09-17T12:00:00Z" {
+		t.Errorf("sourceKey() = %q", got)
+	}
+}
+
+func TestSameSource(t *testing.T) {
+	item := func(source, summary string) map[string]*dynamodb.AttributeValue {
+		item := map[string]*dynamodb.AttributeValue{
+			"pk":      {S: aws.String("TEAM#T1#BUG#b1")},
+			"summary": {S: aws.String(summary)},
+		}
+		if len(source) > 0 {
+			item[legacySource] = &dynamodb.AttributeValue{S: aws.String(source)}
+		}
+		return item
+	}
+	copied := item("U1#2018-09-17T12:00:00Z", "Checkout")
+	tests := []struct {
+		name     string
+		existing map[string]*dynamodb.AttributeValue
+		want     bool
+	}{
+		{"copied from it", item("U1#2018-09-17T12:00:00Z", "Edited since"), true},
+		{"copied from another", item("U2#2018-09-17T12:00:00Z", "Checkout"), false},
+		{"copied before legacy keys", item("", "Checkout"), true},
+		{"other item before legacy keys", item("", "Payments"), false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		if got := sameSource(tt.existing, copied); got != tt.want {
+			t.Errorf("sameSource() of %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
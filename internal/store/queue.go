@@ -0,0 +1,84 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// queuePartition lists the bugs of every team waiting for an unavailable
+// tracker, keyed by <team>#<bug id>
+const queuePartition = "QUEUE"
+
+// QueuedBug is a bug waiting to be filed
+type QueuedBug struct {
+	TeamID   string    `json:"team_id"`
+	BugID    string    `json:"bug_id"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+func queueKey(team, bugID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(queuePartition)},
+		"sk": {S: aws.String(team + "#" + bugID)},
+	}
+}
+
+// Enqueue add bug of the team of d to the bugs waiting to be filed
+func (d Dynamo) Enqueue(bug Bug) (err error) {
+	defer func() {
+		log.Printf("store.Enqueue (%s/%s) - error: %v", d.team(), bug.ID, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	queued := QueuedBug{TeamID: d.team(), BugID: bug.ID, QueuedAt: time.Now()}
+	item, err := dynamodbattribute.MarshalMap(queued)
+	if err != nil {
+		return
+	}
+	for name, value := range queueKey(queued.TeamID, queued.BugID) {
+		item[name] = value
+	}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{TableName: table(), Item: item})
+	return
+}
+
+// ListQueued return the bugs waiting to be filed, of every team
+func ListQueued() (queued []QueuedBug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	err = srv.QueryPages(&dynamodb.QueryInput{
+		TableName:                 table(),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":pk": {S: aws.String(queuePartition)}},
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &queued)
+	return
+}
+
+// Dequeue remove a filed bug from the bugs waiting to be filed
+func Dequeue(queued QueuedBug) (err error) {
+	defer func() {
+		log.Printf("store.Dequeue (%s/%s) - error: %v", queued.TeamID, queued.BugID, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{TableName: table(), Key: queueKey(queued.TeamID, queued.BugID)})
+	return
+}
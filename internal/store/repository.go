@@ -0,0 +1,279 @@
+package store
+
+import "time"
+
+// BugRepository stores bugs and the comments left on them
+type BugRepository interface {
+	PutBug(bug Bug) error
+	ImportBug(bug Bug, keep time.Duration) error
+	GetBug(id string) (Bug, error)
+	FindBug(ref string) (Bug, error)
+	ListBugs(filter Filter) ([]Bug, string, error)
+	SetIssues(bug Bug, issues []Issue) error
+	LinkIssues(bug Bug, issues []Issue) error
+	UpdateStatus(bug Bug, status, resolution, actor string) (Bug, error)
+	UpdateBug(bug Bug) (Bug, error)
+	SetAssignee(bug Bug, assignee string) error
+	SetThread(bug Bug, thread Thread) error
+	SetAnnouncements(bug Bug, announcements []Thread) error
+	SetEscalated(bug Bug, escalated []string) error
+	LinkDuplicate(bug, original Bug) error
+	Merge(duplicate, survivor Bug, cc []string) error
+	Recur(bug Bug) (Bug, error)
+	Verify(bug Bug, userID string) (Bug, error)
+	DeleteBug(bug Bug) (Bug, error)
+	RestoreBug(id string) (Bug, error)
+	PurgeBug(id string) error
+	Enqueue(bug Bug) error
+	AddComment(comment Comment) error
+	ListComments(bugID string) ([]Comment, error)
+}
+
+// ConfigRepository stores the products, channel mappings, form and
+// notification preferences, subscriptions, role grants, tags, drafts and bug
+// bashes of a team
+type ConfigRepository interface {
+	ListProducts() ([]Product, error)
+	GetProduct(value string) (Product, error)
+	PutProduct(product Product) error
+	DeleteProduct(value string) error
+	GetChannel(id string) (Channel, error)
+	PutChannel(channel Channel) error
+	DeleteChannel(id string) error
+	GetPreference(userID string) (Preference, error)
+	PutPreference(preference Preference) error
+	GetNotifyPreference(userID string) (NotifyPreference, error)
+	PutNotifyPreference(preference NotifyPreference) error
+	ListSubscriptions(product string) ([]Subscription, error)
+	GetSubscription(product, channelID string) (Subscription, error)
+	PutSubscription(subscription Subscription) error
+	DeleteSubscription(product, channelID string) error
+	ListGrants() ([]Grant, error)
+	GetGrant(userID string) (Grant, error)
+	PutGrant(grant Grant) error
+	DeleteGrant(userID string) error
+	ListTags() ([]Tag, error)
+	PutTag(tag Tag) error
+	DeleteTag(name string) error
+	GetDraft(userID string) (Draft, error)
+	PutDraft(draft Draft) error
+	DeleteDraft(userID string) error
+	GetBash(channelID string) (Bash, error)
+	PutBash(bash Bash) error
+	DeleteBash(channelID string) error
+}
+
+// AuditRepository appends and lists audit entries
+type AuditRepository interface {
+	Audit(entry AuditEntry) error
+	ListAudit(filter AuditFilter) ([]AuditEntry, string, error)
+}
+
+// MetricsRepository maintains the daily counters of a team
+type MetricsRepository interface {
+	Count(events []Event) error
+	PutCounter(counter Counter) error
+	ListCounters(since, until time.Time) ([]Counter, error)
+}
+
+// Repository is everything kanobug stores
+type Repository interface {
+	BugRepository
+	ConfigRepository
+	AuditRepository
+	MetricsRepository
+}
+
+// Default is the repository behind the package functions, the single
+// DynamoDB table named by TABLE_NAME
+var Default Repository = Dynamo{}
+
+// PutBug upsert bug, see Dynamo.PutBug
+func PutBug(bug Bug) error { return Default.PutBug(bug) }
+
+// ImportBug insert a bug imported from another system, kept for keep
+func ImportBug(bug Bug, keep time.Duration) error { return Default.ImportBug(bug, keep) }
+
+// GetBug return the bug with id
+func GetBug(id string) (Bug, error) { return Default.GetBug(id) }
+
+// FindBug return the bug with id or issue key ref, ErrNotFound when absent
+func FindBug(ref string) (Bug, error) { return Default.FindBug(ref) }
+
+// ListBugs return a page of bugs matching filter and the cursor of the next page
+func ListBugs(filter Filter) ([]Bug, string, error) { return Default.ListBugs(filter) }
+
+// SetIssues record the issues a bug was filed as and mark it filed
+func SetIssues(bug Bug, issues []Issue) error { return Default.SetIssues(bug, issues) }
+
+// LinkIssues replace the issues of bug, leaving its status alone
+func LinkIssues(bug Bug, issues []Issue) error { return Default.LinkIssues(bug, issues) }
+
+// UpdateStatus set the status (and resolution) of bug as changed by actor,
+// returning the updated bug
+func UpdateStatus(bug Bug, status, resolution, actor string) (Bug, error) {
+	return Default.UpdateStatus(bug, status, resolution, actor)
+}
+
+// UpdateBug save the edited summary, product, severity, details and tags of bug
+func UpdateBug(bug Bug) (Bug, error) { return Default.UpdateBug(bug) }
+
+// SetAssignee record the chat user ID the bug was assigned to
+func SetAssignee(bug Bug, assignee string) error { return Default.SetAssignee(bug, assignee) }
+
+// SetThread record the triage message of bug
+func SetThread(bug Bug, thread Thread) error { return Default.SetThread(bug, thread) }
+
+// SetAnnouncements record the messages bug was announced as in subscribed
+// channels
+func SetAnnouncements(bug Bug, announcements []Thread) error {
+	return Default.SetAnnouncements(bug, announcements)
+}
+
+// SetEscalated record the names of the escalation rules bug was escalated
+// by, so none escalates it twice
+func SetEscalated(bug Bug, escalated []string) error { return Default.SetEscalated(bug, escalated) }
+
+// LinkDuplicate record bug as a possible duplicate of original, and
+// original as similar to bug
+func LinkDuplicate(bug, original Bug) error { return Default.LinkDuplicate(bug, original) }
+
+// Merge record duplicate as merged into survivor, CC'ing cc on survivor
+func Merge(duplicate, survivor Bug, cc []string) error { return Default.Merge(duplicate, survivor, cc) }
+
+// Recur count another recurrence of bug and keep it for 7 more days
+func Recur(bug Bug) (Bug, error) { return Default.Recur(bug) }
+
+// Verify record userID confirming the resolution of bug fixed it
+func Verify(bug Bug, userID string) (Bug, error) { return Default.Verify(bug, userID) }
+
+// DeleteBug mark bug deleted, hiding it from every query
+func DeleteBug(bug Bug) (Bug, error) { return Default.DeleteBug(bug) }
+
+// RestoreBug clear the deleted mark of bug id
+func RestoreBug(id string) (Bug, error) { return Default.RestoreBug(id) }
+
+// PurgeBug remove bug id from the table for good
+func PurgeBug(id string) error { return Default.PurgeBug(id) }
+
+// Enqueue add bug to the bugs waiting to be filed
+func Enqueue(bug Bug) error { return Default.Enqueue(bug) }
+
+// AddComment append comment to its bug
+func AddComment(comment Comment) error { return Default.AddComment(comment) }
+
+// ListComments return the comments of bug id, oldest first
+func ListComments(bugID string) ([]Comment, error) { return Default.ListComments(bugID) }
+
+// ListProducts return the configured products
+func ListProducts() ([]Product, error) { return Default.ListProducts() }
+
+// GetProduct return the configured product value, ErrNotFound when absent
+func GetProduct(value string) (Product, error) { return Default.GetProduct(value) }
+
+// PutProduct upsert product
+func PutProduct(product Product) error { return Default.PutProduct(product) }
+
+// DeleteProduct remove product value
+func DeleteProduct(value string) error { return Default.DeleteProduct(value) }
+
+// GetChannel return the product mapping of channel id, ErrNotFound when absent
+func GetChannel(id string) (Channel, error) { return Default.GetChannel(id) }
+
+// PutChannel upsert the product mapping of a channel
+func PutChannel(channel Channel) error { return Default.PutChannel(channel) }
+
+// DeleteChannel remove the product mapping of channel id
+func DeleteChannel(id string) error { return Default.DeleteChannel(id) }
+
+// GetPreference return the form preferences of userID, ErrNotFound when absent
+func GetPreference(userID string) (Preference, error) { return Default.GetPreference(userID) }
+
+// PutPreference upsert the form preferences of a user
+func PutPreference(preference Preference) error { return Default.PutPreference(preference) }
+
+// GetNotifyPreference return the notification preferences of userID,
+// ErrNotFound when absent
+func GetNotifyPreference(userID string) (NotifyPreference, error) {
+	return Default.GetNotifyPreference(userID)
+}
+
+// PutNotifyPreference upsert the notification preferences of a user
+func PutNotifyPreference(preference NotifyPreference) error {
+	return Default.PutNotifyPreference(preference)
+}
+
+// ListSubscriptions return the channels subscribed to product
+func ListSubscriptions(product string) ([]Subscription, error) {
+	return Default.ListSubscriptions(product)
+}
+
+// GetSubscription return the subscription of channelID to product, ErrNotFound when absent
+func GetSubscription(product, channelID string) (Subscription, error) {
+	return Default.GetSubscription(product, channelID)
+}
+
+// PutSubscription subscribe a channel to a product feed
+func PutSubscription(subscription Subscription) error { return Default.PutSubscription(subscription) }
+
+// DeleteSubscription unsubscribe channelID from the product feed
+func DeleteSubscription(product, channelID string) error {
+	return Default.DeleteSubscription(product, channelID)
+}
+
+// ListGrants return every role grant
+func ListGrants() ([]Grant, error) { return Default.ListGrants() }
+
+// GetGrant return the role grant of userID, ErrNotFound when absent
+func GetGrant(userID string) (Grant, error) { return Default.GetGrant(userID) }
+
+// PutGrant upsert the role grant of a user
+func PutGrant(grant Grant) error { return Default.PutGrant(grant) }
+
+// DeleteGrant remove the role grant of userID
+func DeleteGrant(userID string) error { return Default.DeleteGrant(userID) }
+
+// ListTags return the managed tags
+func ListTags() ([]Tag, error) { return Default.ListTags() }
+
+// PutTag upsert a managed tag
+func PutTag(tag Tag) error { return Default.PutTag(tag) }
+
+// DeleteTag remove managed tag name
+func DeleteTag(name string) error { return Default.DeleteTag(name) }
+
+// GetDraft return the saved draft of userID, ErrNotFound when absent
+func GetDraft(userID string) (Draft, error) { return Default.GetDraft(userID) }
+
+// PutDraft upsert the draft of a user, expiring after a week
+func PutDraft(draft Draft) error { return Default.PutDraft(draft) }
+
+// DeleteDraft remove the draft of userID
+func DeleteDraft(userID string) error { return Default.DeleteDraft(userID) }
+
+// GetBash return the bug bash running in channelID, ErrNotFound when absent
+// or over
+func GetBash(channelID string) (Bash, error) { return Default.GetBash(channelID) }
+
+// PutBash upsert the bug bash of a channel, expiring with it
+func PutBash(bash Bash) error { return Default.PutBash(bash) }
+
+// DeleteBash end the bug bash of channelID
+func DeleteBash(channelID string) error { return Default.DeleteBash(channelID) }
+
+// Audit append entry to the audit trail, entries are never overwritten
+func Audit(entry AuditEntry) error { return Default.Audit(entry) }
+
+// ListAudit return a page of audit entries of a subject or actor, newest first
+func ListAudit(filter AuditFilter) ([]AuditEntry, string, error) { return Default.ListAudit(filter) }
+
+// Count add each event to the counter of its day and product
+func Count(events []Event) error { return Default.Count(events) }
+
+// PutCounter overwrite the counter of a product and day
+func PutCounter(counter Counter) error { return Default.PutCounter(counter) }
+
+// ListCounters return the counters of the UTC days from since to until, oldest first
+func ListCounters(since, until time.Time) ([]Counter, error) {
+	return Default.ListCounters(since, until)
+}
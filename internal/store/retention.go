@@ -0,0 +1,90 @@
+package store
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Retention return how long bugs are kept after their last update,
+// BUG_RETENTION, 0 keeping them until purged
+func Retention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("BUG_RETENTION"))
+	if err != nil || retention < 0 {
+		return 0
+	}
+	return retention
+}
+
+// Expiry return the TTL of a bug last updated at, 0 for none
+func Expiry(at time.Time) int64 {
+	retention := Retention()
+	if retention == 0 {
+		return 0
+	}
+	return at.Add(retention).Unix()
+}
+
+// ApplyRetention set the TTL of every stored bug of every team to what
+// BUG_RETENTION gives it, removing it when bugs are kept, and return how
+// many bugs changed. Bugs stored while they expired after a week keep that
+// TTL until this is run
+func ApplyRetention(dryRun bool) (changed int, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var updateErr error
+	err = srv.ScanPages(&dynamodb.ScanInput{
+		TableName:                table(),
+		FilterExpression:         aws.String("sk = :metadata AND begins_with(pk, :team)"),
+		ProjectionExpression:     aws.String("pk, sk, updated_at, #ttl"),
+		ExpressionAttributeNames: map[string]*string{"#ttl": aws.String("ttl")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":metadata": {S: aws.String(metadata)},
+			":team":     {S: aws.String(teamPrefix)},
+		},
+	}, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			var bug Bug
+			if updateErr = dynamodbattribute.UnmarshalMap(item, &bug); updateErr != nil {
+				return false
+			}
+			ttl := Expiry(bug.UpdatedAt)
+			if ttl == bug.TTL {
+				continue
+			}
+			changed++
+			if dryRun {
+				continue
+			}
+			input := &dynamodb.UpdateItemInput{
+				TableName:                table(),
+				Key:                      map[string]*dynamodb.AttributeValue{"pk": item["pk"], "sk": item["sk"]},
+				UpdateExpression:         aws.String("REMOVE #ttl"),
+				ConditionExpression:      aws.String("attribute_exists(pk)"),
+				ExpressionAttributeNames: map[string]*string{"#ttl": aws.String("ttl")},
+			}
+			if ttl > 0 {
+				input.UpdateExpression = aws.String("SET #ttl = :ttl")
+				input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+					":ttl": {N: aws.String(strconv.FormatInt(ttl, 10))},
+				}
+			}
+			if _, updateErr = srv.UpdateItem(input); updateErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = updateErr
+	}
+	log.Printf("store.ApplyRetention - retention: %s, changed: %d, dry run: %v, error: %v", Retention(), changed, dryRun, err)
+	return
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		retention string
+		want      int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"-1h", 0},
+		{"a year", 0},
+		{"8760h", at.Add(8760 * time.Hour).Unix()},
+	}
+	for _, tt := range tests {
+		t.Setenv("BUG_RETENTION", tt.retention)
+		if got := Expiry(at); got != tt.want {
+			t.Errorf("Expiry() with BUG_RETENTION %q = %d, want %d", tt.retention, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,1030 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+// Item types of the single table, bugs are partitioned by TEAM#<team>#BUG#<id>
+// with their METADATA, COMMENT# and AUDIT# items, config entries and admin
+// audit entries by TEAM#<team> with CONFIG# and AUDIT# items
+const (
+	bugPrefix     = "BUG#"
+	teamPrefix    = "TEAM#"
+	metadata      = "METADATA"
+	commentPrefix = "COMMENT#"
+	auditPrefix   = "AUDIT#"
+	configPrefix  = "CONFIG#"
+)
+
+// DefaultTeam is the team of single workspace installs, used when neither the
+// repository nor DEFAULT_TEAM_ID names one
+const DefaultTeam = "default"
+
+// Overloaded secondary indexes of the table, each keyed by <index>pk and
+// <index>sk attributes prefixed by the team. UserIndex also holds audit
+// entries by ACTOR#<actor>
+const (
+	UserIndex     = "gsi1"
+	ActorIndex    = "gsi1"
+	ProductIndex  = "gsi2"
+	StatusIndex   = "gsi3"
+	IssueKeyIndex = "gsi4"
+)
+
+// issueKey matches tracker issue keys such as IQ-123, told apart from bug IDs
+var issueKey = regexp.MustCompile(`^[A-Z][A-Z0-9]+-[0-9]+$`)
+
+// ErrNotFound is returned when no bug matches
+var ErrNotFound = errors.New("bug not found")
+
+// ErrConflict is returned when a bug changed since it was read
+var ErrConflict = errors.New("bug was changed concurrently")
+
+// Filter narrows ListBugs results, Cursor continues a previous page. Deleted
+// lists only the deleted bugs, which are left out otherwise
+type Filter struct {
+	UserID   string
+	Product  string
+	Status   string
+	Severity string
+	Tag      string
+	Since    time.Time
+	Until    time.Time
+	Limit    int64
+	Cursor   string
+	Deleted  bool
+}
+
+// GetDB return DDB handle
+func GetDB() (srv *dynamodb.DynamoDB, err error) {
+	sess, err := outbound.Session(outbound.DynamoDB)
+	if err != nil {
+		return
+	}
+	srv = dynamodb.New(sess)
+	return
+}
+
+// Ping read an item that is never written, checking the table answers
+func Ping() (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.GetItem(&dynamodb.GetItemInput{
+		TableName: table(),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {S: aws.String("PING")},
+			"sk": {S: aws.String(metadata)},
+		},
+	})
+	return
+}
+
+// Dynamo is the single table Repository named by TABLE_NAME, holding the
+// bugs, config and audit entries of Team. The zero value is the repository of
+// the home team, see HomeTeam. Config missing from an Enterprise Grid
+// workspace is read from the team partition of its org, Enterprise
+type Dynamo struct {
+	Team       string
+	Enterprise string
+}
+
+// HomeTeam return the team of requests naming none, such as the REST API,
+// email and web intake and kanobugctl: DEFAULT_TEAM_ID or else DefaultTeam
+func HomeTeam() string {
+	if team := config.Get().DefaultTeam; len(team) > 0 {
+		return team
+	}
+	return DefaultTeam
+}
+
+// ForTeam return the repository of team, the home team when empty
+func ForTeam(team string) Repository {
+	return Dynamo{Team: team}
+}
+
+// ForWorkspace return the repository of workspace team of Enterprise Grid
+// org enterprise, falling back to the config of the org
+func ForWorkspace(enterprise, team string) Repository {
+	return Dynamo{Team: team, Enterprise: enterprise}
+}
+
+// UseTeam point the package functions at the repository of team. A Lambda
+// serves one invocation at a time, so handlers call it with the team of each
+// request before touching the store, or UseWorkspace with its org
+func UseTeam(team string) {
+	UseWorkspace("", team)
+}
+
+// team return the team of d
+func (d Dynamo) team() string {
+	if len(d.Team) > 0 {
+		return d.Team
+	}
+	return HomeTeam()
+}
+
+// partition return value scoped to the team of d, e.g. TEAM#T123#BUG#<id>
+func (d Dynamo) partition(value string) string {
+	return teamPrefix + d.team() + "#" + value
+}
+
+func table() *string {
+	return aws.String(config.Get().Table)
+}
+
+// key return the primary key of bug id
+func (d Dynamo) key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(d.partition(bugPrefix + id))},
+		"sk": {S: aws.String(metadata)},
+	}
+}
+
+// bugItem marshal bug as a bug of the team of d with its table and index keys
+func (d Dynamo) bugItem(bug Bug) (item map[string]*dynamodb.AttributeValue, err error) {
+	bug.TeamID = d.team()
+	if len(d.Enterprise) > 0 {
+		bug.EnterpriseID = d.Enterprise
+	}
+	if item, err = dynamodbattribute.MarshalMap(bug); err != nil {
+		return
+	}
+	for name, value := range d.key(bug.ID) {
+		item[name] = value
+	}
+	created := bug.CreatedAt.Format(time.RFC3339Nano)
+	indexes := map[string]string{
+		UserIndex:    d.partition("USER#" + bug.UserID),
+		ProductIndex: d.partition("PRODUCT#" + bug.Product),
+		StatusIndex:  d.partition("STATUS#" + bug.Status),
+	}
+	for index, pk := range indexes {
+		item[index+"pk"] = &dynamodb.AttributeValue{S: aws.String(pk)}
+		item[index+"sk"] = &dynamodb.AttributeValue{S: aws.String(created)}
+	}
+	if len(bug.IssueKey) > 0 {
+		item[IssueKeyIndex+"pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition("ISSUE#" + bug.IssueKey))}
+	}
+	return
+}
+
+// PutBug upsert BUG instance to db
+func (d Dynamo) PutBug(bug Bug) (err error) {
+	defer func() {
+		log.Printf(
+			"store.PutBug (%s/%s/%s/%s) - error: %v",
+			bug.UserID,
+			bug.UserName,
+			bug.Summary,
+			bug.Product,
+			err,
+		)
+	}()
+	bug.TTL = Expiry(bug.UpdatedAt)
+	return d.put(bug)
+}
+
+// ImportBug insert a bug imported from another system, kept for keep from
+// now, or as BUG_RETENTION says when keep is 0, ErrConflict when a bug of
+// its ID exists already
+func (d Dynamo) ImportBug(bug Bug, keep time.Duration) (err error) {
+	defer func() {
+		log.Printf("store.ImportBug (%s/%s) - error: %v", bug.ID, bug.Product, err)
+	}()
+	bug.Version = 0
+	bug.TTL = Expiry(time.Now())
+	if keep > 0 {
+		bug.TTL = time.Now().Add(keep).Unix()
+	}
+	return d.put(bug)
+}
+
+// put write bug, inserting it unless it has a version to replace
+func (d Dynamo) put(bug Bug) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	if err = encryptDetails(&bug); err != nil {
+		return
+	}
+	condition := aws.String("attribute_not_exists(pk)")
+	var values map[string]*dynamodb.AttributeValue
+	if bug.Version > 0 {
+		condition = aws.String("version = :version")
+		values = map[string]*dynamodb.AttributeValue{":version": {N: aws.String(strconv.FormatInt(bug.Version, 10))}}
+	}
+	bug.Version++
+	item, err := d.bugItem(bug)
+	if err != nil {
+		return
+	}
+	input := &dynamodb.PutItemInput{
+		Item:                      item,
+		TableName:                 table(),
+		ConditionExpression:       condition,
+		ExpressionAttributeValues: values,
+	}
+	_, err = srv.PutItem(input)
+	err = conflict(err)
+	return
+}
+
+// GetBug return the bug with id
+func (d Dynamo) GetBug(id string) (bug Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.GetItem(&dynamodb.GetItemInput{
+		TableName: table(),
+		Key:       d.key(id),
+	})
+	if err != nil {
+		return
+	}
+	if _, deleted := out.Item["deleted_at"]; len(out.Item) == 0 || deleted {
+		err = ErrNotFound
+		return
+	}
+	err = unmarshalBug(out.Item, &bug)
+	return
+}
+
+// FindBug return the bug with id or issue key ref, ErrNotFound when absent
+func (d Dynamo) FindBug(ref string) (bug Bug, err error) {
+	ref = strings.TrimSpace(ref)
+	if !issueKey.MatchString(strings.ToUpper(ref)) {
+		return d.GetBug(ref)
+	}
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.Query(&dynamodb.QueryInput{
+		TableName:              table(),
+		IndexName:              aws.String(IssueKeyIndex),
+		KeyConditionExpression: aws.String(IssueKeyIndex + "pk = :pk"),
+		FilterExpression:       aws.String(notDeleted),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {S: aws.String(d.partition("ISSUE#" + strings.ToUpper(ref)))},
+		},
+	})
+	if err != nil {
+		return
+	}
+	if len(out.Items) == 0 {
+		err = ErrNotFound
+		return
+	}
+	err = unmarshalBug(out.Items[0], &bug)
+	return
+}
+
+// ListBugs return a page of bugs matching filter and the cursor of the next
+// page. User, product and status filters query their index newest first,
+// anything else falls back to a scan of the bug items. Deleted bugs are left
+// out unless filter asks for them
+func (d Dynamo) ListBugs(filter Filter) (bugs []Bug, cursor string, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var start map[string]*dynamodb.AttributeValue
+	if len(filter.Cursor) > 0 {
+		if start, err = decodeCursor(filter.Cursor); err != nil {
+			return
+		}
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	values := map[string]*dynamodb.AttributeValue{}
+	names := map[string]*string{}
+	var keyCondition, filterExpression *string
+	var index string
+	eq := func(attribute, value string) {
+		name, placeholder := "#"+attribute, ":"+attribute
+		names[name] = aws.String(attribute)
+		values[placeholder] = &dynamodb.AttributeValue{S: aws.String(value)}
+		filterExpression = and(filterExpression, name+" = "+placeholder)
+	}
+	var partition string
+	switch {
+	case len(filter.UserID) > 0:
+		index, partition = UserIndex, d.partition("USER#"+filter.UserID)
+	case len(filter.Product) > 0:
+		index, partition = ProductIndex, d.partition("PRODUCT#"+filter.Product)
+	case len(filter.Status) > 0:
+		index, partition = StatusIndex, d.partition("STATUS#"+filter.Status)
+	}
+	if len(index) > 0 {
+		values[":pk"] = &dynamodb.AttributeValue{S: aws.String(partition)}
+		keyCondition = aws.String(index + "pk = :pk")
+	} else {
+		values[":metadata"] = &dynamodb.AttributeValue{S: aws.String(metadata)}
+		values[":bugs"] = &dynamodb.AttributeValue{S: aws.String(d.partition(bugPrefix))}
+		filterExpression = aws.String("sk = :metadata AND begins_with(pk, :bugs)")
+	}
+	if filter.Deleted {
+		filterExpression = and(filterExpression, "attribute_exists(deleted_at)")
+	} else {
+		filterExpression = and(filterExpression, notDeleted)
+	}
+	if len(filter.Product) > 0 && len(filter.UserID) > 0 {
+		eq("product", filter.Product)
+	}
+	if len(filter.Status) > 0 && (len(filter.UserID) > 0 || len(filter.Product) > 0) {
+		eq("status", filter.Status)
+	}
+	if len(filter.Severity) > 0 {
+		eq("severity", filter.Severity)
+	}
+	if len(filter.Tag) > 0 {
+		names["#tags"] = aws.String("tags")
+		values[":tag"] = &dynamodb.AttributeValue{S: aws.String(filter.Tag)}
+		filterExpression = and(filterExpression, "contains(#tags, :tag)")
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		since, until := filter.Since, filter.Until
+		if until.IsZero() {
+			until = time.Now().AddDate(100, 0, 0)
+		}
+		values[":since"] = &dynamodb.AttributeValue{S: aws.String(since.Format(time.RFC3339Nano))}
+		values[":until"] = &dynamodb.AttributeValue{S: aws.String(until.Format(time.RFC3339Nano))}
+		if keyCondition != nil {
+			keyCondition = and(keyCondition, index+"sk BETWEEN :since AND :until")
+		} else {
+			names["#created_at"] = aws.String("created_at")
+			filterExpression = and(filterExpression, "#created_at BETWEEN :since AND :until")
+		}
+	}
+	if len(names) == 0 {
+		names = nil
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	var last map[string]*dynamodb.AttributeValue
+	if keyCondition != nil {
+		out, queryErr := srv.Query(&dynamodb.QueryInput{
+			TableName:                 table(),
+			IndexName:                 aws.String(index),
+			KeyConditionExpression:    keyCondition,
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         start,
+			ScanIndexForward:          aws.Bool(false),
+			Limit:                     aws.Int64(limit),
+		})
+		if queryErr != nil {
+			err = queryErr
+			return
+		}
+		items, last = out.Items, out.LastEvaluatedKey
+	} else {
+		out, scanErr := srv.Scan(&dynamodb.ScanInput{
+			TableName:                 table(),
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         start,
+			Limit:                     aws.Int64(limit),
+		})
+		if scanErr != nil {
+			err = scanErr
+			return
+		}
+		items, last = out.Items, out.LastEvaluatedKey
+	}
+
+	if err = unmarshalBugs(items, &bugs); err != nil {
+		return
+	}
+	if len(last) > 0 {
+		cursor, err = encodeCursor(last)
+	}
+	return
+}
+
+// AllBugs return every bug matching filter, following pages until exhausted
+func AllBugs(filter Filter) (bugs []Bug, err error) {
+	filter.Limit = 100
+	for {
+		page, cursor, listErr := ListBugs(filter)
+		if listErr != nil {
+			return bugs, listErr
+		}
+		bugs = append(bugs, page...)
+		if len(cursor) == 0 {
+			return
+		}
+		filter.Cursor = cursor
+	}
+}
+
+// ScanBugs return the bugs of every team, filed or not, leaving deleted
+// ones out. Every item of the table is read, so this is for batch jobs
+func ScanBugs() (bugs []Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	err = srv.ScanPages(&dynamodb.ScanInput{
+		TableName:        table(),
+		FilterExpression: aws.String("sk = :metadata AND begins_with(pk, :team) AND " + notDeleted),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":metadata": {S: aws.String(metadata)},
+			":team":     {S: aws.String(teamPrefix)},
+		},
+	}, func(page *dynamodb.ScanOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	log.Printf("store.ScanBugs - bugs: %d, error: %v", len(items), err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBugs(items, &bugs)
+	return
+}
+
+// SetIssues record the issues a bug was filed as and mark it filed
+func (d Dynamo) SetIssues(bug Bug, issues []Issue) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	list, err := dynamodbattribute.Marshal(issues)
+	if err != nil {
+		return
+	}
+	var issueKey string
+	for _, issue := range issues {
+		if len(issue.Key) > 0 {
+			issueKey = issue.Key
+			break
+		}
+	}
+	now := time.Now()
+	change, err := changeList(Change{Status: StatusFiled, From: bug.Status, At: now})
+	if err != nil {
+		return
+	}
+	update := "SET issues = :issues, #status = :status, " + StatusIndex + "pk = :status_pk, updated_at = :now, " + appendHistory
+	values := map[string]*dynamodb.AttributeValue{
+		":one":       {N: aws.String("1")},
+		":issues":    list,
+		":status":    {S: aws.String(StatusFiled)},
+		":status_pk": {S: aws.String(d.partition("STATUS#" + StatusFiled))},
+		":now":       {S: aws.String(now.Format(time.RFC3339Nano))},
+		":change":    change,
+		":empty":     {L: []*dynamodb.AttributeValue{}},
+	}
+	if len(issueKey) > 0 {
+		update += ", issue_key = :issue_key, " + IssueKeyIndex + "pk = :issue_pk"
+		values[":issue_key"] = &dynamodb.AttributeValue{S: aws.String(issueKey)}
+		values[":issue_pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition("ISSUE#" + issueKey))}
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String(update + " " + bumpVersion),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: values,
+	})
+	log.Printf("store.SetIssues (%s) - issues: %+v, error: %v", bug.ID, issues, err)
+	return
+}
+
+// LinkIssues replace the issues of bug, e.g. with one found or filed again
+// when reconciling, leaving its status alone
+func (d Dynamo) LinkIssues(bug Bug, issues []Issue) (err error) {
+	defer func() {
+		log.Printf("store.LinkIssues (%s) - issues: %+v, error: %v", bug.ID, issues, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	list, err := dynamodbattribute.Marshal(issues)
+	if err != nil {
+		return
+	}
+	update := "SET issues = :issues, updated_at = :now"
+	values := map[string]*dynamodb.AttributeValue{
+		":one":    {N: aws.String("1")},
+		":issues": list,
+		":now":    {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+	}
+	for _, issue := range issues {
+		if len(issue.Key) > 0 {
+			update += ", issue_key = :issue_key, " + IssueKeyIndex + "pk = :issue_pk"
+			values[":issue_key"] = &dynamodb.AttributeValue{S: aws.String(issue.Key)}
+			values[":issue_pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition("ISSUE#" + issue.Key))}
+			break
+		}
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String(update + " " + bumpVersion),
+		ConditionExpression:       aws.String("attribute_exists(pk)"),
+		ExpressionAttributeValues: values,
+	})
+	return
+}
+
+// UpdateStatus set the status (and resolution) of bug as changed by actor,
+// returning the updated bug, ErrConflict when it changed since it was read
+func (d Dynamo) UpdateStatus(bug Bug, status, resolution, actor string) (updated Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	change, err := changeList(Change{Status: status, From: bug.Status, Resolution: resolution, Actor: actor, At: now})
+	if err != nil {
+		return
+	}
+	update := "SET #status = :status, " + StatusIndex + "pk = :status_pk, updated_at = :now, " + appendHistory
+	values := map[string]*dynamodb.AttributeValue{
+		":status":    {S: aws.String(status)},
+		":status_pk": {S: aws.String(d.partition("STATUS#" + status))},
+		":now":       {S: aws.String(now.Format(time.RFC3339Nano))},
+		":change":    change,
+		":empty":     {L: []*dynamodb.AttributeValue{}},
+	}
+	if len(resolution) > 0 {
+		update += ", resolution = :resolution"
+		values[":resolution"] = &dynamodb.AttributeValue{S: aws.String(resolution)}
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String(update + " " + bumpVersion),
+		ConditionExpression:       aws.String(versioned(bug, values)),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	err = conflict(err)
+	log.Printf("store.UpdateStatus (%s) - status: %s, version: %d, error: %v", bug.ID, status, bug.Version, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &updated)
+	return
+}
+
+// UpdateBug save the edited summary, product, severity and details of bug,
+// returning the updated bug, ErrConflict when it changed since it was read
+func (d Dynamo) UpdateBug(bug Bug) (updated Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	if err = encryptDetails(&bug); err != nil {
+		return
+	}
+	update := "SET summary = :summary, product = :product, " + ProductIndex + "pk = :product_pk, severity = :severity, details = :details, updated_at = :now"
+	values := map[string]*dynamodb.AttributeValue{
+		":summary":    {S: aws.String(bug.Summary)},
+		":product":    {S: aws.String(bug.Product)},
+		":product_pk": {S: aws.String(d.partition("PRODUCT#" + bug.Product))},
+		":severity":   {S: aws.String(bug.Severity)},
+		// empty strings are not valid attribute values
+		":details": {NULL: aws.Bool(true)},
+		":now":     {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+	}
+	if len(bug.Details) > 0 {
+		values[":details"] = &dynamodb.AttributeValue{S: aws.String(bug.Details)}
+	}
+	var remove []string
+	if len(bug.Tags) > 0 {
+		tags, marshalErr := dynamodbattribute.Marshal(bug.Tags)
+		if marshalErr != nil {
+			return updated, marshalErr
+		}
+		update += ", tags = :tags"
+		values[":tags"] = tags
+	} else {
+		remove = append(remove, "tags")
+	}
+	if bug.DetailsEncrypted != nil {
+		encrypted, marshalErr := dynamodbattribute.Marshal(bug.DetailsEncrypted)
+		if marshalErr != nil {
+			return updated, marshalErr
+		}
+		update += ", details_encrypted = :encrypted"
+		values[":encrypted"] = encrypted
+	} else {
+		remove = append(remove, "details_encrypted")
+	}
+	if len(remove) > 0 {
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String(update + " " + bumpVersion),
+		ConditionExpression:       aws.String(versioned(bug, values)),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	err = conflict(err)
+	log.Printf("store.UpdateBug (%s) - version: %d, error: %v", bug.ID, bug.Version, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &updated)
+	return
+}
+
+// SetAssignee record the chat user ID the bug was assigned to
+func (d Dynamo) SetAssignee(bug Bug, assignee string) (err error) {
+	err = d.set(bug, "assignee", assignee)
+	log.Printf("store.SetAssignee (%s) - assignee: %s, error: %v", bug.ID, assignee, err)
+	return
+}
+
+// SetThread record the triage message of bug
+func (d Dynamo) SetThread(bug Bug, thread Thread) (err error) {
+	err = d.set(bug, "thread", thread)
+	log.Printf("store.SetThread (%s) - thread: %+v, error: %v", bug.ID, thread, err)
+	return
+}
+
+// SetAnnouncements record the messages bug was announced as in subscribed
+// channels
+func (d Dynamo) SetAnnouncements(bug Bug, announcements []Thread) (err error) {
+	err = d.set(bug, "announced", announcements)
+	log.Printf("store.SetAnnouncements (%s) - announcements: %d, error: %v", bug.ID, len(announcements), err)
+	return
+}
+
+// SetEscalated record the names of the escalation rules bug was escalated
+// by, so none escalates it twice
+func (d Dynamo) SetEscalated(bug Bug, escalated []string) (err error) {
+	err = d.set(bug, "escalated", escalated)
+	log.Printf("store.SetEscalated (%s) - escalated: %v, error: %v", bug.ID, escalated, err)
+	return
+}
+
+// LinkDuplicate record bug as a possible duplicate of original, and
+// original as similar to bug
+func (d Dynamo) LinkDuplicate(bug, original Bug) (err error) {
+	defer func() {
+		log.Printf("store.LinkDuplicate (%s/%s) - error: %v", bug.ID, original.ID, err)
+	}()
+	if err = d.set(bug, "possible_duplicate_of", original.ID); err != nil {
+		return
+	}
+	return d.appendTo(original, "similar", []string{bug.ID})
+}
+
+// Merge record duplicate as merged into survivor, CC'ing cc on survivor,
+// ErrConflict when either changed since it was read
+func (d Dynamo) Merge(duplicate, survivor Bug, cc []string) (err error) {
+	defer func() {
+		log.Printf("store.Merge (%s/%s) - cc: %v, error: %v", duplicate.ID, survivor.ID, cc, err)
+	}()
+	if err = d.setAttribute(survivor, "cc", cc, true); err != nil {
+		return
+	}
+	return d.setAttribute(duplicate, "merged_into", survivor.ID, true)
+}
+
+// Recur count another recurrence of bug, renewing its retention, and
+// return the updated bug
+func (d Dynamo) Recur(bug Bug) (updated Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	values := map[string]*dynamodb.AttributeValue{
+		":one": {N: aws.String("1")},
+		":now": {S: aws.String(now.Format(time.RFC3339Nano))},
+	}
+	update := "SET recurred_at = :now, updated_at = :now ADD recurrences :one, version :one REMOVE #ttl"
+	if ttl := Expiry(now); ttl > 0 {
+		update = "SET recurred_at = :now, updated_at = :now, #ttl = :ttl ADD recurrences :one, version :one"
+		values[":ttl"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(ttl, 10))}
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           table(),
+		Key:                 d.key(bug.ID),
+		UpdateExpression:    aws.String(update),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+		// TTL is a reserved word
+		ExpressionAttributeNames:  map[string]*string{"#ttl": aws.String("ttl")},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	log.Printf("store.Recur (%s) - recurrences: %d, error: %v", bug.ID, bug.Recurrences+1, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &updated)
+	return
+}
+
+// Verify record userID confirming the resolution of bug fixed it
+func (d Dynamo) Verify(bug Bug, userID string) (updated Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           table(),
+		Key:                 d.key(bug.ID),
+		UpdateExpression:    aws.String("SET verified_by = :user, verified_at = :now, updated_at = :now ADD version :one"),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":  {N: aws.String("1")},
+			":user": {S: aws.String(userID)},
+			":now":  {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	log.Printf("store.Verify (%s/%s) - error: %v", bug.ID, userID, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &updated)
+	return
+}
+
+// DeleteBug mark bug deleted as of now, hiding it from every query,
+// ErrConflict when it changed since it was read
+func (d Dynamo) DeleteBug(bug Bug) (deleted Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	values := map[string]*dynamodb.AttributeValue{
+		":now": {S: aws.String(now.Format(time.RFC3339Nano))},
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String("SET deleted_at = :now, updated_at = :now " + bumpVersion),
+		ConditionExpression:       aws.String(versioned(bug, values)),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	err = conflict(err)
+	log.Printf("store.DeleteBug (%s) - error: %v", bug.ID, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &deleted)
+	return
+}
+
+// RestoreBug clear the deleted mark of bug id, ErrNotFound unless it is deleted
+func (d Dynamo) RestoreBug(id string) (restored Bug, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	out, err := srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           table(),
+		Key:                 d.key(id),
+		UpdateExpression:    aws.String("SET updated_at = :now REMOVE deleted_at " + bumpVersion),
+		ConditionExpression: aws.String("attribute_exists(deleted_at)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+			":one": {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if conflict(err) == ErrConflict {
+		err = ErrNotFound
+	}
+	log.Printf("store.RestoreBug (%s) - error: %v", id, err)
+	if err != nil {
+		return
+	}
+	err = unmarshalBug(out.Attributes, &restored)
+	return
+}
+
+// PurgeBug delete the item of bug id, unlike DeleteBug leaving nothing to
+// restore, for the smoke test bugs
+func (d Dynamo) PurgeBug(id string) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	_, err = srv.DeleteItem(&dynamodb.DeleteItemInput{TableName: table(), Key: d.key(id)})
+	log.Printf("store.PurgeBug (%s) - error: %v", id, err)
+	return
+}
+
+// set update a single attribute of an existing bug, one not computed from
+// the rest of it
+func (d Dynamo) set(bug Bug, attribute string, value interface{}) error {
+	return d.setAttribute(bug, attribute, value, false)
+}
+
+// setAttribute update a single attribute of an existing bug, bumping its
+// version. When checked, the bug must still be at the version it was read
+// at, or ErrConflict is returned
+func (d Dynamo) setAttribute(bug Bug, attribute string, value interface{}, checked bool) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	item, err := dynamodbattribute.Marshal(value)
+	if err != nil {
+		return
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":one":   {N: aws.String("1")},
+		":value": item,
+		":now":   {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+	}
+	condition := "attribute_exists(pk)"
+	if checked {
+		condition = versioned(bug, values)
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 table(),
+		Key:                       d.key(bug.ID),
+		UpdateExpression:          aws.String("SET #attribute = :value, updated_at = :now " + bumpVersion),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  map[string]*string{"#attribute": aws.String(attribute)},
+		ExpressionAttributeValues: values,
+	})
+	return conflict(err)
+}
+
+// appendTo add values to the list attribute of an existing bug as stored,
+// rather than as read, bumping its version
+func (d Dynamo) appendTo(bug Bug, attribute string, values interface{}) (err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	list, err := dynamodbattribute.Marshal(values)
+	if err != nil {
+		return
+	}
+	_, err = srv.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                table(),
+		Key:                      d.key(bug.ID),
+		UpdateExpression:         aws.String("SET #attribute = list_append(if_not_exists(#attribute, :empty), :values), updated_at = :now " + bumpVersion),
+		ConditionExpression:      aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames: map[string]*string{"#attribute": aws.String(attribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":    {N: aws.String("1")},
+			":values": list,
+			":empty":  {L: []*dynamodb.AttributeValue{}},
+			":now":    {S: aws.String(time.Now().Format(time.RFC3339Nano))},
+		},
+	})
+	return
+}
+
+// AddComment store comment as a COMMENT# item of its bug
+func (d Dynamo) AddComment(comment Comment) (err error) {
+	defer func() {
+		log.Printf("store.AddComment (%s/%s) - error: %v", comment.BugID, comment.UserID, err)
+	}()
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	comment.CommentID = comment.CreatedAt.UTC().Format(time.RFC3339Nano) + "/" + NewID()
+	item, err := dynamodbattribute.MarshalMap(comment)
+	if err != nil {
+		return
+	}
+	item["pk"] = &dynamodb.AttributeValue{S: aws.String(d.partition(bugPrefix + comment.BugID))}
+	item["sk"] = &dynamodb.AttributeValue{S: aws.String(commentPrefix + comment.CommentID)}
+	_, err = srv.PutItem(&dynamodb.PutItemInput{
+		TableName:           table(),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	return
+}
+
+// ListComments return the comments of bug id, oldest first
+func (d Dynamo) ListComments(bugID string) (comments []Comment, err error) {
+	srv, err := GetDB()
+	if err != nil {
+		return
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	err = srv.QueryPages(&dynamodb.QueryInput{
+		TableName:              table(),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":     {S: aws.String(d.partition(bugPrefix + bugID))},
+			":prefix": {S: aws.String(commentPrefix)},
+		},
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &comments)
+	return
+}
+
+// notDeleted is the filter leaving out deleted bugs
+const notDeleted = "attribute_not_exists(deleted_at)"
+
+// bumpVersion is the update clause incrementing the version of a bug, needing
+// :one among the values
+const bumpVersion = "ADD version :one"
+
+// versioned return the condition that bug still exists at the version it was
+// read at, adding the values it needs. Bugs stored before versioning have none
+func versioned(bug Bug, values map[string]*dynamodb.AttributeValue) string {
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	values[":version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(bug.Version, 10))}
+	if bug.Version == 0 {
+		return "attribute_exists(pk) AND (attribute_not_exists(version) OR version = :version)"
+	}
+	return "attribute_exists(pk) AND version = :version"
+}
+
+// conflict translate a failed version condition to ErrConflict
+func conflict(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrConflict
+	}
+	return err
+}
+
+// appendHistory is the update clause adding :change to the status history
+const appendHistory = "history = list_append(if_not_exists(history, :empty), :change)"
+
+func changeList(change Change) (*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.Marshal(change)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{item}}, nil
+}
+
+func and(expression *string, condition string) *string {
+	if expression == nil {
+		return aws.String(condition)
+	}
+	return aws.String(*expression + " AND " + condition)
+}
+
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	var plain map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(key, &plain); err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var plain map[string]interface{}
+	if err = json.Unmarshal(raw, &plain); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return dynamodbattribute.MarshalMap(plain)
+}
@@ -0,0 +1,175 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+const (
+	openIDConfig = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	issuer       = "https://api.botframework.com"
+	clockSkew    = 5 * time.Minute
+	keysTTL      = 24 * time.Hour
+)
+
+var (
+	keysMu      sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+)
+
+// Verify check the Bot Framework bearer token sent with an activity was
+// issued for appID, signed by one of the published Bot Framework keys and
+// bound to the activity's service URL, which the token must name
+func Verify(authorization, appID, serviceURL string) error {
+	if len(appID) == 0 {
+		return errors.New("no app id to verify the audience against")
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || token == authorization {
+		return errors.New("missing bearer token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("unexpected signing algorithm: %s", header.Alg)
+	}
+	key, err := signingKey(header.Kid)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return errors.New("invalid token signature")
+	}
+
+	var claims struct {
+		Iss string `json:"iss"`
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Nbf int64  `json:"nbf"`
+
+		ServiceURL string `json:"serviceurl"`
+	}
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	now := time.Now()
+	switch {
+	case claims.Iss != issuer:
+		return fmt.Errorf("unexpected issuer: %s", claims.Iss)
+	case claims.Aud != appID:
+		return fmt.Errorf("unexpected audience: %s", claims.Aud)
+	case now.Add(-clockSkew).After(time.Unix(claims.Exp, 0)):
+		return errors.New("token expired")
+	case claims.Nbf > 0 && now.Add(clockSkew).Before(time.Unix(claims.Nbf, 0)):
+		return errors.New("token not yet valid")
+	case len(claims.ServiceURL) == 0:
+		return errors.New("missing service url claim")
+	case claims.ServiceURL != serviceURL:
+		return fmt.Errorf("unexpected service url: %s", serviceURL)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, into interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, into)
+}
+
+// signingKey return the published key for kid, refreshing the key set daily
+// or when an unknown kid shows up
+func signingKey(kid string) (*rsa.PublicKey, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if key, ok := keys[kid]; ok && time.Since(keysFetched) < keysTTL {
+		return key, nil
+	}
+	fetched, err := fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	keys, keysFetched = fetched, time.Now()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+func fetchKeys() (map[string]*rsa.PublicKey, error) {
+	var config struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := getJSON(openIDConfig, &config); err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(config.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	fetched := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, nErr := base64.RawURLEncoding.DecodeString(k.N)
+		e, eErr := base64.RawURLEncoding.DecodeString(k.E)
+		if nErr != nil || eErr != nil {
+			continue
+		}
+		fetched[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return fetched, nil
+}
+
+func getJSON(url string, into interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := outbound.Do(outbound.TeamsAuth, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
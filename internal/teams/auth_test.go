@@ -0,0 +1,96 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const (
+	testAppID      = "app-id"
+	testServiceURL = "https://smba.trafficmanager.net/emea/"
+)
+
+// testKey is installed as the only Bot Framework signing key, kid "test"
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keysMu.Lock()
+	keys, keysFetched = map[string]*rsa.PublicKey{"test": &key.PublicKey}, time.Now()
+	keysMu.Unlock()
+	return key
+}
+
+func token(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	key := testKey(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := map[string]interface{}{"alg": "RS256", "kid": "test"}
+	claims := func(change func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":        issuer,
+			"aud":        testAppID,
+			"exp":        time.Now().Add(time.Hour).Unix(),
+			"nbf":        time.Now().Add(-time.Minute).Unix(),
+			"serviceurl": testServiceURL,
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	tests := []struct {
+		name          string
+		authorization string
+		appID         string
+		valid         bool
+	}{
+		{"valid", "Bearer " + token(t, key, header, claims(nil)), testAppID, true},
+		{"no bearer", token(t, key, header, claims(nil)), testAppID, false},
+		{"no app id", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["aud"] = "" })), "", false},
+		{"other signer", "Bearer " + token(t, other, header, claims(nil)), testAppID, false},
+		{"hs256", "Bearer " + token(t, key, map[string]interface{}{"alg": "HS256", "kid": "test"}, claims(nil)), testAppID, false},
+		{"other issuer", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["iss"] = "https://example.com" })), testAppID, false},
+		{"other audience", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["aud"] = "other" })), testAppID, false},
+		{"expired", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), testAppID, false},
+		{"not yet valid", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() })), testAppID, false},
+		{"no service url", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { delete(c, "serviceurl") })), testAppID, false},
+		{"other service url", "Bearer " + token(t, key, header, claims(func(c map[string]interface{}) { c["serviceurl"] = "https://example.com/" })), testAppID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.authorization, tt.appID, testServiceURL)
+			if (err == nil) != tt.valid {
+				t.Errorf("Verify() = %v, want valid: %v", err, tt.valid)
+			}
+		})
+	}
+}
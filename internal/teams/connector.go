@@ -0,0 +1,173 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+)
+
+const (
+	tokenEndpoint = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	tokenScope    = "https://api.botframework.com/.default"
+)
+
+// AdaptiveCardType is the attachment content type of Adaptive Cards
+const AdaptiveCardType = "application/vnd.microsoft.card.adaptive"
+
+// Activity is the Bot Framework activity exchanged with Teams
+type Activity struct {
+	Type         string          `json:"type"`
+	ID           string          `json:"id,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	ServiceURL   string          `json:"serviceUrl,omitempty"`
+	ChannelID    string          `json:"channelId,omitempty"`
+	From         Account         `json:"from"`
+	Recipient    Account         `json:"recipient"`
+	Conversation Conversation    `json:"conversation"`
+	ReplyToID    string          `json:"replyToId,omitempty"`
+	Value        json.RawMessage `json:"value,omitempty"`
+	Attachments  []Attachment    `json:"attachments,omitempty"`
+}
+
+// Account is a Teams user or bot
+type Account struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+// Conversation is the chat or channel an activity belongs to
+type Conversation struct {
+	ID string `json:"id"`
+}
+
+// Attachment carries a card on an activity
+type Attachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+// Client replies to activities through the Bot Connector service
+type Client struct {
+	AppID       string
+	AppPassword string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Reply post reply into the conversation of activity
+func (client *Client) Reply(activity Activity, reply Activity) (err error) {
+	reply.From = activity.Recipient
+	reply.Recipient = activity.From
+	reply.Conversation = activity.Conversation
+	reply.ReplyToID = activity.ID
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return
+	}
+	endpoint := fmt.Sprintf("%sv3/conversations/%s/activities/%s",
+		serviceURL(activity), url.PathEscape(activity.Conversation.ID), url.PathEscape(activity.ID))
+	resp, err := client.do("POST", endpoint, body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	log.Printf("teams.Client.Reply - conversation: %s, error: %v", activity.Conversation.ID, err)
+	return
+}
+
+// Email return the email of the activity sender, empty when unavailable
+func (client *Client) Email(activity Activity) string {
+	endpoint := fmt.Sprintf("%sv3/conversations/%s/members/%s",
+		serviceURL(activity), url.PathEscape(activity.Conversation.ID), url.PathEscape(activity.From.ID))
+	resp, err := client.do("GET", endpoint, nil)
+	if err != nil {
+		log.Printf("teams.Client.Email - error: %v", err)
+		return ""
+	}
+	defer resp.Body.Close()
+	var member struct {
+		Email             string `json:"email"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return ""
+	}
+	if len(member.Email) > 0 {
+		return member.Email
+	}
+	return member.UserPrincipalName
+}
+
+func (client *Client) do(method, endpoint string, body []byte) (*http.Response, error) {
+	token, err := client.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return outbound.Do(outbound.Teams, req)
+}
+
+// accessToken return a cached Bot Connector token, fetching a new one with
+// the app credentials once it is about to expire
+func (client *Client) accessToken() (string, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.token) > 0 && time.Now().Before(client.expires) {
+		return client.token, nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", client.AppID)
+	form.Set("client_secret", client.AppPassword)
+	form.Set("scope", tokenScope)
+	req, err := http.NewRequest("POST", tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := outbound.Do(outbound.TeamsAuth, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if len(token.AccessToken) == 0 {
+		return "", fmt.Errorf("token request failed: %s", token.Error)
+	}
+	client.token = token.AccessToken
+	client.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return client.token, nil
+}
+
+func serviceURL(activity Activity) string {
+	if strings.HasSuffix(activity.ServiceURL, "/") {
+		return activity.ServiceURL
+	}
+	return activity.ServiceURL + "/"
+}
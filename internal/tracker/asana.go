@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const asanaTasks = "https://app.asana.com/api/1.0/tasks"
+
+// Asana files bugs as tasks in the product's Asana project
+type Asana struct {
+	Token           string
+	Projects        map[string]string
+	SeverityField   string
+	SeverityOptions map[string]string
+	ReporterField   string
+}
+
+// NewAsana return Asana tracker configured from env
+func NewAsana() Asana {
+	return Asana{
+		Token:           os.Getenv("ASANA_TOKEN"),
+		Projects:        envMap("ASANA_PROJECTS"),
+		SeverityField:   os.Getenv("ASANA_SEVERITY_FIELD"),
+		SeverityOptions: envMap("ASANA_SEVERITY_OPTIONS"),
+		ReporterField:   os.Getenv("ASANA_REPORTER_FIELD"),
+	}
+}
+
+// Name return tracker name
+func (asana Asana) Name() string {
+	return "asana"
+}
+
+// CreateIssue create an Asana task for the bug
+func (asana Asana) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	project, ok := asana.Projects[bug.Product]
+	if !ok {
+		project, ok = asana.Projects["default"]
+	}
+	if !ok {
+		err = fmt.Errorf("no asana project for product: %s", bug.Product)
+		return
+	}
+	customFields := map[string]string{}
+	if option, ok := asana.SeverityOptions[bug.Severity]; ok && len(asana.SeverityField) > 0 {
+		customFields[asana.SeverityField] = option
+	}
+	if len(asana.ReporterField) > 0 {
+		customFields[asana.ReporterField] = bug.UserName
+	}
+	task := map[string]interface{}{
+		"name":     bug.Summary,
+		"notes":    fmt.Sprintf("Product: %s\nSeverity: %s\nReporter: %s\n\n%s", bug.ProductName(), bug.Severity, bug.UserName, bug.Details),
+		"projects": []string{project},
+	}
+	if len(customFields) > 0 {
+		task["custom_fields"] = customFields
+	}
+	body, err := json.Marshal(map[string]interface{}{"data": task})
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", asanaTasks, bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+asana.Token)
+	rr, err := outbound.Do(asana.Name(), r)
+	if err != nil {
+		log.Printf("tracker.Asana.CreateIssue - task: %+v, error: %v", task, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var created struct {
+		Data struct {
+			GID          string `json:"gid"`
+			PermalinkURL string `json:"permalink_url"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.Asana.CreateIssue - created: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: asana.Name(),
+		ID:      created.Data.GID,
+		Key:     created.Data.GID,
+		URL:     created.Data.PermalinkURL,
+	}
+	return
+}
@@ -0,0 +1,121 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const azureWorkItem = "https://dev.azure.com/%s/%s/_apis/wit/workitems/$Bug?api-version=7.0"
+
+// azureSeverity maps bug severity to the Microsoft.VSTS.Common.Severity picklist
+var azureSeverity = map[string]string{
+	store.SeverityBlocker:  "1 - Critical",
+	store.SeverityCritical: "2 - High",
+	store.SeverityMajor:    "3 - Medium",
+	store.SeverityMinor:    "4 - Low",
+	store.SeverityTrivial:  "4 - Low",
+}
+
+// AzureDevOps files bugs as Azure Boards Bug work items
+type AzureDevOps struct {
+	Organization string
+	Project      string
+	PAT          string
+	AreaPaths    map[string]string
+}
+
+// NewAzureDevOps return Azure DevOps tracker configured from env
+func NewAzureDevOps() AzureDevOps {
+	return AzureDevOps{
+		Organization: os.Getenv("AZURE_DEVOPS_ORG"),
+		Project:      os.Getenv("AZURE_DEVOPS_PROJECT"),
+		PAT:          os.Getenv("AZURE_DEVOPS_PAT"),
+		AreaPaths:    envMap("AZURE_DEVOPS_AREA_PATHS"),
+	}
+}
+
+// Name return tracker name
+func (azure AzureDevOps) Name() string {
+	return "azure"
+}
+
+// CreateIssue create a Bug work item for the bug
+func (azure AzureDevOps) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	type op struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}
+	repro := fmt.Sprintf("Product: %s<br>Severity: %s<br>Reporter: %s<br><br>%s",
+		html.EscapeString(bug.ProductName()),
+		html.EscapeString(bug.Severity),
+		html.EscapeString(bug.UserName),
+		strings.Replace(html.EscapeString(bug.Details), "\n", "<br>", -1),
+	)
+	ops := []op{
+		{"add", "/fields/System.Title", bug.Summary},
+		{"add", "/fields/Microsoft.VSTS.TCM.ReproSteps", repro},
+		{"add", "/fields/Microsoft.VSTS.Common.Severity", azureSeverity[bug.Severity]},
+		{"add", "/fields/System.Tags", "slack"},
+	}
+	area, ok := azure.AreaPaths[bug.Product]
+	if !ok {
+		area = azure.AreaPaths["default"]
+	}
+	if len(area) > 0 {
+		ops = append(ops, op{"add", "/fields/System.AreaPath", area})
+	}
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return
+	}
+
+	endpoint := fmt.Sprintf(azureWorkItem, url.PathEscape(azure.Organization), url.PathEscape(azure.Project))
+	r, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.SetBasicAuth("", azure.PAT)
+	r.Header.Set("Content-Type", "application/json-patch+json")
+	rr, err := outbound.Do(azure.Name(), r)
+	if err != nil {
+		log.Printf("tracker.AzureDevOps.CreateIssue - area: %s, error: %v", area, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var created struct {
+		ID    int `json:"id"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"_links"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.AzureDevOps.CreateIssue - work item: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: azure.Name(),
+		ID:      fmt.Sprint(created.ID),
+		Key:     fmt.Sprintf("Bug %d", created.ID),
+		URL:     created.Links.HTML.Href,
+	}
+	return
+}
@@ -0,0 +1,38 @@
+package tracker
+
+import (
+	"log"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// A tracker's breaker opens after breakerThreshold failures in a row, and
+// lets a call through again breakerCooldown after the latest failure
+const (
+	breakerThreshold = 3
+	breakerCooldown  = 5 * time.Minute
+)
+
+// Available report whether tracker name should be called, false while its
+// breaker is open. The breaker is assumed closed when its state is unreadable
+func Available(name string) bool {
+	circuit, err := store.GetCircuit(name)
+	if err != nil {
+		log.Printf("tracker.Available (%s) - error: %v", name, err)
+		return true
+	}
+	return circuit.Failures < breakerThreshold || circuit.OpenedAt == nil || time.Since(*circuit.OpenedAt) >= breakerCooldown
+}
+
+// Record count the outcome of a call to tracker name, a success closes its
+// breaker
+func Record(name string, err error) {
+	if err != nil {
+		_, _ = store.FailCircuit(name, breakerThreshold)
+		return
+	}
+	if circuit, getErr := store.GetCircuit(name); getErr == nil && circuit.Failures > 0 {
+		_ = store.ResetCircuit(name)
+	}
+}
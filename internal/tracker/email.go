@@ -0,0 +1,121 @@
+package tracker
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const emailText = `A bug was reported via Slack.
+
+Summary:  {{.Summary}}
+Product:  {{.ProductName}}
+Severity: {{.Severity}}
+Reporter: {{.UserName}}
+Reported: {{.CreatedAt.Format "2006-01-02 15:04 MST"}}
+
+{{.Details}}
+`
+
+const emailHTML = `<html>
+<body style="font-family: sans-serif;">
+<p>A bug was reported via Slack.</p>
+<table>
+<tr><th align="left">Summary</th><td>{{.Summary}}</td></tr>
+<tr><th align="left">Product</th><td>{{.ProductName}}</td></tr>
+<tr><th align="left">Severity</th><td>{{.Severity}}</td></tr>
+<tr><th align="left">Reporter</th><td>{{.UserName}}</td></tr>
+<tr><th align="left">Reported</th><td>{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</td></tr>
+</table>
+<pre style="white-space: pre-wrap;">{{.Details}}</pre>
+</body>
+</html>
+`
+
+var (
+	emailTextTemplate = texttemplate.Must(texttemplate.New("text").Parse(emailText))
+	emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(emailHTML))
+)
+
+// Email sends a formatted report to the product's distribution list via SES
+type Email struct {
+	From  string
+	Lists map[string]string
+}
+
+// NewEmail return Email tracker configured from env
+func NewEmail() Email {
+	return Email{
+		From:  os.Getenv("EMAIL_FROM"),
+		Lists: envMap("EMAIL_LISTS"),
+	}
+}
+
+// Name return tracker name
+func (email Email) Name() string {
+	return "email"
+}
+
+// CreateIssue email the bug report to the product distribution list
+func (email Email) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	list, ok := email.Lists[bug.Product]
+	if !ok {
+		list, ok = email.Lists["default"]
+	}
+	if !ok {
+		err = fmt.Errorf("no distribution list for product: %s", bug.Product)
+		return
+	}
+	var to []*string
+	for _, address := range strings.Split(list, ",") {
+		if address = strings.TrimSpace(address); len(address) > 0 {
+			to = append(to, aws.String(address))
+		}
+	}
+
+	var text, html bytes.Buffer
+	if err = emailTextTemplate.Execute(&text, bug); err != nil {
+		return
+	}
+	if err = emailHTMLTemplate.Execute(&html, bug); err != nil {
+		return
+	}
+
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	out, err := ses.New(sess).SendEmail(&ses.SendEmailInput{
+		Source:      aws.String(email.From),
+		Destination: &ses.Destination{ToAddresses: to},
+		Message: &ses.Message{
+			Subject: &ses.Content{
+				Charset: aws.String("UTF-8"),
+				Data:    aws.String(fmt.Sprintf("[%s] %s", bug.ProductName(), bug.Summary)),
+			},
+			Body: &ses.Body{
+				Text: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(text.String())},
+				Html: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(html.String())},
+			},
+		},
+	})
+	log.Printf("tracker.Email.CreateIssue - to: %s, out: %+v, error: %v", list, out, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: email.Name(),
+		ID:      aws.StringValue(out.MessageId),
+	}
+	return
+}
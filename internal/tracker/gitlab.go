@@ -0,0 +1,108 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/secrets"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const gitlabIssues = "https://%s/api/v4/projects/%s/issues"
+
+// GitLab files bugs as GitLab issues, picking the project path by product
+type GitLab struct {
+	Host        string
+	TokenSecret string
+	Projects    map[string]string
+	Labels      []string
+}
+
+// NewGitLab return GitLab tracker configured from env
+func NewGitLab() GitLab {
+	host := os.Getenv("GITLAB_HOST")
+	if len(host) == 0 {
+		host = "gitlab.com"
+	}
+	return GitLab{
+		Host:        host,
+		TokenSecret: os.Getenv("GITLAB_TOKEN_SECRET"),
+		Projects:    envMap("GITLAB_PROJECTS"),
+		Labels:      envList("GITLAB_LABELS"),
+	}
+}
+
+// Name return tracker name
+func (gitlab GitLab) Name() string {
+	return "gitlab"
+}
+
+// CreateIssue create a GitLab issue for the bug, confidential when the bug
+// is security sensitive
+func (gitlab GitLab) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	project, ok := gitlab.Projects[bug.Product]
+	if !ok {
+		project, ok = gitlab.Projects["default"]
+	}
+	if !ok {
+		err = fmt.Errorf("no gitlab project for product: %s", bug.Product)
+		return
+	}
+	token, err := secrets.Get(gitlab.TokenSecret)
+	if err != nil {
+		return
+	}
+
+	labels := append([]string{"slack", "severity::" + bug.Severity}, gitlab.Labels...)
+	body, err := json.Marshal(map[string]interface{}{
+		"title":        bug.Summary,
+		"description":  fmt.Sprintf("Product: %s\nSeverity: %s\nReporter: %s\n\n%s", bug.ProductName(), bug.Severity, bug.UserName, bug.Details),
+		"labels":       strings.Join(labels, ","),
+		"confidential": bug.Security,
+	})
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", fmt.Sprintf(gitlabIssues, gitlab.Host, url.PathEscape(project)), bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("PRIVATE-TOKEN", token)
+	rr, err := outbound.Do(gitlab.Name(), r)
+	if err != nil {
+		log.Printf("tracker.GitLab.CreateIssue - project: %s, error: %v", project, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var created struct {
+		ID     int    `json:"id"`
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.GitLab.CreateIssue - issue: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: gitlab.Name(),
+		ID:      fmt.Sprint(created.ID),
+		Key:     fmt.Sprintf("%s#%d", project, created.IID),
+		URL:     created.WebURL,
+	}
+	return
+}
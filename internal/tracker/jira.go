@@ -0,0 +1,830 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/emf"
+	"github.com/anzellai/kanobug/internal/eventbus"
+	jiraapi "github.com/anzellai/kanobug/internal/jira"
+	"github.com/anzellai/kanobug/internal/markup"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	jiraHost     = "https://%s/rest/api/2/issue/"
+	jiraVersions = "https://%s/rest/api/2/project/%s/versions"
+	jiraUsers    = "https://%s/rest/api/2/user/search?query=%s"
+	jiraLink     = "https://%s/rest/api/2/issueLink"
+	jiraMyself   = "https://%s/rest/api/2/myself"
+	jiraSearch   = "https://%s/rest/api/2/search?jql=%s&fields=summary,status&maxResults=1"
+	jiraBoards   = "https://%s/rest/agile/1.0/board?projectKeyOrId=%s&type=scrum"
+	jiraSprints  = "https://%s/rest/agile/1.0/board/%d/sprint?state=active"
+	jiraSprint   = "https://%s/rest/agile/1.0/sprint/%d/issue"
+)
+
+// jiraResolutions are the Jira resolution names tried for each kanobug
+// resolution, covering both Jira Server and Cloud defaults
+var jiraResolutions = map[string][]string{
+	store.ResolutionFixed:     {"Fixed", "Done"},
+	store.ResolutionNotABug:   {"Not a Bug", "Won't Do", "Won't Fix"},
+	store.ResolutionDuplicate: {"Duplicate"},
+}
+
+// jiraProject is the project bugs are filed into unless JIRA_PROJECTS routes
+// their product elsewhere
+const jiraProject = "IQ"
+
+// Jira authentication modes, JIRA_AUTH
+const (
+	// JiraCloud signs in with the account email as JIRA_API_USER and an API
+	// token as JIRA_API_TOKEN
+	JiraCloud = "cloud"
+	// JiraPAT sends JIRA_API_TOKEN as a Data Center personal access token
+	JiraPAT = "pat"
+	// JiraBasic signs in with the username as JIRA_API_USER and the password
+	// as JIRA_API_TOKEN, for Jira Server
+	JiraBasic = "basic"
+)
+
+// Jira files bugs into the project of their product via the Jira REST API
+type Jira struct {
+	Host     string
+	Auth     string
+	User     string
+	Token    string
+	Projects map[string]string
+	// Fields maps Jira field IDs to the bug fields they are set from
+	Fields map[string]string
+	// EpicField is the Epic Link custom field of company-managed projects,
+	// the parent is set when empty
+	EpicField string
+	// SmokeProject is the sandbox project of smoke test bugs, which are
+	// not filed without one
+	SmokeProject string
+	// Stage is the stage of the deploy, which unless prod files every bug
+	// into the Sandbox project and writes to no other, see writable
+	Stage   config.Stage
+	Sandbox string
+}
+
+// ErrProdWrite is returned for a write to an issue outside the sandbox and
+// smoke projects from a stage other than prod
+var ErrProdWrite = errors.New("jira: only JIRA_SANDBOX_PROJECT and JIRA_SMOKE_PROJECT are written to on this stage")
+
+// NewJira return Jira tracker configured from env
+func NewJira() Jira {
+	c := config.Get()
+	return Jira{
+		Host:         c.JiraHost,
+		Auth:         c.JiraAuth,
+		User:         c.JiraUser,
+		Token:        c.JiraToken,
+		Projects:     c.JiraProjects,
+		Fields:       envMap("JIRA_FIELDS"),
+		EpicField:    os.Getenv("JIRA_EPIC_FIELD"),
+		SmokeProject: c.JiraSmokeProject,
+		Stage:        c.Stage,
+		Sandbox:      c.JiraSandbox,
+	}
+}
+
+// writable return ErrProdWrite unless the issues or projects of keys may be
+// written to on the stage: any on prod, else those of the Sandbox and
+// SmokeProject only
+func (jira Jira) writable(keys ...string) error {
+	if jira.Stage.Prod() {
+		return nil
+	}
+	for _, key := range keys {
+		project := strings.SplitN(key, "-", 2)[0]
+		if len(project) == 0 || (project != jira.Sandbox && project != jira.SmokeProject) {
+			log.Printf("tracker.Jira.writable (%s) - refused on stage: %s", key, jira.Stage)
+			return ErrProdWrite
+		}
+	}
+	return nil
+}
+
+// Route return the key of the project product is filed into and the
+// components set on its issues, from JIRA_PROJECTS
+// (product=KEY/Component,Component;...) with an optional default entry, or
+// else IQ without components
+func (jira Jira) Route(product string) (project string, components []string) {
+	route, ok := jira.Projects[product]
+	if !ok {
+		route, ok = jira.Projects["default"]
+	}
+	if !ok {
+		return jiraProject, nil
+	}
+	parts := strings.SplitN(route, "/", 2)
+	project = strings.TrimSpace(parts[0])
+	if len(project) == 0 {
+		project = jiraProject
+	}
+	if len(parts) == 2 {
+		for _, component := range strings.Split(parts[1], ",") {
+			if component = strings.TrimSpace(component); len(component) > 0 {
+				components = append(components, component)
+			}
+		}
+	}
+	return
+}
+
+// Project return the key of the project product is filed into
+func (jira Jira) Project(product string) string {
+	project, _ := jira.Route(product)
+	return project
+}
+
+// Name return tracker name
+func (jira Jira) Name() string {
+	return "jira"
+}
+
+// jiraDescription return the wiki markup description of bug, with the user
+// supplied fields escaped, Slack mrkdwn details converted and the whole kept
+// within Jira's limit. Translated bugs show the translation, followed by the
+// original in a panel
+func jiraDescription(bug store.Bug) string {
+	wiki := markup.EscapeWiki
+	if strings.HasPrefix(bug.Source, "slack") {
+		wiki = markup.SlackToWiki
+	}
+	details := wiki(bug.Details)
+	if bug.Translation != nil {
+		details = fmt.Sprintf("%s\n\n{panel:title=Original (%s, machine translated above)}\n*%s*\n\n%s\n{panel}",
+			wiki(bug.Translation.Details), bug.Translation.Language, markup.EscapeWiki(bug.Summary), details)
+	}
+	description := fmt.Sprintf(
+		"Product: %s\nSeverity: %s\nReporter: %s\n",
+		bug.ProductName(),
+		bug.Severity,
+		markup.EscapeWiki(bug.UserName),
+	)
+	if bug.OccurredAt != nil {
+		// the reporter's local time, which is what they remember, and UTC for logs
+		description += fmt.Sprintf("Occurred: %s (%s)\n",
+			bug.OccurredAt.Format("2006-01-02 15:04 MST"),
+			bug.OccurredAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	if len(bug.SuggestedSeverity) > 0 && bug.SuggestedSeverity != bug.Severity {
+		description += fmt.Sprintf("Suggested severity: %s (%s)\n", bug.SuggestedSeverity, markup.EscapeWiki(bug.SuggestedBecause))
+	}
+	description += "\n" + details
+	return markup.Truncate(description, markup.MaxDescription)
+}
+
+// jiraSummary return the summary of bug, translated when it was
+func jiraSummary(bug store.Bug) string {
+	if bug.Translation != nil && len(bug.Translation.Summary) > 0 {
+		return bug.Translation.Summary
+	}
+	return bug.Summary
+}
+
+// observe emit the latency of a Jira operation started at start, and count
+// it as failed when err is set
+func observe(operation string, start time.Time, err error) {
+	dimensions := emf.Dimensions{"Operation": operation}
+	emf.Latency(emf.JiraLatency, start, dimensions)
+	if err != nil {
+		emf.Count(emf.JiraFailures, dimensions)
+	}
+}
+
+// CreateIssue create a Jira issue for the bug
+func (jira Jira) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	defer func(start time.Time) {
+		failed := err
+		if failed == nil && len(issue.Key) == 0 {
+			failed = errors.New("no issue key")
+		}
+		observe("create", start, failed)
+	}(time.Now())
+	project, components := jira.Route(bug.Product)
+	if bug.Smoke {
+		if len(jira.SmokeProject) == 0 {
+			return issue, errors.New("JIRA_SMOKE_PROJECT is not set")
+		}
+		project, components = jira.SmokeProject, nil
+	} else if !jira.Stage.Prod() {
+		project, components = jira.Sandbox, nil
+	}
+	if err = jira.writable(project); err != nil {
+		return
+	}
+	fields := jiraapi.Fields{
+		Project:     &jiraapi.Ref{Key: project},
+		Summary:     markup.Summary(jiraSummary(bug)),
+		Description: jiraDescription(bug),
+		IssueType:   &jiraapi.Ref{Name: "Bug"},
+		Labels:      append([]string{"slack", BugLabel(bug.ID)}, bug.Tags...),
+		Priority:    &jiraapi.Ref{Name: "Not Yet Prioritized"},
+		Custom:      jira.CustomFields(project, bug),
+	}
+	for _, v := range bug.Versions {
+		fields.Versions = append(fields.Versions, jiraapi.Ref{Name: v})
+	}
+	if len(bug.Epic) > 0 && len(jira.EpicField) > 0 {
+		if fields.Custom == nil {
+			fields.Custom = map[string]interface{}{}
+		}
+		fields.Custom[jira.EpicField] = bug.Epic
+	} else if len(bug.Epic) > 0 {
+		fields.Parent = &jiraapi.Ref{Key: bug.Epic}
+	}
+	for _, c := range components {
+		fields.Components = append(fields.Components, jiraapi.Ref{Name: c})
+	}
+
+	iq, err := json.Marshal(jiraapi.Issue{Fields: fields})
+	log.Printf("tracker.Jira.CreateIssue - inputQueue: %s, error: %v", iq, err)
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", fmt.Sprintf(jiraHost, jira.Host), bytes.NewBuffer(iq))
+	if err != nil {
+		log.Printf("tracker.Jira.CreateIssue - newRequest: %s, error: %v", iq, err)
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		log.Printf("tracker.Jira.CreateIssue - createIssue: %s, error: %v", iq, err)
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr, http.StatusCreated, http.StatusOK); err != nil {
+		log.Printf("tracker.Jira.CreateIssue - createIssue: %s, error: %v", iq, err)
+		return
+	}
+
+	var created jiraapi.Issue
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.Jira.CreateIssue - issue: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	issue.ID, issue.Key = created.ID, created.Key
+	issue.Tracker = jira.Name()
+	issue.URL = fmt.Sprintf("https://%s/projects/%s/issues/%s", jira.Host, project, issue.Key)
+	return
+}
+
+// Attach upload a file to the Jira issue
+func (jira Jira) Attach(issue Issue, name string, content io.Reader) (err error) {
+	if err = jira.writable(issue.Key); err != nil {
+		return
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(part, content); err != nil {
+		return
+	}
+	if err = form.Close(); err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", fmt.Sprintf(jiraHost, jira.Host)+issue.Key+"/attachments", &body)
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	r.Header.Set("X-Atlassian-Token", "no-check")
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	err = jiraapi.CheckResponse(rr)
+	log.Printf("tracker.Jira.Attach - issue: %s, file: %s, error: %v", issue.Key, name, err)
+	return
+}
+
+// AddWatcher add the Jira user with email as a watcher of the issue, failing
+// when no user matches
+func (jira Jira) AddWatcher(issue Issue, email string) (err error) {
+	defer func() {
+		log.Printf("tracker.Jira.AddWatcher - issue: %s, email: %s, error: %v", issue.Key, email, err)
+	}()
+	account, err := jira.accountID(email)
+	if err != nil {
+		return
+	}
+	return jira.send("POST", issue.Key+"/watchers", account, http.StatusNoContent)
+}
+
+// Assign make the Jira user with email the assignee of the issue, failing
+// when no user matches
+func (jira Jira) Assign(issue Issue, email string) (err error) {
+	defer func() {
+		log.Printf("tracker.Jira.Assign - issue: %s, email: %s, error: %v", issue.Key, email, err)
+	}()
+	account, err := jira.accountID(email)
+	if err != nil {
+		return
+	}
+	return jira.send("PUT", issue.Key+"/assignee", jiraapi.User{AccountID: account}, http.StatusNoContent)
+}
+
+// accountID return the account ID of the Jira user with email
+func (jira Jira) accountID(email string) (account string, err error) {
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraUsers, jira.Host, url.QueryEscape(email)), nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	var users []jiraapi.User
+	if err = json.NewDecoder(rr.Body).Decode(&users); err != nil {
+		return
+	}
+	if len(users) == 0 {
+		return "", errors.New("no jira user")
+	}
+	return users[0].AccountID, nil
+}
+
+// Update replace the summary and description of the Jira issue with the
+// edited bug's, adding and removing the labels of tags changed since before.
+// Labels added in Jira are left alone
+func (jira Jira) Update(issue Issue, before, bug store.Bug) (err error) {
+	var labels []jiraapi.Operation
+	for _, tag := range bug.Tags {
+		if !before.Tagged(tag) {
+			labels = append(labels, jiraapi.Operation{Add: tag})
+		}
+	}
+	for _, tag := range before.Tags {
+		if !bug.Tagged(tag) {
+			labels = append(labels, jiraapi.Operation{Remove: tag})
+		}
+	}
+	update := jiraapi.Update{Fields: &jiraapi.Fields{
+		Summary:     markup.Summary(bug.Summary),
+		Description: jiraDescription(bug),
+	}}
+	if len(labels) > 0 {
+		update.Update = map[string][]jiraapi.Operation{"labels": labels}
+	}
+	return jira.send("PUT", issue.Key, update, http.StatusNoContent)
+}
+
+// Comment add a wiki markup comment to the Jira issue
+func (jira Jira) Comment(issue Issue, body string) (err error) {
+	return jira.send("POST", issue.Key+"/comment", jiraapi.Comment{
+		Body: markup.Truncate(body, markup.MaxDescription),
+	}, http.StatusCreated)
+}
+
+// Delete delete the Jira issue, for the smoke test bugs
+func (jira Jira) Delete(issue Issue) error {
+	return jira.send("DELETE", issue.Key, nil, http.StatusNoContent)
+}
+
+// VerifiedLabel is the label of the Jira issues whose resolution the
+// reporter confirmed fixed the bug
+const VerifiedLabel = "kanobug-verified"
+
+// Verify label the Jira issue verified and comment who confirmed the fix
+func (jira Jira) Verify(issue Issue, by string) (err error) {
+	if err = jira.send("PUT", issue.Key, jiraapi.Update{
+		Update: map[string][]jiraapi.Operation{"labels": {{Add: VerifiedLabel}}},
+	}, http.StatusNoContent); err != nil {
+		return
+	}
+	return jira.Comment(issue, fmt.Sprintf("Verified fixed by %s, who reported the bug or was CC'd on it.", markup.EscapeWiki(by)))
+}
+
+// transitions return the transitions available to the Jira issue
+func (jira Jira) transitions(issue Issue) (transitions []jiraapi.Transition, err error) {
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraHost, jira.Host)+issue.Key+"/transitions?expand=transitions.fields", nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	var available struct {
+		Transitions []jiraapi.Transition `json:"transitions"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&available)
+	return available.Transitions, err
+}
+
+// Close move the Jira issue through its first transition into a done status,
+// setting the matching resolution when the transition screen has one, and
+// link it to duplicateOf for duplicates
+func (jira Jira) Close(issue Issue, resolution, duplicateOf string) (err error) {
+	transitions, err := jira.transitions(issue)
+	if err != nil {
+		return
+	}
+	for _, t := range transitions {
+		if t.To.StatusCategory.Key != "done" {
+			continue
+		}
+		payload := jiraapi.DoTransition{Transition: jiraapi.Ref{ID: t.ID}}
+		if field, ok := t.Fields["resolution"]; ok {
+			for _, name := range jiraResolutions[resolution] {
+				for _, allowed := range field.AllowedValues {
+					if strings.EqualFold(allowed.Name, name) && payload.Fields == nil {
+						payload.Fields = &jiraapi.Fields{Resolution: &jiraapi.Ref{Name: allowed.Name}}
+					}
+				}
+			}
+		}
+		if err = jira.send("POST", issue.Key+"/transitions", payload, http.StatusNoContent); err != nil {
+			return
+		}
+		if len(duplicateOf) > 0 {
+			err = jira.link("Duplicate", issue.Key, duplicateOf)
+		}
+		return
+	}
+	return fmt.Errorf("no transition to done for %s", issue.Key)
+}
+
+// ErrNoReopen is returned by Reopen when the workflow of an issue has no
+// transition out of its done status
+var ErrNoReopen = errors.New("no transition out of done")
+
+// Reopen move the done Jira issue back through its first transition into a
+// to do or in progress status, ErrNoReopen when the workflow has none
+func (jira Jira) Reopen(issue Issue) (err error) {
+	transitions, err := jira.transitions(issue)
+	if err != nil {
+		return
+	}
+	for _, t := range transitions {
+		if t.To.StatusCategory.Key == "done" {
+			continue
+		}
+		return jira.send("POST", issue.Key+"/transitions", jiraapi.DoTransition{Transition: jiraapi.Ref{ID: t.ID}}, http.StatusNoContent)
+	}
+	return ErrNoReopen
+}
+
+// Regression file bug again as a new Jira issue relating to its done issue
+func (jira Jira) Regression(issue Issue, bug store.Bug) (regression Issue, err error) {
+	bug.Summary = "Regression: " + bug.Summary
+	if regression, err = jira.CreateIssue(bug); err != nil {
+		return
+	}
+	err = jira.link("Relates", regression.Key, issue.Key)
+	return
+}
+
+// link create an issue link of linkType from inward to outward
+func (jira Jira) link(linkType, inward, outward string) (err error) {
+	if err = jira.writable(inward, outward); err != nil {
+		return
+	}
+	payload, err := json.Marshal(jiraapi.IssueLink{
+		Type:         jiraapi.Ref{Name: linkType},
+		InwardIssue:  jiraapi.Ref{Key: inward},
+		OutwardIssue: jiraapi.Ref{Key: outward},
+	})
+	if err != nil {
+		return
+	}
+	r, err := http.NewRequest("POST", fmt.Sprintf(jiraLink, jira.Host), bytes.NewBuffer(payload))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	err = jiraapi.CheckResponse(rr, http.StatusCreated)
+	log.Printf("tracker.Jira.link (%s %s %s) - error: %v", inward, linkType, outward, err)
+	return
+}
+
+// send a JSON request to the issue path, failing with the *jira.Error of the
+// reply unless Jira answers want
+func (jira Jira) send(method, path string, payload interface{}, want int) (err error) {
+	defer func(start time.Time) {
+		log.Printf("tracker.Jira.send (%s %s) - error: %v", method, path, err)
+		observe(strings.ToLower(method), start, err)
+	}(time.Now())
+	if err = jira.writable(strings.SplitN(path, "/", 2)[0]); err != nil {
+		return
+	}
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(encoded)
+	}
+	r, err := http.NewRequest(method, fmt.Sprintf(jiraHost, jira.Host)+path, body)
+	if err != nil {
+		return
+	}
+	if payload != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	return jiraapi.CheckResponse(rr, want)
+}
+
+// BugLabel return the label of the Jira issues filed for bug id, found by
+// FindByBug when the bug lost its link to them
+func BugLabel(id string) string {
+	return "kanobug-" + id
+}
+
+// ErrNoIssue is returned by Issue when Jira has no issue of the key, e.g.
+// once it was deleted
+var ErrNoIssue = errors.New("issue not found")
+
+// Issue fetch the summary, status, assignee and reporter of the Jira issue
+// key, shown when unfurling its link and compared when reconciling bugs
+func (jira Jira) Issue(key string) (issue jiraapi.Issue, err error) {
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraHost, jira.Host)+key+"?fields=summary,status,assignee,reporter", nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode == http.StatusNotFound {
+		return issue, ErrNoIssue
+	}
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	err = json.NewDecoder(rr.Body).Decode(&issue)
+	log.Printf("tracker.Jira.Issue - key: %s, error: %v", key, err)
+	return
+}
+
+// FindByBug search the Jira issue labelled with BugLabel of bug id, the
+// earliest when a regression was filed too, false when there is none
+func (jira Jira) FindByBug(id string) (issue Issue, found bool, err error) {
+	defer func() {
+		log.Printf("tracker.Jira.FindByBug (%s) - issue: %s, error: %v", id, issue.Key, err)
+	}()
+	jql := fmt.Sprintf(`labels = "%s" ORDER BY created ASC`, BugLabel(id))
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraSearch, jira.Host, url.QueryEscape(jql)), nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	var result struct {
+		Issues []jiraapi.Issue `json:"issues"`
+	}
+	if err = json.NewDecoder(rr.Body).Decode(&result); err != nil || len(result.Issues) == 0 {
+		return
+	}
+	key := result.Issues[0].Key
+	project := strings.SplitN(key, "-", 2)[0]
+	return Issue{
+		Tracker: jira.Name(),
+		ID:      result.Issues[0].ID,
+		Key:     key,
+		URL:     fmt.Sprintf("https://%s/projects/%s/issues/%s", jira.Host, project, key),
+	}, true, nil
+}
+
+// Ping check the Jira credentials by fetching their user
+func (jira Jira) Ping() (err error) {
+	defer func(start time.Time) { observe("ping", start, err) }(time.Now())
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraMyself, jira.Host), nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	return jiraapi.CheckResponse(rr)
+}
+
+// JiraVersion is a version of a Jira project
+type JiraVersion struct {
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+	Released bool   `json:"released"`
+}
+
+// Versions return the unarchived versions of project, released or not,
+// newest first
+func (jira Jira) Versions(project string) (versions []JiraVersion, err error) {
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraVersions, jira.Host, url.PathEscape(project)), nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.JiraVersions, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	var all []JiraVersion
+	if err = json.NewDecoder(rr.Body).Decode(&all); err != nil {
+		return
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].Archived {
+			versions = append(versions, all[i])
+		}
+	}
+	log.Printf("tracker.Jira.Versions (%s) - versions: %d, error: %v", project, len(versions), err)
+	return
+}
+
+// JiraSprint is an active sprint of a scrum board
+type JiraSprint struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// agile decode the Jira Agile API response to GET endpoint into out
+func (jira Jira) agile(endpoint string, out interface{}) (err error) {
+	r, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	return json.NewDecoder(rr.Body).Decode(out)
+}
+
+// Sprints return the active sprints of the scrum boards of project
+func (jira Jira) Sprints(project string) (sprints []JiraSprint, err error) {
+	defer func(start time.Time) {
+		observe("sprints", start, err)
+		log.Printf("tracker.Jira.Sprints (%s) - sprints: %d, error: %v", project, len(sprints), err)
+	}(time.Now())
+	var boards struct {
+		Values []struct {
+			ID int `json:"id"`
+		} `json:"values"`
+	}
+	if err = jira.agile(fmt.Sprintf(jiraBoards, jira.Host, url.QueryEscape(project)), &boards); err != nil {
+		return
+	}
+	seen := map[int]bool{}
+	for _, board := range boards.Values {
+		var active struct {
+			Values []JiraSprint `json:"values"`
+		}
+		if err = jira.agile(fmt.Sprintf(jiraSprints, jira.Host, board.ID), &active); err != nil {
+			return
+		}
+		for _, sprint := range active.Values {
+			if !seen[sprint.ID] {
+				seen[sprint.ID] = true
+				sprints = append(sprints, sprint)
+			}
+		}
+	}
+	return
+}
+
+// MoveToSprint move the issue into sprint
+func (jira Jira) MoveToSprint(issue Issue, sprint int) (err error) {
+	defer func(start time.Time) {
+		observe("sprint", start, err)
+		log.Printf("tracker.Jira.MoveToSprint (%s/%d) - error: %v", issue.Key, sprint, err)
+	}(time.Now())
+	if err = jira.writable(issue.Key); err != nil {
+		return
+	}
+	body, err := json.Marshal(map[string][]string{"issues": {issue.Key}})
+	if err != nil {
+		return
+	}
+	r, err := http.NewRequest("POST", fmt.Sprintf(jiraSprint, jira.Host, sprint), bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	return jiraapi.CheckResponse(rr, http.StatusNoContent, http.StatusOK)
+}
+
+// authRetry is how long auth failures go unreported to ops after one was
+var authRetry = 15 * time.Minute
+
+// authReported is when this container last reported an auth failure
+var authReported time.Time
+
+// authorize sign r in the mode of JIRA_AUTH, cloud unless set
+func (jira Jira) authorize(r *http.Request) {
+	if jira.Auth == JiraPAT {
+		r.Header.Set("Authorization", "Bearer "+jira.Token)
+		return
+	}
+	r.SetBasicAuth(jira.User, jira.Token)
+}
+
+// do send the signed r to dependency, reporting a rejection of the
+// credentials to ops
+func (jira Jira) do(dependency string, r *http.Request) (*http.Response, error) {
+	jira.authorize(r)
+	rr, err := outbound.Do(dependency, r)
+	if err == nil && (rr.StatusCode == http.StatusUnauthorized || rr.StatusCode == http.StatusForbidden) {
+		jira.diagnose(r, rr)
+	}
+	return rr, err
+}
+
+// diagnose log why Jira rejected the credentials of r, as far as its
+// response tells, and publish it once every authRetry for the ops channel
+func (jira Jira) diagnose(r *http.Request, rr *http.Response) {
+	mode := jira.Auth
+	if len(mode) == 0 {
+		mode = JiraCloud
+	}
+	reason := rr.Header.Get("X-Seraph-LoginReason")
+	hint := map[string]string{
+		JiraCloud: "JIRA_API_USER must be the account email and JIRA_API_TOKEN an API token of it",
+		JiraPAT:   "JIRA_API_TOKEN must be an unexpired personal access token, Data Center 8.14 or later",
+		JiraBasic: "JIRA_API_USER and JIRA_API_TOKEN must be a username and password allowed basic auth",
+	}[mode]
+	switch {
+	case len(hint) == 0:
+		hint = "JIRA_AUTH must be cloud, pat or basic"
+	case reason == "AUTHENTICATION_DENIED":
+		hint = "Jira asks for a CAPTCHA after failed sign ins, sign in to the web UI as the user once"
+	case rr.StatusCode == http.StatusForbidden && reason != "AUTHENTICATED_FAILED":
+		hint = "the user is signed in but lacks a permission of the project"
+	}
+	log.Printf("tracker.Jira.diagnose (%s %s) - mode: %s, user: %s, status: %s, reason: %s, hint: %s",
+		r.Method, r.URL.Path, mode, jira.User, rr.Status, reason, hint)
+	if time.Since(authReported) < authRetry {
+		return
+	}
+	authReported = time.Now()
+	_ = eventbus.Publish(eventbus.AuthFailed, eventbus.AuthFailedDetail{
+		Tracker: jira.Name(),
+		Mode:    mode,
+		User:    jira.User,
+		Status:  rr.StatusCode,
+		Reason:  reason,
+		Hint:    hint,
+	})
+}
@@ -0,0 +1,109 @@
+package tracker
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/config"
+	jiraapi "github.com/anzellai/kanobug/internal/jira"
+	"github.com/anzellai/kanobug/internal/outbound/outboundtest"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	// the egress allowlist is read once, before any test runs
+	os.Setenv("EGRESS_HOSTS", "jira=jira.example.com;webhook=.example.com")
+	os.Exit(m.Run())
+}
+
+func recordedJira() Jira {
+	return Jira{
+		Host:     "jira.example.com",
+		User:     "kanobug@example.com",
+		Token:    "token",
+		Projects: map[string]string{"web_app": "WEB/Checkout"},
+		Stage:    config.Prod,
+	}
+}
+
+func recordedBug() store.Bug {
+	occurred := time.Date(2018, 9, 17, 12, 0, 0, 0, time.UTC)
+	return store.Bug{
+		ID:         "b1",
+		Source:     "slack",
+		UserName:   "Ada",
+		Summary:    "Checkout button does nothing",
+		Product:    "web_app",
+		Severity:   "major",
+		Details:    "Clicking *Pay* shows a spinner forever\n\nEnvironment: Chrome 69",
+		Versions:   []string{"2.3.0"},
+		Tags:       []string{"checkout"},
+		OccurredAt: &occurred,
+	}
+}
+
+func TestJiraRoute(t *testing.T) {
+	jira := Jira{Projects: map[string]string{
+		"web_app": "WEB/Checkout, Payments,",
+		"ios":     "IOS",
+		"blank":   "/Misc",
+		"default": "OPS",
+	}}
+	tests := []struct {
+		product    string
+		project    string
+		components []string
+	}{
+		{"web_app", "WEB", []string{"Checkout", "Payments"}},
+		{"ios", "IOS", nil},
+		{"blank", "IQ", []string{"Misc"}},
+		{"android", "OPS", nil},
+	}
+	for _, tt := range tests {
+		project, components := jira.Route(tt.product)
+		if project != tt.project || !reflect.DeepEqual(components, tt.components) {
+			t.Errorf("Route(%s) = %s, %v, want %s, %v", tt.product, project, components, tt.project, tt.components)
+		}
+	}
+	if project, _ := (Jira{}).Route("web_app"); project != "IQ" {
+		t.Errorf("Route() without JIRA_PROJECTS = %s, want IQ", project)
+	}
+}
+
+func TestJiraCreateIssue(t *testing.T) {
+	outboundtest.Replay(t, "testdata/jira_create.json")
+	jira := recordedJira()
+	jira.Fields = map[string]string{
+		"customfield_10042": "versions",
+		"customfield_10050": "severity",
+		"customfield_10060": "occurred_at",
+		// not on the Bug create screen, left out
+		"customfield_10099": "tags",
+	}
+	issue, err := jira.CreateIssue(recordedBug())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Issue{Tracker: "jira", ID: "10234", Key: "WEB-42", URL: "https://jira.example.com/projects/WEB/issues/WEB-42"}
+	if issue != want {
+		t.Errorf("CreateIssue() = %+v, want %+v", issue, want)
+	}
+}
+
+func TestJiraCreateIssueRejected(t *testing.T) {
+	outboundtest.Replay(t, "testdata/jira_create_rejected.json")
+	_, err := recordedJira().CreateIssue(recordedBug())
+	rejected, ok := err.(*jiraapi.Error)
+	if !ok {
+		t.Fatalf("CreateIssue() error = %v, want a *jira.Error", err)
+	}
+	if rejected.Code != 400 || rejected.Field("components") != "Component name 'Checkout' is not valid" {
+		t.Errorf("CreateIssue() error = %v", rejected)
+	}
+	want := "unexpected status: 400 Bad Request: components: Component name 'Checkout' is not valid; versions: Version name '9.9.9' is not valid"
+	if rejected.Error() != want {
+		t.Errorf("Error() = %q, want %q", rejected.Error(), want)
+	}
+}
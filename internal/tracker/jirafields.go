@@ -0,0 +1,177 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jiraapi "github.com/anzellai/kanobug/internal/jira"
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const jiraCreateMeta = "https://%s/rest/api/2/issue/createmeta?projectKeys=%s&issuetypeNames=Bug&expand=projects.issuetypes.fields"
+
+// JiraField is a field of the Bug create screen of a project, as described
+// by createmeta
+type JiraField struct {
+	Name   string `json:"name"`
+	Schema struct {
+		Type  string `json:"type"`
+		Items string `json:"items"`
+	} `json:"schema"`
+}
+
+// createMeta are the Bug create screen fields of each project read so far,
+// kept for the lifetime of the Lambda container
+var (
+	createMeta   = map[string]map[string]JiraField{}
+	createMetaMu sync.Mutex
+)
+
+// CreateMeta return the fields of the Bug create screen of project by ID
+func (jira Jira) CreateMeta(project string) (fields map[string]JiraField, err error) {
+	createMetaMu.Lock()
+	defer createMetaMu.Unlock()
+	if fields, ok := createMeta[project]; ok {
+		return fields, nil
+	}
+	defer func() {
+		log.Printf("tracker.Jira.CreateMeta (%s) - fields: %d, error: %v", project, len(fields), err)
+	}()
+	r, err := http.NewRequest("GET", fmt.Sprintf(jiraCreateMeta, jira.Host, url.QueryEscape(project)), nil)
+	if err != nil {
+		return
+	}
+	rr, err := jira.do(outbound.Jira, r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if err = jiraapi.CheckResponse(rr); err != nil {
+		return
+	}
+	var meta struct {
+		Projects []struct {
+			IssueTypes []struct {
+				Fields map[string]JiraField `json:"fields"`
+			} `json:"issuetypes"`
+		} `json:"projects"`
+	}
+	if err = json.NewDecoder(rr.Body).Decode(&meta); err != nil {
+		return
+	}
+	if len(meta.Projects) == 0 || len(meta.Projects[0].IssueTypes) == 0 {
+		err = fmt.Errorf("no Bug issue type in project %s", project)
+		return
+	}
+	fields = meta.Projects[0].IssueTypes[0].Fields
+	createMeta[project] = fields
+	return
+}
+
+// jiraFieldValues return the bug fields JIRA_FIELDS maps to Jira fields
+func jiraFieldValues(bug store.Bug) map[string][]string {
+	var occurred []string
+	if bug.OccurredAt != nil {
+		occurred = []string{bug.OccurredAt.UTC().Format(time.RFC3339)}
+	}
+	return map[string][]string{
+		"id":                 {bug.ID},
+		"product":            {bug.Product},
+		"product_name":       {bug.ProductName()},
+		"severity":           {bug.Severity},
+		"reporter":           {bug.UserName},
+		"security":           {strconv.FormatBool(bug.Security)},
+		"customer_impacting": {strconv.FormatBool(bug.CustomerImpacting)},
+		"versions":           bug.Versions,
+		"tags":               bug.Tags,
+		"occurred_at":        occurred,
+	}
+}
+
+// jiraFieldValue shape values as field expects them, false when its type is
+// not one kanobug can set
+func jiraFieldValue(field JiraField, values []string) (interface{}, bool) {
+	shape := func(kind string, value string) (interface{}, bool) {
+		switch kind {
+		case "string":
+			return value, true
+		case "number":
+			n, err := strconv.ParseFloat(value, 64)
+			return n, err == nil
+		case "date":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				return t.Format("2006-01-02"), true
+			}
+			return value, true
+		case "datetime":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				return t.Format("2006-01-02T15:04:05.000-0700"), true
+			}
+			return value, true
+		case "option":
+			return map[string]string{"value": value}, true
+		case "version", "component":
+			return map[string]string{"name": value}, true
+		}
+		return nil, false
+	}
+	if field.Schema.Type == "array" {
+		list := []interface{}{}
+		for _, value := range values {
+			v, ok := shape(field.Schema.Items, value)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, v)
+		}
+		return list, true
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	return shape(field.Schema.Type, strings.Join(values, ", "))
+}
+
+// CustomFields return the Jira fields of bug mapped by JIRA_FIELDS
+// (customfield_10042=versions;...), leaving out the ones missing from the
+// Bug create screen of project or of a type kanobug can not set
+func (jira Jira) CustomFields(project string, bug store.Bug) map[string]interface{} {
+	if len(jira.Fields) == 0 {
+		return nil
+	}
+	meta, err := jira.CreateMeta(project)
+	if err != nil {
+		return nil
+	}
+	values := jiraFieldValues(bug)
+	fields := map[string]interface{}{}
+	for id, name := range jira.Fields {
+		bugValues, known := values[name]
+		field, onScreen := meta[id]
+		switch {
+		case !known:
+			log.Printf("tracker.Jira.CustomFields (%s) - %s: unknown bug field %s", project, id, name)
+			continue
+		case !onScreen:
+			log.Printf("tracker.Jira.CustomFields (%s) - %s: not on the Bug create screen", project, id)
+			continue
+		case len(bugValues) == 0 || (len(bugValues) == 1 && len(bugValues[0]) == 0):
+			continue
+		}
+		value, ok := jiraFieldValue(field, bugValues)
+		if !ok {
+			log.Printf("tracker.Jira.CustomFields (%s) - %s: unsupported type %s", project, id, field.Schema.Type)
+			continue
+		}
+		fields[id] = value
+	}
+	return fields
+}
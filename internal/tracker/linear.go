@@ -0,0 +1,132 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	linearEndpoint    = "https://api.linear.app/graphql"
+	linearIssueCreate = `mutation IssueCreate($input: IssueCreateInput!) {
+  issueCreate(input: $input) {
+    success
+    issue { id identifier url }
+  }
+}`
+)
+
+// linearPriority maps bug severity to Linear priority (1 urgent .. 4 low)
+var linearPriority = map[string]int{
+	store.SeverityBlocker:  1,
+	store.SeverityCritical: 2,
+	store.SeverityMajor:    3,
+	store.SeverityMinor:    4,
+	store.SeverityTrivial:  4,
+}
+
+// Linear files bugs as Linear issues, picking the team by product
+type Linear struct {
+	APIKey   string
+	Teams    map[string]string
+	LabelIDs []string
+}
+
+// NewLinear return Linear tracker configured from env
+func NewLinear() Linear {
+	return Linear{
+		APIKey:   os.Getenv("LINEAR_API_KEY"),
+		Teams:    envMap("LINEAR_TEAMS"),
+		LabelIDs: envList("LINEAR_LABEL_IDS"),
+	}
+}
+
+// Name return tracker name
+func (linear Linear) Name() string {
+	return "linear"
+}
+
+// CreateIssue create a Linear issue for the bug
+func (linear Linear) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	teamID, ok := linear.Teams[bug.Product]
+	if !ok {
+		teamID, ok = linear.Teams["default"]
+	}
+	if !ok {
+		err = fmt.Errorf("no linear team for product: %s", bug.Product)
+		return
+	}
+	input := map[string]interface{}{
+		"teamId":      teamID,
+		"title":       bug.Summary,
+		"description": fmt.Sprintf("Product: %s\nSeverity: %s\nReporter: %s\n\n%s", bug.ProductName(), bug.Severity, bug.UserName, bug.Details),
+		"priority":    linearPriority[bug.Severity],
+	}
+	if len(linear.LabelIDs) > 0 {
+		input["labelIds"] = linear.LabelIDs
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     linearIssueCreate,
+		"variables": map[string]interface{}{"input": input},
+	})
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", linearEndpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", linear.APIKey)
+	rr, err := outbound.Do(linear.Name(), r)
+	if err != nil {
+		log.Printf("tracker.Linear.CreateIssue - input: %+v, error: %v", input, err)
+		return
+	}
+	defer rr.Body.Close()
+
+	var result struct {
+		Data struct {
+			IssueCreate struct {
+				Success bool `json:"success"`
+				Issue   struct {
+					ID         string `json:"id"`
+					Identifier string `json:"identifier"`
+					URL        string `json:"url"`
+				} `json:"issue"`
+			} `json:"issueCreate"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&result)
+	log.Printf("tracker.Linear.CreateIssue - result: %+v, error: %v", result, err)
+	if err != nil {
+		return
+	}
+	if len(result.Errors) > 0 {
+		err = errors.New(result.Errors[0].Message)
+		return
+	}
+	if !result.Data.IssueCreate.Success {
+		err = errors.New("linear issueCreate unsuccessful")
+		return
+	}
+	created := result.Data.IssueCreate.Issue
+	issue = Issue{
+		Tracker: linear.Name(),
+		ID:      created.ID,
+		Key:     created.Identifier,
+		URL:     created.URL,
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const servicenowIncident = "https://%s.service-now.com/api/now/table/incident"
+
+// servicenowUrgency is the default severity to urgency (1 high .. 3 low)
+// mapping, overridable via SERVICENOW_URGENCY
+var servicenowUrgency = map[string]string{
+	store.SeverityBlocker:  "1",
+	store.SeverityCritical: "1",
+	store.SeverityMajor:    "2",
+	store.SeverityMinor:    "3",
+	store.SeverityTrivial:  "3",
+}
+
+// ServiceNow files bugs as ServiceNow incidents via the Table API
+type ServiceNow struct {
+	Instance         string
+	User             string
+	Password         string
+	AssignmentGroups map[string]string
+	Urgency          map[string]string
+}
+
+// NewServiceNow return ServiceNow tracker configured from env
+func NewServiceNow() ServiceNow {
+	urgency := map[string]string{}
+	for severity, value := range servicenowUrgency {
+		urgency[severity] = value
+	}
+	for severity, value := range envMap("SERVICENOW_URGENCY") {
+		urgency[severity] = value
+	}
+	return ServiceNow{
+		Instance:         os.Getenv("SERVICENOW_INSTANCE"),
+		User:             os.Getenv("SERVICENOW_USER"),
+		Password:         os.Getenv("SERVICENOW_PASSWORD"),
+		AssignmentGroups: envMap("SERVICENOW_ASSIGNMENT_GROUPS"),
+		Urgency:          urgency,
+	}
+}
+
+// Name return tracker name
+func (servicenow ServiceNow) Name() string {
+	return "servicenow"
+}
+
+// CreateIssue create a ServiceNow incident for the bug
+func (servicenow ServiceNow) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	incident := map[string]string{
+		"short_description": bug.Summary,
+		"description":       fmt.Sprintf("Product: %s\nSeverity: %s\nReporter: %s\n\n%s", bug.ProductName(), bug.Severity, bug.UserName, bug.Details),
+		"urgency":           servicenow.Urgency[bug.Severity],
+		"category":          "software",
+		"contact_type":      "slack",
+	}
+	group, ok := servicenow.AssignmentGroups[bug.Product]
+	if !ok {
+		group = servicenow.AssignmentGroups["default"]
+	}
+	if len(group) > 0 {
+		incident["assignment_group"] = group
+	}
+	body, err := json.Marshal(incident)
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", fmt.Sprintf(servicenowIncident, servicenow.Instance), bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.SetBasicAuth(servicenow.User, servicenow.Password)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	rr, err := outbound.Do(servicenow.Name(), r)
+	if err != nil {
+		log.Printf("tracker.ServiceNow.CreateIssue - incident: %+v, error: %v", incident, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var created struct {
+		Result struct {
+			SysID  string `json:"sys_id"`
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.ServiceNow.CreateIssue - created: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: servicenow.Name(),
+		ID:      created.Result.SysID,
+		Key:     created.Result.Number,
+		URL:     fmt.Sprintf("https://%s.service-now.com/nav_to.do?uri=incident.do?sys_id=%s", servicenow.Instance, created.Result.SysID),
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package tracker
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/config"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// Tracker is implemented by every backend a bug can be filed to
+type Tracker interface {
+	Name() string
+	CreateIssue(bug store.Bug) (Issue, error)
+}
+
+// Attacher is implemented by trackers that accept file uploads on a filed issue
+type Attacher interface {
+	Attach(issue Issue, name string, content io.Reader) error
+}
+
+// Issue is the reference a tracker returns for a filed bug
+type Issue = store.Issue
+
+// ForProduct return the trackers routed for product by the config table or
+// TRACKER_ROUTES (e.g. "pixel_kit=linear;motion_sensor_kit=jira,webhook"),
+// falling back to TRACKERS and then jira
+func ForProduct(product string) (trackers []Tracker) {
+	names := config.Get().Routes[product]
+	if store.ConfigEnabled() {
+		if configured, err := store.GetProduct(product); err == nil && len(configured.Trackers) > 0 {
+			names = configured.Trackers
+		}
+	}
+	if len(names) == 0 {
+		names = config.Get().Trackers
+	}
+	for _, name := range names {
+		if t := ByName(strings.TrimSpace(name)); t != nil {
+			trackers = append(trackers, t)
+		}
+	}
+	return
+}
+
+// ByName return the tracker registered as name, nil when unknown
+func ByName(name string) Tracker {
+	switch name {
+	case "jira":
+		return NewJira()
+	case "webhook":
+		return NewWebhook()
+	case "linear":
+		return NewLinear()
+	case "gitlab":
+		return NewGitLab()
+	case "azure":
+		return NewAzureDevOps()
+	case "servicenow":
+		return NewServiceNow()
+	case "email":
+		return NewEmail()
+	case "trello":
+		return NewTrello()
+	case "asana":
+		return NewAsana()
+	}
+	log.Printf("tracker.ByName - unknown tracker: %s", name)
+	return nil
+}
+
+// envMap parse a "key=value;key=value" env variable
+func envMap(name string) map[string]string {
+	return config.Map(os.Getenv(name))
+}
+
+// envList parse a comma separated env variable
+func envList(name string) []string {
+	return config.List(os.Getenv(name))
+}
@@ -0,0 +1,135 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const trelloAPI = "https://api.trello.com/1"
+
+// Trello files bugs as cards on the product's board list
+type Trello struct {
+	Key    string
+	Token  string
+	Lists  map[string]string
+	Labels map[string]string
+}
+
+// NewTrello return Trello tracker configured from env
+func NewTrello() Trello {
+	return Trello{
+		Key:    os.Getenv("TRELLO_API_KEY"),
+		Token:  os.Getenv("TRELLO_TOKEN"),
+		Lists:  envMap("TRELLO_LISTS"),
+		Labels: envMap("TRELLO_LABELS"),
+	}
+}
+
+// Name return tracker name
+func (trello Trello) Name() string {
+	return "trello"
+}
+
+func (trello Trello) endpoint(path string, query url.Values) string {
+	query.Set("key", trello.Key)
+	query.Set("token", trello.Token)
+	return trelloAPI + path + "?" + query.Encode()
+}
+
+// CreateIssue create a Trello card for the bug
+func (trello Trello) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	list, ok := trello.Lists[bug.Product]
+	if !ok {
+		list, ok = trello.Lists["default"]
+	}
+	if !ok {
+		err = fmt.Errorf("no trello list for product: %s", bug.Product)
+		return
+	}
+	query := url.Values{}
+	query.Set("idList", list)
+	query.Set("name", bug.Summary)
+	query.Set("desc", fmt.Sprintf("**Product:** %s\n**Severity:** %s\n**Reported by:** %s (Slack)\n\n%s", bug.ProductName(), bug.Severity, bug.UserName, bug.Details))
+	query.Set("pos", "top")
+	if label, ok := trello.Labels[bug.Severity]; ok {
+		query.Set("idLabels", label)
+	}
+
+	r, err := http.NewRequest("POST", trello.endpoint("/cards", query), nil)
+	if err != nil {
+		return
+	}
+	rr, err := outbound.Do(trello.Name(), r)
+	if err != nil {
+		log.Printf("tracker.Trello.CreateIssue - list: %s, error: %v", list, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var card struct {
+		ID        string `json:"id"`
+		ShortLink string `json:"shortLink"`
+		ShortURL  string `json:"shortUrl"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&card)
+	log.Printf("tracker.Trello.CreateIssue - card: %+v, error: %v", card, err)
+	if err != nil {
+		return
+	}
+	issue = Issue{
+		Tracker: trello.Name(),
+		ID:      card.ID,
+		Key:     card.ShortLink,
+		URL:     card.ShortURL,
+	}
+	return
+}
+
+// Attach upload a file to the card
+func (trello Trello) Attach(issue Issue, name string, content io.Reader) (err error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(part, content); err != nil {
+		return
+	}
+	if err = form.WriteField("name", name); err != nil {
+		return
+	}
+	if err = form.Close(); err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", trello.endpoint("/cards/"+issue.ID+"/attachments", url.Values{}), &body)
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	rr, err := outbound.Do(trello.Name(), r)
+	if err != nil {
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+	}
+	log.Printf("tracker.Trello.Attach - card: %s, file: %s, error: %v", issue.ID, name, err)
+	return
+}
@@ -0,0 +1,137 @@
+package tracker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const (
+	// SignatureHeader carries the HMAC signature of the webhook body
+	SignatureHeader = "X-Kanobug-Signature"
+	// TimestampHeader carries the unix timestamp the signature was made at
+	TimestampHeader  = "X-Kanobug-Request-Timestamp"
+	signatureVersion = "v0"
+)
+
+// ErrUnsigned is returned rather than delivering a payload without
+// WEBHOOK_SECRET to sign it with
+var ErrUnsigned = errors.New("webhook: WEBHOOK_SECRET is not set")
+
+// ErrNoURLs is returned rather than reporting a bug delivered to no one
+var ErrNoURLs = errors.New("webhook: WEBHOOK_URLS is not set")
+
+// deliveryScope is the store.Claim scope of the deliveries of a bug to a URL
+const deliveryScope = "webhook"
+
+// delivery records the URLs a bug was delivered to, so a retry only sends
+// it to those that failed, swapped in tests
+var delivery = struct {
+	Claim         func(scope, key string) (bool, error)
+	Done, Release func(scope, key string) error
+}{store.Claim, store.Done, store.Release}
+
+// Webhook POSTs the Bug JSON to every configured URL, signed with a shared secret
+type Webhook struct {
+	URLs   []string
+	Secret string
+}
+
+// NewWebhook return Webhook tracker configured from env
+func NewWebhook() Webhook {
+	return Webhook{
+		URLs:   envList("WEBHOOK_URLS"),
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+}
+
+// Name return tracker name
+func (webhook Webhook) Name() string {
+	return "webhook"
+}
+
+// Sign return the signature for body sent at timestamp, in the form
+// v0=hex(hmac_sha256(secret, "v0:" + timestamp + ":" + body))
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateIssue deliver the bug to all webhook URLs it was not delivered to
+// yet, within the hour a delivery is recorded for
+func (webhook Webhook) CreateIssue(bug store.Bug) (issue Issue, err error) {
+	issue.Tracker = webhook.Name()
+	if len(webhook.Secret) == 0 {
+		err = ErrUnsigned
+		return
+	}
+	if len(webhook.URLs) == 0 {
+		err = ErrNoURLs
+		return
+	}
+	body, err := json.Marshal(bug)
+	if err != nil {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := Sign(webhook.Secret, timestamp, body)
+
+	failed := 0
+	for _, u := range webhook.URLs {
+		key := store.PayloadKey(bug.TeamID + "#" + bug.ID + "#" + u)
+		claimed, claimErr := delivery.Claim(deliveryScope, key)
+		if claimErr != nil {
+			log.Printf("tracker.Webhook.CreateIssue - url: %s, error: %v", u, claimErr)
+			failed++
+			continue
+		}
+		if !claimed {
+			log.Printf("tracker.Webhook.CreateIssue - url: %s, delivered already", u)
+			continue
+		}
+		if postErr := webhook.post(u, timestamp, signature, body); postErr != nil {
+			log.Printf("tracker.Webhook.CreateIssue - url: %s, error: %v", u, postErr)
+			_ = delivery.Release(deliveryScope, key)
+			failed++
+			continue
+		}
+		_ = delivery.Done(deliveryScope, key)
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d webhooks failed", failed, len(webhook.URLs))
+	}
+	return
+}
+
+func (webhook Webhook) post(u, timestamp, signature string, body []byte) error {
+	r, err := http.NewRequest("POST", u, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(TimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, signature)
+	rr, err := outbound.Do(webhook.Name(), r)
+	if err != nil {
+		return err
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", rr.Status)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/anzellai/kanobug/internal/outbound/outboundtest"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"b1"}`)
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		want      string
+	}{
+		{"signed", "shh", "1537185600", "v0=28dcfe53d5353e4a9672a15444b5b8c49883d052776c937f82cf7e7d33a4c08f"},
+		{"other secret", "other", "1537185600", "v0=5f9e2289ae19da8254f2e9210da9a6dec4ee81ef27d21b62d7f55ca17f4a476e"},
+		{"other timestamp", "shh", "1537185601", "v0=893dcd564103e8223d71a6443225e7fc2143c02e6877a9189b60a8d13f13256f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, body); got != tt.want {
+				t.Errorf("Sign(%q, %q) = %s, want %s", tt.secret, tt.timestamp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	webhook := Webhook{URLs: []string{"https://hooks.example.com/kanobug"}}
+	if _, err := webhook.CreateIssue(store.Bug{ID: "b1"}); err != ErrUnsigned {
+		t.Errorf("CreateIssue without a secret = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestWebhookNoURLs(t *testing.T) {
+	webhook := Webhook{Secret: "shh"}
+	if _, err := webhook.CreateIssue(store.Bug{ID: "b1"}); err != ErrNoURLs {
+		t.Errorf("CreateIssue without URLs = %v, want ErrNoURLs", err)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	states := map[string]string{}
+	recorded := delivery
+	delivery.Claim = func(scope, key string) (bool, error) {
+		if len(states[key]) > 0 {
+			return false, nil
+		}
+		states[key] = "claimed"
+		return true, nil
+	}
+	delivery.Done = func(scope, key string) error {
+		states[key] = "done"
+		return nil
+	}
+	delivery.Release = func(scope, key string) error {
+		delete(states, key)
+		return nil
+	}
+	t.Cleanup(func() { delivery = recorded })
+
+	outboundtest.Replay(t, "testdata/webhook_retry.json")
+	webhook := Webhook{URLs: []string{"https://hooks.example.com/kanobug", "https://ci.example.com/bugs"}, Secret: "shh"}
+	bug := store.Bug{ID: "b1", TeamID: "T1", Summary: "Checkout button does nothing"}
+	if _, err := webhook.CreateIssue(bug); err == nil {
+		t.Fatal("CreateIssue with a failing URL error = nil")
+	}
+	// the retry only goes to the URL that failed
+	if issue, err := webhook.CreateIssue(bug); err != nil || issue.Tracker != "webhook" {
+		t.Errorf("CreateIssue() retry = %+v, %v", issue, err)
+	}
+	for key, state := range states {
+		if state != "done" {
+			t.Errorf("delivery %s = %s, want done", key, state)
+		}
+	}
+}
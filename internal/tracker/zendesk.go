@@ -0,0 +1,146 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+const zendeskTickets = "https://%s.zendesk.com/api/v2/tickets.json"
+
+// zendeskPriority maps bug severity to Zendesk ticket priority
+var zendeskPriority = map[string]string{
+	store.SeverityBlocker:  "urgent",
+	store.SeverityCritical: "high",
+	store.SeverityMajor:    "normal",
+	store.SeverityMinor:    "low",
+	store.SeverityTrivial:  "low",
+}
+
+// Zendesk opens support tickets for customer impacting bugs, linked to the
+// issues already filed for them
+type Zendesk struct {
+	Subdomain string
+	Email     string
+	Token     string
+	// Fields maps bug fields (summary, product, severity, reporter,
+	// issue_key, issue_url) to Zendesk custom field IDs
+	Fields map[string]string
+}
+
+// NewZendesk return Zendesk client configured from env
+func NewZendesk() Zendesk {
+	return Zendesk{
+		Subdomain: os.Getenv("ZENDESK_SUBDOMAIN"),
+		Email:     os.Getenv("ZENDESK_EMAIL"),
+		Token:     os.Getenv("ZENDESK_API_TOKEN"),
+		Fields:    envMap("ZENDESK_FIELDS"),
+	}
+}
+
+// Enabled report whether Zendesk is configured
+func (zendesk Zendesk) Enabled() bool {
+	return len(zendesk.Subdomain) > 0 && len(zendesk.Token) > 0
+}
+
+// Name return tracker name
+func (zendesk Zendesk) Name() string {
+	return "zendesk"
+}
+
+// CreateTicket open a ticket for the bug, linked to the first issue in
+// linked and cc'ing the reporter email when known
+func (zendesk Zendesk) CreateTicket(bug store.Bug, linked []Issue, reporterEmail string) (ticket Issue, err error) {
+	var link Issue
+	var refs []string
+	for _, issue := range linked {
+		if len(issue.Key) == 0 {
+			continue
+		}
+		if len(link.Key) == 0 {
+			link = issue
+		}
+		refs = append(refs, fmt.Sprintf("%s: %s %s", issue.Tracker, issue.Key, issue.URL))
+	}
+
+	values := map[string]string{
+		"summary":   bug.Summary,
+		"product":   bug.ProductName(),
+		"severity":  bug.Severity,
+		"reporter":  bug.UserName,
+		"issue_key": link.Key,
+		"issue_url": link.URL,
+	}
+	var customFields []map[string]interface{}
+	for field, id := range zendesk.Fields {
+		fieldID, convErr := strconv.ParseInt(id, 10, 64)
+		if convErr != nil {
+			log.Printf("tracker.Zendesk.CreateTicket - invalid field id %s: %v", id, convErr)
+			continue
+		}
+		customFields = append(customFields, map[string]interface{}{"id": fieldID, "value": values[field]})
+	}
+
+	t := map[string]interface{}{
+		"subject": bug.Summary,
+		"comment": map[string]string{
+			"body": fmt.Sprintf("Product: %s\nSeverity: %s\nReporter: %s\n\n%s\n\nLinked issues:\n%s",
+				bug.ProductName(), bug.Severity, bug.UserName, bug.Details, strings.Join(refs, "\n")),
+		},
+		"priority":      zendeskPriority[bug.Severity],
+		"tags":          []string{"kanobug", "slack"},
+		"external_id":   link.Key,
+		"custom_fields": customFields,
+	}
+	if len(reporterEmail) > 0 {
+		t["email_ccs"] = []map[string]string{{"user_email": reporterEmail, "action": "put"}}
+	}
+	body, err := json.Marshal(map[string]interface{}{"ticket": t})
+	if err != nil {
+		return
+	}
+
+	r, err := http.NewRequest("POST", fmt.Sprintf(zendeskTickets, zendesk.Subdomain), bytes.NewBuffer(body))
+	if err != nil {
+		return
+	}
+	r.SetBasicAuth(zendesk.Email+"/token", zendesk.Token)
+	r.Header.Set("Content-Type", "application/json")
+	rr, err := outbound.Do(zendesk.Name(), r)
+	if err != nil {
+		log.Printf("tracker.Zendesk.CreateTicket - ticket: %+v, error: %v", t, err)
+		return
+	}
+	defer rr.Body.Close()
+	if rr.StatusCode != http.StatusCreated {
+		err = fmt.Errorf("unexpected status: %s", rr.Status)
+		return
+	}
+
+	var created struct {
+		Ticket struct {
+			ID int64 `json:"id"`
+		} `json:"ticket"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	log.Printf("tracker.Zendesk.CreateTicket - created: %+v, error: %v", created, err)
+	if err != nil {
+		return
+	}
+	id := fmt.Sprint(created.Ticket.ID)
+	ticket = Issue{
+		Tracker: zendesk.Name(),
+		ID:      id,
+		Key:     "#" + id,
+		URL:     fmt.Sprintf("https://%s.zendesk.com/agent/tickets/%s", zendesk.Subdomain, id),
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package translate
+
+import (
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/translate"
+
+	"github.com/anzellai/kanobug/internal/outbound"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// maxTranslate is the most text, in bytes, TranslateText accepts
+const maxTranslate = 10000
+
+// defaultTarget is the language bugs are translated to unless
+// TRANSLATE_TARGET says otherwise
+const defaultTarget = "en"
+
+// Enabled report whether product is listed in the comma separated
+// TRANSLATE_PRODUCTS, "*" enabling every product
+func Enabled(product string) bool {
+	for _, p := range strings.Split(os.Getenv("TRANSLATE_PRODUCTS"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "*" || (p == product && len(p) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// Target return the language code bugs are translated to
+func Target() string {
+	if target := os.Getenv("TRANSLATE_TARGET"); len(target) > 0 {
+		return target
+	}
+	return defaultTarget
+}
+
+// Bug return the summary and details of bug in the target language, with
+// the language Amazon Translate detected them in, false when they already
+// are in it or translating fails
+func Bug(bug store.Bug) (translation store.Translation, ok bool) {
+	target := Target()
+	details, err := text(bug.Details, "auto", target)
+	if err != nil || details.language == target {
+		return
+	}
+	summary, err := text(bug.Summary, details.language, target)
+	if err != nil {
+		return
+	}
+	log.Printf("translate.Bug (%s) - language: %s, target: %s", bug.ID, details.language, target)
+	return store.Translation{Language: details.language, Summary: summary.text, Details: details.text}, true
+}
+
+type translated struct {
+	text     string
+	language string
+}
+
+// text translate s from source, "auto" detecting it, to target
+func text(s, source, target string) (result translated, err error) {
+	defer func() {
+		if err != nil {
+			log.Printf("translate.text (%s/%s) - error: %v", source, target, err)
+		}
+	}()
+	if len(s) > maxTranslate {
+		s = s[:maxTranslate]
+		for !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	sess, err := outbound.Session(outbound.AWS)
+	if err != nil {
+		return
+	}
+	out, err := translate.New(sess).Text(&translate.TextInput{
+		Text:               aws.String(s),
+		SourceLanguageCode: aws.String(source),
+		TargetLanguageCode: aws.String(target),
+	})
+	if err != nil {
+		return
+	}
+	return translated{text: aws.StringValue(out.TranslatedText), language: aws.StringValue(out.SourceLanguageCode)}, nil
+}
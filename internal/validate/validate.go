@@ -0,0 +1,78 @@
+package validate
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanobug/internal/i18n"
+)
+
+// Summary length limits
+const (
+	MinSummary = 10
+	MaxSummary = 150
+)
+
+// Form is the user entered part of a bug report
+type Form struct {
+	Summary     string
+	Product     string
+	Details     string
+	Environment string
+	Link        string
+	// Locale is the reporter's, whose language the errors are in
+	Locale string
+}
+
+// Errors return the inline error for each invalid field keyed by field name,
+// empty when the form is valid
+func Errors(form Form) map[string]string {
+	errs := map[string]string{}
+	summary := strings.TrimSpace(form.Summary)
+	switch {
+	case len(summary) < MinSummary:
+		errs["summary"] = i18n.T(form.Locale, "validate.summary.short", MinSummary)
+	case len(summary) > MaxSummary:
+		errs["summary"] = i18n.T(form.Locale, "validate.summary.long", MaxSummary)
+	}
+	if len(form.Link) > 0 {
+		if u, err := url.Parse(form.Link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs["link"] = i18n.T(form.Locale, "validate.link")
+		}
+	}
+	if requiresEnvironment(form.Product) && len(strings.TrimSpace(form.Environment)) == 0 {
+		errs["environment"] = i18n.T(form.Locale, "validate.environment")
+	}
+	for field, value := range map[string]string{"summary": form.Summary, "details": form.Details, "environment": form.Environment} {
+		if _, invalid := errs[field]; invalid {
+			continue
+		}
+		if banned := bannedContent(value); len(banned) > 0 {
+			errs[field] = i18n.T(form.Locale, "validate.banned", banned)
+		}
+	}
+	return errs
+}
+
+// requiresEnvironment report whether product is listed in ENVIRONMENT_REQUIRED
+func requiresEnvironment(product string) bool {
+	for _, p := range strings.Split(os.Getenv("ENVIRONMENT_REQUIRED"), ",") {
+		if strings.TrimSpace(p) == product && len(product) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bannedContent return the first BANNED_CONTENT phrase found in value
+func bannedContent(value string) string {
+	value = strings.ToLower(value)
+	for _, phrase := range strings.Split(os.Getenv("BANNED_CONTENT"), ",") {
+		phrase = strings.TrimSpace(phrase)
+		if len(phrase) > 0 && strings.Contains(value, strings.ToLower(phrase)) {
+			return phrase
+		}
+	}
+	return ""
+}
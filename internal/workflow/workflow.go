@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanobug/internal/catalog"
+	"github.com/anzellai/kanobug/internal/slack"
+	"github.com/anzellai/kanobug/internal/store"
+)
+
+// CallbackID identifies the "File a Kanobug" workflow step
+const CallbackID = "file-kanobug"
+
+// Input is a configured workflow step input, Value may contain workflow
+// variables which Slack replaces before the step executes
+type Input struct {
+	Value string `json:"value"`
+}
+
+// Step is the workflow_step object of edit, save and execute payloads
+type Step struct {
+	EditID    string           `json:"workflow_step_edit_id"`
+	ExecuteID string           `json:"workflow_step_execute_id"`
+	Inputs    map[string]Input `json:"inputs"`
+}
+
+// fields are the step inputs, in configuration modal order
+var fields = []struct {
+	Name, Label, Hint string
+	Multiline         bool
+	Optional          bool
+}{
+	{Name: "summary", Label: "Summary", Hint: "A sentence to summarise the problem"},
+	{Name: "product", Label: "Product", Hint: "Product name or value, e.g. Pixel Kit"},
+	{Name: "severity", Label: "Severity", Hint: "blocker, critical, major, minor or trivial", Optional: true},
+	{Name: "details", Label: "Details", Multiline: true, Optional: true},
+	{Name: "reporter", Label: "Reporter", Hint: "Usually the Person who submitted the form variable", Optional: true},
+}
+
+// Outputs are the values later workflow steps can use
+var Outputs = []map[string]string{
+	{"name": "bug_id", "type": "text", "label": "Kanobug ID"},
+	{"name": "issue_key", "type": "text", "label": "Issue key"},
+	{"name": "issue_url", "type": "text", "label": "Issue link"},
+}
+
+// OpenConfig open the step configuration modal for triggerID, pre-filled
+// with the currently saved inputs
+func OpenConfig(triggerID string, inputs map[string]Input) error {
+	var blocks []map[string]interface{}
+	for _, f := range fields {
+		element := map[string]interface{}{
+			"type":      "plain_text_input",
+			"action_id": f.Name,
+			"multiline": f.Multiline,
+		}
+		if value := inputs[f.Name].Value; len(value) > 0 {
+			element["initial_value"] = value
+		}
+		block := map[string]interface{}{
+			"type":     "input",
+			"block_id": f.Name,
+			"label":    map[string]string{"type": "plain_text", "text": f.Label},
+			"element":  element,
+			"optional": f.Optional,
+		}
+		if len(f.Hint) > 0 {
+			block["hint"] = map[string]string{"type": "plain_text", "text": f.Hint}
+		}
+		blocks = append(blocks, block)
+	}
+	return call("views.open", map[string]interface{}{
+		"trigger_id": triggerID,
+		"view": map[string]interface{}{
+			"type":        "workflow_step",
+			"callback_id": CallbackID,
+			"blocks":      blocks,
+		},
+	})
+}
+
+// Save store the inputs submitted in the configuration modal,
+// values is the view state keyed by block and action id
+func Save(editID string, values map[string]map[string]Input) error {
+	inputs := map[string]Input{}
+	for _, f := range fields {
+		inputs[f.Name] = values[f.Name][f.Name]
+	}
+	return call("workflows.updateStep", map[string]interface{}{
+		"workflow_step_edit_id": editID,
+		"inputs":                inputs,
+		"outputs":               Outputs,
+	})
+}
+
+// ToBug map the executed step inputs to a Bug
+func ToBug(step Step) store.Bug {
+	value := func(name string) string {
+		return strings.TrimSpace(step.Inputs[name].Value)
+	}
+	product := value("product")
+	for _, p := range catalog.ProductOptions() {
+		if strings.EqualFold(p.Value, product) || strings.EqualFold(p.Label, product) {
+			product = p.Value
+		}
+	}
+	severity := strings.ToLower(value("severity"))
+	known := false
+	for _, s := range catalog.Severities {
+		known = known || s.Value == severity
+	}
+	if !known {
+		severity = catalog.DefaultSeverity
+	}
+	details := value("details")
+	if len(details) == 0 {
+		details = "N/A"
+	}
+	reporter := value("reporter")
+	if len(reporter) == 0 {
+		reporter = "workflow"
+	}
+	// person variables are replaced with <@U123> mentions
+	userID := strings.TrimSuffix(strings.TrimPrefix(reporter, "<@"), ">")
+	now := time.Now()
+	return store.Bug{
+		Source:    "slack-workflow",
+		UserID:    userID,
+		UserName:  reporter,
+		Summary:   value("summary"),
+		Product:   product,
+		Severity:  severity,
+		Details:   details,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Complete mark the step executeID as completed with outputs
+func Complete(executeID string, outputs map[string]string) error {
+	return call("workflows.stepCompleted", map[string]interface{}{
+		"workflow_step_execute_id": executeID,
+		"outputs":                  outputs,
+	})
+}
+
+// Fail mark the step executeID as failed with message
+func Fail(executeID, message string) error {
+	return call("workflows.stepFailed", map[string]interface{}{
+		"workflow_step_execute_id": executeID,
+		"error":                    map[string]string{"message": message},
+	})
+}
+
+func call(method string, payload interface{}) (err error) {
+	err = slack.Default.Call(method, payload, nil)
+	log.Printf("workflow.call (%s) - error: %v", method, err)
+	return
+}